@@ -0,0 +1,189 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// ErrArenaFull is returned by ArenaView.Alloc if the arena has no space left.
+var ErrArenaFull = errors.New("arena is full")
+
+// ArenaView is a view of the memory shared between BPF programs and user space
+// via a map of type Arena.
+//
+// Pages are allocated lazily by the kernel: either when a BPF program calls
+// bpf_arena_alloc_pages or when user space first touches a page through the
+// view. The latter fails with SIGSEGV if the map was created with
+// BPF_F_SEGV_ON_FAULT.
+type ArenaView struct {
+	mu  sync.Mutex
+	mem []byte
+	// low is the lowest offset handed out by Alloc so far.
+	low uint64
+}
+
+// NewArenaView maps the memory of an Arena map into the address space of the
+// process.
+//
+// The kernel only allows one user space mapping per arena. The mapping
+// outlives m and must be released by calling Close.
+func NewArenaView(m *Map) (*ArenaView, error) {
+	if m.Type() != Arena {
+		return nil, fmt.Errorf("invalid map type: %s", m.Type())
+	}
+
+	size := int(m.MaxEntries()) * os.Getpagesize()
+	mem, err := unix.Mmap(m.fd.Int(), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap arena: %w", err)
+	}
+
+	a := &ArenaView{mem: mem, low: uint64(len(mem))}
+	runtime.SetFinalizer(a, (*ArenaView).Close)
+	return a, nil
+}
+
+// Close unmaps the arena.
+//
+// Slices returned by Bytes and Alloc must not be accessed afterwards.
+func (a *ArenaView) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	runtime.SetFinalizer(a, nil)
+
+	if a.mem == nil {
+		return nil
+	}
+
+	err := unix.Munmap(a.mem)
+	a.mem = nil
+	return err
+}
+
+// Size returns the size of the arena in bytes.
+func (a *ArenaView) Size() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.mem)
+}
+
+// Bytes returns the whole arena.
+//
+// The slice aliases memory which is concurrently modified by BPF programs.
+func (a *ArenaView) Bytes() []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.mem
+}
+
+// Addr returns the user space address of the byte at offset off.
+//
+// This is the value BPF programs store in __arena pointers, which allows
+// building linked data structures in the arena from user space.
+func (a *ArenaView) Addr(off uint64) uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.base() + off
+}
+
+// Offset converts a pointer into the arena, as written by a BPF program, into
+// an offset into the arena.
+func (a *ArenaView) Offset(addr uint64) (uint64, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	base := a.base()
+	if addr < base || addr-base >= uint64(len(a.mem)) {
+		return 0, fmt.Errorf("address %#x is outside of arena", addr)
+	}
+
+	return addr - base, nil
+}
+
+func (a *ArenaView) base() uint64 {
+	if len(a.mem) == 0 {
+		return 0
+	}
+	return uint64(uintptr(unsafe.Pointer(&a.mem[0])))
+}
+
+// Alloc reserves size bytes aligned to align bytes and returns the memory
+// together with its offset into the arena.
+//
+// Memory is handed out starting from the end of the arena, since the kernel
+// serves bpf_arena_alloc_pages from the start. Touching a page from user space
+// prevents the kernel from handing it to BPF programs, but Alloc can't detect
+// pages already allocated by BPF programs. It's up to the caller to partition
+// the arena if both sides allocate.
+//
+// Returns ErrArenaFull if there is not enough space left.
+func (a *ArenaView) Alloc(size, align int) ([]byte, uint64, error) {
+	if size <= 0 {
+		return nil, 0, fmt.Errorf("invalid size %d", size)
+	}
+	if align <= 0 || align&(align-1) != 0 {
+		return nil, 0, fmt.Errorf("alignment %d is not a power of two", align)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.mem == nil {
+		return nil, 0, fmt.Errorf("arena: %w", os.ErrClosed)
+	}
+
+	if uint64(size) > a.low {
+		return nil, 0, fmt.Errorf("allocate %d bytes: %w", size, ErrArenaFull)
+	}
+
+	off := (a.low - uint64(size)) &^ uint64(align-1)
+	a.low = off
+
+	return a.mem[off : off+uint64(size) : off+uint64(size)], off, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (a *ArenaView) ReadAt(p []byte, off int64) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= int64(len(a.mem)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, a.mem[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt.
+func (a *ArenaView) WriteAt(p []byte, off int64) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if off < 0 || off > int64(len(a.mem)) {
+		return 0, fmt.Errorf("offset %d is outside of arena", off)
+	}
+
+	n := copy(a.mem[off:], p)
+	if n < len(p) {
+		return n, io.ErrShortWrite
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package ebpf
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func newArena(t *testing.T, pages uint32) *ArenaView {
+	t.Helper()
+
+	m, err := NewMap(&MapSpec{
+		Type:       Arena,
+		MaxEntries: pages,
+		Flags:      unix.BPF_F_MMAPABLE,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	a, err := NewArenaView(m)
+	qt.Assert(t, err, qt.IsNil)
+	t.Cleanup(func() { a.Close() })
+	return a
+}
+
+func TestArena(t *testing.T) {
+	a := newArena(t, 2)
+	size := 2 * os.Getpagesize()
+	qt.Assert(t, a.Size(), qt.Equals, size)
+
+	buf, off, err := a.Alloc(8, 8)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, off, qt.Equals, uint64(size-8))
+	copy(buf, "deadbeef")
+
+	got := make([]byte, 8)
+	_, err = a.ReadAt(got, int64(off))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, string(got), qt.Equals, "deadbeef")
+
+	_, off, err = a.Alloc(3, 16)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, off%16, qt.Equals, uint64(0))
+	qt.Assert(t, off < uint64(size-8), qt.IsTrue)
+
+	addr := a.Addr(off)
+	back, err := a.Offset(addr)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, back, qt.Equals, off)
+
+	_, err = a.Offset(a.Addr(0) - 1)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	_, _, err = a.Alloc(size, 1)
+	qt.Assert(t, errors.Is(err, ErrArenaFull), qt.IsTrue)
+
+	_, err = a.WriteAt([]byte{1, 2}, int64(size-1))
+	qt.Assert(t, err, qt.Equals, io.ErrShortWrite)
+
+	_, err = a.ReadAt(got, int64(size))
+	qt.Assert(t, err, qt.Equals, io.EOF)
+
+	qt.Assert(t, a.Close(), qt.IsNil)
+	qt.Assert(t, a.Close(), qt.IsNil)
+
+	_, _, err = a.Alloc(1, 1)
+	qt.Assert(t, errors.Is(err, os.ErrClosed), qt.IsTrue)
+}
+
+func TestArenaInvalidSpec(t *testing.T) {
+	_, err := NewMap(&MapSpec{
+		Type:       Arena,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	_, err = NewMap(&MapSpec{
+		Type:       Arena,
+		KeySize:    4,
+		MaxEntries: 1,
+		Flags:      unix.BPF_F_MMAPABLE,
+	})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	arr := createArray(t)
+	defer arr.Close()
+
+	_, err = NewArenaView(arr)
+	qt.Assert(t, err, qt.IsNotNil)
+}
@@ -37,6 +37,7 @@ const (
 const (
 	PseudoMapFD     = R1 // BPF_PSEUDO_MAP_FD
 	PseudoMapValue  = R2 // BPF_PSEUDO_MAP_VALUE
+	PseudoBTFID     = R3 // BPF_PSEUDO_BTF_ID
 	PseudoCall      = R1 // BPF_PSEUDO_CALL
 	PseudoFunc      = R4 // BPF_PSEUDO_FUNC
 	PseudoKfuncCall = R2 // BPF_PSEUDO_KFUNC_CALL
@@ -0,0 +1,250 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// The default number of elements processed per system call.
+const defaultBatchChunkSize = 256
+
+// BatchLookupAll returns all keys and values of m, using one system call per
+// BatchOptions.ChunkSize elements.
+//
+// Values of per-CPU maps must be slices, which hold the value of each
+// possible CPU.
+//
+// Elements which are added or removed concurrently may or may not be
+// returned.
+func BatchLookupAll[K, V any](m *Map, opts *BatchOptions) ([]K, []V, error) {
+	return batchLookupAll[K, V](m, sys.BPF_MAP_LOOKUP_BATCH, opts)
+}
+
+// BatchLookupAndDeleteAll is like BatchLookupAll, except that it also deletes
+// the returned elements. This is the quickest way to empty a hash map.
+func BatchLookupAndDeleteAll[K, V any](m *Map, opts *BatchOptions) ([]K, []V, error) {
+	return batchLookupAll[K, V](m, sys.BPF_MAP_LOOKUP_AND_DELETE_BATCH, opts)
+}
+
+func batchLookupAll[K, V any](m *Map, cmd sys.Cmd, opts *BatchOptions) ([]K, []V, error) {
+	if err := haveBatchAPI(); err != nil {
+		return nil, nil, err
+	}
+
+	// Hash maps use a bucket index as the batch token, other maps a key.
+	tokenSize := int(m.keySize)
+	if tokenSize < 4 {
+		tokenSize = 4
+	}
+	prevToken, nextToken := make([]byte, tokenSize), make([]byte, tokenSize)
+
+	var (
+		chunkSize = batchChunkSize(opts)
+		inBatch   sys.Pointer
+		keys      []K
+		values    []V
+	)
+	for {
+		// The buffers aren't reused since values of type []byte refer to them.
+		keyBuf := make([]byte, chunkSize*int(m.keySize))
+		valueBuf := make([]byte, chunkSize*m.fullValueSize)
+
+		attr := sys.MapLookupBatchAttr{
+			MapFd:    m.fd.Uint(),
+			Keys:     sys.NewSlicePointer(keyBuf),
+			Values:   sys.NewSlicePointer(valueBuf),
+			Count:    uint32(chunkSize),
+			InBatch:  inBatch,
+			OutBatch: sys.NewSlicePointer(nextToken),
+		}
+		if opts != nil {
+			attr.ElemFlags = opts.ElemFlags
+			attr.Flags = opts.Flags
+		}
+
+		_, err := sys.BPF(cmd, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+		err = wrapMapError(err)
+		if errors.Is(err, unix.ENOSPC) && attr.Count == 0 && chunkSize < int(m.maxEntries) {
+			// A bucket of a hash map holds more elements than fit into
+			// the buffers.
+			chunkSize *= 2
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrKeyNotExist) {
+			return nil, nil, fmt.Errorf("batch lookup: %w", err)
+		}
+
+		for i := 0; i < int(attr.Count); i++ {
+			var key K
+			if err := unmarshalBytes(&key, batchElem(keyBuf, i, int(m.keySize), int(m.keySize))); err != nil {
+				return nil, nil, fmt.Errorf("batch lookup: key %d: %w", len(keys), err)
+			}
+
+			var value V
+			var valueErr error
+			if m.typ.hasPerCPUValue() {
+				valueErr = unmarshalPerCPUValue(&value, int(m.valueSize), batchElem(valueBuf, i, m.fullValueSize, m.fullValueSize))
+			} else {
+				valueErr = unmarshalBytes(&value, batchElem(valueBuf, i, int(m.valueSize), m.fullValueSize))
+			}
+			if valueErr != nil {
+				return nil, nil, fmt.Errorf("batch lookup: value %d: %w", len(values), valueErr)
+			}
+
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+
+		if err != nil {
+			// ErrKeyNotExist signals the end of the map.
+			return keys, values, nil
+		}
+
+		prevToken, nextToken = nextToken, prevToken
+		inBatch = sys.NewSlicePointer(prevToken)
+	}
+}
+
+// BatchUpdateAll writes keys and values to m, using one system call per
+// BatchOptions.ChunkSize elements.
+//
+// Values of per-CPU maps must be slices, which hold the value of each
+// possible CPU.
+//
+// Returns the number of elements which were written, even if an error
+// occurred.
+func BatchUpdateAll[K, V any](m *Map, keys []K, values []V, opts *BatchOptions) (int, error) {
+	if len(keys) != len(values) {
+		return 0, fmt.Errorf("keys and values must be the same length")
+	}
+	if err := haveBatchAPI(); err != nil {
+		return 0, err
+	}
+
+	var (
+		chunkSize = batchChunkSize(opts)
+		n         int
+	)
+	for len(keys) > 0 {
+		count := len(keys)
+		if count > chunkSize {
+			count = chunkSize
+		}
+
+		keyBuf, err := marshalBatch(keys[:count], int(m.keySize), int(m.keySize), false)
+		if err != nil {
+			return n, fmt.Errorf("batch update: keys: %w", err)
+		}
+		valueBuf, err := marshalBatch(values[:count], int(m.valueSize), m.fullValueSize, m.typ.hasPerCPUValue())
+		if err != nil {
+			return n, fmt.Errorf("batch update: values: %w", err)
+		}
+
+		attr := sys.MapUpdateBatchAttr{
+			MapFd:  m.fd.Uint(),
+			Keys:   sys.NewSlicePointer(keyBuf),
+			Values: sys.NewSlicePointer(valueBuf),
+			Count:  uint32(count),
+		}
+		if opts != nil {
+			attr.ElemFlags = opts.ElemFlags
+			attr.Flags = opts.Flags
+		}
+
+		err = sys.MapUpdateBatch(&attr)
+		n += int(attr.Count)
+		if err != nil {
+			return n, fmt.Errorf("batch update: %w", wrapMapError(err))
+		}
+
+		keys, values = keys[count:], values[count:]
+	}
+
+	return n, nil
+}
+
+// BatchDeleteAll deletes keys from m, using one system call per
+// BatchOptions.ChunkSize elements.
+//
+// Returns the number of elements which were deleted, even if an error
+// occurred. Deleting a key which doesn't exist returns ErrKeyNotExist.
+func BatchDeleteAll[K any](m *Map, keys []K, opts *BatchOptions) (int, error) {
+	if err := haveBatchAPI(); err != nil {
+		return 0, err
+	}
+
+	var (
+		chunkSize = batchChunkSize(opts)
+		n         int
+	)
+	for len(keys) > 0 {
+		count := len(keys)
+		if count > chunkSize {
+			count = chunkSize
+		}
+
+		keyBuf, err := marshalBatch(keys[:count], int(m.keySize), int(m.keySize), false)
+		if err != nil {
+			return n, fmt.Errorf("batch delete: keys: %w", err)
+		}
+
+		attr := sys.MapDeleteBatchAttr{
+			MapFd: m.fd.Uint(),
+			Keys:  sys.NewSlicePointer(keyBuf),
+			Count: uint32(count),
+		}
+		if opts != nil {
+			attr.ElemFlags = opts.ElemFlags
+			attr.Flags = opts.Flags
+		}
+
+		err = sys.MapDeleteBatch(&attr)
+		n += int(attr.Count)
+		if err != nil {
+			return n, fmt.Errorf("batch delete: %w", wrapMapError(err))
+		}
+
+		keys = keys[count:]
+	}
+
+	return n, nil
+}
+
+func batchChunkSize(opts *BatchOptions) int {
+	if opts == nil || opts.ChunkSize <= 0 {
+		return defaultBatchChunkSize
+	}
+	return opts.ChunkSize
+}
+
+// batchElem returns the size bytes of element i in a buffer with the given
+// stride. The result can't be appended to.
+func batchElem(buf []byte, i, size, stride int) []byte {
+	return buf[i*stride : i*stride+size : i*stride+size]
+}
+
+// marshalBatch encodes elems into a buffer with the given stride.
+func marshalBatch[T any](elems []T, size, stride int, perCPU bool) ([]byte, error) {
+	buf := make([]byte, len(elems)*stride)
+	for i, elem := range elems {
+		var (
+			elemBytes []byte
+			err       error
+		)
+		if perCPU {
+			elemBytes, err = marshalPerCPUBytes(elem, size)
+		} else {
+			elemBytes, err = marshalBytes(elem, size)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+
+		copy(buf[i*stride:(i+1)*stride], elemBytes)
+	}
+	return buf, nil
+}
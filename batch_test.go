@@ -0,0 +1,124 @@
+package ebpf
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestBatchAllHash(t *testing.T) {
+	if err := haveBatchAPI(); err != nil {
+		t.Skipf("batch api not available: %v", err)
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	keys := make([]uint32, 1000)
+	values := make([]uint64, len(keys))
+	for i := range keys {
+		keys[i] = uint32(i)
+		values[i] = uint64(i) * 3
+	}
+
+	// A small chunk size forces retries with ENOSPC, since buckets can hold
+	// more than one element.
+	opts := &BatchOptions{ChunkSize: 1}
+
+	n, err := BatchUpdateAll(m, keys, values, opts)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, len(keys))
+
+	gotKeys, gotValues, err := BatchLookupAll[uint32, uint64](m, opts)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, gotKeys, qt.HasLen, len(keys))
+	for i, key := range gotKeys {
+		qt.Assert(t, gotValues[i], qt.Equals, uint64(key)*3)
+	}
+
+	n, err = BatchDeleteAll(m, keys[:500], nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, 500)
+
+	_, err = BatchDeleteAll(m, keys[:1], nil)
+	qt.Assert(t, errors.Is(err, ErrKeyNotExist), qt.IsTrue)
+
+	gotKeys, _, err = BatchLookupAndDeleteAll[uint32, uint64](m, nil)
+	qt.Assert(t, err, qt.IsNil)
+	sort.Slice(gotKeys, func(i, j int) bool { return gotKeys[i] < gotKeys[j] })
+	qt.Assert(t, gotKeys, qt.DeepEquals, keys[500:])
+
+	gotKeys, _, err = BatchLookupAll[uint32, uint64](m, nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, gotKeys, qt.HasLen, 0)
+}
+
+func TestBatchAllPerCPUHash(t *testing.T) {
+	if err := haveBatchAPI(); err != nil {
+		t.Skipf("batch api not available: %v", err)
+	}
+
+	possibleCPUs, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUHash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	keys := []uint32{1, 2, 3}
+	values := make([][]uint32, len(keys))
+	for i, key := range keys {
+		values[i] = make([]uint32, possibleCPUs)
+		for cpu := range values[i] {
+			values[i][cpu] = key*100 + uint32(cpu)
+		}
+	}
+
+	n, err := BatchUpdateAll(m, keys, values, &BatchOptions{ChunkSize: 2})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, len(keys))
+
+	gotKeys, gotValues, err := BatchLookupAll[uint32, []uint32](m, nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, gotKeys, qt.HasLen, len(keys))
+	for i, key := range gotKeys {
+		qt.Assert(t, gotValues[i], qt.DeepEquals, values[key-1])
+	}
+}
+
+func TestBatchAllLengthMismatch(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	_, err = BatchUpdateAll(m, []uint32{1}, []uint32{}, nil)
+	qt.Assert(t, err, qt.IsNotNil)
+}
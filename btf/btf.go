@@ -386,13 +386,14 @@ func fixupDatasec(types []Type, sectionSizes map[string]uint32, offsets map[symb
 		// Some Datasecs are virtual and don't have corresponding ELF sections.
 		switch name {
 		case ".ksyms":
-			// .ksyms describes forward declarations of kfunc signatures.
-			// Nothing to fix up, all sizes and offsets are 0.
+			// .ksyms describes forward declarations of kfunc signatures and
+			// extern kernel variables. Nothing to fix up, all sizes and
+			// offsets are 0.
 			for _, vsi := range ds.Vars {
-				_, ok := vsi.Type.(*Func)
-				if !ok {
-					// Only Funcs are supported in the .ksyms Datasec.
-					return fmt.Errorf("data section %s: expected *btf.Func, not %T: %w", name, vsi.Type, ErrNotSupported)
+				switch vsi.Type.(type) {
+				case *Func, *Var:
+				default:
+					return fmt.Errorf("data section %s: expected *btf.Func or *btf.Var, not %T: %w", name, vsi.Type, ErrNotSupported)
 				}
 			}
 
@@ -772,6 +773,27 @@ var haveFuncLinkage = internal.NewFeatureTest("BTF func linkage", "5.6", func()
 	return err
 })
 
+var haveDeclTags = internal.NewFeatureTest("BTF decl tags", "5.16", func() error {
+	if err := haveProgBTF(); err != nil {
+		return err
+	}
+
+	tag := &declTag{
+		Type: &Func{
+			Name: "a",
+			Type: &FuncProto{Return: (*Void)(nil)},
+		},
+		Value: "a",
+		Index: -1,
+	}
+
+	err := probeBTF(tag)
+	if errors.Is(err, unix.EINVAL) {
+		return internal.ErrNotSupported
+	}
+	return err
+})
+
 func probeBTF(typ Type) error {
 	buf := getBuffer()
 	defer putBuffer(buf)
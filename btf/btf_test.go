@@ -345,7 +345,7 @@ func TestVerifierError(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err := newHandleFromRawBTF(buf.Bytes())
+	_, err := newHandleFromRawBTF(buf.Bytes(), HandleOptions{})
 	testutils.SkipIfNotSupported(t, err)
 	var ve *internal.VerifierError
 	if !errors.As(err, &ve) {
@@ -410,6 +410,10 @@ func TestHaveFuncLinkage(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveFuncLinkage)
 }
 
+func TestHaveDeclTags(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveDeclTags)
+}
+
 func ExampleSpec_TypeByName() {
 	// Acquire a Spec via one of its constructors.
 	spec := new(Spec)
@@ -135,6 +135,14 @@ func (ei *ExtInfos) Assign(insns asm.Instructions, section string) {
 //
 // Returns ErrNotSupported if the kernel doesn't support BTF-associated programs.
 func MarshalExtInfos(insns asm.Instructions) (_ *Handle, funcInfos, lineInfos []byte, _ error) {
+	return MarshalExtInfosWithOptions(insns, HandleOptions{})
+}
+
+// MarshalExtInfosWithOptions encodes function and line info embedded in insns
+// into kernel wire format, loading the BTF according to opts.
+//
+// Returns ErrNotSupported if the kernel doesn't support BTF-associated programs.
+func MarshalExtInfosWithOptions(insns asm.Instructions, opts HandleOptions) (_ *Handle, funcInfos, lineInfos []byte, _ error) {
 	// Bail out early if the kernel doesn't support Func(Proto). If this is the
 	// case, func_info will also be unsupported.
 	if err := haveProgBTF(); err != nil {
@@ -189,7 +197,7 @@ marshal:
 		return nil, nil, nil, fmt.Errorf("marshal BTF: %w", err)
 	}
 
-	handle, err := newHandleFromRawBTF(buf.Bytes())
+	handle, err := newHandleFromRawBTF(buf.Bytes(), opts)
 	return handle, fiBuf.Bytes(), liBuf.Bytes(), err
 }
 
@@ -388,6 +396,17 @@ func (fi *funcInfo) marshal(w *bytes.Buffer, spec *Spec) error {
 	if err != nil {
 		return err
 	}
+
+	// Tags such as exception_callback: are interpreted by the verifier, so
+	// pass them along if the kernel understands them.
+	if len(fi.fn.Tags) > 0 && haveDeclTags() == nil {
+		for _, tag := range fi.fn.Tags {
+			if _, err := spec.Add(&declTag{fi.fn, tag, -1}); err != nil {
+				return err
+			}
+		}
+	}
+
 	bfi := bpfFuncInfo{
 		InsnOff: uint32(fi.offset),
 		TypeID:  id,
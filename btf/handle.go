@@ -22,10 +22,24 @@ type Handle struct {
 	needsKernelBase bool
 }
 
+// HandleOptions control loading BTF into the kernel.
+type HandleOptions struct {
+	// TokenFD is a BPF token which grants the permission to load BTF.
+	// Zero means that no token is used.
+	TokenFD int
+}
+
 // NewHandle loads BTF into the kernel.
 //
 // Returns ErrNotSupported if BTF is not supported.
 func NewHandle(spec *Spec) (*Handle, error) {
+	return NewHandleWithOptions(spec, HandleOptions{})
+}
+
+// NewHandleWithOptions loads BTF into the kernel.
+//
+// Returns ErrNotSupported if BTF is not supported.
+func NewHandleWithOptions(spec *Spec, opts HandleOptions) (*Handle, error) {
 	if spec.byteOrder != nil && spec.byteOrder != internal.NativeEndian {
 		return nil, fmt.Errorf("can't load %s BTF on %s", spec.byteOrder, internal.NativeEndian)
 	}
@@ -50,10 +64,10 @@ func NewHandle(spec *Spec) (*Handle, error) {
 		return nil, fmt.Errorf("marshal BTF: %w", err)
 	}
 
-	return newHandleFromRawBTF(buf.Bytes())
+	return newHandleFromRawBTF(buf.Bytes(), opts)
 }
 
-func newHandleFromRawBTF(btf []byte) (*Handle, error) {
+func newHandleFromRawBTF(btf []byte, opts HandleOptions) (*Handle, error) {
 	if uint64(len(btf)) > math.MaxUint32 {
 		return nil, errors.New("BTF exceeds the maximum size")
 	}
@@ -63,6 +77,11 @@ func newHandleFromRawBTF(btf []byte) (*Handle, error) {
 		BtfSize: uint32(len(btf)),
 	}
 
+	if opts.TokenFD != 0 {
+		attr.BtfFlags |= uint32(sys.BPF_F_TOKEN_FD)
+		attr.BtfTokenFd = int32(opts.TokenFD)
+	}
+
 	fd, err := sys.BtfLoad(attr)
 	if err == nil {
 		return &Handle{fd, attr.BtfSize, false}, nil
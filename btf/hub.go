@@ -0,0 +1,218 @@
+package btf
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// hubSuffix is the extension of files containing BTF for a single kernel.
+const hubSuffix = ".btf"
+
+// Hub is a collection of BTF for kernels which don't ship their own, for
+// example built from BTFHub.
+//
+// Each kernel is described by a file named after its release, as returned
+// by uname -r, with a .btf suffix. For example 5.4.0-1010-aws.btf. The file
+// can be raw BTF or an ELF containing a .BTF section.
+//
+// Use [LoadSpec] or [LoadSpecFromReader] instead if you only need the BTF of
+// a single kernel.
+//
+// A Hub caches parsed Specs and is safe for concurrent use.
+type Hub struct {
+	// Maps a release to the location of its BTF.
+	index map[string]string
+	load  func(location string) (*Spec, error)
+
+	mu    sync.Mutex
+	cache map[string]*Spec
+}
+
+// NewHubFromDir indexes all BTF files in dir and its subdirectories.
+//
+// BTFHub stores files as <distro>/<version>/<arch>/<release>.btf.tar.xz.
+// They must be decompressed first.
+func NewHubFromDir(dir string) (*Hub, error) {
+	index := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		return addToIndex(index, path)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("index BTF in %s: %w", dir, err)
+	}
+
+	return newHub(index, LoadSpec), nil
+}
+
+// NewHubFromArchive indexes all BTF files in a tar archive, which may be
+// compressed with gzip.
+//
+// The archive is read again each time a Spec is loaded which isn't cached.
+func NewHubFromArchive(file string) (*Hub, error) {
+	index := make(map[string]string)
+	err := walkArchive(file, func(hdr *tar.Header, _ io.Reader) (bool, error) {
+		return false, addToIndex(index, hdr.Name)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("index BTF in %s: %w", file, err)
+	}
+
+	return newHub(index, func(name string) (*Spec, error) {
+		var spec *Spec
+		err := walkArchive(file, func(hdr *tar.Header, r io.Reader) (bool, error) {
+			if hdr.Name != name {
+				return false, nil
+			}
+
+			buf, err := io.ReadAll(r)
+			if err != nil {
+				return false, err
+			}
+
+			spec, err = LoadSpecFromReader(bytes.NewReader(buf))
+			return true, err
+		})
+		if err == nil && spec == nil {
+			err = fmt.Errorf("%s: %w", name, os.ErrNotExist)
+		}
+		return spec, err
+	}), nil
+}
+
+func newHub(index map[string]string, load func(string) (*Spec, error)) *Hub {
+	return &Hub{
+		index: index,
+		load:  load,
+		cache: make(map[string]*Spec),
+	}
+}
+
+func addToIndex(index map[string]string, path string) error {
+	base := filepath.Base(path)
+	if !strings.HasSuffix(base, hubSuffix) {
+		return nil
+	}
+
+	release := strings.TrimSuffix(base, hubSuffix)
+	if other, ok := index[release]; ok {
+		return fmt.Errorf("release %s: duplicate BTF in %s and %s", release, other, path)
+	}
+	index[release] = path
+	return nil
+}
+
+// walkArchive calls fn for each regular file in a tar archive until fn
+// returns true.
+func walkArchive(file string, fn func(*tar.Header, io.Reader) (bool, error)) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var rd io.Reader = bufio.NewReader(fh)
+	if magic, _ := rd.(*bufio.Reader).Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(rd)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		rd = gz
+	}
+
+	tr := tar.NewReader(rd)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		done, err := fn(hdr, tr)
+		if err != nil {
+			return fmt.Errorf("%s: %w", hdr.Name, err)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// Releases returns the sorted list of kernel releases in the hub.
+func (h *Hub) Releases() []string {
+	releases := make([]string, 0, len(h.index))
+	for release := range h.index {
+		releases = append(releases, release)
+	}
+	sort.Strings(releases)
+	return releases
+}
+
+// Spec returns the BTF for the given kernel release.
+//
+// Returns ErrNotFound if the hub doesn't contain the release.
+func (h *Hub) Spec(release string) (*Spec, error) {
+	spec, err := h.specNoCopy(release)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Copy(), nil
+}
+
+// KernelSpec returns the BTF for the running kernel, as identified by uname.
+//
+// Returns ErrNotFound if the hub doesn't contain the running kernel.
+func (h *Hub) KernelSpec() (*Spec, error) {
+	release, err := internal.KernelRelease()
+	if err != nil {
+		return nil, err
+	}
+	return h.Spec(release)
+}
+
+func (h *Hub) specNoCopy(release string) (*Spec, error) {
+	location, ok := h.index[release]
+	if !ok {
+		return nil, fmt.Errorf("BTF for kernel %s: %w", release, ErrNotFound)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if spec := h.cache[release]; spec != nil {
+		return spec, nil
+	}
+
+	spec, err := h.load(location)
+	if err != nil {
+		return nil, fmt.Errorf("load BTF for kernel %s: %w", release, err)
+	}
+
+	h.cache[release] = spec
+	return spec, nil
+}
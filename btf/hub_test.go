@@ -0,0 +1,126 @@
+package btf
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestHubFromDir(t *testing.T) {
+	raw := vmlinuxTestdataBytes(t)
+	dir := t.TempDir()
+
+	sub := filepath.Join(dir, "ubuntu", "20.04", "x86_64")
+	qt.Assert(t, os.MkdirAll(sub, 0755), qt.IsNil)
+	qt.Assert(t, os.WriteFile(filepath.Join(sub, "5.4.0-1010-aws.btf"), raw, 0644), qt.IsNil)
+	qt.Assert(t, os.WriteFile(filepath.Join(sub, "README"), nil, 0644), qt.IsNil)
+
+	hub, err := NewHubFromDir(dir)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, hub.Releases(), qt.DeepEquals, []string{"5.4.0-1010-aws"})
+
+	testHub(t, hub, "5.4.0-1010-aws")
+
+	// The same release may only occur once.
+	other := filepath.Join(dir, "debian")
+	qt.Assert(t, os.MkdirAll(other, 0755), qt.IsNil)
+	qt.Assert(t, os.WriteFile(filepath.Join(other, "5.4.0-1010-aws.btf"), raw, 0644), qt.IsNil)
+	_, err = NewHubFromDir(dir)
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestHubFromArchive(t *testing.T) {
+	raw := vmlinuxTestdataBytes(t)
+
+	for _, compress := range []bool{false, true} {
+		file := filepath.Join(t.TempDir(), "btfhub.tar")
+		fh, err := os.Create(file)
+		qt.Assert(t, err, qt.IsNil)
+
+		var w io.Writer = fh
+		var gz *gzip.Writer
+		if compress {
+			gz = gzip.NewWriter(fh)
+			w = gz
+		}
+
+		tw := tar.NewWriter(w)
+		for _, name := range []string{"centos/7/x86_64/3.10.0-1062.el7.x86_64.btf", "fedora/31/x86_64/5.3.7-301.fc31.x86_64.btf"} {
+			qt.Assert(t, tw.WriteHeader(&tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeReg,
+				Mode:     0644,
+				Size:     int64(len(raw)),
+			}), qt.IsNil)
+			_, err = tw.Write(raw)
+			qt.Assert(t, err, qt.IsNil)
+		}
+		qt.Assert(t, tw.Close(), qt.IsNil)
+		if gz != nil {
+			qt.Assert(t, gz.Close(), qt.IsNil)
+		}
+		qt.Assert(t, fh.Close(), qt.IsNil)
+
+		hub, err := NewHubFromArchive(file)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, hub.Releases(), qt.DeepEquals, []string{"3.10.0-1062.el7.x86_64", "5.3.7-301.fc31.x86_64"})
+
+		testHub(t, hub, "5.3.7-301.fc31.x86_64")
+	}
+}
+
+func TestHubKernelSpec(t *testing.T) {
+	release, err := internal.KernelRelease()
+	qt.Assert(t, err, qt.IsNil)
+
+	dir := t.TempDir()
+	qt.Assert(t, os.WriteFile(filepath.Join(dir, release+".btf"), vmlinuxTestdataBytes(t), 0644), qt.IsNil)
+
+	hub, err := NewHubFromDir(dir)
+	qt.Assert(t, err, qt.IsNil)
+
+	spec, err := hub.KernelSpec()
+	qt.Assert(t, err, qt.IsNil)
+
+	var s *Struct
+	qt.Assert(t, spec.TypeByName("task_struct", &s), qt.IsNil)
+}
+
+func testHub(t *testing.T, hub *Hub, release string) {
+	t.Helper()
+
+	spec, err := hub.Spec(release)
+	qt.Assert(t, err, qt.IsNil)
+
+	var s *Struct
+	qt.Assert(t, spec.TypeByName("task_struct", &s), qt.IsNil)
+
+	// Parsed specs are cached, but callers receive a copy.
+	cached, err := hub.specNoCopy(release)
+	qt.Assert(t, err, qt.IsNil)
+	again, err := hub.specNoCopy(release)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, again, qt.Equals, cached)
+	qt.Assert(t, spec, qt.Not(qt.Equals), cached)
+
+	_, err = hub.Spec("0.0.0-missing")
+	qt.Assert(t, errors.Is(err, ErrNotFound), qt.IsTrue, qt.Commentf("got %v", err))
+}
+
+func vmlinuxTestdataBytes(tb testing.TB) []byte {
+	tb.Helper()
+
+	td, err := vmlinuxTestdata()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return td.raw
+}
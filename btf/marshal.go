@@ -441,6 +441,15 @@ func (e *encoder) deflateVarSecinfos(vars []VarSecinfo) []btfVarSecinfo {
 // The function is intended for the use of the ebpf package and may be removed
 // at any point in time.
 func MarshalMapKV(key, value Type) (_ *Handle, keyID, valueID TypeID, err error) {
+	return MarshalMapKVWithOptions(key, value, HandleOptions{})
+}
+
+// MarshalMapKVWithOptions creates a BTF object containing a map key and value,
+// loading it according to opts.
+//
+// The function is intended for the use of the ebpf package and may be removed
+// at any point in time.
+func MarshalMapKVWithOptions(key, value Type, opts HandleOptions) (_ *Handle, keyID, valueID TypeID, err error) {
 	spec := NewSpec()
 
 	if key != nil {
@@ -463,7 +472,7 @@ func MarshalMapKV(key, value Type) (_ *Handle, keyID, valueID TypeID, err error)
 		}
 	}
 
-	handle, err := NewHandle(spec)
+	handle, err := NewHandleWithOptions(spec, opts)
 	if err != nil {
 		// Check for 'full' map BTF support, since kernels between 4.18 and 5.2
 		// already support BTF blobs for maps without Var or Datasec just fine.
@@ -401,6 +401,9 @@ type Func struct {
 	Name    string
 	Type    Type
 	Linkage FuncLinkage
+	// Tags holds the values of BTF_KIND_DECL_TAG annotations which apply to
+	// the function as a whole, for example "exception_callback:cb".
+	Tags []string
 }
 
 func FuncMetadata(ins *asm.Instruction) *Func {
@@ -965,7 +968,7 @@ func inflateRawTypes(rawTypes []rawType, rawStrings *stringTable, base *Spec) ([
 			typ = restrict
 
 		case kindFunc:
-			fn := &Func{name, nil, raw.Linkage()}
+			fn := &Func{name, nil, raw.Linkage(), nil}
 			if err := fixupAndAssert(raw.Type(), &fn.Type, reflect.TypeOf((*FuncProto)(nil))); err != nil {
 				return nil, err
 			}
@@ -1100,6 +1103,10 @@ func inflateRawTypes(rawTypes []rawType, rawStrings *stringTable, base *Spec) ([
 				return nil, fmt.Errorf("type %s: index %d exceeds params of %s", dt, dt.Index, t)
 			}
 
+			if dt.Index == -1 {
+				t.Tags = append(t.Tags, dt.Value)
+			}
+
 		default:
 			return nil, fmt.Errorf("type %s: decl tag for type %s is not supported", dt, t)
 		}
@@ -11,6 +11,8 @@ import (
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/kconfig"
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
 )
 
 // CollectionOptions control loading a collection into the kernel.
@@ -30,6 +32,60 @@ type CollectionOptions struct {
 	// The given Maps are Clone()d before being used in the Collection, so the
 	// caller can Close() them freely when they are no longer needed.
 	MapReplacements map[string]*Map
+
+	// ExistingMaps identifies maps outside of the current process which are
+	// used instead of creating new ones, for example to share state between
+	// Collections.
+	//
+	// For each entry there must be a corresponding MapSpec in
+	// CollectionSpec.Maps and the map must pass the check selected by
+	// ExistingMap.Compatibility. The contents of existing maps are left
+	// untouched. A map may not be given in both ExistingMaps and
+	// MapReplacements.
+	ExistingMaps map[string]ExistingMap
+}
+
+// ExistingMap identifies a map which is used instead of creating a new one.
+type ExistingMap struct {
+	// Path of a pinned map on bpffs. Mutually exclusive with FD.
+	Path string
+	// PinOptions are used when opening the map at Path.
+	PinOptions LoadPinOptions
+
+	// FD is a file descriptor referring to the map. It is duplicated and
+	// remains owned by the caller. Mutually exclusive with Path.
+	FD int
+
+	// Compatibility controls how strictly the map is checked against its
+	// MapSpec.
+	Compatibility MapCompatibility
+}
+
+// open the existing map.
+func (em *ExistingMap) open() (*Map, error) {
+	switch {
+	case em.Path != "" && em.FD != 0:
+		return nil, errors.New("Path and FD are mutually exclusive")
+
+	case em.Path != "":
+		return LoadPinnedMap(em.Path, &em.PinOptions)
+
+	case em.FD > 0:
+		// Duplicate the descriptor so that the caller's copy stays valid.
+		dup, err := unix.FcntlInt(uintptr(em.FD), unix.F_DUPFD_CLOEXEC, 1)
+		if err != nil {
+			return nil, fmt.Errorf("duplicate fd: %w", err)
+		}
+
+		fd, err := sys.NewFD(dup)
+		if err != nil {
+			return nil, err
+		}
+		return newMapFromFD(fd)
+
+	default:
+		return nil, errors.New("missing Path or FD")
+	}
 }
 
 // CollectionSpec describes a collection.
@@ -37,6 +93,11 @@ type CollectionSpec struct {
 	Maps     map[string]*MapSpec
 	Programs map[string]*ProgramSpec
 
+	// Variables are the global variables declared in data sections like
+	// .data, .bss and .rodata. Setting a variable modifies the contents of
+	// the corresponding map in Maps.
+	Variables map[string]*VariableSpec
+
 	// Types holds type information about Maps and Programs.
 	// Modifications to Types are currently undefined behaviour.
 	Types *btf.Spec
@@ -55,6 +116,7 @@ func (cs *CollectionSpec) Copy() *CollectionSpec {
 	cpy := CollectionSpec{
 		Maps:      make(map[string]*MapSpec, len(cs.Maps)),
 		Programs:  make(map[string]*ProgramSpec, len(cs.Programs)),
+		Variables: make(map[string]*VariableSpec, len(cs.Variables)),
 		ByteOrder: cs.ByteOrder,
 		Types:     cs.Types,
 	}
@@ -67,6 +129,10 @@ func (cs *CollectionSpec) Copy() *CollectionSpec {
 		cpy.Programs[name] = spec.Copy()
 	}
 
+	for name, spec := range cs.Variables {
+		cpy.Variables[name] = spec.copy(cpy.Maps)
+	}
+
 	return &cpy
 }
 
@@ -121,6 +187,9 @@ func (m *MissingConstantsError) Error() string {
 
 // RewriteConstants replaces the value of multiple constants.
 //
+// Prefer [VariableSpec.Set] via CollectionSpec.Variables, which checks the
+// type of the constant.
+//
 // The constant must be defined like so in the C program:
 //
 //	volatile const type foobar;
@@ -346,6 +415,10 @@ func (cs *CollectionSpec) LoadAndAssign(to interface{}, opts *CollectionOptions)
 type Collection struct {
 	Programs map[string]*Program
 	Maps     map[string]*Map
+
+	// Variables are the global variables of the Collection. They remain
+	// valid until the map they live in is closed.
+	Variables map[string]*Variable
 }
 
 // NewCollection creates a Collection from the given spec, creating and
@@ -392,6 +465,14 @@ func NewCollectionWithOptions(spec *CollectionSpec, opts CollectionOptions) (*Co
 		return nil, err
 	}
 
+	vars := make(map[string]*Variable, len(spec.Variables))
+	for name, vs := range spec.Variables {
+		// The data section may have been removed by RewriteMaps.
+		if m := loader.maps[vs.mapName]; m != nil {
+			vars[name] = newVariable(vs, m)
+		}
+	}
+
 	// Prevent loader.cleanup from closing maps and programs.
 	maps, progs := loader.maps, loader.programs
 	loader.maps, loader.programs = nil, nil
@@ -399,6 +480,7 @@ func NewCollectionWithOptions(spec *CollectionSpec, opts CollectionOptions) (*Co
 	return &Collection{
 		progs,
 		maps,
+		vars,
 	}, nil
 }
 
@@ -407,6 +489,8 @@ type collectionLoader struct {
 	opts     *CollectionOptions
 	maps     map[string]*Map
 	programs map[string]*Program
+	// Maps from CollectionOptions.ExistingMaps, which aren't populated.
+	existing map[string]bool
 }
 
 func newCollectionLoader(coll *CollectionSpec, opts *CollectionOptions) (*collectionLoader, error) {
@@ -426,12 +510,43 @@ func newCollectionLoader(coll *CollectionSpec, opts *CollectionOptions) (*collec
 		}
 	}
 
-	return &collectionLoader{
+	cl := &collectionLoader{
 		coll,
 		opts,
 		make(map[string]*Map),
 		make(map[string]*Program),
-	}, nil
+		make(map[string]bool),
+	}
+
+	for name, em := range opts.ExistingMaps {
+		spec, ok := coll.Maps[name]
+		if !ok {
+			cl.close()
+			return nil, fmt.Errorf("existing map %s not found in CollectionSpec", name)
+		}
+
+		if _, ok := opts.MapReplacements[name]; ok {
+			cl.close()
+			return nil, fmt.Errorf("map %s is both an existing map and a replacement", name)
+		}
+
+		m, err := em.open()
+		if err != nil {
+			cl.close()
+			return nil, fmt.Errorf("open existing map %s: %w", name, err)
+		}
+
+		if err := spec.compatible(m, em.Compatibility); err != nil {
+			m.Close()
+			cl.close()
+			return nil, fmt.Errorf("using existing map %s: %w", name, err)
+		}
+
+		cl.maps[name] = m
+		cl.existing[name] = true
+	}
+
+	return cl, nil
 }
 
 // close all resources left over in the collectionLoader.
@@ -529,6 +644,10 @@ func (cl *collectionLoader) loadProgram(progName string) (*Program, error) {
 
 func (cl *collectionLoader) populateMaps() error {
 	for mapName, m := range cl.maps {
+		if cl.existing[mapName] {
+			continue
+		}
+
 		mapSpec, ok := cl.coll.Maps[mapName]
 		if !ok {
 			return fmt.Errorf("missing map spec %s", mapName)
@@ -568,6 +687,35 @@ func (cl *collectionLoader) populateMaps() error {
 			}
 		}
 
+		// The value of a struct_ops map refers to the programs implementing
+		// its function pointers by name. Writing the value registers it with
+		// the kernel, unless the map was created with BPF_F_LINK.
+		if mapSpec.Type == StructOpsMap {
+			mapSpec = mapSpec.Copy()
+
+			for i, kv := range mapSpec.Contents {
+				value, ok := kv.Value.(*StructOpsValue)
+				if !ok {
+					continue
+				}
+
+				progs := make(map[string]*Program, len(value.Programs))
+				for _, progName := range value.Programs {
+					prog, err := cl.loadProgram(progName)
+					if err != nil {
+						return fmt.Errorf("loading program %s, for map %s: %w", progName, mapName, err)
+					}
+					progs[progName] = prog
+				}
+
+				buf, err := marshalStructOpsValue(mapSpec.Value, value, progs)
+				if err != nil {
+					return fmt.Errorf("map %s: %w", mapName, err)
+				}
+				mapSpec.Contents[i] = MapKV{kv.Key, buf}
+			}
+		}
+
 		// Populate and freeze the map if specified.
 		if err := m.finalize(mapSpec); err != nil {
 			return fmt.Errorf("populating map %s: %w", mapName, err)
@@ -579,7 +727,10 @@ func (cl *collectionLoader) populateMaps() error {
 
 // resolveKconfig resolves all variables declared in .kconfig and populates
 // m.Contents. Does nothing if the given m.Contents is non-empty.
-func resolveKconfig(m *MapSpec) error {
+//
+// weak contains the offsets of variables which are left zero instead of
+// causing an error if the kernel config doesn't contain them.
+func resolveKconfig(m *MapSpec, weak map[uint32]bool) error {
 	ds, ok := m.Value.(*btf.Datasec)
 	if !ok {
 		return errors.New("map value is not a Datasec")
@@ -623,6 +774,10 @@ func resolveKconfig(m *MapSpec) error {
 			internal.NativeEndian.PutUint32(data[vsi.Offset:], value)
 
 		default: // Catch CONFIG_*.
+			if strings.HasPrefix(n, "LINUX_") {
+				return fmt.Errorf("variable %s: unrecognized virtual extern", n)
+			}
+
 			configs[n] = configInfo{
 				offset: vsi.Offset,
 				typ:    v.Type,
@@ -650,6 +805,10 @@ func resolveKconfig(m *MapSpec) error {
 
 		for n, info := range configs {
 			value, ok := kernelConfig[n]
+			if !ok && weak[info.offset] {
+				// Weak variables default to zero, like in libbpf.
+				continue
+			}
 			if !ok {
 				return fmt.Errorf("config option %q does not exists for this kernel", n)
 			}
@@ -3,12 +3,14 @@ package ebpf
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/kconfig"
 	"github.com/cilium/ebpf/internal/testutils"
 	"github.com/cilium/ebpf/internal/testutils/fdtrace"
 	qt "github.com/frankban/quicktest"
@@ -604,6 +606,42 @@ func TestIncompleteLoadAndAssign(t *testing.T) {
 	}
 }
 
+func TestResolveKconfigWeak(t *testing.T) {
+	if f, err := kconfig.Find(); err != nil {
+		t.Skip("No kernel config available:", err)
+	} else {
+		f.Close()
+	}
+
+	integer := &btf.Int{Size: 4, Encoding: btf.Signed}
+	newSpec := func(names ...string) *MapSpec {
+		ds := &btf.Datasec{Name: ".kconfig"}
+		for i, name := range names {
+			ds.Vars = append(ds.Vars, btf.VarSecinfo{
+				Type:   &btf.Var{Name: name, Type: integer, Linkage: btf.ExternVar},
+				Offset: uint32(i) * 4,
+				Size:   4,
+			})
+		}
+		ds.Size = uint32(len(names)) * 4
+		return &MapSpec{Value: ds}
+	}
+
+	spec := newSpec("CONFIG_HZ", "CONFIG_DOES_NOT_EXIST")
+	err := resolveKconfig(spec, nil)
+	qt.Assert(t, err, qt.ErrorMatches, `.*CONFIG_DOES_NOT_EXIST.*`)
+
+	err = resolveKconfig(spec, map[uint32]bool{4: true})
+	qt.Assert(t, err, qt.IsNil)
+
+	data := spec.Contents[0].Value.([]byte)
+	qt.Assert(t, internal.NativeEndian.Uint32(data[0:]), qt.Not(qt.Equals), uint32(0))
+	qt.Assert(t, internal.NativeEndian.Uint32(data[4:]), qt.Equals, uint32(0))
+
+	err = resolveKconfig(newSpec("LINUX_DOES_NOT_EXIST"), nil)
+	qt.Assert(t, err, qt.ErrorMatches, `.*unrecognized virtual extern`)
+}
+
 func BenchmarkNewCollection(b *testing.B) {
 	file := fmt.Sprintf("testdata/loader-%s.elf", internal.ClangEndian)
 	spec, err := LoadCollectionSpec(file)
@@ -728,3 +766,86 @@ func ExampleCollectionSpec_LoadAndAssign() {
 	// Output: SocketFilter
 	// Array
 }
+
+func TestCollectionSpecExistingMaps(t *testing.T) {
+	tmp := testutils.TempBPFFS(t)
+
+	cs := &CollectionSpec{
+		Maps: map[string]*MapSpec{
+			"state": {
+				Type:       Array,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 1,
+				Contents:   []MapKV{{uint32(0), uint32(1)}},
+			},
+		},
+	}
+
+	existing, err := NewMap(cs.Maps["state"])
+	qt.Assert(t, err, qt.IsNil)
+	defer existing.Close()
+
+	qt.Assert(t, existing.Put(uint32(0), uint32(42)), qt.IsNil)
+	path := filepath.Join(tmp, "state")
+	qt.Assert(t, existing.Pin(path), qt.IsNil)
+
+	for name, em := range map[string]ExistingMap{
+		"path": {Path: path},
+		"fd":   {FD: existing.FD()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var objs struct {
+				State *Map `ebpf:"state"`
+			}
+			err := cs.LoadAndAssign(&objs, &CollectionOptions{
+				ExistingMaps: map[string]ExistingMap{"state": em},
+			})
+			qt.Assert(t, err, qt.IsNil)
+			defer objs.State.Close()
+
+			var value uint32
+			qt.Assert(t, objs.State.Lookup(uint32(0), &value), qt.IsNil)
+			qt.Assert(t, value, qt.Equals, uint32(42), qt.Commentf("contents of existing map were overwritten"))
+		})
+	}
+
+	// The caller's fd must remain valid.
+	var value uint32
+	qt.Assert(t, existing.Lookup(uint32(0), &value), qt.IsNil)
+
+	incompatible := cs.Copy()
+	incompatible.Maps["state"].MaxEntries = 2
+
+	_, err = NewCollectionWithOptions(incompatible, CollectionOptions{
+		ExistingMaps: map[string]ExistingMap{"state": {Path: path}},
+	})
+	qt.Assert(t, errors.Is(err, ErrMapIncompatible), qt.IsTrue)
+
+	coll, err := NewCollectionWithOptions(incompatible, CollectionOptions{
+		ExistingMaps: map[string]ExistingMap{"state": {Path: path, Compatibility: MapCompatibilityLenient}},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	coll.Close()
+
+	for name, em := range map[string]ExistingMap{
+		"missing": {},
+		"both":    {Path: path, FD: existing.FD()},
+	} {
+		_, err = NewCollectionWithOptions(cs, CollectionOptions{
+			ExistingMaps: map[string]ExistingMap{"state": em},
+		})
+		qt.Assert(t, err, qt.IsNotNil, qt.Commentf(name))
+	}
+
+	_, err = NewCollectionWithOptions(cs, CollectionOptions{
+		ExistingMaps:    map[string]ExistingMap{"state": {Path: path}},
+		MapReplacements: map[string]*Map{"state": existing},
+	})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	_, err = NewCollectionWithOptions(cs, CollectionOptions{
+		ExistingMaps: map[string]ExistingMap{"unknown": {Path: path}},
+	})
+	qt.Assert(t, err, qt.IsNotNil)
+}
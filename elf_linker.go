@@ -0,0 +1,541 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+)
+
+// LinkCollectionSpecs parses multiple ELF files and statically links them
+// into a single CollectionSpec, similar to `bpftool gen object`.
+//
+// See LinkCollectionSpecsFromReaders for details.
+func LinkCollectionSpecs(files ...string) (*CollectionSpec, error) {
+	rds := make([]io.ReaderAt, 0, len(files))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		rds = append(rds, f)
+	}
+
+	objs := make([]*elfObject, 0, len(rds))
+	for i, rd := range rds {
+		obj, err := loadObject(rd)
+		if err != nil {
+			return nil, fmt.Errorf("file %s: %w", files[i], err)
+		}
+		objs = append(objs, obj)
+	}
+
+	return linkCollectionSpec(objs)
+}
+
+// LinkCollectionSpecsFromReaders parses multiple ELF files and statically links
+// them into a single CollectionSpec.
+//
+// Programs may call global functions and access global variables defined in
+// any of the files, which allows splitting BPF code into multiple compilation
+// units. A __weak function is replaced by a non-weak function of the same
+// name. Other symbol names must be unique:
+//
+//   - Static functions are renamed if their name collides with a function
+//     in another file. This also applies to programs.
+//   - Maps of the same name are merged, provided their definitions are
+//     identical.
+//   - Data sections like .data and .rodata are concatenated.
+//
+// The BTF of all files is merged, omitting named types which are declared in
+// multiple files.
+func LinkCollectionSpecsFromReaders(rds ...io.ReaderAt) (*CollectionSpec, error) {
+	objs := make([]*elfObject, 0, len(rds))
+	for i, rd := range rds {
+		obj, err := loadObject(rd)
+		if err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+		objs = append(objs, obj)
+	}
+
+	return linkCollectionSpec(objs)
+}
+
+func linkCollectionSpec(objs []*elfObject) (*CollectionSpec, error) {
+	obj, err := linkObjects(objs)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.collectionSpec()
+}
+
+// linkObjects merges multiple objects into one.
+//
+// The objects are modified and must not be used afterwards.
+func linkObjects(objs []*elfObject) (*elfObject, error) {
+	if len(objs) == 0 {
+		return nil, errors.New("no objects to link")
+	}
+
+	if len(objs) == 1 {
+		return objs[0], nil
+	}
+
+	renameStaticFunctions(objs)
+
+	if err := mergeKconfig(objs); err != nil {
+		return nil, fmt.Errorf(".kconfig: %w", err)
+	}
+
+	out := &elfObject{
+		byteOrder:  objs[0].byteOrder,
+		maps:       make(map[string]*MapSpec),
+		unusedData: make(map[string]*MapSpec),
+		progs:      make(map[string]*ProgramSpec),
+		static:     make(map[string]bool),
+		weak:       make(map[string]bool),
+		globals:    make(map[string]dataSymbol),
+	}
+
+	for i, obj := range objs {
+		if obj.byteOrder != out.byteOrder {
+			return nil, fmt.Errorf("object %d: byte order %s doesn't match %s", i, obj.byteOrder, out.byteOrder)
+		}
+
+		if err := out.add(obj); err != nil {
+			return nil, fmt.Errorf("object %d: %w", i, err)
+		}
+	}
+
+	out.resolveGlobals()
+
+	var err error
+	out.types, err = mergeTypes(objs, out.maps)
+	if err != nil {
+		return nil, fmt.Errorf("merge BTF: %w", err)
+	}
+
+	return out, nil
+}
+
+// add the functions, maps and variables of obj to o.
+func (o *elfObject) add(obj *elfObject) error {
+	if err := o.addDataSections(obj); err != nil {
+		return err
+	}
+
+	for name, m := range obj.maps {
+		if isDataSection(name) {
+			continue
+		}
+
+		if existing := o.maps[name]; existing != nil {
+			if err := mapSpecsCompatible(existing, m); err != nil {
+				return fmt.Errorf("map %s: %w", name, err)
+			}
+			continue
+		}
+
+		o.maps[name] = m
+	}
+
+	for name, prog := range obj.progs {
+		if o.progs[name] != nil {
+			switch {
+			case obj.weak[name]:
+				continue
+			case !o.weak[name]:
+				return fmt.Errorf("function %s is defined multiple times", name)
+			}
+		}
+
+		o.progs[name] = prog
+		o.static[name] = obj.static[name]
+		o.weak[name] = obj.weak[name]
+	}
+
+	for name, sym := range obj.globals {
+		if _, ok := o.globals[name]; ok {
+			return fmt.Errorf("global variable %s is defined multiple times", name)
+		}
+		o.globals[name] = sym
+	}
+
+	return nil
+}
+
+// addDataSections concatenates the data sections of obj with the ones in o.
+//
+// Rewrites the instructions and global variables of obj to account for the
+// new offsets.
+func (o *elfObject) addDataSections(obj *elfObject) error {
+	add := func(name string, m *MapSpec, used bool) error {
+		existing, existingUsed := o.maps[name], true
+		if existing == nil {
+			existing, existingUsed = o.unusedData[name], false
+		}
+
+		merged, base := m, uint32(0)
+		if existing != nil {
+			var err error
+			merged, base, err = concatDataSections(existing, m)
+			if err != nil {
+				return fmt.Errorf("data section %s: %w", name, err)
+			}
+		}
+
+		delete(o.maps, name)
+		delete(o.unusedData, name)
+		if used || existingUsed {
+			o.maps[name] = merged
+		} else {
+			o.unusedData[name] = merged
+		}
+
+		if base == 0 {
+			return nil
+		}
+
+		for _, prog := range obj.progs {
+			for i := range prog.Instructions {
+				ins := &prog.Instructions[i]
+				if ins.Reference() != name || !ins.IsLoadFromMap() || ins.Src != asm.PseudoMapValue {
+					continue
+				}
+
+				ins.Constant = int64(uint64(ins.Constant) + uint64(base)<<32)
+			}
+		}
+
+		for sym, loc := range obj.globals {
+			if loc.section == name {
+				obj.globals[sym] = dataSymbol{name, loc.offset + base}
+			}
+		}
+
+		return nil
+	}
+
+	for name, m := range obj.maps {
+		if !isDataSection(name) {
+			continue
+		}
+
+		if err := add(name, m, true); err != nil {
+			return err
+		}
+	}
+
+	for name, m := range obj.unusedData {
+		if err := add(name, m, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveGlobals turns references to extern global variables into direct
+// loads from the data section which defines them.
+func (o *elfObject) resolveGlobals() {
+	for _, prog := range o.progs {
+		for i := range prog.Instructions {
+			ins := &prog.Instructions[i]
+			if !ins.OpCode.IsDWordLoad() || ins.Src != asm.R0 {
+				continue
+			}
+
+			sym, ok := o.globals[ins.Reference()]
+			if !ok {
+				continue
+			}
+
+			if m := o.unusedData[sym.section]; m != nil {
+				o.maps[sym.section] = m
+				delete(o.unusedData, sym.section)
+			}
+
+			*ins = ins.WithReference(sym.section)
+			ins.Src = asm.PseudoMapValue
+			ins.Constant = int64(uint64(sym.offset) << 32)
+		}
+	}
+}
+
+// concatDataSections appends the contents of b to a and returns the offset of
+// b in the result.
+func concatDataSections(a, b *MapSpec) (*MapSpec, uint32, error) {
+	if a.Flags != b.Flags {
+		return nil, 0, fmt.Errorf("expected flags %v, got %v: %w", a.Flags, b.Flags, ErrMapIncompatible)
+	}
+
+	// Variables may require up to 8 byte alignment.
+	base := internal.Align(a.ValueSize, 8)
+
+	merged := a.Copy()
+	merged.ValueSize = base + b.ValueSize
+
+	if len(a.Contents) > 0 || len(b.Contents) > 0 {
+		data := make([]byte, merged.ValueSize)
+		for _, part := range []struct {
+			m      *MapSpec
+			offset uint32
+		}{{a, 0}, {b, base}} {
+			if len(part.m.Contents) == 0 {
+				continue
+			}
+
+			value, ok := part.m.Contents[0].Value.([]byte)
+			if !ok {
+				return nil, 0, fmt.Errorf("contents are %T, not []byte", part.m.Contents[0].Value)
+			}
+			copy(data[part.offset:], value)
+		}
+
+		merged.Contents = []MapKV{{uint32(0), data}}
+	}
+
+	dsA, okA := a.Value.(*btf.Datasec)
+	dsB, okB := b.Value.(*btf.Datasec)
+	if !okA || !okB {
+		// Without a Datasec for both sections we can't describe the result.
+		merged.Key = nil
+		merged.Value = nil
+		return merged, base, nil
+	}
+
+	ds := &btf.Datasec{
+		Name: dsA.Name,
+		Size: merged.ValueSize,
+		Vars: make([]btf.VarSecinfo, 0, len(dsA.Vars)+len(dsB.Vars)),
+	}
+	ds.Vars = append(ds.Vars, dsA.Vars...)
+	for _, vsi := range dsB.Vars {
+		vsi.Offset += base
+		ds.Vars = append(ds.Vars, vsi)
+	}
+	merged.Value = ds
+
+	return merged, base, nil
+}
+
+// mapSpecsCompatible returns nil if both specs describe the same map.
+func mapSpecsCompatible(a, b *MapSpec) error {
+	switch {
+	case a.Type != b.Type:
+		return fmt.Errorf("expected type %v, got %v: %w", a.Type, b.Type, ErrMapIncompatible)
+	case a.KeySize != b.KeySize:
+		return fmt.Errorf("expected key size %v, got %v: %w", a.KeySize, b.KeySize, ErrMapIncompatible)
+	case a.ValueSize != b.ValueSize:
+		return fmt.Errorf("expected value size %v, got %v: %w", a.ValueSize, b.ValueSize, ErrMapIncompatible)
+	case a.MaxEntries != b.MaxEntries:
+		return fmt.Errorf("expected max entries %v, got %v: %w", a.MaxEntries, b.MaxEntries, ErrMapIncompatible)
+	case a.Flags != b.Flags:
+		return fmt.Errorf("expected flags %v, got %v: %w", a.Flags, b.Flags, ErrMapIncompatible)
+	case a.Pinning != b.Pinning:
+		return fmt.Errorf("expected pinning %v, got %v: %w", a.Pinning, b.Pinning, ErrMapIncompatible)
+	case (a.InnerMap == nil) != (b.InnerMap == nil):
+		return fmt.Errorf("inner map mismatch: %w", ErrMapIncompatible)
+	case len(a.Contents) > 0 || len(b.Contents) > 0:
+		return fmt.Errorf("maps with contents can't be merged: %w", ErrMapIncompatible)
+	}
+
+	if a.InnerMap != nil {
+		return mapSpecsCompatible(a.InnerMap, b.InnerMap)
+	}
+	return nil
+}
+
+// renameStaticFunctions renames static functions whose name clashes with a
+// function in another object.
+func renameStaticFunctions(objs []*elfObject) {
+	taken := make(map[string]bool)
+	for _, obj := range objs {
+		for name := range obj.progs {
+			if !obj.static[name] {
+				taken[name] = true
+			}
+		}
+	}
+
+	for i, obj := range objs {
+		var static []string
+		for name := range obj.progs {
+			if obj.static[name] {
+				static = append(static, name)
+			}
+		}
+		sort.Strings(static)
+
+		for _, name := range static {
+			if !taken[name] {
+				taken[name] = true
+				continue
+			}
+
+			newName := name
+			for n := i; taken[newName]; n++ {
+				newName = fmt.Sprintf("%s_%d", name, n)
+			}
+
+			obj.renameFunction(name, newName)
+			taken[newName] = true
+		}
+	}
+}
+
+// renameFunction renames a function and all references to it.
+func (o *elfObject) renameFunction(oldName, newName string) {
+	prog := o.progs[oldName]
+	delete(o.progs, oldName)
+	prog.Name = newName
+	o.progs[newName] = prog
+
+	o.static[newName] = o.static[oldName]
+	delete(o.static, oldName)
+
+	for _, prog := range o.progs {
+		for i := range prog.Instructions {
+			ins := &prog.Instructions[i]
+			if ins.Symbol() == oldName {
+				*ins = ins.WithSymbol(newName)
+			}
+			if ins.IsFunctionReference() && ins.Reference() == oldName {
+				*ins = ins.WithReference(newName)
+			}
+		}
+	}
+}
+
+// mergeKconfig replaces the .kconfig sections of all objects with a single
+// one, since a program may only refer to one .kconfig map.
+func mergeKconfig(objs []*elfObject) error {
+	var sections []*MapSpec
+	for _, obj := range objs {
+		if obj.kconfig != nil {
+			sections = append(sections, obj.kconfig)
+		}
+	}
+
+	if len(sections) < 2 {
+		return nil
+	}
+
+	ds := &btf.Datasec{Name: ".kconfig"}
+	vars := make(map[string]btf.VarSecinfo)
+	for _, kconfig := range sections {
+		for _, vsi := range kconfig.Value.(*btf.Datasec).Vars {
+			name := vsi.Type.(*btf.Var).Name
+			if existing, ok := vars[name]; ok {
+				if existing.Size != vsi.Size {
+					return fmt.Errorf("variable %s: size %d doesn't match %d", name, vsi.Size, existing.Size)
+				}
+				continue
+			}
+
+			vsi.Offset = internal.Align(ds.Size, 8)
+			ds.Size = vsi.Offset + vsi.Size
+			ds.Vars = append(ds.Vars, vsi)
+			vars[name] = vsi
+		}
+	}
+
+	merged := sections[0].Copy()
+	merged.ValueSize = ds.Size
+	merged.Value = ds
+
+	for _, obj := range objs {
+		if obj.kconfig == nil {
+			continue
+		}
+
+		names := make(map[uint32]string)
+		for _, vsi := range obj.kconfig.Value.(*btf.Datasec).Vars {
+			names[vsi.Offset] = vsi.Type.(*btf.Var).Name
+		}
+
+		for _, prog := range obj.progs {
+			for i := range prog.Instructions {
+				ins := &prog.Instructions[i]
+				meta, _ := ins.Metadata.Get(kconfigMetaKey{}).(*kconfigMeta)
+				if meta == nil {
+					continue
+				}
+
+				offset := vars[names[meta.Offset]].Offset
+				ins.Metadata.Set(kconfigMetaKey{}, &kconfigMeta{merged, offset, meta.Weak})
+			}
+		}
+
+		obj.kconfig = merged
+	}
+
+	return nil
+}
+
+// mergeTypes combines the BTF of multiple objects.
+//
+// Named types are deduplicated by name and kind. Data sections are taken from
+// maps since they may have been merged.
+func mergeTypes(objs []*elfObject, maps map[string]*MapSpec) (*btf.Spec, error) {
+	type key struct {
+		name string
+		kind reflect.Type
+	}
+
+	var spec *btf.Spec
+	seen := make(map[key]bool)
+	for _, obj := range objs {
+		if obj.types == nil {
+			continue
+		}
+
+		if spec == nil {
+			spec = btf.NewSpec()
+		}
+
+		iter := obj.types.Iterate()
+		for iter.Next() {
+			typ := iter.Type
+			if _, ok := typ.(*btf.Datasec); ok {
+				continue
+			}
+
+			if name := typ.TypeName(); name != "" {
+				k := key{name, reflect.TypeOf(typ)}
+				if seen[k] {
+					continue
+				}
+				seen[k] = true
+			}
+
+			if _, err := spec.Add(typ); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if spec == nil {
+		return nil, nil
+	}
+
+	for _, m := range maps {
+		if ds, ok := m.Value.(*btf.Datasec); ok {
+			if _, err := spec.Add(ds); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return spec, nil
+}
@@ -0,0 +1,245 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLinkObjects(t *testing.T) {
+	integer := &btf.Int{Size: 4}
+	dataSection := func(values map[string]uint32) *MapSpec {
+		ds := &btf.Datasec{Name: ".data", Size: uint32(len(values)) * 4}
+		data := make([]byte, ds.Size)
+		var offset uint32
+		for _, name := range []string{"a", "b", "counter"} {
+			value, ok := values[name]
+			if !ok {
+				continue
+			}
+
+			ds.Vars = append(ds.Vars, btf.VarSecinfo{
+				Type:   &btf.Var{Name: name, Type: integer},
+				Offset: offset,
+				Size:   4,
+			})
+			internal.NativeEndian.PutUint32(data[offset:], value)
+			offset += 4
+		}
+
+		return &MapSpec{
+			Name:       ".data",
+			Type:       Array,
+			KeySize:    4,
+			ValueSize:  ds.Size,
+			MaxEntries: 1,
+			Contents:   []MapKV{{uint32(0), data}},
+			Key:        &btf.Void{},
+			Value:      ds,
+		}
+	}
+
+	function := func(name, section string, insns ...asm.Instruction) *ProgramSpec {
+		insns[0] = insns[0].WithSymbol(name)
+		return &ProgramSpec{
+			Name:         name,
+			Type:         SocketFilter,
+			SectionName:  section,
+			License:      "MIT",
+			Instructions: insns,
+			ByteOrder:    internal.NativeEndian,
+		}
+	}
+
+	newObject := func(data *MapSpec, progs ...*ProgramSpec) *elfObject {
+		obj := &elfObject{
+			byteOrder:  internal.NativeEndian,
+			maps:       map[string]*MapSpec{".data": data},
+			unusedData: make(map[string]*MapSpec),
+			progs:      make(map[string]*ProgramSpec),
+			static:     make(map[string]bool),
+			weak:       make(map[string]bool),
+			globals:    make(map[string]dataSymbol),
+		}
+		for _, prog := range progs {
+			obj.progs[prog.Name] = prog
+		}
+		return obj
+	}
+
+	a := newObject(dataSection(map[string]uint32{"a": 1}),
+		function("entry", "socket",
+			asm.Call.Label("helper"),
+			asm.Mov.Reg(asm.R6, asm.R0),
+			// Extern variable defined in the other object.
+			asm.LoadImm(asm.R1, 0, asm.DWord).WithReference("counter"),
+			asm.LoadMem(asm.R1, asm.R1, 0, asm.Word),
+			asm.Add.Reg(asm.R6, asm.R1),
+			asm.LoadMapValue(asm.R1, 0, 0).WithReference(".data"),
+			asm.LoadMem(asm.R1, asm.R1, 0, asm.Word),
+			asm.Add.Reg(asm.R6, asm.R1),
+			asm.Call.Label("util"),
+			asm.Add.Reg(asm.R0, asm.R6),
+			asm.Return(),
+		),
+		function("util", ".text",
+			asm.Mov.Imm(asm.R0, 100),
+			asm.Return(),
+		),
+	)
+	a.static["util"] = true
+
+	b := newObject(dataSection(map[string]uint32{"b": 1000, "counter": 40}),
+		function("helper", ".text",
+			asm.Call.Label("util"),
+			asm.LoadMapValue(asm.R1, 0, 0).WithReference(".data"),
+			asm.LoadMem(asm.R1, asm.R1, 0, asm.Word),
+			asm.Add.Reg(asm.R0, asm.R1),
+			asm.Return(),
+		),
+		function("util", ".text",
+			asm.Mov.Imm(asm.R0, 10000),
+			asm.Return(),
+		),
+	)
+	b.static["util"] = true
+	b.globals["counter"] = dataSymbol{".data", 4}
+
+	obj, err := linkObjects([]*elfObject{a, b})
+	qt.Assert(t, err, qt.IsNil)
+
+	// The static function of the second object is renamed.
+	qt.Assert(t, obj.progs["util"], qt.IsNotNil)
+	qt.Assert(t, obj.progs["util_1"], qt.IsNotNil)
+
+	spec, err := obj.collectionSpec()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, spec.Programs, qt.HasLen, 1)
+	qt.Assert(t, spec.Maps, qt.HasLen, 1)
+	qt.Assert(t, spec.Maps[".data"].ValueSize, qt.Equals, uint32(16))
+
+	for name, offset := range map[string]uint32{"a": 0, "b": 8, "counter": 12} {
+		qt.Assert(t, spec.Variables[name], qt.IsNotNil, qt.Commentf("variable %s", name))
+		qt.Assert(t, spec.Variables[name].Offset(), qt.Equals, offset, qt.Commentf("variable %s", name))
+	}
+
+	var counter uint32
+	qt.Assert(t, spec.Variables["counter"].Get(&counter), qt.IsNil)
+	qt.Assert(t, counter, qt.Equals, uint32(40))
+
+	coll, err := NewCollection(spec)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer coll.Close()
+
+	ret, _, err := coll.Programs["entry"].Test(internal.EmptyBPFContext)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ret, qt.Equals, uint32(10000+1000+40+1+100))
+}
+
+func TestLinkObjectsWeak(t *testing.T) {
+	newObject := func(weak bool, ret int32) *elfObject {
+		obj := &elfObject{
+			progs: map[string]*ProgramSpec{
+				"fn": {
+					Name:        "fn",
+					SectionName: ".text",
+					Instructions: asm.Instructions{
+						asm.Mov.Imm(asm.R0, ret).WithSymbol("fn"),
+						asm.Return(),
+					},
+				},
+			},
+			static: make(map[string]bool),
+			weak:   map[string]bool{"fn": weak},
+		}
+		return obj
+	}
+
+	obj, err := linkObjects([]*elfObject{newObject(true, 1), newObject(false, 2)})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, obj.progs["fn"].Instructions[0].Constant, qt.Equals, int64(2))
+
+	obj, err = linkObjects([]*elfObject{newObject(false, 1), newObject(true, 2)})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, obj.progs["fn"].Instructions[0].Constant, qt.Equals, int64(1))
+
+	_, err = linkObjects([]*elfObject{newObject(false, 1), newObject(false, 2)})
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestLinkCollectionSpecs(t *testing.T) {
+	file := func(name string) string {
+		return fmt.Sprintf("testdata/%s-%s.elf", name, internal.ClangEndian)
+	}
+
+	_, err := LinkCollectionSpecs(file("kconfig"), file("kconfig"))
+	qt.Assert(t, err, qt.ErrorMatches, ".*function .* is defined multiple times")
+
+	_, err = LinkCollectionSpecs(file("loader"), file("subprog_reloc"))
+	qt.Assert(t, errors.Is(err, ErrMapIncompatible), qt.IsTrue, qt.Commentf("got %v", err))
+
+	spec, err := LinkCollectionSpecs(file("kconfig"), file("kconfig_config"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, spec.Programs["kernel_version"], qt.IsNotNil)
+	qt.Assert(t, spec.Programs["kconfig"], qt.IsNotNil)
+	qt.Assert(t, spec.Maps["array_map"], qt.IsNotNil)
+
+	// All programs refer to the same .kconfig section.
+	var kconfig *MapSpec
+	for _, prog := range spec.Programs {
+		for _, ins := range prog.Instructions {
+			meta, _ := ins.Metadata.Get(kconfigMetaKey{}).(*kconfigMeta)
+			if meta == nil {
+				continue
+			}
+
+			if kconfig == nil {
+				kconfig = meta.Map
+			}
+			qt.Assert(t, meta.Map, qt.Equals, kconfig)
+		}
+	}
+	qt.Assert(t, kconfig, qt.IsNotNil)
+	qt.Assert(t, kconfig.Value.(*btf.Datasec).Vars, qt.HasLen, 3)
+
+	if spec.ByteOrder != internal.NativeEndian {
+		return
+	}
+
+	var obj struct {
+		KernelVersion *Program `ebpf:"kernel_version"`
+		Kconfig       *Program `ebpf:"kconfig"`
+		ArrayMap      *Map     `ebpf:"array_map"`
+	}
+
+	err = spec.LoadAndAssign(&obj, nil)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer obj.KernelVersion.Close()
+	defer obj.Kconfig.Close()
+	defer obj.ArrayMap.Close()
+
+	ret, _, err := obj.KernelVersion.Test(internal.EmptyBPFContext)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+
+	version, err := internal.KernelVersion()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ret, qt.Equals, version.Kernel())
+
+	_, _, err = obj.Kconfig.Test(internal.EmptyBPFContext)
+	qt.Assert(t, err, qt.IsNil)
+
+	var hz uint64
+	qt.Assert(t, obj.ArrayMap.Lookup(uint32(0), &hz), qt.IsNil)
+	qt.Assert(t, hz, qt.Not(qt.Equals), uint64(0))
+}
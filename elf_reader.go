@@ -15,6 +15,7 @@ import (
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/sys"
 	"github.com/cilium/ebpf/internal/unix"
 )
 
@@ -23,10 +24,22 @@ type kconfigMetaKey struct{}
 type kconfigMeta struct {
 	Map    *MapSpec
 	Offset uint32
+	// Weak is true if the variable was declared __weak. Weak variables are
+	// zero if the kernel config doesn't contain them.
+	Weak bool
 }
 
 type kfuncMeta struct{}
 
+type ksymMetaKey struct{}
+
+type ksymMeta struct {
+	Var *btf.Var
+	// Weak is true if the variable was declared __weak. Weak variables are
+	// zero if the kernel doesn't contain them.
+	Weak bool
+}
+
 // elfCode is a convenience to reduce the amount of arguments that have to
 // be passed around explicitly. You should treat its contents as immutable.
 type elfCode struct {
@@ -37,8 +50,11 @@ type elfCode struct {
 	btf      *btf.Spec
 	extInfo  *btf.ExtInfos
 	maps     map[string]*MapSpec
-	kfuncs   map[string]*btf.Func
-	kconfig  *MapSpec
+	// Data sections without any references to them.
+	unusedData map[string]*MapSpec
+	kfuncs     map[string]*btf.Func
+	ksyms      map[string]*btf.Var
+	kconfig    *MapSpec
 }
 
 // LoadCollectionSpec parses an ELF file into a CollectionSpec.
@@ -58,6 +74,40 @@ func LoadCollectionSpec(file string) (*CollectionSpec, error) {
 
 // LoadCollectionSpecFromReader parses an ELF file into a CollectionSpec.
 func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
+	obj, err := loadObject(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	return obj.collectionSpec()
+}
+
+// elfObject is a parsed ELF file whose bpf-to-bpf calls haven't been resolved
+// yet. Multiple objects can be merged using linkObjects.
+type elfObject struct {
+	byteOrder binary.ByteOrder
+	types     *btf.Spec
+	maps      map[string]*MapSpec
+	// Data sections which aren't referenced by the object itself. They are
+	// only used when linking, since another object may refer to them.
+	unusedData map[string]*MapSpec
+	kconfig    *MapSpec
+	// All functions, including library functions in .text.
+	progs map[string]*ProgramSpec
+	// Function names by symbol binding.
+	static, weak map[string]bool
+	// Global variables defined in a data section, indexed by name.
+	globals map[string]dataSymbol
+}
+
+// dataSymbol is the location of a variable in a data section.
+type dataSymbol struct {
+	section string
+	offset  uint32
+}
+
+// loadObject parses an ELF file.
+func loadObject(rd io.ReaderAt) (*elfObject, error) {
 	f, err := internal.NewSafeELFFile(rd)
 	if err != nil {
 		return nil, err
@@ -93,7 +143,9 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 			sections[idx] = newElfSection(sec, mapSection)
 		case sec.Name == ".maps":
 			sections[idx] = newElfSection(sec, btfMapSection)
-		case sec.Name == ".bss" || sec.Name == ".data" || strings.HasPrefix(sec.Name, ".rodata"):
+		case sec.Name == ".struct_ops" || sec.Name == ".struct_ops.link":
+			sections[idx] = newElfSection(sec, structOpsSection)
+		case isDataSection(sec.Name):
 			sections[idx] = newElfSection(sec, dataSection)
 		case sec.Type == elf.SHT_REL:
 			// Store relocations under the section index of the target
@@ -126,7 +178,9 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		btf:         btfSpec,
 		extInfo:     btfExtInfo,
 		maps:        make(map[string]*MapSpec),
+		unusedData:  make(map[string]*MapSpec),
 		kfuncs:      make(map[string]*btf.Func),
+		ksyms:       make(map[string]*btf.Var),
 	}
 
 	symbols, err := f.Symbols()
@@ -152,6 +206,10 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		return nil, fmt.Errorf("load data sections: %w", err)
 	}
 
+	if err := ec.loadStructOpsMaps(); err != nil {
+		return nil, fmt.Errorf("load struct_ops maps: %w", err)
+	}
+
 	if err := ec.loadKconfigSection(); err != nil {
 		return nil, fmt.Errorf("load virtual .kconfig section: %w", err)
 	}
@@ -160,13 +218,65 @@ func LoadCollectionSpecFromReader(rd io.ReaderAt) (*CollectionSpec, error) {
 		return nil, fmt.Errorf("load virtual .ksyms section: %w", err)
 	}
 
-	// Finally, collect programs and link them.
-	progs, err := ec.loadProgramSections()
+	// Finally, collect programs.
+	obj := &elfObject{
+		byteOrder:  ec.ByteOrder,
+		types:      btfSpec,
+		maps:       ec.maps,
+		unusedData: ec.unusedData,
+		kconfig:    ec.kconfig,
+		static:     make(map[string]bool),
+		weak:       make(map[string]bool),
+		globals:    ec.loadGlobals(),
+	}
+
+	obj.progs, err = ec.loadProgramSections(obj.static, obj.weak)
 	if err != nil {
 		return nil, fmt.Errorf("load programs: %w", err)
 	}
 
-	return &CollectionSpec{ec.maps, progs, btfSpec, ec.ByteOrder}, nil
+	if err := assignStructOpsPrograms(obj.maps, obj.progs); err != nil {
+		return nil, fmt.Errorf("load struct_ops programs: %w", err)
+	}
+
+	return obj, nil
+}
+
+// collectionSpec resolves bpf-to-bpf calls and turns obj into a
+// CollectionSpec. obj must not be used afterwards.
+func (obj *elfObject) collectionSpec() (*CollectionSpec, error) {
+	var export []string
+	for name, prog := range obj.progs {
+		if prog.SectionName != ".text" {
+			export = append(export, name)
+		}
+	}
+
+	flattenPrograms(obj.progs, export)
+
+	// Hide programs (e.g. library functions) that were not explicitly emitted
+	// to an ELF section. These could be exposed in a separate CollectionSpec
+	// field later to allow them to be modified.
+	for n, p := range obj.progs {
+		if p.SectionName == ".text" {
+			delete(obj.progs, n)
+		}
+	}
+
+	vars := make(map[string]*VariableSpec)
+	ambiguous := make(map[string]bool)
+	for name, m := range obj.maps {
+		if err := dataSectionVariables(vars, ambiguous, name, m); err != nil {
+			return nil, fmt.Errorf("load variables: %w", err)
+		}
+	}
+
+	return &CollectionSpec{obj.maps, obj.progs, vars, obj.types, obj.byteOrder}, nil
+}
+
+// isDataSection returns true if the named section holds global variables.
+func isDataSection(name string) bool {
+	return name == ".bss" || name == ".data" || strings.HasPrefix(name, ".rodata")
 }
 
 func loadLicense(sec *elf.Section) (string, error) {
@@ -201,6 +311,7 @@ const (
 	btfMapSection
 	programSection
 	dataSection
+	structOpsSection
 )
 
 type elfSection struct {
@@ -244,7 +355,7 @@ func (ec *elfCode) assignSymbols(symbols []elf.Symbol) {
 		// Older versions of LLVM don't tag symbols correctly, so keep
 		// all NOTYPE ones.
 		switch symSection.kind {
-		case mapSection, btfMapSection, dataSection:
+		case mapSection, btfMapSection, dataSection, structOpsSection:
 			if symType != elf.STT_NOTYPE && symType != elf.STT_OBJECT {
 				continue
 			}
@@ -297,15 +408,16 @@ func (ec *elfCode) loadRelocations(relSections map[elf.SectionIndex]*elf.Section
 }
 
 // loadProgramSections iterates ec's sections and emits a ProgramSpec
-// for each function it finds.
+// for each function it finds. The names of functions with local or weak
+// binding are added to static and weak respectively.
 //
-// The resulting map is indexed by function name.
-func (ec *elfCode) loadProgramSections() (map[string]*ProgramSpec, error) {
+// The resulting map is indexed by function name. bpf-to-bpf calls are not
+// resolved.
+func (ec *elfCode) loadProgramSections(static, weak map[string]bool) (map[string]*ProgramSpec, error) {
 
 	progs := make(map[string]*ProgramSpec)
 
 	// Generate a ProgramSpec for each function found in each program section.
-	var export []string
 	for _, sec := range ec.sections {
 		if sec.kind != programSection {
 			continue
@@ -341,25 +453,38 @@ func (ec *elfCode) loadProgramSections() (map[string]*ProgramSpec, error) {
 				return nil, fmt.Errorf("duplicate program name %s", name)
 			}
 			progs[name] = spec
+		}
 
-			if spec.SectionName != ".text" {
-				export = append(export, name)
+		for _, sym := range sec.symbols {
+			switch elf.ST_BIND(sym.Info) {
+			case elf.STB_LOCAL:
+				static[sym.Name] = true
+			case elf.STB_WEAK:
+				weak[sym.Name] = true
 			}
 		}
 	}
 
-	flattenPrograms(progs, export)
+	return progs, nil
+}
 
-	// Hide programs (e.g. library functions) that were not explicitly emitted
-	// to an ELF section. These could be exposed in a separate CollectionSpec
-	// field later to allow them to be modified.
-	for n, p := range progs {
-		if p.SectionName == ".text" {
-			delete(progs, n)
+// loadGlobals returns the location of all global variables in data sections.
+func (ec *elfCode) loadGlobals() map[string]dataSymbol {
+	globals := make(map[string]dataSymbol)
+	for _, sec := range ec.sections {
+		if sec.kind != dataSection {
+			continue
 		}
-	}
 
-	return progs, nil
+		for offset, sym := range sec.symbols {
+			if elf.ST_BIND(sym.Info) != elf.STB_GLOBAL || elf.ST_TYPE(sym.Info) != elf.STT_OBJECT {
+				continue
+			}
+
+			globals[sym.Name] = dataSymbol{sec.Name, uint32(offset)}
+		}
+	}
+	return globals
 }
 
 // loadFunctions extracts instruction streams from the given program section
@@ -588,10 +713,12 @@ func (ec *elfCode) relocateInstruction(ins *asm.Instruction, rel elf.Symbol) err
 
 	// The Undefined section is used for 'virtual' symbols that aren't backed by
 	// an ELF section. This includes symbol references from inline asm, forward
-	// function declarations, as well as extern kfunc declarations using __ksym
-	// and extern kconfig variables declared using __kconfig.
+	// function declarations, as well as extern kfunc and variable declarations
+	// using __ksym and extern kconfig variables declared using __kconfig.
 	case undefSection:
-		if bind != elf.STB_GLOBAL {
+		// Only extern kconfig and ksym variables may be weak.
+		weak := bind == elf.STB_WEAK
+		if bind != elf.STB_GLOBAL && !weak {
 			return fmt.Errorf("asm relocation: %s: unsupported binding: %s", name, bind)
 		}
 
@@ -608,6 +735,12 @@ func (ec *elfCode) relocateInstruction(ins *asm.Instruction, rel elf.Symbol) err
 			ins.Src = asm.PseudoKfuncCall
 			ins.Constant = -1
 
+		// extern __ksym variables are resolved to the address or the BTF ID
+		// of the kernel symbol when loading the program.
+		case ec.ksyms[name] != nil && ins.OpCode.IsDWordLoad():
+			ins.Metadata.Set(ksymMetaKey{}, &ksymMeta{ec.ksyms[name], weak})
+			return nil
+
 		// If no kconfig map is found, this must be a symbol reference from inline
 		// asm (see testdata/loader.c:asm_relocation()) or a call to a forward
 		// function declaration (see testdata/fwd_decl.c). Don't interfere, These
@@ -623,13 +756,17 @@ func (ec *elfCode) relocateInstruction(ins *asm.Instruction, rel elf.Symbol) err
 				}
 
 				ins.Src = asm.PseudoMapValue
-				ins.Metadata.Set(kconfigMetaKey{}, &kconfigMeta{ec.kconfig, vsi.Offset})
+				ins.Metadata.Set(kconfigMetaKey{}, &kconfigMeta{ec.kconfig, vsi.Offset, weak})
 				return nil
 			}
 
 			return fmt.Errorf("kconfig %s not found in .kconfig", rel.Name)
 		}
 
+		if weak {
+			return fmt.Errorf("asm relocation: %s: unsupported binding: %s", name, bind)
+		}
+
 	default:
 		return fmt.Errorf("relocation to %q: %w", target.Name, ErrNotSupported)
 	}
@@ -1067,12 +1204,6 @@ func (ec *elfCode) loadDataSections() error {
 			continue
 		}
 
-		if sec.references == 0 {
-			// Prune data sections which are not referenced by any
-			// instructions.
-			continue
-		}
-
 		mapSpec := &MapSpec{
 			Name:       SanitizeName(sec.Name, -1),
 			Type:       Array,
@@ -1118,12 +1249,178 @@ func (ec *elfCode) loadDataSections() error {
 			mapSpec.Freeze = true
 		}
 
+		if sec.references == 0 {
+			// Prune data sections which are not referenced by any
+			// instructions. Keep them around for linking, since they may be
+			// referenced from another object.
+			ec.unusedData[sec.Name] = mapSpec
+			continue
+		}
+
 		ec.maps[sec.Name] = mapSpec
 	}
 
 	return nil
 }
 
+// loadStructOpsMaps emits a StructOpsMap for each variable in the
+// .struct_ops and .struct_ops.link sections.
+//
+// Relocations in these sections point at the programs implementing the
+// function pointers of the struct.
+func (ec *elfCode) loadStructOpsMaps() error {
+	for _, sec := range ec.sections {
+		if sec.kind != structOpsSection {
+			continue
+		}
+
+		if ec.btf == nil {
+			return fmt.Errorf("section %s: struct_ops requires BTF", sec.Name)
+		}
+
+		var ds *btf.Datasec
+		if err := ec.btf.TypeByName(sec.Name, &ds); err != nil {
+			return fmt.Errorf("section %s: %w", sec.Name, err)
+		}
+
+		data, err := sec.Data()
+		if err != nil {
+			return fmt.Errorf("section %s: can't get contents: %w", sec.Name, err)
+		}
+
+		var flags uint32
+		if sec.Name == ".struct_ops.link" {
+			flags = uint32(sys.BPF_F_LINK)
+		}
+
+		for _, vsi := range ds.Vars {
+			v, ok := vsi.Type.(*btf.Var)
+			if !ok {
+				return fmt.Errorf("section %s: unexpected type %s", sec.Name, vsi.Type)
+			}
+
+			s, ok := btf.UnderlyingType(v.Type).(*btf.Struct)
+			if !ok {
+				return fmt.Errorf("struct_ops %s: expected a struct, got %s", v.Name, v.Type)
+			}
+
+			if uint64(vsi.Offset)+uint64(vsi.Size) > uint64(len(data)) || vsi.Size != s.Size {
+				return fmt.Errorf("struct_ops %s: invalid offset or size", v.Name)
+			}
+
+			value := &StructOpsValue{
+				Data:     make([]byte, vsi.Size),
+				Programs: make(map[string]string),
+			}
+			copy(value.Data, data[vsi.Offset:])
+
+			for off, rel := range sec.relocations {
+				if off < uint64(vsi.Offset) || off >= uint64(vsi.Offset+vsi.Size) {
+					continue
+				}
+
+				if elf.ST_TYPE(rel.Info) != elf.STT_FUNC {
+					return fmt.Errorf("struct_ops %s: reference to %s: expected a function", v.Name, rel.Name)
+				}
+
+				member, err := structOpsMemberAt(s, uint32(off)-vsi.Offset)
+				if err != nil {
+					return fmt.Errorf("struct_ops %s: reference to %s: %w", v.Name, rel.Name, err)
+				}
+
+				value.Programs[member.Name] = rel.Name
+
+				// Clear the relocated pointer, the kernel needs a program fd.
+				pos := member.Offset.Bytes()
+				for i := pos; i < pos+8 && i < vsi.Size; i++ {
+					value.Data[i] = 0
+				}
+			}
+
+			if _, ok := ec.maps[v.Name]; ok {
+				return fmt.Errorf("struct_ops %s: duplicate map name", v.Name)
+			}
+
+			ec.maps[v.Name] = &MapSpec{
+				Name:       SanitizeName(v.Name, -1),
+				Type:       StructOpsMap,
+				KeySize:    4,
+				ValueSize:  s.Size,
+				MaxEntries: 1,
+				Flags:      flags,
+				Contents:   []MapKV{{uint32(0), value}},
+				Value:      s,
+			}
+		}
+	}
+
+	return nil
+}
+
+// structOpsMemberAt returns the function pointer member at off.
+func structOpsMemberAt(s *btf.Struct, off uint32) (btf.Member, error) {
+	for _, m := range s.Members {
+		if m.Offset.Bytes() != off || m.BitfieldSize > 0 {
+			continue
+		}
+
+		ptr, ok := btf.UnderlyingType(m.Type).(*btf.Pointer)
+		if !ok {
+			break
+		}
+		if _, ok := btf.UnderlyingType(ptr.Target).(*btf.FuncProto); !ok {
+			break
+		}
+
+		return m, nil
+	}
+
+	return btf.Member{}, fmt.Errorf("no function pointer in %s at offset %d", s.Name, off)
+}
+
+// assignStructOpsPrograms sets AttachTo of all programs referenced by a
+// StructOpsMap to the implemented member.
+func assignStructOpsPrograms(maps map[string]*MapSpec, progs map[string]*ProgramSpec) error {
+	for _, name := range sortedKeys(maps) {
+		m := maps[name]
+		if m.Type != StructOpsMap {
+			continue
+		}
+
+		s, ok := m.Value.(*btf.Struct)
+		if !ok {
+			return fmt.Errorf("map %s: value is not a struct", name)
+		}
+
+		for _, kv := range m.Contents {
+			value, ok := kv.Value.(*StructOpsValue)
+			if !ok {
+				continue
+			}
+
+			for _, member := range sortedKeys(value.Programs) {
+				progName := value.Programs[member]
+				prog := progs[progName]
+				if prog == nil {
+					return fmt.Errorf("map %s: member %s: unknown program %s", name, member, progName)
+				}
+
+				if prog.Type != StructOps {
+					return fmt.Errorf("map %s: member %s: program %s has type %s", name, member, progName, prog.Type)
+				}
+
+				target := s.Name + ":" + member
+				if prog.AttachTo != "" && prog.AttachTo != target {
+					return fmt.Errorf("program %s implements both %s and %s", progName, prog.AttachTo, target)
+				}
+				prog.AttachTo = target
+			}
+		}
+	}
+
+	return nil
+}
+
 // loadKconfigSection handles the 'virtual' Datasec .kconfig that doesn't
 // have a corresponding ELF section and exist purely in BTF.
 func (ec *elfCode) loadKconfigSection() error {
@@ -1176,8 +1473,13 @@ func (ec *elfCode) loadKsymsSection() error {
 	}
 
 	for _, v := range ds.Vars {
-		// we have already checked the .ksyms Datasec to only contain Func Vars.
-		ec.kfuncs[v.Type.TypeName()] = v.Type.(*btf.Func)
+		// we have already checked the .ksyms Datasec to only contain Funcs and Vars.
+		switch t := v.Type.(type) {
+		case *btf.Func:
+			ec.kfuncs[t.Name] = t
+		case *btf.Var:
+			ec.ksyms[t.Name] = t
+		}
 	}
 
 	return nil
@@ -1263,7 +1565,8 @@ func getProgType(sectionName string) (ProgramType, AttachType, uint32, string) {
 		{"cgroup/sysctl", CGroupSysctl, AttachCGroupSysctl, 0},
 		{"cgroup/getsockopt", CGroupSockopt, AttachCGroupGetsockopt, 0},
 		{"cgroup/setsockopt", CGroupSockopt, AttachCGroupSetsockopt, 0},
-		{"struct_ops+", StructOps, AttachNone, 0},
+		{"struct_ops.s", StructOps, AttachNone, unix.BPF_F_SLEEPABLE},
+		{"struct_ops", StructOps, AttachNone, 0},
 		{"sk_lookup/", SkLookup, AttachSkLookup, 0},
 		{"seccomp", SocketFilter, AttachNone, 0},
 		{"kprobe.multi", Kprobe, AttachTraceKprobeMulti, 0},
@@ -151,7 +151,7 @@ func TestLoadCollectionSpec(t *testing.T) {
 			return false
 		}),
 		cmpopts.IgnoreTypes(new(btf.Spec)),
-		cmpopts.IgnoreFields(CollectionSpec{}, "ByteOrder", "Types"),
+		cmpopts.IgnoreFields(CollectionSpec{}, "ByteOrder", "Types", "Variables"),
 		cmpopts.IgnoreFields(ProgramSpec{}, "Instructions", "ByteOrder"),
 		cmpopts.IgnoreFields(MapSpec{}, "Key", "Value"),
 		cmpopts.IgnoreUnexported(ProgramSpec{}),
@@ -1159,6 +1159,17 @@ func TestGetProgType(t *testing.T) {
 			At: AttachNone,
 			To: "func",
 		},
+		"struct_ops/ssthresh": {
+			Pt: StructOps,
+			At: AttachNone,
+			To: "",
+		},
+		"struct_ops.s/init": {
+			Pt: StructOps,
+			At: AttachNone,
+			To: "",
+			Fl: unix.BPF_F_SLEEPABLE,
+		},
 		"xdp/foo": {
 			Pt: XDP,
 			At: AttachNone,
@@ -0,0 +1,235 @@
+// Package events reads records from PerfEventArray and RingBuf maps through
+// a common interface.
+//
+// BPF ring buffers require Linux 5.8, older kernels only support perf event
+// arrays. A Reader allows an application to pick the map type depending on
+// the kernel without changing how it consumes records.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// ErrClosed is returned when interacting with a closed Reader.
+var ErrClosed = os.ErrClosed
+
+// Record is a record read from either type of map.
+type Record struct {
+	// The CPU the record was generated on, or -1 for ring buffers, which
+	// don't record it.
+	CPU int
+
+	// The data submitted by the BPF program. Samples from perf event
+	// arrays can contain between 0 and 7 bytes of trailing garbage.
+	RawSample []byte
+
+	// The number of samples which could not be output, since the ring was
+	// full. Only perf event arrays emit such records, RawSample is empty.
+	LostSamples uint64
+}
+
+// Stats describes the fill level of a Reader.
+type Stats struct {
+	// The size of all rings in bytes.
+	Size int
+	// The number of bytes waiting to be read.
+	AvailableData int
+	// The number of samples which were lost because the ring was full. For
+	// ring buffers this requires ringbuf.ReaderOptions.DropCounter.
+	Lost uint64
+}
+
+// ReaderOptions control the behaviour of a Reader.
+type ReaderOptions struct {
+	// The size of the ring of each CPU in bytes for perf event arrays,
+	// rounded up to a power of two pages. Defaults to one page. The size of
+	// ring buffers is fixed when creating the map.
+	PerCPUBuffer int
+	// Options for perf event arrays.
+	Perf perf.ReaderOptions
+	// Options for ring buffers.
+	Ringbuf ringbuf.ReaderOptions
+}
+
+// Reader reads records from a PerfEventArray or RingBuf map.
+type Reader struct {
+	perf *perf.Reader
+	ring *ringbuf.Reader
+}
+
+// NewReader creates a Reader for m, which must be a PerfEventArray or a
+// RingBuf.
+func NewReader(m *ebpf.Map) (*Reader, error) {
+	return NewReaderWithOptions(m, ReaderOptions{})
+}
+
+// NewReaderWithOptions creates a Reader for m with the given options.
+func NewReaderWithOptions(m *ebpf.Map, opts ReaderOptions) (*Reader, error) {
+	switch m.Type() {
+	case ebpf.PerfEventArray:
+		perCPUBuffer := opts.PerCPUBuffer
+		if perCPUBuffer == 0 {
+			perCPUBuffer = os.Getpagesize()
+		}
+
+		rd, err := perf.NewReaderWithOptions(m, perCPUBuffer, opts.Perf, perf.ExtraPerfOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{perf: rd}, nil
+
+	case ebpf.RingBuf:
+		rd, err := ringbuf.NewReaderWithOptions(m, opts.Ringbuf)
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{ring: rd}, nil
+
+	default:
+		return nil, fmt.Errorf("events: unsupported map type %s", m.Type())
+	}
+}
+
+// Perf returns the underlying perf reader, or nil if the Reader reads from
+// a ring buffer.
+func (r *Reader) Perf() *perf.Reader {
+	return r.perf
+}
+
+// Ringbuf returns the underlying ring buffer reader, or nil if the Reader
+// reads from a perf event array.
+func (r *Reader) Ringbuf() *ringbuf.Reader {
+	return r.ring
+}
+
+// Close frees resources used by the Reader and interrupts calls to Read.
+func (r *Reader) Close() error {
+	if r.perf != nil {
+		return r.perf.Close()
+	}
+	return r.ring.Close()
+}
+
+// SetDeadline controls how long Read and ReadInto will block waiting for
+// records.
+//
+// Passing a zero time.Time will remove the deadline.
+func (r *Reader) SetDeadline(t time.Time) {
+	if r.perf != nil {
+		r.perf.SetDeadline(t)
+	} else {
+		r.ring.SetDeadline(t)
+	}
+}
+
+// Read the next record.
+//
+// Returns ErrClosed if Close is called on the Reader, or
+// os.ErrDeadlineExceeded if a deadline was set.
+func (r *Reader) Read() (Record, error) {
+	var rec Record
+	return rec, r.ReadInto(&rec)
+}
+
+// ReadInto is like Read except that it allows reusing Record and associated
+// buffers.
+func (r *Reader) ReadInto(rec *Record) error {
+	if r.perf != nil {
+		prec := perf.Record{RawSample: rec.RawSample}
+		err := r.perf.ReadInto(&prec)
+		rec.CPU = prec.CPU
+		rec.RawSample = perfSample(prec.RawSample)
+		rec.LostSamples = prec.LostSamples
+		return err
+	}
+
+	rrec := ringbuf.Record{RawSample: rec.RawSample}
+	err := r.ring.ReadInto(&rrec)
+	rec.CPU = -1
+	rec.RawSample = rrec.RawSample
+	rec.LostSamples = 0
+	return err
+}
+
+// Pause stops BPF programs from writing to the rings until Resume is called.
+// Writes fail with -ENOENT in the meantime.
+//
+// Returns an error wrapping ebpf.ErrNotSupported for ring buffers, which BPF
+// programs can always write to.
+func (r *Reader) Pause() error {
+	if r.ring != nil {
+		return fmt.Errorf("pause ring buffer: %w", ebpf.ErrNotSupported)
+	}
+	return r.perf.Pause()
+}
+
+// Resume allows BPF programs to write to the rings again after Pause.
+//
+// Returns an error wrapping ebpf.ErrNotSupported for ring buffers.
+func (r *Reader) Resume() error {
+	if r.ring != nil {
+		return fmt.Errorf("resume ring buffer: %w", ebpf.ErrNotSupported)
+	}
+	return r.perf.Resume()
+}
+
+// Stats returns the current fill level of the rings.
+func (r *Reader) Stats() (Stats, error) {
+	if r.ring != nil {
+		stats, err := r.ring.Stats()
+		if err != nil {
+			return Stats{}, err
+		}
+		return Stats{
+			Size:          stats.Size,
+			AvailableData: stats.AvailableData,
+			Lost:          stats.ReserveFailures,
+		}, nil
+	}
+
+	cpus, err := internal.PossibleCPUs()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{Lost: r.perf.LostSamples()}
+	for cpu := 0; cpu < cpus; cpu++ {
+		meta, err := r.perf.RingMeta(cpu)
+		if errors.Is(err, perf.ErrClosed) {
+			return Stats{}, err
+		}
+		if err != nil {
+			// The CPU is offline.
+			continue
+		}
+
+		head := atomic.LoadUint64(&meta.Data_head)
+		tail := atomic.LoadUint64(&meta.Data_tail)
+		stats.Size += int(meta.Data_size)
+		stats.AvailableData += int(head - tail)
+	}
+
+	return stats, nil
+}
+
+// perfSample strips the size of a PERF_SAMPLE_RAW field.
+func perfSample(raw []byte) []byte {
+	if len(raw) < 4 {
+		return raw[:0]
+	}
+
+	size := int(internal.NativeEndian.Uint32(raw))
+	if size > len(raw)-4 {
+		size = len(raw) - 4
+	}
+	return raw[4 : 4+size]
+}
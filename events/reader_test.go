@@ -0,0 +1,140 @@
+package events
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/testutils/fdtrace"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMain(m *testing.M) {
+	fdtrace.TestMain(m)
+}
+
+func TestReader(t *testing.T) {
+	for _, typ := range []ebpf.MapType{ebpf.PerfEventArray, ebpf.RingBuf} {
+		t.Run(typ.String(), func(t *testing.T) {
+			if typ == ebpf.RingBuf {
+				testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+			}
+
+			prog, events := outputSampleProg(t, typ)
+
+			rd, err := NewReader(events)
+			qt.Assert(t, err, qt.IsNil)
+			defer rd.Close()
+
+			_, _, err = prog.Test(internal.EmptyBPFContext)
+			qt.Assert(t, err, qt.IsNil)
+
+			stats, err := rd.Stats()
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, stats.AvailableData > 0, qt.IsTrue)
+			qt.Assert(t, stats.Size >= stats.AvailableData, qt.IsTrue)
+
+			rd.SetDeadline(time.Now().Add(time.Second))
+			rec, err := rd.Read()
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, rec.RawSample[:8], qt.DeepEquals, []byte{1, 2, 3, 4, 0, 0, 0, 0})
+			if typ == ebpf.RingBuf {
+				qt.Assert(t, rec.CPU, qt.Equals, -1)
+			} else {
+				qt.Assert(t, rec.CPU >= 0, qt.IsTrue)
+			}
+
+			stats, err = rd.Stats()
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, stats.AvailableData, qt.Equals, 0)
+
+			err = rd.Pause()
+			if typ == ebpf.RingBuf {
+				qt.Assert(t, errors.Is(err, ebpf.ErrNotSupported), qt.IsTrue)
+			} else {
+				qt.Assert(t, err, qt.IsNil)
+				qt.Assert(t, rd.Resume(), qt.IsNil)
+			}
+
+			rd.SetDeadline(time.Now().Add(10 * time.Millisecond))
+			_, err = rd.Read()
+			qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue)
+
+			qt.Assert(t, rd.Close(), qt.IsNil)
+			_, err = rd.Read()
+			qt.Assert(t, errors.Is(err, ErrClosed), qt.IsTrue)
+		})
+	}
+}
+
+func TestReaderUnsupportedMap(t *testing.T) {
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	_, err = NewReader(m)
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+// outputSampleProg returns a program which writes the sample 1, 2, 3, 4 to
+// a map of the given type.
+func outputSampleProg(tb testing.TB, typ ebpf.MapType) (*ebpf.Program, *ebpf.Map) {
+	tb.Helper()
+
+	spec := &ebpf.MapSpec{Type: typ}
+	if typ == ebpf.RingBuf {
+		spec.MaxEntries = 4096
+	}
+	events, err := ebpf.NewMap(spec)
+	qt.Assert(tb, err, qt.IsNil)
+	tb.Cleanup(func() { events.Close() })
+
+	insns := asm.Instructions{
+		asm.StoreImm(asm.RFP, -8, 0x04030201, asm.Word),
+		asm.StoreImm(asm.RFP, -4, 0, asm.Word),
+	}
+	if typ == ebpf.RingBuf {
+		insns = append(insns,
+			asm.LoadMapPtr(asm.R1, events.FD()),
+			asm.Mov.Reg(asm.R2, asm.RFP),
+			asm.Add.Imm(asm.R2, -8),
+			asm.Mov.Imm(asm.R3, 8),
+			asm.Mov.Imm(asm.R4, 0),
+			asm.FnRingbufOutput.Call(),
+		)
+	} else {
+		insns = append(insns,
+			asm.LoadMapPtr(asm.R2, events.FD()),
+			// BPF_F_CURRENT_CPU
+			asm.LoadImm(asm.R3, 0xffffffff, asm.DWord),
+			asm.Mov.Reg(asm.R4, asm.RFP),
+			asm.Add.Imm(asm.R4, -8),
+			asm.Mov.Imm(asm.R5, 8),
+			asm.FnPerfEventOutput.Call(),
+		)
+	}
+	insns = append(insns,
+		asm.Mov.Imm(asm.R0, 0),
+		asm.Return(),
+	)
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		License:      "GPL",
+		Type:         ebpf.XDP,
+		Instructions: insns,
+	})
+	qt.Assert(tb, err, qt.IsNil)
+	tb.Cleanup(func() { prog.Close() })
+
+	return prog, events
+}
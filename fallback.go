@@ -0,0 +1,125 @@
+package ebpf
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// ProgramFallback is a set of features which are removed from a program if
+// the kernel refuses to load it otherwise.
+type ProgramFallback uint32
+
+const (
+	// FallbackName loads the program without a name.
+	FallbackName ProgramFallback = 1 << iota
+	// FallbackLineInfo loads the program without BTF line info. Verifier
+	// logs won't contain source code.
+	FallbackLineInfo
+	// FallbackFuncInfo loads the program without BTF function info and
+	// line info. Programs which require BTF, like those calling global
+	// functions, fail to load.
+	FallbackFuncInfo
+	// FallbackXDPFrags loads an XDP program without BPF_F_XDP_HAS_FRAGS.
+	// The program then drops frames which don't fit into a single buffer.
+	FallbackXDPFrags
+
+	// AllFallbacks enables every fallback.
+	AllFallbacks = FallbackName | FallbackLineInfo | FallbackFuncInfo | FallbackXDPFrags
+)
+
+// The order in which fallbacks are tried, from least to most intrusive.
+var fallbackLadder = []struct {
+	fallback ProgramFallback
+	name     string
+	// apply removes the feature from attr. Returns false if attr doesn't
+	// use the feature.
+	apply func(attr *sys.ProgLoadAttr) bool
+}{
+	{FallbackName, "name", func(attr *sys.ProgLoadAttr) bool {
+		if attr.ProgName == (sys.ObjName{}) {
+			return false
+		}
+		attr.ProgName = sys.ObjName{}
+		return true
+	}},
+	{FallbackLineInfo, "line info", func(attr *sys.ProgLoadAttr) bool {
+		if attr.LineInfoCnt == 0 {
+			return false
+		}
+		attr.LineInfoRecSize = 0
+		attr.LineInfoCnt = 0
+		attr.LineInfo = sys.Pointer{}
+		return true
+	}},
+	{FallbackFuncInfo, "func info", func(attr *sys.ProgLoadAttr) bool {
+		if attr.ProgBtfFd == 0 {
+			return false
+		}
+		attr.ProgBtfFd = 0
+		attr.FuncInfoRecSize = 0
+		attr.FuncInfoCnt = 0
+		attr.FuncInfo = sys.Pointer{}
+		attr.LineInfoRecSize = 0
+		attr.LineInfoCnt = 0
+		attr.LineInfo = sys.Pointer{}
+		return true
+	}},
+	{FallbackXDPFrags, "xdp frags", func(attr *sys.ProgLoadAttr) bool {
+		if attr.ProgFlags&unix.BPF_F_XDP_HAS_FRAGS == 0 {
+			return false
+		}
+		attr.ProgFlags &^= unix.BPF_F_XDP_HAS_FRAGS
+		return true
+	}},
+}
+
+func (pf ProgramFallback) String() string {
+	if pf == 0 {
+		return "none"
+	}
+
+	var names []string
+	for _, step := range fallbackLadder {
+		if pf&step.fallback != 0 {
+			names = append(names, step.name)
+			pf &^= step.fallback
+		}
+	}
+	if pf != 0 {
+		names = append(names, "unknown")
+	}
+	return strings.Join(names, ", ")
+}
+
+// loadWithFallbacks retries loading a program which was rejected with err,
+// removing one feature from attr after the other.
+//
+// Returns the fallbacks which were applied if the program loaded
+// successfully. attr is left unmodified otherwise.
+func loadWithFallbacks(attr *sys.ProgLoadAttr, allowed ProgramFallback, err error) (*sys.FD, ProgramFallback, error) {
+	// Unknown fields and flags are rejected with these errors. Other errors
+	// most likely come from the verifier.
+	if !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.E2BIG) {
+		return nil, 0, err
+	}
+
+	orig := *attr
+	var applied ProgramFallback
+	for _, step := range fallbackLadder {
+		if allowed&step.fallback == 0 || !step.apply(attr) {
+			continue
+		}
+		applied |= step.fallback
+
+		fd, err := sys.ProgLoad(attr)
+		if err == nil {
+			return fd, applied, nil
+		}
+	}
+
+	*attr = orig
+	return nil, 0, err
+}
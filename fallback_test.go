@@ -0,0 +1,73 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestProgramFallbacks(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.15", "object names")
+
+	spec := socketFilterSpec.Copy()
+	// The kernel rejects names containing a dash with EINVAL.
+	spec.Name = "invalid-name"
+
+	_, err := NewProgram(spec)
+	qt.Assert(t, errors.Is(err, unix.EINVAL), qt.IsTrue, qt.Commentf("got %v", err))
+
+	prog, err := NewProgramWithOptions(spec, ProgramOptions{
+		Fallbacks: AllFallbacks,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer prog.Close()
+	qt.Assert(t, prog.Fallbacks(), qt.Equals, FallbackName)
+
+	clone, err := prog.Clone()
+	qt.Assert(t, err, qt.IsNil)
+	defer clone.Close()
+	qt.Assert(t, clone.Fallbacks(), qt.Equals, FallbackName)
+
+	// Fallbacks which aren't allowed are never applied.
+	_, err = NewProgramWithOptions(spec, ProgramOptions{
+		Fallbacks: AllFallbacks &^ FallbackName,
+	})
+	qt.Assert(t, errors.Is(err, unix.EINVAL), qt.IsTrue, qt.Commentf("got %v", err))
+
+	prog, err = NewProgramWithOptions(socketFilterSpec, ProgramOptions{
+		Fallbacks: AllFallbacks,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer prog.Close()
+	qt.Assert(t, prog.Fallbacks(), qt.Equals, ProgramFallback(0))
+}
+
+func TestLoadWithFallbacksRestoresAttr(t *testing.T) {
+	attr := &sys.ProgLoadAttr{
+		ProgName:  sys.NewObjName("foo"),
+		ProgFlags: unix.BPF_F_XDP_HAS_FRAGS,
+	}
+	orig := *attr
+
+	// The program is missing instructions, so every attempt fails.
+	_, applied, err := loadWithFallbacks(attr, AllFallbacks, unix.EINVAL)
+	qt.Assert(t, err, qt.IsNotNil)
+	qt.Assert(t, applied, qt.Equals, ProgramFallback(0))
+	qt.Assert(t, *attr, qt.Equals, orig)
+
+	// Errors from the verifier don't trigger fallbacks.
+	_, _, err = loadWithFallbacks(attr, AllFallbacks, unix.EACCES)
+	qt.Assert(t, errors.Is(err, unix.EACCES), qt.IsTrue)
+}
+
+func TestProgramFallbackString(t *testing.T) {
+	qt.Assert(t, ProgramFallback(0).String(), qt.Equals, "none")
+	qt.Assert(t, FallbackName.String(), qt.Equals, "name")
+	qt.Assert(t, (FallbackLineInfo | FallbackXDPFrags).String(), qt.Equals, "line info, xdp frags")
+	qt.Assert(t, (FallbackName | 1<<31).String(), qt.Equals, "name, unknown")
+}
@@ -213,6 +213,21 @@ var haveMapTypeMatrix = internal.FeatureMatrix[ebpf.MapType]{
 		Version: "5.11",
 		Fn:      func() error { return probeStorageMap(sys.BPF_MAP_TYPE_TASK_STORAGE) },
 	},
+	ebpf.Arena: {
+		Version: "6.9",
+		Fn: func() error {
+			// keySize and valueSize need to be 0
+			// maxEntries is the number of pages
+			// BPF_F_MMAPABLE needs to be set
+			return createMap(&sys.MapCreateAttr{
+				MapType:    sys.BPF_MAP_TYPE_ARENA,
+				KeySize:    0,
+				ValueSize:  0,
+				MaxEntries: 1,
+				MapFlags:   unix.BPF_F_MMAPABLE,
+			})
+		},
+	},
 }
 
 func init() {
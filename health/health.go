@@ -0,0 +1,374 @@
+// Package health aggregates the state of readers, links and maps into a
+// snapshot suitable for serving from the health endpoint of an agent.
+package health
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+// Options control the thresholds of a Checker.
+type Options struct {
+	// The fraction of MaxEntries above which a map is unhealthy. Defaults
+	// to 0.9.
+	MapUsage float64
+	// The fraction of a ring filled with unread data above which it is
+	// unhealthy. For perf readers the fullest ring counts. Defaults to 0.9.
+	RingUsage float64
+	// The number of lost events per second above which a ring is unhealthy.
+	// Defaults to zero, which means that any loss since the previous
+	// snapshot is unhealthy.
+	MaxLostRate float64
+}
+
+// Checker takes health snapshots of registered objects.
+//
+// The Checker doesn't own the objects: they must be removed before being
+// closed, unless the snapshot should report them as closed. It is safe for
+// concurrent use.
+type Checker struct {
+	opts Options
+
+	mu    sync.Mutex
+	rings []*ringSource
+	links []namedLink
+	maps  []namedMap
+}
+
+type ringSource struct {
+	name string
+	perf *perf.Reader
+	ring *ringbuf.Reader
+
+	// The number of lost events when the first snapshot was taken, and
+	// when the previous snapshot was taken.
+	initialized    bool
+	baseLost       uint64
+	prevLost       uint64
+	prevSnapshotAt time.Time
+}
+
+type namedLink struct {
+	name string
+	link link.Link
+}
+
+type namedMap struct {
+	name string
+	m    *ebpf.Map
+}
+
+// NewChecker creates a Checker with the given thresholds.
+func NewChecker(opts Options) *Checker {
+	if opts.MapUsage <= 0 {
+		opts.MapUsage = 0.9
+	}
+	if opts.RingUsage <= 0 {
+		opts.RingUsage = 0.9
+	}
+	return &Checker{opts: opts}
+}
+
+// AddPerfReader registers a perf reader under name.
+func (c *Checker) AddPerfReader(name string, rd *perf.Reader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rings = append(c.rings, &ringSource{name: name, perf: rd})
+}
+
+// AddRingbufReader registers a ringbuf reader under name. Losses are only
+// reported if the reader was created with ringbuf.ReaderOptions.DropCounter.
+func (c *Checker) AddRingbufReader(name string, rd *ringbuf.Reader) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.rings = append(c.rings, &ringSource{name: name, ring: rd})
+}
+
+// AddLink registers a link under name.
+func (c *Checker) AddLink(name string, l link.Link) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.links = append(c.links, namedLink{name, l})
+}
+
+// AddMap registers a map under name. Only maps with a variable number of
+// entries, like hash maps, can exceed their capacity.
+func (c *Checker) AddMap(name string, m *ebpf.Map) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maps = append(c.maps, namedMap{name, m})
+}
+
+// Remove unregisters all objects with the given name.
+func (c *Checker) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rings := c.rings[:0]
+	for _, r := range c.rings {
+		if r.name != name {
+			rings = append(rings, r)
+		}
+	}
+	c.rings = rings
+
+	links := c.links[:0]
+	for _, l := range c.links {
+		if l.name != name {
+			links = append(links, l)
+		}
+	}
+	c.links = links
+
+	maps := c.maps[:0]
+	for _, m := range c.maps {
+		if m.name != name {
+			maps = append(maps, m)
+		}
+	}
+	c.maps = maps
+}
+
+// Snapshot describes the health of all registered objects at a point in time.
+type Snapshot struct {
+	Time  time.Time    `json:"time"`
+	Rings []RingStatus `json:"rings"`
+	Links []LinkStatus `json:"links"`
+	Maps  []MapStatus  `json:"maps"`
+}
+
+// Live returns false if a reader was closed, which means that the agent
+// stopped processing events.
+func (s *Snapshot) Live() bool {
+	for _, r := range s.Rings {
+		if r.Closed {
+			return false
+		}
+	}
+	return true
+}
+
+// Ready returns true if all objects are healthy.
+func (s *Snapshot) Ready() bool {
+	for _, r := range s.Rings {
+		if r.Problem != "" {
+			return false
+		}
+	}
+	for _, l := range s.Links {
+		if l.Problem != "" {
+			return false
+		}
+	}
+	for _, m := range s.Maps {
+		if m.Problem != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// RingStatus is the state of a perf or ringbuf reader.
+type RingStatus struct {
+	Name   string `json:"name"`
+	Closed bool   `json:"closed"`
+	// The fraction of the ring filled with unread data.
+	Usage float64 `json:"usage"`
+	// The number of lost events since the first snapshot, and their rate
+	// since the previous snapshot.
+	Lost     uint64  `json:"lost"`
+	LostRate float64 `json:"lostRate"`
+	// Problem is empty if the ring is healthy.
+	Problem string `json:"problem,omitempty"`
+}
+
+// LinkStatus is the state of a link.
+type LinkStatus struct {
+	Name     string         `json:"name"`
+	Attached bool           `json:"attached"`
+	Program  ebpf.ProgramID `json:"program"`
+	Problem  string         `json:"problem,omitempty"`
+}
+
+// MapStatus is the state of a map.
+type MapStatus struct {
+	Name       string  `json:"name"`
+	Entries    uint32  `json:"entries"`
+	MaxEntries uint32  `json:"maxEntries"`
+	Usage      float64 `json:"usage"`
+	Problem    string  `json:"problem,omitempty"`
+}
+
+// Snapshot queries the state of all registered objects.
+//
+// Counting the entries of maps iterates their keys, which is expensive for
+// large maps.
+func (c *Checker) Snapshot() *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	s := &Snapshot{Time: now}
+
+	for _, r := range c.rings {
+		s.Rings = append(s.Rings, c.ringStatus(r, now))
+	}
+	for _, l := range c.links {
+		s.Links = append(s.Links, linkStatus(l))
+	}
+	for _, m := range c.maps {
+		s.Maps = append(s.Maps, c.mapStatus(m))
+	}
+
+	return s
+}
+
+func (c *Checker) ringStatus(r *ringSource, now time.Time) RingStatus {
+	status := RingStatus{Name: r.name}
+
+	var (
+		lost uint64
+		err  error
+	)
+	if r.perf != nil {
+		status.Usage, err = perfUsage(r.perf)
+		lost = r.perf.LostSamples()
+	} else {
+		var stats ringbuf.Stats
+		stats, err = r.ring.Stats()
+		if err == nil {
+			status.Usage = float64(stats.AvailableData) / float64(stats.Size)
+		}
+		lost = stats.ReserveFailures
+	}
+
+	if errors.Is(err, perf.ErrClosed) || errors.Is(err, ringbuf.ErrClosed) {
+		status.Closed = true
+		status.Problem = "reader closed"
+		return status
+	}
+	if err != nil {
+		status.Problem = err.Error()
+		return status
+	}
+
+	if !r.initialized {
+		// Only report losses which happened after the first snapshot.
+		r.initialized = true
+		r.baseLost, r.prevLost, r.prevSnapshotAt = lost, lost, now
+	}
+
+	status.Lost = lost - r.baseLost
+	if elapsed := now.Sub(r.prevSnapshotAt).Seconds(); elapsed > 0 && lost > r.prevLost {
+		status.LostRate = float64(lost-r.prevLost) / elapsed
+	}
+	r.prevLost, r.prevSnapshotAt = lost, now
+
+	switch {
+	case status.Usage > c.opts.RingUsage:
+		status.Problem = fmt.Sprintf("ring is %.0f%% full", status.Usage*100)
+	case status.LostRate > c.opts.MaxLostRate:
+		status.Problem = fmt.Sprintf("losing %.1f events per second", status.LostRate)
+	}
+
+	return status
+}
+
+// perfUsage returns the fill level of the fullest ring of rd.
+func perfUsage(rd *perf.Reader) (float64, error) {
+	cpus, err := internal.PossibleCPUs()
+	if err != nil {
+		return 0, err
+	}
+
+	var usage float64
+	for cpu := 0; cpu < cpus; cpu++ {
+		meta, err := rd.RingMeta(cpu)
+		if errors.Is(err, perf.ErrClosed) {
+			return 0, err
+		}
+		if err != nil {
+			// The CPU is offline.
+			continue
+		}
+
+		head := atomic.LoadUint64(&meta.Data_head)
+		tail := atomic.LoadUint64(&meta.Data_tail)
+		if meta.Data_size == 0 {
+			continue
+		}
+		if u := float64(head-tail) / float64(meta.Data_size); u > usage {
+			usage = u
+		}
+	}
+	return usage, nil
+}
+
+func linkStatus(l namedLink) LinkStatus {
+	status := LinkStatus{Name: l.name}
+
+	info, err := l.link.Info()
+	if errors.Is(err, link.ErrNotSupported) {
+		// The link can't be queried, assume it's still attached.
+		status.Attached = true
+		return status
+	}
+	if err != nil {
+		status.Problem = fmt.Sprintf("query link: %s", err)
+		return status
+	}
+
+	status.Program = info.Program
+	status.Attached = info.Program != 0
+	if !status.Attached {
+		status.Problem = "no program attached"
+	}
+	return status
+}
+
+func (c *Checker) mapStatus(m namedMap) MapStatus {
+	status := MapStatus{Name: m.name, MaxEntries: m.m.MaxEntries()}
+
+	switch m.m.Type() {
+	case ebpf.Hash, ebpf.PerCPUHash, ebpf.LRUHash, ebpf.LRUCPUHash, ebpf.LPMTrie, ebpf.HashOfMaps:
+	default:
+		// The number of entries is fixed.
+		status.Entries = status.MaxEntries
+		return status
+	}
+
+	var key interface{}
+	for status.Entries < status.MaxEntries {
+		next, err := m.m.NextKeyBytes(key)
+		if err != nil {
+			status.Problem = fmt.Sprintf("count entries: %s", err)
+			return status
+		}
+		if next == nil {
+			break
+		}
+		status.Entries++
+		key = next
+	}
+
+	if status.MaxEntries > 0 {
+		status.Usage = float64(status.Entries) / float64(status.MaxEntries)
+	}
+	if status.Usage > c.opts.MapUsage {
+		status.Problem = fmt.Sprintf("map is %.0f%% full", status.Usage*100)
+	}
+	return status
+}
@@ -0,0 +1,166 @@
+package health
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCheckerMaps(t *testing.T) {
+	hash := mustNewMap(t, &ebpf.MapSpec{
+		Type:       ebpf.Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+	array := mustNewMap(t, &ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 10,
+	})
+
+	c := NewChecker(Options{})
+	c.AddMap("hash", hash)
+	c.AddMap("array", array)
+
+	qt.Assert(t, hash.Put(uint32(1), uint32(1)), qt.IsNil)
+
+	s := c.Snapshot()
+	qt.Assert(t, s.Ready(), qt.IsTrue)
+	qt.Assert(t, s.Maps, qt.DeepEquals, []MapStatus{
+		{Name: "hash", Entries: 1, MaxEntries: 10, Usage: 0.1},
+		{Name: "array", Entries: 10, MaxEntries: 10},
+	})
+
+	for i := uint32(2); i <= 10; i++ {
+		qt.Assert(t, hash.Put(i, i), qt.IsNil)
+	}
+
+	s = c.Snapshot()
+	qt.Assert(t, s.Ready(), qt.IsFalse)
+	qt.Assert(t, s.Maps[0].Entries, qt.Equals, uint32(10))
+	qt.Assert(t, s.Maps[0].Problem, qt.Not(qt.Equals), "")
+
+	c.Remove("hash")
+	qt.Assert(t, c.Snapshot().Ready(), qt.IsTrue)
+}
+
+func TestCheckerRingbuf(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	events := mustNewMap(t, &ebpf.MapSpec{
+		Type:       ebpf.RingBuf,
+		MaxEntries: 4096,
+	})
+	counter := mustNewMap(t, &ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, counter.Put(uint32(0), uint64(5)), qt.IsNil)
+
+	rd, err := ringbuf.NewReaderWithOptions(events, ringbuf.ReaderOptions{DropCounter: counter})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	c := NewChecker(Options{})
+	c.AddRingbufReader("events", rd)
+
+	// Losses before the first snapshot are ignored.
+	s := c.Snapshot()
+	qt.Assert(t, s.Live(), qt.IsTrue)
+	qt.Assert(t, s.Ready(), qt.IsTrue)
+	qt.Assert(t, s.Rings[0].Lost, qt.Equals, uint64(0))
+
+	qt.Assert(t, counter.Put(uint32(0), uint64(7)), qt.IsNil)
+	s = c.Snapshot()
+	qt.Assert(t, s.Ready(), qt.IsFalse)
+	qt.Assert(t, s.Rings[0].Lost, qt.Equals, uint64(2))
+	qt.Assert(t, s.Rings[0].LostRate > 0, qt.IsTrue)
+
+	s = c.Snapshot()
+	qt.Assert(t, s.Ready(), qt.IsTrue)
+	qt.Assert(t, s.Rings[0].Lost, qt.Equals, uint64(2))
+
+	qt.Assert(t, rd.Close(), qt.IsNil)
+	s = c.Snapshot()
+	qt.Assert(t, s.Live(), qt.IsFalse)
+	qt.Assert(t, s.Rings[0].Closed, qt.IsTrue)
+
+	_, err = json.Marshal(s)
+	qt.Assert(t, err, qt.IsNil)
+}
+
+func TestCheckerPerf(t *testing.T) {
+	events := mustNewMap(t, &ebpf.MapSpec{
+		Type: ebpf.PerfEventArray,
+	})
+
+	rd, err := perf.NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	c := NewChecker(Options{})
+	c.AddPerfReader("events", rd)
+
+	s := c.Snapshot()
+	qt.Assert(t, s.Ready(), qt.IsTrue)
+	qt.Assert(t, s.Rings[0].Usage, qt.Equals, 0.0)
+
+	qt.Assert(t, rd.Close(), qt.IsNil)
+	qt.Assert(t, c.Snapshot().Live(), qt.IsFalse)
+}
+
+func TestCheckerLink(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "BPF_LINK_TYPE_XDP")
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:    ebpf.XDP,
+		License: "MIT",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 2),
+			asm.Return(),
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer prog.Close()
+
+	l, err := link.AttachXDP(link.XDPOptions{Program: prog, Interface: 1})
+	qt.Assert(t, err, qt.IsNil)
+	defer l.Close()
+
+	info, err := prog.Info()
+	qt.Assert(t, err, qt.IsNil)
+	id, _ := info.ID()
+
+	c := NewChecker(Options{})
+	c.AddLink("xdp", l)
+
+	s := c.Snapshot()
+	qt.Assert(t, s.Ready(), qt.IsTrue)
+	qt.Assert(t, s.Links, qt.DeepEquals, []LinkStatus{
+		{Name: "xdp", Attached: true, Program: id},
+	})
+
+	qt.Assert(t, l.Close(), qt.IsNil)
+	qt.Assert(t, c.Snapshot().Ready(), qt.IsFalse)
+}
+
+func mustNewMap(tb testing.TB, spec *ebpf.MapSpec) *ebpf.Map {
+	tb.Helper()
+
+	m, err := ebpf.NewMap(spec)
+	qt.Assert(tb, err, qt.IsNil)
+	tb.Cleanup(func() { m.Close() })
+	return m
+}
@@ -30,6 +30,10 @@ type MapInfo struct {
 	Flags      uint32
 	// Name as supplied by user space at load time. Available from 4.15.
 	Name string
+
+	btf      btf.ID
+	memlock  uint64
+	mapExtra uint64
 }
 
 func newMapInfoFromFd(fd *sys.FD) (*MapInfo, error) {
@@ -50,6 +54,9 @@ func newMapInfoFromFd(fd *sys.FD) (*MapInfo, error) {
 		info.MaxEntries,
 		uint32(info.MapFlags),
 		unix.ByteSliceToString(info.Name[:]),
+		btf.ID(info.BtfId),
+		readMemlock(fd),
+		info.MapExtra,
 	}, nil
 }
 
@@ -65,6 +72,7 @@ func newMapInfoFromProc(fd *sys.FD) (*MapInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	mi.memlock = readMemlock(fd)
 	return &mi, nil
 }
 
@@ -77,12 +85,35 @@ func (mi *MapInfo) ID() (MapID, bool) {
 	return mi.id, mi.id > 0
 }
 
+// BTFID returns the BTF ID associated with the map.
+//
+// The ID is only valid as long as the associated map is kept alive.
+// Available from 4.18.
+//
+// The bool return value indicates whether this optional field is available and
+// populated. (The field may be available but not populated if the kernel
+// supports the field but the map was created without BTF information.)
+func (mi *MapInfo) BTFID() (btf.ID, bool) {
+	return mi.btf, mi.btf > 0
+}
+
+// Memlock returns the number of bytes of memory charged to the map.
+//
+// Available from 4.10.
+//
+// The bool return value indicates whether this optional field is available.
+func (mi *MapInfo) Memlock() (uint64, bool) {
+	return mi.memlock, mi.memlock > 0
+}
+
 // programStats holds statistics of a program.
 type programStats struct {
 	// Total accumulated runtime of the program ins ns.
 	runtime time.Duration
 	// Total number of times the program was called.
 	runCount uint64
+	// Total number of times the program was not called due to recursion.
+	recursionMisses uint64
 }
 
 // ProgramInfo describes a program.
@@ -98,6 +129,10 @@ type ProgramInfo struct {
 	haveCreatedByUID bool
 	btf              btf.ID
 	stats            *programStats
+	loadTime         time.Duration
+	memlock          uint64
+	jitedSize        uint32
+	xlatedSize       uint32
 
 	maps  []MapID
 	insns []byte
@@ -120,9 +155,14 @@ func newProgramInfoFromFd(fd *sys.FD) (*ProgramInfo, error) {
 		Name: unix.ByteSliceToString(info.Name[:]),
 		btf:  btf.ID(info.BtfId),
 		stats: &programStats{
-			runtime:  time.Duration(info.RunTimeNs),
-			runCount: info.RunCnt,
+			runtime:         time.Duration(info.RunTimeNs),
+			runCount:        info.RunCnt,
+			recursionMisses: info.RecursionMisses,
 		},
+		loadTime:   time.Duration(info.LoadTime),
+		memlock:    readMemlock(fd),
+		jitedSize:  info.JitedProgLen,
+		xlatedSize: info.XlatedProgLen,
 	}
 
 	// Start with a clean struct for the second call, otherwise we may get EFAULT.
@@ -176,6 +216,7 @@ func newProgramInfoFromProc(fd *sys.FD) (*ProgramInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	info.memlock = readMemlock(fd)
 
 	return &info, nil
 }
@@ -232,6 +273,60 @@ func (pi *ProgramInfo) Runtime() (time.Duration, bool) {
 	return time.Duration(0), false
 }
 
+// RecursionMisses returns the total number of times the program was not
+// called because it was already running on the same CPU, for example when a
+// tracing program triggers its own hook.
+//
+// Available from 5.12.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) RecursionMisses() (uint64, bool) {
+	if pi.stats != nil {
+		return pi.stats.recursionMisses, true
+	}
+	return 0, false
+}
+
+// LoadTime returns when the program was loaded, relative to boot time.
+//
+// Available from 4.15.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) LoadTime() (time.Duration, bool) {
+	return pi.loadTime, pi.loadTime > 0
+}
+
+// Memlock returns the number of bytes of memory charged to the program.
+//
+// Available from 4.10.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) Memlock() (uint64, bool) {
+	return pi.memlock, pi.memlock > 0
+}
+
+// JitedSize returns the size of the program's JIT-compiled machine code in
+// bytes.
+//
+// Available from 4.13. Requires CAP_BPF or equivalent.
+//
+// The bool return value indicates whether this optional field is available and
+// populated. (The field may be available but not populated if the program
+// wasn't JIT-compiled.)
+func (pi *ProgramInfo) JitedSize() (uint32, bool) {
+	return pi.jitedSize, pi.jitedSize > 0
+}
+
+// TranslatedSize returns the size of the program's 'xlated' instructions in
+// bytes, see Instructions.
+//
+// Available from 4.13. Requires CAP_BPF or equivalent.
+//
+// The bool return value indicates whether this optional field is available.
+func (pi *ProgramInfo) TranslatedSize() (uint32, bool) {
+	return pi.xlatedSize, pi.xlatedSize > 0
+}
+
 // Instructions returns the 'xlated' instruction stream of the program
 // after it has been verified and rewritten by the kernel. These instructions
 // cannot be loaded back into the kernel as-is, this is mainly used for
@@ -287,6 +382,16 @@ func scanFdInfo(fd *sys.FD, fields map[string]interface{}) error {
 	return nil
 }
 
+// readMemlock returns the memlock field of fd's fdinfo, or zero if it isn't
+// available.
+func readMemlock(fd *sys.FD) uint64 {
+	var memlock uint64
+	if err := scanFdInfo(fd, map[string]interface{}{"memlock": &memlock}); err != nil {
+		return 0
+	}
+	return memlock
+}
+
 var errMissingFields = errors.New("missing fields")
 
 func scanFdInfoReader(r io.Reader, fields map[string]interface{}) error {
@@ -332,7 +437,10 @@ func scanFdInfoReader(r io.Reader, fields map[string]interface{}) error {
 // EnableStats starts the measuring of the runtime
 // and run counts of eBPF programs.
 //
-// Collecting statistics can have an impact on the performance.
+// Collecting statistics can have an impact on the performance. Statistics
+// are collected until the returned io.Closer is closed.
+//
+// which is the kind of statistics to collect, for example StatsRunTime.
 //
 // Requires at least 5.8.
 func EnableStats(which uint32) (io.Closer, error) {
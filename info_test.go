@@ -64,6 +64,10 @@ func TestMapInfoFromProc(t *testing.T) {
 		t.Error("Expected ID to not be available")
 	}
 
+	if _, ok := info.Memlock(); !ok && !testutils.IsKernelLessThan(t, "4.10") {
+		t.Error("Expected Memlock to be available")
+	}
+
 	nested, err := NewMap(&MapSpec{
 		Type:       ArrayOfMaps,
 		KeySize:    4,
@@ -131,6 +135,21 @@ func TestProgramInfo(t *testing.T) {
 					qt.Assert(t, uid, qt.Equals, uint32(os.Getuid()))
 				}
 			}
+
+			if !testutils.IsKernelLessThan(t, "4.10") {
+				_, ok := info.Memlock()
+				qt.Assert(t, ok, qt.IsTrue)
+			}
+
+			if name == "generic" && !testutils.IsKernelLessThan(t, "4.15") {
+				loadTime, ok := info.LoadTime()
+				qt.Assert(t, ok, qt.IsTrue)
+				qt.Assert(t, loadTime > 0, qt.IsTrue)
+
+				size, ok := info.TranslatedSize()
+				qt.Assert(t, ok, qt.IsTrue)
+				qt.Assert(t, size%asm.InstructionSize, qt.Equals, uint32(0))
+			}
 		})
 	}
 }
@@ -260,6 +279,14 @@ func TestStats(t *testing.T) {
 		t.Errorf("expected a runtime of 0ns but got %v", rt)
 	}
 
+	rm, ok := pi.RecursionMisses()
+	if !ok {
+		t.Errorf("expected recursion misses info to be available")
+	}
+	if rm != 0 {
+		t.Errorf("expected recursion misses to be 0 but got %d", rm)
+	}
+
 	if err := testStats(prog); err != nil {
 		t.Error(err)
 	}
@@ -291,7 +318,7 @@ func BenchmarkStats(b *testing.B) {
 func testStats(prog *Program) error {
 	in := internal.EmptyBPFContext
 
-	stats, err := EnableStats(uint32(unix.BPF_STATS_RUN_TIME))
+	stats, err := EnableStats(StatsRunTime)
 	if err != nil {
 		return fmt.Errorf("failed to enable stats: %v", err)
 	}
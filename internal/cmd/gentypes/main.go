@@ -105,6 +105,7 @@ import (
 		{"ProgType", "bpf_prog_type"},
 		{"AttachType", "bpf_attach_type"},
 		{"LinkType", "bpf_link_type"},
+		{"PerfEventType", "bpf_perf_event_type"},
 		{"StatsType", "bpf_stats_type"},
 		{"SkAction", "sk_action"},
 		{"StackBuildIdStatus", "bpf_stack_build_id_status"},
@@ -185,6 +186,48 @@ import (
 				replaceWithBytes("extra"),
 			},
 		},
+		{
+			"KprobeLinkInfo", "bpf_link_info",
+			[]patch{
+				replace(enumTypes["LinkType"], "type"),
+				replace(linkID, "id"),
+				choose(3, "perf_event"),
+				modify(func(m *btf.Member) error {
+					return rename("type", "perf_event_type")(m.Type.(*btf.Struct))
+				}, "perf_event"),
+				flattenAnon,
+				replace(enumTypes["PerfEventType"], "perf_event_type"),
+				choose(4, "kprobe"),
+				flattenAnon,
+				replace(pointer, "func_name"),
+			},
+		},
+		{
+			"UprobeLinkInfo", "bpf_link_info",
+			[]patch{
+				replace(enumTypes["LinkType"], "type"),
+				replace(linkID, "id"),
+				choose(3, "perf_event"),
+				modify(func(m *btf.Member) error {
+					return rename("type", "perf_event_type")(m.Type.(*btf.Struct))
+				}, "perf_event"),
+				flattenAnon,
+				replace(enumTypes["PerfEventType"], "perf_event_type"),
+				choose(4, "uprobe"),
+				flattenAnon,
+				replace(pointer, "file_name"),
+			},
+		},
+		{
+			"KprobeMultiLinkInfo", "bpf_link_info",
+			[]patch{
+				replace(enumTypes["LinkType"], "type"),
+				replace(linkID, "id"),
+				choose(3, "kprobe_multi"),
+				flattenAnon,
+				replace(pointer, "addrs", "cookies"),
+			},
+		},
 		{"FuncInfo", "bpf_func_info", nil},
 		{"LineInfo", "bpf_line_info", nil},
 		{"XdpMd", "xdp_md", nil},
@@ -309,11 +352,14 @@ import (
 		},
 		{
 			"ProgAttach", retError, "prog_attach", "BPF_PROG_ATTACH",
-			nil,
+			[]patch{
+				choose(0, "target_fd"),
+				choose(5, "relative_fd"),
+			},
 		},
 		{
 			"ProgDetach", retError, "prog_attach", "BPF_PROG_DETACH",
-			[]patch{truncateAfter("attach_type")},
+			[]patch{choose(0, "target_fd"), truncateAfter("attach_type")},
 		},
 		{
 			"ProgRun", retError, "prog_run", "BPF_PROG_TEST_RUN",
@@ -341,6 +387,14 @@ import (
 				truncateAfter("next_id"),
 			},
 		},
+		{
+			"LinkGetNextId", retError, "obj_next_id", "BPF_LINK_GET_NEXT_ID",
+			[]patch{
+				choose(0, "start_id"), rename("start_id", "id"),
+				replace(linkID, "id", "next_id"),
+				truncateAfter("next_id"),
+			},
+		},
 		// These piggy back on the obj_next_id decl, but only support the
 		// first field...
 		{
@@ -355,6 +409,10 @@ import (
 			"ProgGetFdById", retFd, "obj_next_id", "BPF_PROG_GET_FD_BY_ID",
 			[]patch{choose(0, "start_id"), rename("start_id", "id"), truncateAfter("id")},
 		},
+		{
+			"LinkGetFdById", retFd, "obj_next_id", "BPF_LINK_GET_FD_BY_ID",
+			[]patch{choose(0, "start_id"), rename("start_id", "id"), replace(linkID, "id"), truncateAfter("id")},
+		},
 		{
 			"ObjGetInfoByFd", retError, "info_by_fd", "BPF_OBJ_GET_INFO_BY_FD",
 			[]patch{replace(pointer, "info")},
@@ -370,6 +428,8 @@ import (
 		{
 			"LinkCreate", retFd, "link_create", "BPF_LINK_CREATE",
 			[]patch{
+				choose(0, "prog_fd"),
+				choose(1, "target_fd"),
 				replace(enumTypes["AttachType"], "attach_type"),
 				choose(4, "target_btf_id"),
 				replace(typeID, "target_btf_id"),
@@ -378,6 +438,8 @@ import (
 		{
 			"LinkCreateIter", retFd, "link_create", "BPF_LINK_CREATE",
 			[]patch{
+				choose(0, "prog_fd"),
+				choose(1, "target_fd"),
 				chooseNth(4, 1),
 				replace(enumTypes["AttachType"], "attach_type"),
 				flattenAnon,
@@ -387,6 +449,8 @@ import (
 		{
 			"LinkCreatePerfEvent", retFd, "link_create", "BPF_LINK_CREATE",
 			[]patch{
+				choose(0, "prog_fd"),
+				choose(1, "target_fd"),
 				chooseNth(4, 2),
 				replace(enumTypes["AttachType"], "attach_type"),
 				flattenAnon,
@@ -395,6 +459,8 @@ import (
 		{
 			"LinkCreateKprobeMulti", retFd, "link_create", "BPF_LINK_CREATE",
 			[]patch{
+				choose(0, "prog_fd"),
+				choose(1, "target_fd"),
 				chooseNth(4, 3),
 				replace(enumTypes["AttachType"], "attach_type"),
 				modify(func(m *btf.Member) error {
@@ -410,6 +476,8 @@ import (
 		{
 			"LinkCreateTracing", retFd, "link_create", "BPF_LINK_CREATE",
 			[]patch{
+				choose(0, "prog_fd"),
+				choose(1, "target_fd"),
 				chooseNth(4, 4),
 				replace(enumTypes["AttachType"], "attach_type"),
 				flattenAnon,
@@ -418,7 +486,7 @@ import (
 		},
 		{
 			"LinkUpdate", retError, "link_update", "BPF_LINK_UPDATE",
-			nil,
+			[]patch{choose(1, "new_prog_fd"), choose(3, "old_prog_fd")},
 		},
 		{
 			"EnableStats", retFd, "enable_stats", "BPF_ENABLE_STATS",
@@ -431,11 +499,17 @@ import (
 		{
 			"ProgQuery", retError, "prog_query", "BPF_PROG_QUERY",
 			[]patch{
+				choose(0, "target_fd"),
 				replace(enumTypes["AttachType"], "attach_type"),
 				replace(pointer, "prog_ids"),
+				choose(5, "prog_cnt"),
 				rename("prog_cnt", "prog_count"),
 			},
 		},
+		{
+			"TokenCreate", retFd, "token_create", "BPF_TOKEN_CREATE",
+			nil,
+		},
 	}
 
 	sort.Slice(attrs, func(i, j int) bool {
@@ -452,7 +526,7 @@ import (
 		{"map_elem_batch", "batch"},
 		{"prog_load", "prog_type"},
 		{"obj_pin", "pathname"},
-		{"prog_attach", "target_fd"},
+		{"prog_attach", ""},
 		{"prog_run", "test"},
 		{"obj_next_id", ""},
 		{"info_by_fd", "info"},
@@ -466,6 +540,7 @@ import (
 		{"enable_stats", "enable_stats"},
 		{"iter_create", "iter_create"},
 		{"prog_bind_map", "prog_bind_map"},
+		{"token_create", "token_create"},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("splitting bpf_attr: %w", err)
@@ -1,6 +1,7 @@
 package epoll
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"os"
@@ -12,6 +13,9 @@ import (
 	"github.com/cilium/ebpf/internal/unix"
 )
 
+// ErrInterrupted is returned by Wait if it was unblocked by Interrupt.
+var ErrInterrupted = errors.New("interrupted")
+
 // Poller waits for readiness notifications from multiple file descriptors.
 //
 // The wait can be interrupted by calling Close.
@@ -21,8 +25,9 @@ type Poller struct {
 	epollMu sync.Mutex
 	epollFd int
 
-	eventMu sync.Mutex
-	event   *eventFd
+	eventMu   sync.Mutex
+	event     *eventFd
+	interrupt *eventFd
 }
 
 func New() (*Poller, error) {
@@ -44,6 +49,20 @@ func New() (*Poller, error) {
 		return nil, fmt.Errorf("add eventfd: %w", err)
 	}
 
+	p.interrupt, err = newEventFd()
+	if err != nil {
+		unix.Close(epollFd)
+		p.event.close()
+		return nil, err
+	}
+
+	if err := p.Add(p.interrupt.raw, 0); err != nil {
+		unix.Close(epollFd)
+		p.event.close()
+		p.interrupt.close()
+		return nil, fmt.Errorf("add interrupt eventfd: %w", err)
+	}
+
 	runtime.SetFinalizer(p, (*Poller).Close)
 	return p, nil
 }
@@ -78,6 +97,11 @@ func (p *Poller) Close() error {
 		p.event = nil
 	}
 
+	if p.interrupt != nil {
+		p.interrupt.close()
+		p.interrupt = nil
+	}
+
 	return nil
 }
 
@@ -119,7 +143,8 @@ func (p *Poller) Add(fd int, id int) error {
 // Wait for events.
 //
 // Returns the number of pending events or an error wrapping os.ErrClosed if
-// Close is called, or os.ErrDeadlineExceeded if EpollWait timeout.
+// Close is called, ErrInterrupted if Interrupt is called, or
+// os.ErrDeadlineExceeded if EpollWait timeout.
 func (p *Poller) Wait(events []unix.EpollEvent, deadline time.Time) (int, error) {
 	p.epollMu.Lock()
 	defer p.epollMu.Unlock()
@@ -165,10 +190,38 @@ func (p *Poller) Wait(events []unix.EpollEvent, deadline time.Time) (int, error)
 			}
 		}
 
+		for _, event := range events[:n] {
+			if int(event.Fd) == p.interrupt.raw {
+				// Consume the interrupt so that it only affects a single call
+				// to Wait. Other pending events will be returned by the next
+				// call.
+				if _, err := p.interrupt.read(); err != nil {
+					return 0, fmt.Errorf("epoll wait: clear interrupt: %w", err)
+				}
+				return 0, fmt.Errorf("epoll wait: %w", ErrInterrupted)
+			}
+		}
+
 		return n, nil
 	}
 }
 
+// Interrupt unblocks a call to Wait, which then returns ErrInterrupted.
+//
+// If Wait isn't currently blocked the next call to Wait returns immediately
+// instead. Multiple calls to Interrupt before a call to Wait only interrupt
+// it once.
+func (p *Poller) Interrupt() error {
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+
+	if p.interrupt == nil {
+		return fmt.Errorf("epoll interrupt: %w", os.ErrClosed)
+	}
+
+	return p.interrupt.add(1)
+}
+
 type temporaryError interface {
 	Temporary() bool
 }
@@ -107,6 +107,53 @@ func TestPollerDeadline(t *testing.T) {
 	<-done
 }
 
+func TestPollerInterrupt(t *testing.T) {
+	t.Parallel()
+
+	event, poller := mustNewPoller(t)
+	events := make([]unix.EpollEvent, 2)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := poller.Wait(events, time.Time{})
+		done <- err
+	}()
+
+	// Wait for the goroutine to enter the syscall.
+	time.Sleep(100 * time.Millisecond)
+
+	if err := poller.Interrupt(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInterrupted) {
+			t.Fatal("Expected ErrInterrupted, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Interrupt doesn't unblock Wait")
+	}
+
+	// The interrupt is consumed by a single Wait.
+	if err := event.add(1); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := poller.Wait(events, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal("Wait after interrupt:", err)
+	}
+	if n != 1 || events[0].Pad != 42 {
+		t.Fatalf("Expected a single event with id 42, got %d events", n)
+	}
+
+	poller.Close()
+	if err := poller.Interrupt(); !errors.Is(err, os.ErrClosed) {
+		t.Fatal("Interrupt after Close doesn't return ErrClosed:", err)
+	}
+}
+
 func mustNewPoller(t *testing.T) (*eventFd, *Poller) {
 	t.Helper()
 
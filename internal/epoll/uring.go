@@ -0,0 +1,451 @@
+package epoll
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// io_uring ABI, see include/uapi/linux/io_uring.h.
+const (
+	uringOffSQRing = 0
+	uringOffSQEs   = 0x10000000
+
+	uringFeatSingleMmap = 1 << 0
+	uringFeatNoDrop     = 1 << 1
+	uringFeatExtArg     = 1 << 8
+	// Introduced in the same release as multishot poll requests, which
+	// don't have a feature bit of their own.
+	uringFeatRsrcTags = 1 << 10
+
+	uringEnterGetEvents = 1 << 0
+	uringEnterExtArg    = 1 << 3
+
+	uringOpPollAdd     = 6
+	uringPollAddMulti  = 1 << 0
+	uringCQEFMore      = 1 << 1
+	uringSQESize       = 64
+	uringCQESize       = 16
+	uringFeatsRequired = uringFeatSingleMmap | uringFeatNoDrop | uringFeatExtArg | uringFeatRsrcTags
+)
+
+type uringSQOffsets struct {
+	Head, Tail, RingMask, RingEntries, Flags, Dropped, Array, Resv1 uint32
+	UserAddr                                                        uint64
+}
+
+type uringCQOffsets struct {
+	Head, Tail, RingMask, RingEntries, Overflow, CQEs, Flags, Resv1 uint32
+	UserAddr                                                        uint64
+}
+
+type uringParams struct {
+	SQEntries, CQEntries, Flags, SQThreadCPU, SQThreadIdle, Features, WQFd uint32
+	Resv                                                                   [3]uint32
+	SQOff                                                                  uringSQOffsets
+	CQOff                                                                  uringCQOffsets
+}
+
+type uringGetEventsArg struct {
+	Sigmask   uint64
+	SigmaskSz uint32
+	Pad       uint32
+	Ts        uint64
+}
+
+type uringTimespec struct {
+	Sec, Nsec int64
+}
+
+// URingPoller waits for readiness notifications from multiple file
+// descriptors using io_uring multishot poll requests instead of epoll.
+//
+// It behaves like Poller, except that readiness is reported when a file
+// descriptor is woken up rather than while it is readable. Interrupt may
+// spuriously interrupt more than one call to Wait.
+//
+// Requires at least Linux 5.13.
+type URingPoller struct {
+	// mutexes protect the fields declared below them. If you need to
+	// acquire both at once you must lock ringMu before eventMu.
+	ringMu sync.Mutex
+	ringFd int
+	rings  []byte
+	sqes   []byte
+	// Pointers into rings.
+	sqHead, sqTail, sqMask *uint32
+	sqArray                []uint32
+	cqHead, cqTail, cqMask *uint32
+	cqes                   []byte
+	// pending holds events which were received but not yet returned by
+	// Wait.
+	pending []unix.EpollEvent
+
+	eventMu   sync.Mutex
+	event     *eventFd
+	interrupt *eventFd
+}
+
+// NewURing creates a URingPoller which can poll up to entries file
+// descriptors at once.
+func NewURing(entries int) (*URingPoller, error) {
+	var params uringParams
+	// Add room for the eventfds.
+	fd, _, errno := unix.Syscall(unix.SYS_IO_URING_SETUP, uintptr(entries+2), uintptr(unsafe.Pointer(&params)), 0)
+	if errno == unix.ENOSYS {
+		return nil, &internal.UnsupportedFeatureError{Name: "io_uring", MinimumVersion: internal.Version{5, 1}}
+	}
+	if errno != 0 {
+		return nil, fmt.Errorf("create io_uring: %w", errno)
+	}
+
+	p := &URingPoller{ringFd: int(fd)}
+	if params.Features&uringFeatsRequired != uringFeatsRequired {
+		p.closeRing()
+		return nil, &internal.UnsupportedFeatureError{Name: "io_uring multishot poll", MinimumVersion: internal.Version{5, 13}}
+	}
+
+	if err := p.mmap(&params); err != nil {
+		p.closeRing()
+		return nil, err
+	}
+
+	var err error
+	p.event, err = newEventFd()
+	if err != nil {
+		p.closeRing()
+		return nil, err
+	}
+
+	p.interrupt, err = newEventFd()
+	if err != nil {
+		p.closeRing()
+		p.event.close()
+		return nil, err
+	}
+
+	for _, efd := range []*eventFd{p.event, p.interrupt} {
+		if err := p.pollAdd(efd.raw, 0); err != nil {
+			p.closeRing()
+			p.event.close()
+			p.interrupt.close()
+			return nil, fmt.Errorf("add eventfd: %w", err)
+		}
+	}
+
+	runtime.SetFinalizer(p, (*URingPoller).Close)
+	return p, nil
+}
+
+func (p *URingPoller) mmap(params *uringParams) error {
+	sqSize := int(params.SQOff.Array + params.SQEntries*4)
+	cqSize := int(params.CQOff.CQEs + params.CQEntries*uringCQESize)
+	size := sqSize
+	if cqSize > size {
+		size = cqSize
+	}
+
+	rings, err := unix.Mmap(p.ringFd, uringOffSQRing, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("mmap io_uring rings: %w", err)
+	}
+	p.rings = rings
+
+	sqes, err := unix.Mmap(p.ringFd, uringOffSQEs, int(params.SQEntries)*uringSQESize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("mmap io_uring submission queue entries: %w", err)
+	}
+	p.sqes = sqes
+
+	field := func(off uint32) *uint32 {
+		return (*uint32)(unsafe.Pointer(&rings[off]))
+	}
+
+	p.sqHead = field(params.SQOff.Head)
+	p.sqTail = field(params.SQOff.Tail)
+	p.sqMask = field(params.SQOff.RingMask)
+	p.sqArray = unsafe.Slice(field(params.SQOff.Array), params.SQEntries)
+	p.cqHead = field(params.CQOff.Head)
+	p.cqTail = field(params.CQOff.Tail)
+	p.cqMask = field(params.CQOff.RingMask)
+	p.cqes = rings[params.CQOff.CQEs:cqSize]
+	return nil
+}
+
+func (p *URingPoller) closeRing() {
+	if p.sqes != nil {
+		unix.Munmap(p.sqes)
+		p.sqes = nil
+	}
+	if p.rings != nil {
+		unix.Munmap(p.rings)
+		p.rings = nil
+	}
+	unix.Close(p.ringFd)
+	p.ringFd = -1
+}
+
+// Close the poller.
+//
+// Interrupts any calls to Wait. Multiple calls to Close are valid, but subsequent
+// calls will return os.ErrClosed.
+func (p *URingPoller) Close() error {
+	runtime.SetFinalizer(p, nil)
+
+	// Interrupt Wait() via the event fd if it's currently blocked.
+	if err := p.wakeWait(); err != nil {
+		return err
+	}
+
+	// Acquire the lock. This ensures that Wait isn't running.
+	p.ringMu.Lock()
+	defer p.ringMu.Unlock()
+
+	// Prevent other calls to Close().
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+
+	if p.ringFd != -1 {
+		p.closeRing()
+	}
+
+	if p.event != nil {
+		p.event.close()
+		p.event = nil
+	}
+
+	if p.interrupt != nil {
+		p.interrupt.close()
+		p.interrupt = nil
+	}
+
+	return nil
+}
+
+// Add an fd to the poller.
+//
+// id is returned by Wait in the unix.EpollEvent.Pad field any may be zero. It
+// must not exceed math.MaxInt32.
+//
+// Add is blocked by Wait.
+func (p *URingPoller) Add(fd int, id int) error {
+	if int64(id) > math.MaxInt32 {
+		return fmt.Errorf("unsupported id: %d", id)
+	}
+
+	p.ringMu.Lock()
+	defer p.ringMu.Unlock()
+
+	if p.ringFd == -1 {
+		return fmt.Errorf("io_uring add: %w", os.ErrClosed)
+	}
+
+	return p.pollAdd(fd, id)
+}
+
+// pollAdd submits a multishot poll request for fd. The fd and id are
+// encoded in the user data of the request.
+//
+// Requires holding ringMu.
+func (p *URingPoller) pollAdd(fd, id int) error {
+	tail := *p.sqTail
+	if tail-atomic.LoadUint32(p.sqHead) > *p.sqMask {
+		return fmt.Errorf("io_uring submission queue is full")
+	}
+
+	events := uint32(unix.EPOLLIN)
+	if internal.NativeEndian == binary.BigEndian {
+		// The kernel swaps the halves of poll32_events on big endian.
+		events = events<<16 | events>>16
+	}
+
+	idx := tail & *p.sqMask
+	sqe := p.sqes[idx*uringSQESize : (idx+1)*uringSQESize]
+	for i := range sqe {
+		sqe[i] = 0
+	}
+	sqe[0] = uringOpPollAdd
+	internal.NativeEndian.PutUint32(sqe[4:], uint32(fd))
+	internal.NativeEndian.PutUint32(sqe[24:], uringPollAddMulti)
+	internal.NativeEndian.PutUint32(sqe[28:], events)
+	internal.NativeEndian.PutUint64(sqe[32:], uint64(uint32(fd))<<32|uint64(uint32(id)))
+	p.sqArray[idx] = idx
+	atomic.StoreUint32(p.sqTail, tail+1)
+
+	for {
+		_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(p.ringFd), 1, 0, 0, 0, 0)
+		if errno == unix.EINTR {
+			continue
+		}
+		if errno != 0 {
+			return fmt.Errorf("submit io_uring poll: %w", errno)
+		}
+		return nil
+	}
+}
+
+// Wait for events.
+//
+// Returns the number of pending events or an error wrapping os.ErrClosed if
+// Close is called, ErrInterrupted if Interrupt is called, or
+// os.ErrDeadlineExceeded if the deadline expires.
+func (p *URingPoller) Wait(events []unix.EpollEvent, deadline time.Time) (int, error) {
+	p.ringMu.Lock()
+	defer p.ringMu.Unlock()
+
+	if p.ringFd == -1 {
+		return 0, fmt.Errorf("io_uring wait: %w", os.ErrClosed)
+	}
+
+	for {
+		interrupted, err := p.reap()
+		if err != nil {
+			return 0, err
+		}
+
+		if interrupted {
+			// Events received alongside the interrupt stay pending and are
+			// returned by the next call.
+			return 0, fmt.Errorf("io_uring wait: %w", ErrInterrupted)
+		}
+
+		if len(p.pending) > 0 {
+			n := copy(events, p.pending)
+			p.pending = p.pending[:copy(p.pending, p.pending[n:])]
+			return n, nil
+		}
+
+		if err := p.enter(deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// reap consumes all completions and adds the fds which became ready to
+// pending.
+func (p *URingPoller) reap() (interrupted bool, _ error) {
+	head := *p.cqHead
+	for ; head != atomic.LoadUint32(p.cqTail); head++ {
+		off := (head & *p.cqMask) * uringCQESize
+		cqe := p.cqes[off : off+uringCQESize]
+		userData := internal.NativeEndian.Uint64(cqe[0:])
+		res := int32(internal.NativeEndian.Uint32(cqe[8:]))
+		flags := internal.NativeEndian.Uint32(cqe[12:])
+		fd, id := int(int32(userData>>32)), int(uint32(userData))
+
+		if res < 0 {
+			atomic.StoreUint32(p.cqHead, head+1)
+			return false, fmt.Errorf("io_uring poll fd %d: %w", fd, syscall.Errno(-res))
+		}
+
+		if flags&uringCQEFMore == 0 {
+			// The kernel terminated the multishot request, for example
+			// because the completion queue overflowed.
+			if err := p.pollAdd(fd, id); err != nil {
+				atomic.StoreUint32(p.cqHead, head+1)
+				return false, err
+			}
+		}
+
+		switch fd {
+		case p.event.raw:
+			// Since we don't clear p.event we'll keep returning this until
+			// Close() acquires the lock and sets p.ringFd = -1.
+			atomic.StoreUint32(p.cqHead, head)
+			return false, fmt.Errorf("io_uring wait: %w", os.ErrClosed)
+
+		case p.interrupt.raw:
+			// The eventfd isn't read, since completions are triggered by
+			// writes rather than by it being readable.
+			interrupted = true
+
+		default:
+			p.addPending(fd, id)
+		}
+	}
+
+	atomic.StoreUint32(p.cqHead, head)
+	return interrupted, nil
+}
+
+func (p *URingPoller) addPending(fd, id int) {
+	for _, event := range p.pending {
+		if int(event.Fd) == fd {
+			return
+		}
+	}
+
+	p.pending = append(p.pending, unix.EpollEvent{
+		Events: unix.EPOLLIN,
+		Fd:     int32(fd),
+		Pad:    int32(id),
+	})
+}
+
+// enter blocks until at least one completion is available or the deadline
+// expires.
+func (p *URingPoller) enter(deadline time.Time) error {
+	var (
+		ts  uringTimespec
+		arg uringGetEventsArg
+	)
+	if !deadline.IsZero() {
+		timeout := time.Until(deadline)
+		if timeout < 0 {
+			timeout = 0
+		}
+		ts.Sec = int64(timeout / time.Second)
+		ts.Nsec = int64(timeout % time.Second)
+		arg.Ts = uint64(uintptr(unsafe.Pointer(&ts)))
+	}
+
+	_, _, errno := unix.Syscall6(unix.SYS_IO_URING_ENTER, uintptr(p.ringFd), 0, 1,
+		uringEnterGetEvents|uringEnterExtArg, uintptr(unsafe.Pointer(&arg)), unsafe.Sizeof(arg))
+	runtime.KeepAlive(&ts)
+
+	switch errno {
+	case 0, unix.EINTR:
+		return nil
+	case unix.ETIME:
+		return fmt.Errorf("io_uring wait: %w", os.ErrDeadlineExceeded)
+	default:
+		return fmt.Errorf("io_uring wait: %w", errno)
+	}
+}
+
+// Interrupt unblocks a call to Wait, which then returns ErrInterrupted.
+//
+// If Wait isn't currently blocked the next call to Wait returns immediately
+// instead.
+func (p *URingPoller) Interrupt() error {
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+
+	if p.interrupt == nil {
+		return fmt.Errorf("io_uring interrupt: %w", os.ErrClosed)
+	}
+
+	return p.interrupt.add(1)
+}
+
+// wakeWait unblocks Wait if it's in io_uring_enter.
+func (p *URingPoller) wakeWait() error {
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+
+	if p.event == nil {
+		return fmt.Errorf("io_uring wake: %w", os.ErrClosed)
+	}
+
+	return p.event.add(1)
+}
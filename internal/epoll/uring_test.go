@@ -0,0 +1,149 @@
+package epoll
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+func TestURingPoller(t *testing.T) {
+	t.Parallel()
+
+	event, poller := mustNewURingPoller(t)
+	events := make([]unix.EpollEvent, 1)
+
+	if err := event.add(1); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := poller.Wait(events, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal("Wait:", err)
+	}
+	if n != 1 || events[0].Pad != 42 {
+		t.Fatalf("Expected a single event with id 42, got %d events", n)
+	}
+
+	// Readiness is only reported once per wakeup.
+	_, err = poller.Wait(events, time.Now().Add(100*time.Millisecond))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("Expected os.ErrDeadlineExceeded, got", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := poller.Wait(events, time.Time{})
+		done <- err
+	}()
+
+	// Wait for the goroutine to enter the syscall.
+	time.Sleep(100 * time.Millisecond)
+
+	if err := poller.Interrupt(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInterrupted) {
+			t.Fatal("Expected ErrInterrupted, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Interrupt doesn't unblock Wait")
+	}
+
+	go func() {
+		_, err := poller.Wait(events, time.Time{})
+		done <- err
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := poller.Close(); err != nil {
+		t.Fatal("Close returns an error:", err)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrClosed) {
+			t.Fatal("Expected os.ErrClosed, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close doesn't unblock Wait")
+	}
+
+	if err := poller.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Fatal("Closing a second time doesn't return ErrClosed:", err)
+	}
+}
+
+func TestURingPollerPending(t *testing.T) {
+	t.Parallel()
+
+	poller, err := NewURing(2)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer poller.Close()
+
+	for id := 1; id <= 2; id++ {
+		event, err := newEventFd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer event.close()
+
+		if err := poller.Add(event.raw, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := event.add(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Events which don't fit are returned by the next call.
+	events := make([]unix.EpollEvent, 1)
+	var ids []int32
+	for i := 0; i < 2; i++ {
+		n, err := poller.Wait(events, time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatal("Wait:", err)
+		}
+		if n != 1 {
+			t.Fatalf("Expected a single event, got %d", n)
+		}
+		ids = append(ids, events[0].Pad)
+	}
+
+	if ids[0] == ids[1] {
+		t.Fatal("Received the same event twice:", ids)
+	}
+}
+
+func mustNewURingPoller(t *testing.T) (*eventFd, *URingPoller) {
+	t.Helper()
+
+	event, err := newEventFd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { event.close() })
+
+	poller, err := NewURing(1)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { poller.Close() })
+
+	if err := poller.Add(event.raw, 42); err != nil {
+		t.Fatal("Can't add fd:", err)
+	}
+
+	return event, poller
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -23,7 +24,7 @@ func ErrorWithLog(source string, err error, log []byte, truncated bool) *Verifie
 
 	log = bytes.Trim(log, whitespace)
 	if len(log) == 0 {
-		return &VerifierError{source, err, nil, truncated}
+		return &VerifierError{source: source, Cause: err, Truncated: truncated, Instruction: -1}
 	}
 
 	logLines := bytes.Split(log, []byte{'\n'})
@@ -34,7 +35,9 @@ func ErrorWithLog(source string, err error, log []byte, truncated bool) *Verifie
 		lines = append(lines, string(bytes.TrimRight(line, whitespace)))
 	}
 
-	return &VerifierError{source, err, lines, truncated}
+	ve := &VerifierError{source: source, Cause: err, Log: lines, Truncated: truncated}
+	ve.Instruction, ve.Registers, ve.Source = parseVerifierLog(lines)
+	return ve
 }
 
 // VerifierError includes information from the eBPF verifier.
@@ -48,6 +51,104 @@ type VerifierError struct {
 	Log []string
 	// Whether the log output is truncated, based on several heuristics.
 	Truncated bool
+
+	// The instruction rejected by the verifier as a raw instruction offset,
+	// which accounts for double-wide instructions. Negative if it can't be
+	// determined from the log.
+	Instruction int
+	// The state of the registers before Instruction, as reported by the
+	// verifier. For example "R1" maps to "ctx(off=0,imm=0)". Nil if the log
+	// contains no register state.
+	Registers map[string]string
+	// The line of source code associated with Instruction, if known.
+	Source string
+}
+
+// parseVerifierLog extracts the last instruction processed by the verifier
+// from its log, together with the register state and source line at that
+// instruction.
+//
+// The log looks like this, where the source lines are only present if the
+// program has BTF line info. Older kernels don't print the state after an
+// instruction.
+//
+//	0: R1=ctx(off=0,imm=0) R10=fp0
+//	; return x; @ prog.c:12
+//	0: (bf) r0 = r1                       ; R0_w=ctx(off=0,imm=0)
+//	1: (bf) r10 = r0
+//	frame pointer is read only
+func parseVerifierLog(lines []string) (insn int, regs map[string]string, source string) {
+	insn = -1
+	var lastSource string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "; ") {
+			lastSource = strings.TrimSpace(line[2:])
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+
+		if strings.HasPrefix(prefix, "from ") {
+			// from 3 to 5: R0=... is the state after a branch.
+			regs = parseRegisters(rest, nil)
+			continue
+		}
+
+		idx, err := strconv.Atoi(prefix)
+		if err != nil {
+			continue
+		}
+
+		// The state of a subprogram is prefixed with the frame.
+		if strings.HasPrefix(rest, "frame") {
+			if _, state, ok := strings.Cut(rest, ": "); ok {
+				rest = state
+			}
+		}
+
+		if strings.HasPrefix(rest, "R") {
+			// 0: R1=ctx() R10=fp0 is the full state before instruction 0.
+			regs = parseRegisters(rest, nil)
+			continue
+		}
+
+		if !strings.HasPrefix(rest, "(") {
+			continue
+		}
+
+		insn, source = idx, lastSource
+		if i := strings.LastIndex(rest, "; "); i != -1 {
+			// Registers modified by the instruction.
+			regs = parseRegisters(rest[i+2:], regs)
+		}
+	}
+
+	return insn, regs, source
+}
+
+// parseRegisters adds the registers in state to regs, which may be nil.
+func parseRegisters(state string, regs map[string]string) map[string]string {
+	for _, field := range strings.Fields(state) {
+		name, value, ok := strings.Cut(field, "=")
+		if !ok || len(name) < 2 || name[0] != 'R' {
+			continue
+		}
+
+		// Strip liveness marks like R0_w.
+		name, _, _ = strings.Cut(name, "_")
+		if _, err := strconv.Atoi(name[1:]); err != nil {
+			continue
+		}
+
+		if regs == nil {
+			regs = make(map[string]string)
+		}
+		regs[name] = value
+	}
+	return regs
 }
 
 func (le *VerifierError) Unwrap() error {
@@ -75,6 +75,45 @@ func TestVerifierErrorSummary(t *testing.T) {
 	qt.Assert(t, invalidCtx.Error(), qt.Contains, "func '__x64_sys_recvfrom' arg0 type FWD is not a struct: invalid bpf_context access off=0 size=8")
 }
 
+func TestVerifierErrorParse(t *testing.T) {
+	invalidR0 := readErrorFromFile(t, "testdata/invalid-R0.log")
+	qt.Assert(t, invalidR0.Instruction, qt.Equals, 0)
+	qt.Assert(t, invalidR0.Registers, qt.DeepEquals, map[string]string{
+		"R1":  "ctx(id=0,off=0,imm=0)",
+		"R10": "fp0",
+	})
+	qt.Assert(t, invalidR0.Source, qt.Equals, "")
+
+	invalidCtx := readErrorFromFile(t, "testdata/invalid-ctx-access.log")
+	qt.Assert(t, invalidCtx.Instruction, qt.Equals, 0)
+	qt.Assert(t, invalidCtx.Registers, qt.IsNil)
+	qt.Assert(t, invalidCtx.Source, qt.Equals, "int BPF_PROG(sys_recvfrom, struct pt_regs *regs) {")
+
+	errno524 := readErrorFromFile(t, "testdata/errno524.log")
+	qt.Assert(t, errno524.Instruction, qt.Equals, -1)
+
+	ve := ErrorWithLog("frob", errors.New("test"), []byte(`func#0 @0
+0: R1=ctx() R10=fp0
+; int x = 0; @ prog.c:3
+0: (bf) r0 = r1                       ; R0_w=ctx() R1=ctx()
+; if (x) @ prog.c:4
+1: (07) r0 += 8                       ; R0_w=ctx(off=8)
+2: (55) if r0 != 0x0 goto pc+1        ; R0_w=ctx(off=8)
+from 2 to 4: R0=ctx(off=8) R1=ctx() R10=fp0
+4: (bf) r10 = r0
+frame pointer is read only`), false)
+	qt.Assert(t, ve.Instruction, qt.Equals, 4)
+	qt.Assert(t, ve.Registers, qt.DeepEquals, map[string]string{
+		"R0":  "ctx(off=8)",
+		"R1":  "ctx()",
+		"R10": "fp0",
+	})
+	qt.Assert(t, ve.Source, qt.Equals, "if (x) @ prog.c:4")
+
+	ve = ErrorWithLog("frob", errors.New("test"), nil, false)
+	qt.Assert(t, ve.Instruction, qt.Equals, -1)
+}
+
 func readErrorFromFile(tb testing.TB, file string) *VerifierError {
 	tb.Helper()
 
@@ -0,0 +1,72 @@
+// Package kallsyms reads the addresses of kernel symbols from /proc/kallsyms.
+package kallsyms
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ErrAmbiguous is returned if a symbol exists at multiple addresses.
+var ErrAmbiguous = errors.New("multiple addresses")
+
+// AssignAddresses looks up the addresses of the requested symbols in the
+// running kernel.
+//
+// symbols maps names to addresses and is updated in place. Symbols which
+// don't exist remain unchanged.
+//
+// Returns an error if the kernel hides addresses from the caller, which
+// happens without CAP_SYSLOG or depending on kernel.kptr_restrict.
+func AssignAddresses(symbols map[string]uint64) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+
+	f, err := os.Open("/proc/kallsyms")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return assignAddresses(f, symbols)
+}
+
+func assignAddresses(r io.Reader, symbols map[string]uint64) error {
+	found := make(map[string]bool, len(symbols))
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Lines have the format "address type name [module]".
+		fields := bytes.Fields(scanner.Bytes())
+		if len(fields) < 3 {
+			continue
+		}
+
+		name := string(fields[2])
+		if _, ok := symbols[name]; !ok {
+			continue
+		}
+
+		addr, err := strconv.ParseUint(string(fields[0]), 16, 64)
+		if err != nil {
+			return fmt.Errorf("symbol %s: parse address: %w", name, err)
+		}
+
+		if addr == 0 {
+			return fmt.Errorf("symbol %s: address is hidden, check kernel.kptr_restrict and CAP_SYSLOG", name)
+		}
+
+		if found[name] && symbols[name] != addr {
+			return fmt.Errorf("symbol %s: %w", name, ErrAmbiguous)
+		}
+
+		symbols[name] = addr
+		found[name] = true
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,46 @@
+package kallsyms
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestAssignAddresses(t *testing.T) {
+	const kallsyms = `ffffffff81000000 T _stext
+ffffffff81001000 t static_fn
+ffffffff81002000 t static_fn
+ffffffffc0000000 t module_fn	[nf_tables]
+ffffffff82000000 D bpf_prog_active
+`
+
+	symbols := map[string]uint64{
+		"_stext":          0,
+		"module_fn":       0,
+		"bpf_prog_active": 0,
+		"missing":         0,
+	}
+	qt.Assert(t, assignAddresses(strings.NewReader(kallsyms), symbols), qt.IsNil)
+	qt.Assert(t, symbols, qt.DeepEquals, map[string]uint64{
+		"_stext":          0xffffffff81000000,
+		"module_fn":       0xffffffffc0000000,
+		"bpf_prog_active": 0xffffffff82000000,
+		"missing":         0,
+	})
+
+	err := assignAddresses(strings.NewReader(kallsyms), map[string]uint64{"static_fn": 0})
+	qt.Assert(t, errors.Is(err, ErrAmbiguous), qt.IsTrue, qt.Commentf("got %v", err))
+
+	err = assignAddresses(strings.NewReader("0000000000000000 T _stext\n"), map[string]uint64{"_stext": 0})
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestAssignAddressesKernel(t *testing.T) {
+	symbols := map[string]uint64{"bpf_prog_put": 0}
+	if err := AssignAddresses(symbols); err != nil {
+		t.Skip("Can't read /proc/kallsyms:", err)
+	}
+	qt.Assert(t, symbols["bpf_prog_put"], qt.Not(qt.Equals), uint64(0))
+}
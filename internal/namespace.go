@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// InNamespace invokes fn while the calling thread is a member of the
+// namespace ns, for example a network namespace opened from
+// /proc/<pid>/ns/net. The thread returns to its original namespace
+// afterwards.
+//
+// Only namespaces which a single thread of a multi-threaded process may
+// enter are supported, which excludes user and mount namespaces.
+//
+// Entering a namespace requires CAP_SYS_ADMIN.
+func InNamespace(ns *os.File, fn func() error) error {
+	typ, err := namespaceType(ns)
+	if err != nil {
+		return err
+	}
+
+	if typ == "user" || typ == "mnt" {
+		return fmt.Errorf("can't enter %s namespace from a multi-threaded process", typ)
+	}
+
+	// Run fn on a fresh goroutine: if the thread can't be moved back it is
+	// left locked, which makes the runtime terminate the thread once the
+	// goroutine exits.
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		prev, err := os.Open("/proc/thread-self/ns/" + typ)
+		if err != nil {
+			runtime.UnlockOSThread()
+			errs <- err
+			return
+		}
+		defer prev.Close()
+
+		if err := unix.Setns(int(ns.Fd()), 0); err != nil {
+			runtime.UnlockOSThread()
+			errs <- fmt.Errorf("enter %s namespace: %w", typ, err)
+			return
+		}
+
+		fnErr := fn()
+
+		if err := unix.Setns(int(prev.Fd()), 0); err != nil {
+			errs <- fmt.Errorf("restore %s namespace: %w", typ, err)
+			return
+		}
+
+		runtime.UnlockOSThread()
+		errs <- fnErr
+	}()
+
+	return <-errs
+}
+
+// namespaceType returns the type of the namespace referred to by ns, as
+// used in /proc/*/ns.
+func namespaceType(ns *os.File) (string, error) {
+	// The link target has the form net:[4026531840].
+	target, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", ns.Fd()))
+	if err != nil {
+		return "", fmt.Errorf("namespace %s: %w", ns.Name(), err)
+	}
+
+	typ, _, ok := strings.Cut(target, ":[")
+	if !ok {
+		return "", fmt.Errorf("%s is not a namespace", ns.Name())
+	}
+
+	return typ, nil
+}
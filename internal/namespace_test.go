@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestInNamespace(t *testing.T) {
+	self, err := os.Readlink("/proc/thread-self/ns/net")
+	qt.Assert(t, err, qt.IsNil)
+
+	// Create a namespace on a locked thread which returns to its original
+	// namespace afterwards.
+	nsc := make(chan *os.File, 1)
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		orig, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			errc <- err
+			return
+		}
+		defer orig.Close()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errc <- err
+			return
+		}
+
+		ns, err := os.Open("/proc/thread-self/ns/net")
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+		if err != nil {
+			errc <- err
+			return
+		}
+		nsc <- ns
+	}()
+
+	var ns *os.File
+	select {
+	case err := <-errc:
+		if errors.Is(err, unix.EPERM) {
+			t.Skip("Can't create network namespace:", err)
+		}
+		t.Fatal(err)
+	case ns = <-nsc:
+	}
+	defer ns.Close()
+
+	want, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", ns.Fd()))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, want, qt.Not(qt.Equals), self)
+
+	var got string
+	err = InNamespace(ns, func() error {
+		got, err = os.Readlink("/proc/thread-self/ns/net")
+		return err
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.Equals, want)
+
+	// Errors from fn are returned.
+	errFn := errors.New("fn")
+	err = InNamespace(ns, func() error { return errFn })
+	qt.Assert(t, errors.Is(err, errFn), qt.IsTrue)
+
+	restored, err := os.Readlink("/proc/thread-self/ns/net")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, restored, qt.Equals, self)
+}
+
+func TestInNamespaceInvalid(t *testing.T) {
+	f, err := os.Open("/proc/self/ns/user")
+	qt.Assert(t, err, qt.IsNil)
+	defer f.Close()
+
+	err = InNamespace(f, func() error { return nil })
+	qt.Assert(t, err, qt.IsNotNil)
+
+	f, err = os.Open("/dev/null")
+	qt.Assert(t, err, qt.IsNil)
+	defer f.Close()
+
+	err = InNamespace(f, func() error { return nil })
+	qt.Assert(t, err, qt.IsNotNil)
+}
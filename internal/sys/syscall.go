@@ -71,6 +71,24 @@ func (i *LinkInfo) info() (unsafe.Pointer, uint32) {
 	return unsafe.Pointer(i), uint32(unsafe.Sizeof(*i))
 }
 
+var _ Info = (*KprobeLinkInfo)(nil)
+
+func (i *KprobeLinkInfo) info() (unsafe.Pointer, uint32) {
+	return unsafe.Pointer(i), uint32(unsafe.Sizeof(*i))
+}
+
+var _ Info = (*KprobeMultiLinkInfo)(nil)
+
+func (i *KprobeMultiLinkInfo) info() (unsafe.Pointer, uint32) {
+	return unsafe.Pointer(i), uint32(unsafe.Sizeof(*i))
+}
+
+var _ Info = (*UprobeLinkInfo)(nil)
+
+func (i *UprobeLinkInfo) info() (unsafe.Pointer, uint32) {
+	return unsafe.Pointer(i), uint32(unsafe.Sizeof(*i))
+}
+
 var _ Info = (*BtfInfo)(nil)
 
 func (i *BtfInfo) info() (unsafe.Pointer, uint32) {
@@ -79,7 +97,8 @@ func (i *BtfInfo) info() (unsafe.Pointer, uint32) {
 
 // ObjInfo retrieves information about a BPF Fd.
 //
-// info may be one of MapInfo, ProgInfo, LinkInfo and BtfInfo.
+// info may be one of MapInfo, ProgInfo, BtfInfo, LinkInfo or one of the
+// type-specific link infos.
 func ObjInfo(fd *FD, info Info) error {
 	ptr, len := info.info()
 	err := ObjGetInfoByFd(&ObjGetInfoByFdAttr{
@@ -139,6 +158,12 @@ const (
 	BPF_F_MMAPABLE
 	BPF_F_PRESERVE_ELEMS
 	BPF_F_INNER_MAP
+	BPF_F_LINK
+	BPF_F_PATH_FD
+	BPF_F_VTYPE_BTF_OBJ_FD
+	BPF_F_TOKEN_FD
+	BPF_F_SEGV_ON_FAULT
+	BPF_F_NO_USER_CONV
 )
 
 // wrappedErrno wraps syscall.Errno to prevent direct comparisons with
@@ -0,0 +1,62 @@
+package testutils
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// NewNetNS creates a fresh network namespace and returns a file referring to
+// it. The namespace is destroyed once the file is closed.
+//
+// Skips the test if the namespace can't be created.
+func NewNetNS(tb testing.TB) *os.File {
+	tb.Helper()
+
+	type result struct {
+		ns  *os.File
+		err error
+	}
+
+	// Create the namespace on a locked thread and return the thread to its
+	// original namespace afterwards. The thread is left locked if that fails,
+	// which makes the runtime discard it.
+	results := make(chan result, 1)
+	go func() {
+		runtime.LockOSThread()
+
+		orig, err := os.Open("/proc/thread-self/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			results <- result{nil, err}
+			return
+		}
+		defer orig.Close()
+
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			results <- result{nil, err}
+			return
+		}
+
+		ns, err := os.Open("/proc/thread-self/ns/net")
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+		results <- result{ns, err}
+	}()
+
+	res := <-results
+	if errors.Is(res.err, unix.EPERM) {
+		tb.Skip("Can't create network namespace:", res.err)
+	}
+	if res.err != nil {
+		tb.Fatal("Create network namespace:", res.err)
+	}
+
+	tb.Cleanup(func() { res.ns.Close() })
+	return res.ns
+}
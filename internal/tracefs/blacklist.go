@@ -0,0 +1,96 @@
+package tracefs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+const kprobeBlacklistPath = "/sys/kernel/debug/kprobes/blacklist"
+
+// kprobeBlacklist contains the symbols which the kernel refuses to probe, for
+// example because they are marked with NOKPROBE_SYMBOL.
+var kprobeBlacklist = internal.Memoize(func() (map[string]struct{}, error) {
+	f, err := os.Open(kprobeBlacklistPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseKprobeBlacklist(f)
+})
+
+// parseKprobeBlacklist parses lines of the form
+//
+//	0xffffffff81000000-0xffffffff81000020	symbol
+//
+// Addresses are zero if the caller isn't allowed to see them.
+func parseKprobeBlacklist(r io.Reader) (map[string]struct{}, error) {
+	syms := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		syms[fields[len(fields)-1]] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read kprobe blacklist: %w", err)
+	}
+	return syms, nil
+}
+
+// KprobeBlacklisted returns true if the kernel doesn't allow placing kprobes
+// on symbol.
+//
+// Returns an error if the blacklist isn't accessible, for example because
+// debugfs isn't mounted or the caller lacks privileges.
+func KprobeBlacklisted(symbol string) (bool, error) {
+	syms, err := kprobeBlacklist()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := syms[symbol]
+	return ok, nil
+}
+
+// TraceableFunctions returns the functions listed in available_filter_functions,
+// which ftrace based probes like fprobe can be attached to.
+//
+// Functions marked notrace or which are always inlined are missing. The file
+// is read on every call since it's large and changes as modules are loaded.
+func TraceableFunctions() (map[string]struct{}, error) {
+	path, err := sanitizeTracefsPath("available_filter_functions")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseFilterFunctions(f)
+}
+
+// parseFilterFunctions parses lines of the form "symbol [module]".
+func parseFilterFunctions(r io.Reader) (map[string]struct{}, error) {
+	funcs := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if name, _, _ := strings.Cut(scanner.Text(), " "); name != "" {
+			funcs[name] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read available_filter_functions: %w", err)
+	}
+	return funcs, nil
+}
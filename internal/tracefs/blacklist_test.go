@@ -0,0 +1,34 @@
+package tracefs
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseKprobeBlacklist(t *testing.T) {
+	syms, err := parseKprobeBlacklist(strings.NewReader(
+		"0xffffffff81000000-0xffffffff81000020\tdo_int3\n" +
+			"0x0000000000000000-0x0000000000000000\tnotify_die\n" +
+			"\n",
+	))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, syms, qt.HasLen, 2)
+
+	_, ok := syms["do_int3"]
+	qt.Assert(t, ok, qt.IsTrue)
+	_, ok = syms["notify_die"]
+	qt.Assert(t, ok, qt.IsTrue)
+}
+
+func TestParseFilterFunctions(t *testing.T) {
+	funcs, err := parseFilterFunctions(strings.NewReader("vprintk\nnf_conntrack_in [nf_conntrack]\n"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, funcs, qt.HasLen, 2)
+
+	_, ok := funcs["vprintk"]
+	qt.Assert(t, ok, qt.IsTrue)
+	_, ok = funcs["nf_conntrack_in"]
+	qt.Assert(t, ok, qt.IsTrue)
+}
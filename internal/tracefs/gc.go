@@ -0,0 +1,102 @@
+package tracefs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// RemoveStaleEvents removes events of the given type which were created in a
+// group returned by RandomGroup(prefix) by a process which doesn't exist
+// anymore.
+//
+// Such events are left behind when a process exits without closing its
+// links, and can prevent attaching to the same symbol again. Events which
+// are still in use are skipped, since the kernel refuses to remove them.
+//
+// Processes are looked up in /proc, which must belong to the PID namespace
+// the events were created from.
+//
+// Returns the number of removed events.
+func RemoveStaleEvents(typ ProbeType, prefix string) (int, error) {
+	if !validIdentifier(prefix) {
+		return 0, fmt.Errorf("prefix '%s' must be alphanumeric or underscore: %w", prefix, ErrInvalidInput)
+	}
+
+	path, err := sanitizeTracefsPath(fmt.Sprintf("%s_events", typ))
+	if err != nil {
+		return 0, err
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, event := range parseProbeEvents(contents) {
+		group, _, _ := strings.Cut(event, "/")
+		pid, ok := groupOwner(group, prefix)
+		if !ok || processExists(pid) {
+			continue
+		}
+
+		err := removeEvent(typ, event)
+		if errors.Is(err, syscall.EBUSY) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// parseProbeEvents returns the group/event pairs listed in a probe events
+// file.
+//
+// Lines have the form "p:group/event token [args]", see NewEvent.
+func parseProbeEvents(contents []byte) []string {
+	var events []string
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		def, _, _ := strings.Cut(scanner.Text(), " ")
+		_, event, ok := strings.Cut(def, ":")
+		if !ok || !strings.Contains(event, "/") {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// groupOwner extracts the pid encoded in a group generated by RandomGroup.
+func groupOwner(group, prefix string) (int, bool) {
+	suffix, ok := strings.CutPrefix(group, prefix+"_")
+	if !ok || len(suffix) != 16 {
+		return 0, false
+	}
+
+	if _, err := strconv.ParseUint(suffix, 16, 64); err != nil {
+		return 0, false
+	}
+
+	pid, err := strconv.ParseUint(suffix[:8], 16, 32)
+	if err != nil || pid == 0 {
+		return 0, false
+	}
+
+	return int(pid), true
+}
+
+func processExists(pid int) bool {
+	_, err := os.Stat(fmt.Sprintf("/proc/%d", pid))
+	return !errors.Is(err, os.ErrNotExist)
+}
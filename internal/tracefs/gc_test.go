@@ -0,0 +1,61 @@
+package tracefs
+
+import (
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseProbeEvents(t *testing.T) {
+	events := parseProbeEvents([]byte(
+		"p:ebpf_0000002a01020304/vprintk vprintk\n" +
+			"r16:kprobes/r_vprintk_0 vprintk\n" +
+			"p:ebpf_0000002a01020304/main /bin/bash:0x0000000000031f30\n" +
+			"garbage\n",
+	))
+	qt.Assert(t, events, qt.DeepEquals, []string{
+		"ebpf_0000002a01020304/vprintk",
+		"kprobes/r_vprintk_0",
+		"ebpf_0000002a01020304/main",
+	})
+}
+
+func TestGroupOwner(t *testing.T) {
+	group, err := RandomGroup("ebpftest")
+	qt.Assert(t, err, qt.IsNil)
+
+	pid, ok := groupOwner(group, "ebpftest")
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, pid, qt.Equals, os.Getpid())
+	qt.Assert(t, processExists(pid), qt.IsTrue)
+
+	for _, group := range []string{
+		"ebpf_0000002a01020304",
+		"ebpftest_0000002a",
+		"ebpftest_00000000deadbeef",
+		"ebpftest_0000002a0102030g",
+	} {
+		_, ok := groupOwner(group, "ebpftest")
+		qt.Assert(t, ok, qt.IsFalse, qt.Commentf("group %s", group))
+	}
+}
+
+func TestRemoveStaleEvents(t *testing.T) {
+	args := ProbeArgs{Type: Kprobe, Symbol: ksym}
+	args.Group, _ = RandomGroup("ebpftest")
+
+	evt, err := NewEvent(args)
+	if err != nil {
+		t.Skip("Can't create trace event:", err)
+	}
+	defer evt.Close()
+
+	// Events of live processes are kept.
+	n, err := RemoveStaleEvents(Kprobe, "ebpftest")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, 0)
+
+	_, err = EventID(evt.group, evt.name)
+	qt.Assert(t, err, qt.IsNil)
+}
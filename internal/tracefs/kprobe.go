@@ -50,17 +50,21 @@ type ProbeArgs struct {
 // Returns an error when the output string would exceed 63 characters (kernel
 // limitation), when rand.Read() fails or when prefix contains characters not
 // allowed by IsValidTraceID.
+//
+// The group has the form <prefix>_<pid><random>, where pid is the process ID
+// of the caller and random are 4 random bytes, both hex encoded. The pid
+// allows RemoveStaleEvents to find events of processes which have exited.
 func RandomGroup(prefix string) (string, error) {
 	if !validIdentifier(prefix) {
 		return "", fmt.Errorf("prefix '%s' must be alphanumeric or underscore: %w", prefix, ErrInvalidInput)
 	}
 
-	b := make([]byte, 8)
+	b := make([]byte, 4)
 	if _, err := rand.Read(b); err != nil {
 		return "", fmt.Errorf("reading random bytes: %w", err)
 	}
 
-	group := fmt.Sprintf("%s_%x", prefix, b)
+	group := fmt.Sprintf("%s_%08x%x", prefix, uint32(os.Getpid()), b)
 	if len(group) > 63 {
 		return "", fmt.Errorf("group name '%s' cannot be longer than 63 characters: %w", group, ErrInvalidInput)
 	}
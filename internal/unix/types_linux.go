@@ -25,71 +25,83 @@ const (
 	EACCES     = linux.EACCES
 	EILSEQ     = linux.EILSEQ
 	EOPNOTSUPP = linux.EOPNOTSUPP
+	ENOSYS     = linux.ENOSYS
+	ETIME      = linux.ETIME
+	ENODATA    = linux.ENODATA
+	ERANGE     = linux.ERANGE
 )
 
 const (
-	BPF_F_NO_PREALLOC         = linux.BPF_F_NO_PREALLOC
-	BPF_F_NUMA_NODE           = linux.BPF_F_NUMA_NODE
-	BPF_F_RDONLY              = linux.BPF_F_RDONLY
-	BPF_F_WRONLY              = linux.BPF_F_WRONLY
-	BPF_F_RDONLY_PROG         = linux.BPF_F_RDONLY_PROG
-	BPF_F_WRONLY_PROG         = linux.BPF_F_WRONLY_PROG
-	BPF_F_SLEEPABLE           = linux.BPF_F_SLEEPABLE
-	BPF_F_XDP_HAS_FRAGS       = linux.BPF_F_XDP_HAS_FRAGS
-	BPF_F_MMAPABLE            = linux.BPF_F_MMAPABLE
-	BPF_F_INNER_MAP           = linux.BPF_F_INNER_MAP
-	BPF_F_KPROBE_MULTI_RETURN = linux.BPF_F_KPROBE_MULTI_RETURN
-	BPF_OBJ_NAME_LEN          = linux.BPF_OBJ_NAME_LEN
-	BPF_TAG_SIZE              = linux.BPF_TAG_SIZE
-	BPF_RINGBUF_BUSY_BIT      = linux.BPF_RINGBUF_BUSY_BIT
-	BPF_RINGBUF_DISCARD_BIT   = linux.BPF_RINGBUF_DISCARD_BIT
-	BPF_RINGBUF_HDR_SZ        = linux.BPF_RINGBUF_HDR_SZ
-	SYS_BPF                   = linux.SYS_BPF
-	F_DUPFD_CLOEXEC           = linux.F_DUPFD_CLOEXEC
-	EPOLL_CTL_ADD             = linux.EPOLL_CTL_ADD
-	EPOLL_CLOEXEC             = linux.EPOLL_CLOEXEC
-	O_CLOEXEC                 = linux.O_CLOEXEC
-	O_NONBLOCK                = linux.O_NONBLOCK
-	PROT_NONE                 = linux.PROT_NONE
-	PROT_READ                 = linux.PROT_READ
-	PROT_WRITE                = linux.PROT_WRITE
-	MAP_ANON                  = linux.MAP_ANON
-	MAP_SHARED                = linux.MAP_SHARED
-	MAP_PRIVATE               = linux.MAP_PRIVATE
-	PERF_ATTR_SIZE_VER1       = linux.PERF_ATTR_SIZE_VER1
-	PERF_TYPE_SOFTWARE        = linux.PERF_TYPE_SOFTWARE
-	PERF_TYPE_TRACEPOINT      = linux.PERF_TYPE_TRACEPOINT
-	PERF_COUNT_SW_BPF_OUTPUT  = linux.PERF_COUNT_SW_BPF_OUTPUT
-	PERF_EVENT_IOC_DISABLE    = linux.PERF_EVENT_IOC_DISABLE
-	PERF_EVENT_IOC_ENABLE     = linux.PERF_EVENT_IOC_ENABLE
-	PERF_EVENT_IOC_SET_BPF    = linux.PERF_EVENT_IOC_SET_BPF
-	PerfBitWatermark          = linux.PerfBitWatermark
-	PerfBitWriteBackward      = linux.PerfBitWriteBackward
-	PERF_SAMPLE_RAW           = linux.PERF_SAMPLE_RAW
-	PERF_FLAG_FD_CLOEXEC      = linux.PERF_FLAG_FD_CLOEXEC
-	RLIM_INFINITY             = linux.RLIM_INFINITY
-	RLIMIT_MEMLOCK            = linux.RLIMIT_MEMLOCK
-	BPF_STATS_RUN_TIME        = linux.BPF_STATS_RUN_TIME
-	PERF_RECORD_LOST          = linux.PERF_RECORD_LOST
-	PERF_RECORD_SAMPLE        = linux.PERF_RECORD_SAMPLE
-	AT_FDCWD                  = linux.AT_FDCWD
-	RENAME_NOREPLACE          = linux.RENAME_NOREPLACE
-	SO_ATTACH_BPF             = linux.SO_ATTACH_BPF
-	SO_DETACH_BPF             = linux.SO_DETACH_BPF
-	SOL_SOCKET                = linux.SOL_SOCKET
-	SIGPROF                   = linux.SIGPROF
-	SIG_BLOCK                 = linux.SIG_BLOCK
-	SIG_UNBLOCK               = linux.SIG_UNBLOCK
-	EM_NONE                   = linux.EM_NONE
-	EM_BPF                    = linux.EM_BPF
-	BPF_FS_MAGIC              = linux.BPF_FS_MAGIC
-	TRACEFS_MAGIC             = linux.TRACEFS_MAGIC
-	DEBUGFS_MAGIC             = linux.DEBUGFS_MAGIC
+	BPF_F_NO_PREALLOC           = linux.BPF_F_NO_PREALLOC
+	BPF_F_NUMA_NODE             = linux.BPF_F_NUMA_NODE
+	BPF_F_RDONLY                = linux.BPF_F_RDONLY
+	BPF_F_WRONLY                = linux.BPF_F_WRONLY
+	BPF_F_RDONLY_PROG           = linux.BPF_F_RDONLY_PROG
+	BPF_F_WRONLY_PROG           = linux.BPF_F_WRONLY_PROG
+	BPF_F_SLEEPABLE             = linux.BPF_F_SLEEPABLE
+	BPF_F_XDP_HAS_FRAGS         = linux.BPF_F_XDP_HAS_FRAGS
+	BPF_F_MMAPABLE              = linux.BPF_F_MMAPABLE
+	BPF_F_INNER_MAP             = linux.BPF_F_INNER_MAP
+	BPF_F_KPROBE_MULTI_RETURN   = linux.BPF_F_KPROBE_MULTI_RETURN
+	BPF_OBJ_NAME_LEN            = linux.BPF_OBJ_NAME_LEN
+	BPF_TAG_SIZE                = linux.BPF_TAG_SIZE
+	BPF_RINGBUF_BUSY_BIT        = linux.BPF_RINGBUF_BUSY_BIT
+	BPF_RINGBUF_DISCARD_BIT     = linux.BPF_RINGBUF_DISCARD_BIT
+	BPF_RINGBUF_HDR_SZ          = linux.BPF_RINGBUF_HDR_SZ
+	SYS_BPF                     = linux.SYS_BPF
+	SYS_IO_URING_SETUP          = linux.SYS_IO_URING_SETUP
+	SYS_IO_URING_ENTER          = linux.SYS_IO_URING_ENTER
+	F_DUPFD_CLOEXEC             = linux.F_DUPFD_CLOEXEC
+	EPOLL_CTL_ADD               = linux.EPOLL_CTL_ADD
+	EPOLL_CLOEXEC               = linux.EPOLL_CLOEXEC
+	O_CLOEXEC                   = linux.O_CLOEXEC
+	O_NONBLOCK                  = linux.O_NONBLOCK
+	PROT_NONE                   = linux.PROT_NONE
+	PROT_READ                   = linux.PROT_READ
+	PROT_WRITE                  = linux.PROT_WRITE
+	MAP_ANON                    = linux.MAP_ANON
+	MAP_SHARED                  = linux.MAP_SHARED
+	MAP_PRIVATE                 = linux.MAP_PRIVATE
+	PERF_ATTR_SIZE_VER1         = linux.PERF_ATTR_SIZE_VER1
+	PERF_TYPE_SOFTWARE          = linux.PERF_TYPE_SOFTWARE
+	PERF_TYPE_TRACEPOINT        = linux.PERF_TYPE_TRACEPOINT
+	PERF_COUNT_SW_BPF_OUTPUT    = linux.PERF_COUNT_SW_BPF_OUTPUT
+	PERF_EVENT_IOC_DISABLE      = linux.PERF_EVENT_IOC_DISABLE
+	PERF_EVENT_IOC_ENABLE       = linux.PERF_EVENT_IOC_ENABLE
+	PERF_EVENT_IOC_SET_BPF      = linux.PERF_EVENT_IOC_SET_BPF
+	PERF_EVENT_IOC_PAUSE_OUTPUT = linux.PERF_EVENT_IOC_PAUSE_OUTPUT
+	PerfBitWatermark            = linux.PerfBitWatermark
+	PerfBitWriteBackward        = linux.PerfBitWriteBackward
+	PERF_SAMPLE_RAW             = linux.PERF_SAMPLE_RAW
+	PERF_FLAG_FD_CLOEXEC        = linux.PERF_FLAG_FD_CLOEXEC
+	PERF_FLAG_PID_CGROUP        = linux.PERF_FLAG_PID_CGROUP
+	RLIM_INFINITY               = linux.RLIM_INFINITY
+	RLIMIT_MEMLOCK              = linux.RLIMIT_MEMLOCK
+	RUSAGE_SELF                 = linux.RUSAGE_SELF
+	BPF_STATS_RUN_TIME          = linux.BPF_STATS_RUN_TIME
+	BPF_F_TEST_RUN_ON_CPU       = linux.BPF_F_TEST_RUN_ON_CPU
+	BPF_F_TEST_XDP_LIVE_FRAMES  = linux.BPF_F_TEST_XDP_LIVE_FRAMES
+	PERF_RECORD_LOST            = linux.PERF_RECORD_LOST
+	PERF_RECORD_SAMPLE          = linux.PERF_RECORD_SAMPLE
+	AT_FDCWD                    = linux.AT_FDCWD
+	RENAME_NOREPLACE            = linux.RENAME_NOREPLACE
+	SO_ATTACH_BPF               = linux.SO_ATTACH_BPF
+	SO_DETACH_BPF               = linux.SO_DETACH_BPF
+	SOL_SOCKET                  = linux.SOL_SOCKET
+	SIGPROF                     = linux.SIGPROF
+	SIG_BLOCK                   = linux.SIG_BLOCK
+	SIG_UNBLOCK                 = linux.SIG_UNBLOCK
+	EM_NONE                     = linux.EM_NONE
+	EM_BPF                      = linux.EM_BPF
+	BPF_FS_MAGIC                = linux.BPF_FS_MAGIC
+	TRACEFS_MAGIC               = linux.TRACEFS_MAGIC
+	DEBUGFS_MAGIC               = linux.DEBUGFS_MAGIC
 )
 
 type Statfs_t = linux.Statfs_t
 type Stat_t = linux.Stat_t
 type Rlimit = linux.Rlimit
+type Rusage = linux.Rusage
 type Signal = linux.Signal
 type Sigset_t = linux.Sigset_t
 type PerfEventMmapPage = linux.PerfEventMmapPage
@@ -101,6 +113,10 @@ func Syscall(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err syscall.Errno) {
 	return linux.Syscall(trap, a1, a2, a3)
 }
 
+func Syscall6(trap, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err syscall.Errno) {
+	return linux.Syscall6(trap, a1, a2, a3, a4, a5, a6)
+}
+
 func PthreadSigmask(how int, set, oldset *Sigset_t) error {
 	return linux.PthreadSigmask(how, set, oldset)
 }
@@ -185,6 +201,10 @@ func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags
 	return linux.Renameat2(olddirfd, oldpath, newdirfd, newpath, flags)
 }
 
+func Getrusage(who int, rusage *Rusage) error {
+	return linux.Getrusage(who, rusage)
+}
+
 func Prlimit(pid, resource int, new, old *Rlimit) error {
 	return linux.Prlimit(pid, resource, new, old)
 }
@@ -196,3 +216,15 @@ func Open(path string, mode int, perm uint32) (int, error) {
 func Fstat(fd int, stat *Stat_t) error {
 	return linux.Fstat(fd, stat)
 }
+
+func Setxattr(path string, attr string, data []byte, flags int) error {
+	return linux.Setxattr(path, attr, data, flags)
+}
+
+func Getxattr(path string, attr string, dest []byte) (int, error) {
+	return linux.Getxattr(path, attr, dest)
+}
+
+func Setns(fd int, nstype int) error {
+	return linux.Setns(fd, nstype)
+}
@@ -27,6 +27,10 @@ const (
 	EACCES
 	EILSEQ
 	EOPNOTSUPP
+	ENOSYS
+	ETIME
+	ENODATA
+	ERANGE
 )
 
 // Constants are distinct to avoid breaking switch statements.
@@ -48,6 +52,8 @@ const (
 	BPF_RINGBUF_DISCARD_BIT
 	BPF_RINGBUF_HDR_SZ
 	SYS_BPF
+	SYS_IO_URING_SETUP
+	SYS_IO_URING_ENTER
 	F_DUPFD_CLOEXEC
 	EPOLLIN
 	EPOLL_CTL_ADD
@@ -67,13 +73,18 @@ const (
 	PERF_EVENT_IOC_DISABLE
 	PERF_EVENT_IOC_ENABLE
 	PERF_EVENT_IOC_SET_BPF
+	PERF_EVENT_IOC_PAUSE_OUTPUT
 	PerfBitWatermark
 	PerfBitWriteBackward
 	PERF_SAMPLE_RAW
 	PERF_FLAG_FD_CLOEXEC
+	PERF_FLAG_PID_CGROUP
 	RLIM_INFINITY
 	RLIMIT_MEMLOCK
+	RUSAGE_SELF
 	BPF_STATS_RUN_TIME
+	BPF_F_TEST_RUN_ON_CPU
+	BPF_F_TEST_XDP_LIVE_FRAMES
 	PERF_RECORD_LOST
 	PERF_RECORD_SAMPLE
 	AT_FDCWD
@@ -113,6 +124,20 @@ type Rlimit struct {
 	Max uint64
 }
 
+type Timeval struct {
+	Sec  int64
+	Usec int64
+}
+
+func (tv *Timeval) Nano() int64 {
+	return tv.Sec*1e9 + tv.Usec*1e3
+}
+
+type Rusage struct {
+	Utime Timeval
+	Stime Timeval
+}
+
 type Signal int
 
 type Sigset_t struct {
@@ -123,6 +148,10 @@ func Syscall(trap, a1, a2, a3 uintptr) (r1, r2 uintptr, err syscall.Errno) {
 	return 0, 0, syscall.ENOTSUP
 }
 
+func Syscall6(trap, a1, a2, a3, a4, a5, a6 uintptr) (r1, r2 uintptr, err syscall.Errno) {
+	return 0, 0, syscall.ENOTSUP
+}
+
 func PthreadSigmask(how int, set, oldset *Sigset_t) error {
 	return errNonLinux
 }
@@ -265,6 +294,10 @@ func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags
 	return errNonLinux
 }
 
+func Getrusage(who int, rusage *Rusage) error {
+	return errNonLinux
+}
+
 func Prlimit(pid, resource int, new, old *Rlimit) error {
 	return errNonLinux
 }
@@ -276,3 +309,15 @@ func Open(path string, mode int, perm uint32) (int, error) {
 func Fstat(fd int, stat *Stat_t) error {
 	return errNonLinux
 }
+
+func Setxattr(path string, attr string, data []byte, flags int) error {
+	return errNonLinux
+}
+
+func Getxattr(path string, attr string, dest []byte) (int, error) {
+	return 0, errNonLinux
+}
+
+func Setns(fd int, nstype int) error {
+	return errNonLinux
+}
@@ -0,0 +1,257 @@
+package link
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// AttachKind is the kind of hook a program is attached to.
+type AttachKind int
+
+const (
+	// The program can't be attached based on its section name.
+	AttachKindNone AttachKind = iota
+	AttachKindKprobe
+	AttachKindKretprobe
+	AttachKindTracepoint
+	AttachKindRawTracepoint
+	AttachKindTracing
+	AttachKindLSM
+	AttachKindCgroup
+	AttachKindXDP
+)
+
+func (k AttachKind) String() string {
+	switch k {
+	case AttachKindKprobe:
+		return "kprobe"
+	case AttachKindKretprobe:
+		return "kretprobe"
+	case AttachKindTracepoint:
+		return "tracepoint"
+	case AttachKindRawTracepoint:
+		return "raw_tracepoint"
+	case AttachKindTracing:
+		return "tracing"
+	case AttachKindLSM:
+		return "lsm"
+	case AttachKindCgroup:
+		return "cgroup"
+	case AttachKindXDP:
+		return "xdp"
+	default:
+		return "none"
+	}
+}
+
+// AttachPlan describes where a program should be attached, as declared by
+// the ELF section it was loaded from.
+type AttachPlan struct {
+	Kind AttachKind
+	// The attach target. Its interpretation depends on Kind:
+	//   - the kernel symbol for kprobes
+	//   - group/name for tracepoints
+	//   - the tracepoint name for raw tracepoints
+	// Empty for all other kinds.
+	Target string
+	// The offset into Target for kprobes, given as kprobe/<symbol>+<offset>.
+	Offset uint64
+}
+
+// NewAttachPlan derives an AttachPlan from the section name and type of a
+// program.
+//
+// Returns an AttachPlan of kind AttachKindNone if the section name doesn't
+// declare an attach point.
+func NewAttachPlan(spec *ebpf.ProgramSpec) (AttachPlan, error) {
+	section := spec.SectionName
+	prefix, target, _ := strings.Cut(section, "/")
+
+	switch prefix {
+	case "kprobe", "kretprobe":
+		if target == "" {
+			return AttachPlan{}, fmt.Errorf("section %s: missing symbol", section)
+		}
+
+		plan := AttachPlan{Kind: AttachKindKprobe, Target: target}
+		if prefix == "kretprobe" {
+			plan.Kind = AttachKindKretprobe
+		}
+
+		if symbol, offset, ok := strings.Cut(target, "+"); ok {
+			if plan.Kind == AttachKindKretprobe {
+				return AttachPlan{}, fmt.Errorf("section %s: kretprobes don't support an offset", section)
+			}
+
+			off, err := strconv.ParseUint(offset, 0, 64)
+			if err != nil || symbol == "" {
+				return AttachPlan{}, fmt.Errorf("section %s: expected kprobe/<symbol>+<offset>", section)
+			}
+			plan.Target, plan.Offset = symbol, off
+		}
+
+		return plan, nil
+
+	case "tracepoint", "tp":
+		if group, name, _ := strings.Cut(target, "/"); group == "" || name == "" {
+			return AttachPlan{}, fmt.Errorf("section %s: expected %s/<group>/<name>", section, prefix)
+		}
+		return AttachPlan{Kind: AttachKindTracepoint, Target: target}, nil
+
+	case "raw_tracepoint", "raw_tp":
+		if target == "" {
+			return AttachPlan{}, fmt.Errorf("section %s: missing tracepoint name", section)
+		}
+		return AttachPlan{Kind: AttachKindRawTracepoint, Target: target}, nil
+
+	case "xdp", "xdp.frags":
+		// Programs for devmaps and cpumaps are invoked via a map.
+		if spec.AttachType != ebpf.AttachXDP {
+			return AttachPlan{Kind: AttachKindNone}, nil
+		}
+		return AttachPlan{Kind: AttachKindXDP}, nil
+
+	case "tc", "classifier":
+		// tc programs are attached via netlink, which this package doesn't
+		// implement.
+		return AttachPlan{Kind: AttachKindNone}, nil
+	}
+
+	switch spec.Type {
+	case ebpf.Tracing:
+		switch spec.AttachType {
+		case ebpf.AttachTraceFEntry, ebpf.AttachTraceFExit, ebpf.AttachModifyReturn, ebpf.AttachTraceRawTp:
+			return AttachPlan{Kind: AttachKindTracing}, nil
+		}
+
+	case ebpf.LSM:
+		return AttachPlan{Kind: AttachKindLSM}, nil
+
+	case ebpf.CGroupSKB, ebpf.CGroupSock, ebpf.CGroupDevice, ebpf.CGroupSockAddr,
+		ebpf.CGroupSysctl, ebpf.CGroupSockopt, ebpf.SockOps:
+		return AttachPlan{Kind: AttachKindCgroup}, nil
+	}
+
+	return AttachPlan{Kind: AttachKindNone}, nil
+}
+
+// AttachAllOptions control AttachAll.
+type AttachAllOptions struct {
+	// Path of the cgroupv2 to attach cgroup programs to.
+	Cgroup string
+	// Index of the network interface to attach XDP programs to.
+	Interface int
+	// Override attaches the named programs instead of their AttachPlan.
+	// Returning a nil Link leaves the program detached.
+	Override map[string]func(*ebpf.Program) (Link, error)
+}
+
+// AttachAll attaches all programs of coll according to their AttachPlan,
+// which is derived from the program specs in spec.
+//
+// Programs without an AttachPlan, like tc programs, are skipped unless an
+// override is provided. Attaching programs which require an option that isn't
+// set is an error.
+//
+// On success the returned Manager owns coll and all links. On error, links
+// created so far are closed but coll is left open.
+func AttachAll(spec *ebpf.CollectionSpec, coll *ebpf.Collection, opts AttachAllOptions) (*Manager, error) {
+	names := make([]string, 0, len(coll.Programs))
+	for name := range coll.Programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mgr := NewManager(coll)
+	for _, name := range names {
+		attach := opts.Override[name]
+		if attach == nil {
+			progSpec := spec.Programs[name]
+			if progSpec == nil {
+				mgr.closeLinks()
+				return nil, fmt.Errorf("missing spec for program %s", name)
+			}
+
+			plan, err := NewAttachPlan(progSpec)
+			if err != nil {
+				mgr.closeLinks()
+				return nil, fmt.Errorf("program %s: %w", name, err)
+			}
+
+			if plan.Kind == AttachKindNone {
+				continue
+			}
+
+			attach, err = plan.attachFunc(progSpec, opts)
+			if err != nil {
+				mgr.closeLinks()
+				return nil, fmt.Errorf("program %s: %w", name, err)
+			}
+		}
+
+		if _, err := mgr.Attach(name, attach); err != nil {
+			mgr.closeLinks()
+			return nil, err
+		}
+	}
+
+	return mgr, nil
+}
+
+// attachFunc returns a function which attaches a program according to plan.
+func (plan AttachPlan) attachFunc(spec *ebpf.ProgramSpec, opts AttachAllOptions) (func(*ebpf.Program) (Link, error), error) {
+	switch plan.Kind {
+	case AttachKindKprobe:
+		return func(prog *ebpf.Program) (Link, error) {
+			return Kprobe(plan.Target, prog, &KprobeOptions{Offset: plan.Offset})
+		}, nil
+
+	case AttachKindKretprobe:
+		return func(prog *ebpf.Program) (Link, error) {
+			return Kretprobe(plan.Target, prog, nil)
+		}, nil
+
+	case AttachKindTracepoint:
+		group, name, _ := strings.Cut(plan.Target, "/")
+		return func(prog *ebpf.Program) (Link, error) {
+			return Tracepoint(group, name, prog, nil)
+		}, nil
+
+	case AttachKindRawTracepoint:
+		return func(prog *ebpf.Program) (Link, error) {
+			return AttachRawTracepoint(RawTracepointOptions{Name: plan.Target, Program: prog})
+		}, nil
+
+	case AttachKindTracing:
+		return func(prog *ebpf.Program) (Link, error) {
+			return AttachTracing(TracingOptions{Program: prog, AttachType: spec.AttachType})
+		}, nil
+
+	case AttachKindLSM:
+		return func(prog *ebpf.Program) (Link, error) {
+			return AttachLSM(LSMOptions{Program: prog})
+		}, nil
+
+	case AttachKindCgroup:
+		if opts.Cgroup == "" {
+			return nil, fmt.Errorf("%s program requires AttachAllOptions.Cgroup", spec.Type)
+		}
+		return func(prog *ebpf.Program) (Link, error) {
+			return AttachCgroup(CgroupOptions{Path: opts.Cgroup, Attach: spec.AttachType, Program: prog})
+		}, nil
+
+	case AttachKindXDP:
+		if opts.Interface == 0 {
+			return nil, fmt.Errorf("XDP program requires AttachAllOptions.Interface")
+		}
+		return func(prog *ebpf.Program) (Link, error) {
+			return AttachXDP(XDPOptions{Program: prog, Interface: opts.Interface})
+		}, nil
+	}
+
+	return nil, fmt.Errorf("attach %s: %w", plan.Kind, ErrNotSupported)
+}
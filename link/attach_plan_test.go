@@ -0,0 +1,134 @@
+package link
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestNewAttachPlan(t *testing.T) {
+	for _, tc := range []struct {
+		section string
+		typ     ebpf.ProgramType
+		attach  ebpf.AttachType
+		plan    AttachPlan
+	}{
+		{"kprobe/sys_open", ebpf.Kprobe, 0, AttachPlan{Kind: AttachKindKprobe, Target: "sys_open"}},
+		{"kprobe/sys_open+0x10", ebpf.Kprobe, 0, AttachPlan{Kind: AttachKindKprobe, Target: "sys_open", Offset: 0x10}},
+		{"kretprobe/sys_open", ebpf.Kprobe, 0, AttachPlan{Kind: AttachKindKretprobe, Target: "sys_open"}},
+		{"tracepoint/syscalls/sys_enter_open", ebpf.TracePoint, 0, AttachPlan{Kind: AttachKindTracepoint, Target: "syscalls/sys_enter_open"}},
+		{"tp/sched/sched_process_exec", ebpf.TracePoint, 0, AttachPlan{Kind: AttachKindTracepoint, Target: "sched/sched_process_exec"}},
+		{"raw_tp/sched_switch", ebpf.RawTracepoint, 0, AttachPlan{Kind: AttachKindRawTracepoint, Target: "sched_switch"}},
+		{"raw_tracepoint/sched_switch", ebpf.RawTracepoint, 0, AttachPlan{Kind: AttachKindRawTracepoint, Target: "sched_switch"}},
+		{"fentry/sys_open", ebpf.Tracing, ebpf.AttachTraceFEntry, AttachPlan{Kind: AttachKindTracing}},
+		{"fexit.s/sys_open", ebpf.Tracing, ebpf.AttachTraceFExit, AttachPlan{Kind: AttachKindTracing}},
+		{"lsm/file_open", ebpf.LSM, ebpf.AttachLSMMac, AttachPlan{Kind: AttachKindLSM}},
+		{"cgroup_skb/ingress", ebpf.CGroupSKB, ebpf.AttachCGroupInetIngress, AttachPlan{Kind: AttachKindCgroup}},
+		{"cgroup/connect4", ebpf.CGroupSockAddr, ebpf.AttachCGroupInet4Connect, AttachPlan{Kind: AttachKindCgroup}},
+		{"xdp", ebpf.XDP, ebpf.AttachXDP, AttachPlan{Kind: AttachKindXDP}},
+		{"xdp.frags/foo", ebpf.XDP, ebpf.AttachXDP, AttachPlan{Kind: AttachKindXDP}},
+		{"xdp/devmap", ebpf.XDP, ebpf.AttachXDPDevMap, AttachPlan{Kind: AttachKindNone}},
+		{"xdp.frags/cpumap", ebpf.XDP, ebpf.AttachXDPCPUMap, AttachPlan{Kind: AttachKindNone}},
+		{"tc", ebpf.SchedCLS, 0, AttachPlan{Kind: AttachKindNone}},
+		{"classifier/ingress", ebpf.SchedCLS, 0, AttachPlan{Kind: AttachKindNone}},
+		{"socket", ebpf.SocketFilter, 0, AttachPlan{Kind: AttachKindNone}},
+		{"iter/bpf_map", ebpf.Tracing, ebpf.AttachTraceIter, AttachPlan{Kind: AttachKindNone}},
+	} {
+		t.Run(tc.section, func(t *testing.T) {
+			plan, err := NewAttachPlan(&ebpf.ProgramSpec{
+				SectionName: tc.section,
+				Type:        tc.typ,
+				AttachType:  tc.attach,
+			})
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, plan, qt.Equals, tc.plan)
+		})
+	}
+
+	for _, section := range []string{
+		"kprobe/",
+		"kprobe",
+		"kprobe/+8",
+		"kprobe/sys_open+",
+		"kprobe/sys_open+foo",
+		"kretprobe/sys_open+8",
+		"tp/sched",
+		"tracepoint//foo",
+		"raw_tp/",
+	} {
+		t.Run(section, func(t *testing.T) {
+			_, err := NewAttachPlan(&ebpf.ProgramSpec{SectionName: section})
+			qt.Assert(t, err, qt.IsNotNil)
+		})
+	}
+}
+
+func TestAttachAll(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "BPF_LINK_TYPE_XDP")
+
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"array": {Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: 1},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"xdp": xdpSpec(2),
+			"socket": {
+				Type:        ebpf.SocketFilter,
+				SectionName: "socket",
+				Instructions: asm.Instructions{
+					asm.Mov.Imm(asm.R0, 0),
+					asm.Return(),
+				},
+				License: "MIT",
+			},
+		},
+	}
+	spec.Programs["xdp"].SectionName = "xdp"
+	spec.Programs["xdp"].AttachType = ebpf.AttachXDP
+
+	coll, err := ebpf.NewCollection(spec)
+	qt.Assert(t, err, qt.IsNil)
+	defer coll.Close()
+
+	_, err = AttachAll(spec, coll, AttachAllOptions{})
+	qt.Assert(t, err, qt.IsNotNil, qt.Commentf("missing interface should return an error"))
+
+	mgr, err := AttachAll(spec, coll, AttachAllOptions{Interface: IfIndexLO})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, mgr.links["xdp"], qt.HasLen, 1)
+	qt.Assert(t, mgr.links["socket"], qt.HasLen, 0)
+
+	info, err := mgr.links["xdp"][0].Info()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, info.Type, qt.Equals, XDPType)
+
+	// Closing the links leaves the collection usable.
+	qt.Assert(t, mgr.closeLinks(), qt.IsNil)
+
+	var called bool
+	mgr, err = AttachAll(spec, coll, AttachAllOptions{
+		Override: map[string]func(*ebpf.Program) (Link, error){
+			"xdp": func(prog *ebpf.Program) (Link, error) {
+				called = true
+				return nil, nil
+			},
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, called, qt.IsTrue)
+	qt.Assert(t, mgr.links["xdp"], qt.HasLen, 0)
+
+	errFailed := errors.New("failed")
+	_, err = AttachAll(spec, coll, AttachAllOptions{
+		Override: map[string]func(*ebpf.Program) (Link, error){
+			"xdp": func(*ebpf.Program) (Link, error) { return nil, errFailed },
+		},
+	})
+	qt.Assert(t, errors.Is(err, errFailed), qt.IsTrue)
+}
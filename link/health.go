@@ -0,0 +1,75 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+)
+
+// Health contains counters of events which fired the hook of a link, but
+// were dropped before or instead of running the attached program.
+//
+// Non-zero counters mean that tracing is silently losing hits, for example
+// under high load or because a program triggers its own hook.
+type Health struct {
+	probeMisses     uint64
+	haveProbeMisses bool
+
+	recursionMisses     uint64
+	haveRecursionMisses bool
+}
+
+// QueryHealth retrieves the miss counters of a link and its program.
+//
+// Returns an error wrapping ErrNotSupported if the link doesn't support
+// querying its metadata, e.g. perf event links on kernels older than 5.15.
+func QueryHealth(l Link) (*Health, error) {
+	info, err := l.Info()
+	if err != nil {
+		return nil, fmt.Errorf("link health: %w", err)
+	}
+
+	var h Health
+	if pe := info.PerfEvent(); pe != nil {
+		if kp := pe.Kprobe(); kp != nil {
+			h.probeMisses, h.haveProbeMisses = kp.Missed, true
+		}
+	}
+	if km := info.KprobeMulti(); km != nil {
+		h.probeMisses, h.haveProbeMisses = km.Missed, true
+	}
+
+	prog, err := ebpf.NewProgramFromID(info.Program)
+	if err != nil {
+		return nil, fmt.Errorf("link health: get program %d: %w", info.Program, err)
+	}
+	defer prog.Close()
+
+	pi, err := prog.Info()
+	if err != nil {
+		return nil, fmt.Errorf("link health: %w", err)
+	}
+	h.recursionMisses, h.haveRecursionMisses = pi.RecursionMisses()
+
+	return &h, nil
+}
+
+// ProbeMisses returns the number of times a kprobe fired without running the
+// program, as reported by the kernel's nmissed counter.
+//
+// Available from 6.7 for kprobe and kprobe_multi links.
+//
+// The bool return value indicates whether this optional field is available.
+func (h *Health) ProbeMisses() (uint64, bool) {
+	return h.probeMisses, h.haveProbeMisses
+}
+
+// RecursionMisses returns the number of times the program attached to the link
+// was not run because it was already running on the same CPU.
+//
+// Available from 5.12.
+//
+// The bool return value indicates whether this optional field is available.
+func (h *Health) RecursionMisses() (uint64, bool) {
+	return h.recursionMisses, h.haveRecursionMisses
+}
@@ -0,0 +1,33 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestQueryHealth(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	link, err := AttachRawLink(RawLinkOptions{
+		Target:  int(cgroup.Fd()),
+		Program: prog,
+		Attach:  ebpf.AttachCGroupInetEgress,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer link.Close()
+
+	h, err := QueryHealth(link)
+	qt.Assert(t, err, qt.IsNil)
+
+	_, ok := h.ProbeMisses()
+	qt.Assert(t, ok, qt.IsFalse)
+
+	misses, ok := h.RecursionMisses()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, misses, qt.Equals, uint64(0))
+}
@@ -0,0 +1,119 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf/internal/sys"
+)
+
+// NewFromID returns the link associated with the given id.
+//
+// Returns ErrNotExist if there is no link with the given id.
+func NewFromID(id ID) (Link, error) {
+	raw, err := newRawLinkFromID(id)
+	if err != nil {
+		return nil, err
+	}
+	return wrapRawLink(raw)
+}
+
+func newRawLinkFromID(id ID) (*RawLink, error) {
+	fd, err := sys.LinkGetFdById(&sys.LinkGetFdByIdAttr{Id: id})
+	if err != nil {
+		return nil, fmt.Errorf("get link fd from ID %d: %w", id, err)
+	}
+	return &RawLink{fd: fd}, nil
+}
+
+// Iterator allows enumerating the links in the kernel.
+//
+// Requires CAP_SYS_ADMIN.
+type Iterator struct {
+	// The ID of the current link. Only valid after a call to Next.
+	ID ID
+	// The current Link. Only valid until a call to Next.
+	// See Take if you want to retain the link.
+	//
+	// Links attached via a perf event are returned as a *RawLink.
+	Link Link
+	err  error
+}
+
+// Next retrieves the next link.
+//
+// Returns true if another link was found. Call [Iterator.Err] after the
+// function returns false.
+func (it *Iterator) Next() bool {
+	id := it.ID
+	for {
+		attr := &sys.LinkGetNextIdAttr{Id: id}
+		err := sys.LinkGetNextId(attr)
+		if errors.Is(err, os.ErrNotExist) {
+			// There are no more links.
+			break
+		} else if err != nil {
+			it.err = fmt.Errorf("get next link ID: %w", err)
+			break
+		}
+
+		id = attr.NextId
+		l, err := it.open(id)
+		if errors.Is(err, os.ErrNotExist) {
+			// The link was released in the meantime.
+			continue
+		} else if err != nil {
+			it.err = fmt.Errorf("open link %d: %w", id, err)
+			break
+		}
+
+		it.close()
+		it.ID, it.Link = id, l
+		return true
+	}
+
+	// No more links or we encountered an error.
+	it.close()
+	it.Link = nil
+	return false
+}
+
+func (it *Iterator) open(id ID) (Link, error) {
+	raw, err := newRawLinkFromID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, err := raw.typ()
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+
+	if typ == PerfEventType {
+		// The perf event can't be recovered from the link.
+		return raw, nil
+	}
+	return wrapRawLink(raw)
+}
+
+func (it *Iterator) close() {
+	if it.Link != nil {
+		it.Link.Close()
+	}
+}
+
+// Take the ownership of the current link.
+//
+// It's the callers responsibility to close the link.
+func (it *Iterator) Take() Link {
+	l := it.Link
+	it.Link = nil
+	return l
+}
+
+// Err returns an error if iteration failed for some reason.
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,71 @@
+package link
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestIterator(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	raw, err := AttachRawLink(RawLinkOptions{
+		Target:  int(cgroup.Fd()),
+		Program: prog,
+		Attach:  ebpf.AttachCGroupInetEgress,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer raw.Close()
+
+	info, err := raw.Info()
+	qt.Assert(t, err, qt.IsNil)
+
+	var found Link
+	it := new(Iterator)
+	for it.Next() {
+		if it.ID == info.ID {
+			found = it.Take()
+		}
+	}
+	qt.Assert(t, it.Err(), qt.IsNil)
+	qt.Assert(t, it.Link, qt.IsNil)
+	qt.Assert(t, found, qt.IsNotNil)
+	defer found.Close()
+
+	_, ok := found.(*linkCgroup)
+	qt.Assert(t, ok, qt.IsTrue, qt.Commentf("got %T", found))
+
+	foundInfo, err := found.Info()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, foundInfo.Program, qt.Equals, info.Program)
+}
+
+func TestNewFromID(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	raw, err := AttachRawLink(RawLinkOptions{
+		Target:  int(cgroup.Fd()),
+		Program: prog,
+		Attach:  ebpf.AttachCGroupInetEgress,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer raw.Close()
+
+	info, err := raw.Info()
+	qt.Assert(t, err, qt.IsNil)
+
+	l, err := NewFromID(info.ID)
+	qt.Assert(t, err, qt.IsNil)
+	l.Close()
+
+	_, err = NewFromID(ID(math.MaxUint32))
+	qt.Assert(t, errors.Is(err, os.ErrNotExist), qt.IsTrue, qt.Commentf("got %v", err))
+}
@@ -105,6 +105,31 @@ func Kretprobe(symbol string, prog *ebpf.Program, opts *KprobeOptions) (Link, er
 	return lnk, nil
 }
 
+// SymbolNotProbeableError is returned when attaching to a kernel symbol which
+// the kernel refuses to probe.
+type SymbolNotProbeableError struct {
+	Symbol string
+	// Reason describes why the symbol can't be probed.
+	Reason string
+}
+
+func (e *SymbolNotProbeableError) Error() string {
+	return fmt.Sprintf("symbol %s is not probeable: %s", e.Symbol, e.Reason)
+}
+
+// checkKprobeBlacklist returns a SymbolNotProbeableError if symbol is on the
+// kprobe blacklist.
+//
+// The check is skipped if the blacklist can't be read.
+func checkKprobeBlacklist(symbol string) error {
+	blacklisted, err := tracefs.KprobeBlacklisted(symbol)
+	if err != nil || !blacklisted {
+		return nil
+	}
+
+	return &SymbolNotProbeableError{symbol, "listed in kprobes/blacklist"}
+}
+
 // isValidKprobeSymbol implements the equivalent of a regex match
 // against "^[a-zA-Z_][0-9a-zA-Z_.]*$".
 func isValidKprobeSymbol(s string) bool {
@@ -147,6 +172,9 @@ func kprobe(symbol string, prog *ebpf.Program, opts *KprobeOptions, ret bool) (*
 	if prog.Type() != ebpf.Kprobe {
 		return nil, fmt.Errorf("eBPF program type %s is not a Kprobe: %w", prog.Type(), errInvalidInput)
 	}
+	if err := checkKprobeBlacklist(symbol); err != nil {
+		return nil, err
+	}
 
 	args := tracefs.ProbeArgs{
 		Type:   tracefs.Kprobe,
@@ -316,6 +344,36 @@ func pmuProbe(args tracefs.ProbeArgs) (*perfEvent, error) {
 	return newPerfEvent(fd, nil), nil
 }
 
+// maxGroupAttempts is the number of random group names tracefsProbe tries
+// before giving up.
+const maxGroupAttempts = 3
+
+// RemoveStaleTraceFSProbes removes kprobe and uprobe trace events left behind
+// by processes which exited without closing their links.
+//
+// Only events in groups created by this package with the given prefix are
+// considered, see KprobeOptions.TraceFSPrefix. The empty prefix is equivalent
+// to "ebpf". Events which are still in use are not removed.
+//
+// Call this during startup to reclaim probes of a previous instance which
+// crashed. Returns the number of removed events.
+func RemoveStaleTraceFSProbes(prefix string) (int, error) {
+	if prefix == "" {
+		prefix = "ebpf"
+	}
+
+	var removed int
+	for _, typ := range []tracefs.ProbeType{tracefs.Kprobe, tracefs.Uprobe} {
+		n, err := tracefs.RemoveStaleEvents(typ, prefix)
+		removed += n
+		if err != nil {
+			return removed, fmt.Errorf("remove stale %ss: %w", typ, err)
+		}
+	}
+
+	return removed, nil
+}
+
 // tracefsProbe creates a trace event by writing an entry to <tracefs>/[k,u]probe_events.
 // A new trace event group name is generated on every call to support creating
 // multiple trace events for the same kernel or userspace symbol.
@@ -328,17 +386,27 @@ func tracefsProbe(args tracefs.ProbeArgs) (*perfEvent, error) {
 		groupPrefix = args.Group
 	}
 
-	// Generate a random string for each trace event we attempt to create.
-	// This value is used as the 'group' token in tracefs to allow creating
-	// multiple kprobe trace events with the same name.
-	group, err := tracefs.RandomGroup(groupPrefix)
-	if err != nil {
-		return nil, fmt.Errorf("randomizing group name: %w", err)
-	}
-	args.Group = group
+	var (
+		evt *tracefs.Event
+		err error
+	)
+	for i := 0; i < maxGroupAttempts; i++ {
+		// Generate a random string for each trace event we attempt to create.
+		// This value is used as the 'group' token in tracefs to allow creating
+		// multiple kprobe trace events with the same name.
+		args.Group, err = tracefs.RandomGroup(groupPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("randomizing group name: %w", err)
+		}
 
-	// Create the [k,u]probe trace event using tracefs.
-	evt, err := tracefs.NewEvent(args)
+		// Create the [k,u]probe trace event using tracefs. Another process
+		// may have raced us to the same group, in which case we simply try
+		// again with a different one.
+		evt, err = tracefs.NewEvent(args)
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("creating probe entry on tracefs: %w", err)
 	}
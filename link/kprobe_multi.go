@@ -10,6 +10,7 @@ import (
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/tracefs"
 	"github.com/cilium/ebpf/internal/unix"
 )
 
@@ -111,6 +112,9 @@ func kprobeMulti(prog *ebpf.Program, opts KprobeMultiOptions, flags uint32) (Lin
 		return nil, fmt.Errorf("couldn't find one or more symbols: %w", os.ErrNotExist)
 	}
 	if errors.Is(err, unix.EINVAL) {
+		if err := checkKprobeMultiSymbols(opts.Symbols); err != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("%w (missing kernel symbol or prog's AttachType not AttachTraceKprobeMulti?)", err)
 	}
 	if err != nil {
@@ -120,6 +124,32 @@ func kprobeMulti(prog *ebpf.Program, opts KprobeMultiOptions, flags uint32) (Lin
 	return &kprobeMultiLink{RawLink{fd, ""}}, nil
 }
 
+// checkKprobeMultiSymbols returns a SymbolNotProbeableError for the first
+// symbol which can't be attached to via fprobe.
+//
+// Checks are skipped if the relevant files in debugfs or tracefs can't be
+// read.
+func checkKprobeMultiSymbols(symbols []string) error {
+	for _, symbol := range symbols {
+		if err := checkKprobeBlacklist(symbol); err != nil {
+			return err
+		}
+	}
+
+	funcs, err := tracefs.TraceableFunctions()
+	if err != nil {
+		return nil
+	}
+
+	for _, symbol := range symbols {
+		if _, ok := funcs[symbol]; !ok {
+			return &SymbolNotProbeableError{symbol, "not in available_filter_functions (notrace or inlined)"}
+		}
+	}
+
+	return nil
+}
+
 type kprobeMultiLink struct {
 	RawLink
 }
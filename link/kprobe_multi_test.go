@@ -26,6 +26,35 @@ func TestKprobeMulti(t *testing.T) {
 	testLink(t, km, prog)
 }
 
+func TestKprobeMultiInfo(t *testing.T) {
+	testutils.SkipIfNotSupported(t, haveBPFLinkKprobeMulti())
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, ebpf.AttachTraceKprobeMulti, "")
+
+	km, err := KretprobeMulti(prog, KprobeMultiOptions{Symbols: kprobeMultiSyms})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer km.Close()
+
+	info, err := km.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kmi := info.KprobeMulti()
+	if kmi == nil || kmi.Count == 0 {
+		t.Skip("Kernel doesn't expose kprobe_multi link info")
+	}
+
+	if kmi.Count != uint32(len(kprobeMultiSyms)) {
+		t.Errorf("Expected %d functions, got %d", len(kprobeMultiSyms), kmi.Count)
+	}
+	if !kmi.Retprobe {
+		t.Error("Expected Retprobe to be true")
+	}
+}
+
 func TestKprobeMultiInput(t *testing.T) {
 	// Program type that loads on all kernels. Not expected to link successfully.
 	prog := mustLoadProgram(t, ebpf.SocketFilter, 0, "")
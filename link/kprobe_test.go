@@ -1,8 +1,10 @@
 package link
 
 import (
+	"bufio"
 	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	qt "github.com/frankban/quicktest"
@@ -121,6 +123,56 @@ func TestKretprobe(t *testing.T) {
 	testLink(t, k, prog)
 }
 
+func TestKprobeInfo(t *testing.T) {
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+
+	k, err := Kretprobe(ksym, prog, nil)
+	qt.Assert(t, err, qt.IsNil)
+	defer k.Close()
+
+	info, err := k.Info()
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+
+	pe := info.PerfEvent()
+	if pe == nil || pe.Type == 0 {
+		t.Skip("Kernel doesn't expose perf event link info")
+	}
+
+	kp := pe.Kprobe()
+	qt.Assert(t, kp, qt.IsNotNil)
+	qt.Assert(t, kp.Function, qt.Equals, ksym)
+	qt.Assert(t, kp.Retprobe, qt.IsTrue)
+}
+
+func TestKprobeBlacklisted(t *testing.T) {
+	f, err := os.Open("/sys/kernel/debug/kprobes/blacklist")
+	if err != nil {
+		t.Skip("Can't read kprobe blacklist:", err)
+	}
+	defer f.Close()
+
+	var symbol string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && isValidKprobeSymbol(fields[len(fields)-1]) {
+			symbol = fields[len(fields)-1]
+			break
+		}
+	}
+	if symbol == "" {
+		t.Skip("No blacklisted symbols")
+	}
+
+	prog := mustLoadProgram(t, ebpf.Kprobe, 0, "")
+
+	_, err = Kprobe(symbol, prog, nil)
+	var npe *SymbolNotProbeableError
+	qt.Assert(t, errors.As(err, &npe), qt.IsTrue, qt.Commentf("got error: %v", err))
+	qt.Assert(t, npe.Symbol, qt.Equals, symbol)
+}
+
 func TestKprobeErrors(t *testing.T) {
 	c := qt.New(t)
 
@@ -231,6 +283,11 @@ func TestKprobeTraceFS(t *testing.T) {
 	defer k3.Close()
 	c.Assert(k3.tracefsEvent.Group(), qt.Matches, `customgroup_[a-f0-9]{16}`)
 
+	// Probes of a live process are never stale.
+	n, err := RemoveStaleTraceFSProbes(cg)
+	c.Assert(err, qt.IsNil)
+	c.Assert(n, qt.Equals, 0)
+
 	// Prepare probe args.
 	args := tracefs.ProbeArgs{Type: tracefs.Kprobe, Group: "testgroup", Symbol: "symbol"}
 
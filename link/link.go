@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"os"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
 )
 
 var ErrNotSupported = internal.ErrNotSupported
@@ -78,12 +80,12 @@ func wrapRawLink(raw *RawLink) (_ Link, err error) {
 		}
 	}()
 
-	info, err := raw.Info()
+	typ, err := raw.typ()
 	if err != nil {
 		return nil, err
 	}
 
-	switch info.Type {
+	switch typ {
 	case RawTracepointType:
 		return &rawTracepoint{*raw}, nil
 	case TracingType:
@@ -96,6 +98,8 @@ func wrapRawLink(raw *RawLink) (_ Link, err error) {
 		return &NetNsLink{*raw}, nil
 	case KprobeMultiType:
 		return &kprobeMultiLink{*raw}, nil
+	case StructOpsType:
+		return &structOpsLink{*raw}, nil
 	case PerfEventType:
 		return nil, fmt.Errorf("recovering perf event fd: %w", ErrNotSupported)
 	default:
@@ -118,6 +122,12 @@ type RawLinkOptions struct {
 	BTF btf.TypeID
 	// Flags control the attach behaviour.
 	Flags uint32
+	// Namespace is entered while creating the link, for example a network
+	// namespace opened from /proc/<pid>/ns/net. Target is resolved in this
+	// namespace if it is an interface index. Optional.
+	//
+	// Requires CAP_SYS_ADMIN. User and mount namespaces are not supported.
+	Namespace *os.File
 }
 
 // Info contains metadata on a link.
@@ -133,6 +143,74 @@ type CgroupInfo sys.CgroupLinkInfo
 type NetNsInfo sys.NetNsLinkInfo
 type XDPInfo sys.XDPLinkInfo
 
+// PerfEventInfo contains metadata about a link attached via a perf event.
+//
+// Requires at least Linux 6.6.
+type PerfEventInfo struct {
+	Type  sys.PerfEventType
+	extra interface{}
+}
+
+// Kprobe returns information about a kprobe or kretprobe.
+//
+// Returns nil if the perf event isn't a kprobe.
+func (r *PerfEventInfo) Kprobe() *KprobeInfo {
+	e, _ := r.extra.(*KprobeInfo)
+	return e
+}
+
+// Uprobe returns information about a uprobe or uretprobe.
+//
+// Returns nil if the perf event isn't a uprobe.
+func (r *PerfEventInfo) Uprobe() *UprobeInfo {
+	e, _ := r.extra.(*UprobeInfo)
+	return e
+}
+
+// KprobeInfo describes a kprobe or kretprobe attached to a perf event link.
+type KprobeInfo struct {
+	// Function the probe is attached to. Empty if the probe was created
+	// for an address.
+	Function string
+	// Offset into Function.
+	Offset uint32
+	// Address the probe was resolved to. Zero if the caller isn't allowed
+	// to see kernel addresses, see kptr_restrict.
+	Address uint64
+	// Retprobe is true for kretprobes.
+	Retprobe bool
+	// Missed is the number of times the probe fired without running the
+	// program, for example due to recursion. Requires at least Linux 6.7.
+	Missed uint64
+	// Cookie is the bpf_cookie of the link. Requires at least Linux 6.9.
+	Cookie uint64
+}
+
+// UprobeInfo describes a uprobe or uretprobe attached to a perf event link.
+type UprobeInfo struct {
+	// Path of the probed binary.
+	Path string
+	// Offset into the binary.
+	Offset uint32
+	// Retprobe is true for uretprobes.
+	Retprobe bool
+	// Cookie is the bpf_cookie of the link. Requires at least Linux 6.9.
+	Cookie uint64
+}
+
+// KprobeMultiInfo contains metadata about a kprobe_multi link.
+//
+// Requires at least Linux 6.6.
+type KprobeMultiInfo struct {
+	// Count is the number of attached functions.
+	Count uint32
+	// Retprobe is true if the link was created via KretprobeMulti.
+	Retprobe bool
+	// Missed is the number of times one of the probes fired without running
+	// the program. Requires at least Linux 6.7.
+	Missed uint64
+}
+
 // Tracing returns tracing type-specific link info.
 //
 // Returns nil if the type-specific link info isn't available.
@@ -157,6 +235,22 @@ func (r Info) NetNs() *NetNsInfo {
 	return e
 }
 
+// PerfEvent returns perf event type-specific link info.
+//
+// Returns nil if the type-specific link info isn't available.
+func (r Info) PerfEvent() *PerfEventInfo {
+	e, _ := r.extra.(*PerfEventInfo)
+	return e
+}
+
+// KprobeMulti returns kprobe_multi type-specific link info.
+//
+// Returns nil if the type-specific link info isn't available.
+func (r Info) KprobeMulti() *KprobeMultiInfo {
+	e, _ := r.extra.(*KprobeMultiInfo)
+	return e
+}
+
 // ExtraNetNs returns XDP type-specific link info.
 //
 // Returns nil if the type-specific link info isn't available.
@@ -196,8 +290,24 @@ func AttachRawLink(opts RawLinkOptions) (*RawLink, error) {
 		TargetBtfId: opts.BTF,
 		Flags:       opts.Flags,
 	}
-	fd, err := sys.LinkCreate(&attr)
+	var fd *sys.FD
+	create := func() (err error) {
+		fd, err = sys.LinkCreate(&attr)
+		return err
+	}
+
+	var err error
+	if opts.Namespace != nil {
+		err = internal.InNamespace(opts.Namespace, create)
+	} else {
+		err = create()
+	}
 	if err != nil {
+		// The link may have been created before restoring the namespace
+		// failed.
+		if fd != nil {
+			fd.Close()
+		}
 		return nil, fmt.Errorf("create link: %w", err)
 	}
 
@@ -294,6 +404,92 @@ func (l *RawLink) UpdateArgs(opts RawLinkUpdateOptions) error {
 	return sys.LinkUpdate(&attr)
 }
 
+func (l *RawLink) perfEventInfo(info *sys.LinkInfo) (*PerfEventInfo, error) {
+	typ := sys.PerfEventType(internal.NativeEndian.Uint32(info.Extra[:]))
+	pe := &PerfEventInfo{Type: typ}
+
+	switch typ {
+	case sys.BPF_PERF_EVENT_KPROBE, sys.BPF_PERF_EVENT_KRETPROBE:
+		var kp sys.KprobeLinkInfo
+		name, err := l.infoWithName(&kp, &kp.FuncName, &kp.NameLen)
+		if err != nil {
+			return nil, fmt.Errorf("kprobe link info: %w", err)
+		}
+
+		pe.extra = &KprobeInfo{
+			Function: name,
+			Offset:   kp.Offset,
+			Address:  kp.Addr,
+			Retprobe: typ == sys.BPF_PERF_EVENT_KRETPROBE,
+			Missed:   kp.Missed,
+			Cookie:   kp.Cookie,
+		}
+
+	case sys.BPF_PERF_EVENT_UPROBE, sys.BPF_PERF_EVENT_URETPROBE:
+		var up sys.UprobeLinkInfo
+		path, err := l.infoWithName(&up, &up.FileName, &up.NameLen)
+		if err != nil {
+			return nil, fmt.Errorf("uprobe link info: %w", err)
+		}
+
+		pe.extra = &UprobeInfo{
+			Path:     path,
+			Offset:   up.Offset,
+			Retprobe: typ == sys.BPF_PERF_EVENT_URETPROBE,
+			Cookie:   up.Cookie,
+		}
+	}
+
+	return pe, nil
+}
+
+// infoWithName retrieves info, which contains a variable length string
+// described by ptr and size.
+//
+// The kernel reports the length of the string on the first call, a second
+// call retrieves the string itself.
+func (l *RawLink) infoWithName(info sys.Info, ptr *sys.Pointer, size *uint32) (string, error) {
+	if err := sys.ObjInfo(l.fd, info); err != nil {
+		return "", err
+	}
+
+	if *size <= 1 {
+		return "", nil
+	}
+
+	buf := make([]byte, *size)
+	*ptr = sys.NewSlicePointer(buf)
+	*size = uint32(len(buf))
+	if err := sys.ObjInfo(l.fd, info); err != nil {
+		return "", err
+	}
+	*ptr = sys.Pointer{}
+
+	return unix.ByteSliceToString(buf), nil
+}
+
+func (l *RawLink) kprobeMultiInfo() (*KprobeMultiInfo, error) {
+	var km sys.KprobeMultiLinkInfo
+	if err := sys.ObjInfo(l.fd, &km); err != nil {
+		return nil, fmt.Errorf("kprobe_multi link info: %w", err)
+	}
+
+	return &KprobeMultiInfo{
+		Count:    km.Count,
+		Retprobe: km.Flags&unix.BPF_F_KPROBE_MULTI_RETURN != 0,
+		Missed:   km.Missed,
+	}, nil
+}
+
+// typ returns the type of the link.
+func (l *RawLink) typ() (Type, error) {
+	var info sys.LinkInfo
+	if err := sys.ObjInfo(l.fd, &info); err != nil {
+		return 0, fmt.Errorf("link info: %s", err)
+	}
+	return info.Type, nil
+}
+
 // Info returns metadata about the link.
 func (l *RawLink) Info() (*Info, error) {
 	var info sys.LinkInfo
@@ -312,8 +508,19 @@ func (l *RawLink) Info() (*Info, error) {
 		extra = &TracingInfo{}
 	case XDPType:
 		extra = &XDPInfo{}
-	case RawTracepointType, IterType,
-		PerfEventType, KprobeMultiType:
+	case PerfEventType:
+		pe, err := l.perfEventInfo(&info)
+		if err != nil {
+			return nil, err
+		}
+		return &Info{info.Type, info.Id, ebpf.ProgramID(info.ProgId), pe}, nil
+	case KprobeMultiType:
+		km, err := l.kprobeMultiInfo()
+		if err != nil {
+			return nil, err
+		}
+		return &Info{info.Type, info.Id, ebpf.ProgramID(info.ProgId), km}, nil
+	case RawTracepointType, IterType, StructOpsType:
 		// Extra metadata not supported.
 	default:
 		return nil, fmt.Errorf("unknown link info type: %d", info.Type)
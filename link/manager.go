@@ -0,0 +1,177 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// Manager owns a Collection and the links of its programs.
+//
+// It allows replacing an attached program without detaching it, which is
+// useful when upgrading an agent without interrupting the programs it
+// manages.
+//
+// A Manager is safe for concurrent use.
+type Manager struct {
+	mu     sync.Mutex
+	coll   *ebpf.Collection
+	links  map[string][]Link
+	closed bool
+}
+
+// NewManager creates a Manager which takes ownership of coll.
+func NewManager(coll *ebpf.Collection) *Manager {
+	return &Manager{
+		coll:  coll,
+		links: make(map[string][]Link),
+	}
+}
+
+// Program returns the current version of a program, or nil if it doesn't
+// exist.
+//
+// The program is owned by the Manager and is closed when it is replaced.
+func (m *Manager) Program(name string) *ebpf.Program {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.coll.Programs[name]
+}
+
+// Map returns a map of the Collection, or nil if it doesn't exist.
+//
+// The map is owned by the Manager.
+func (m *Manager) Map(name string) *ebpf.Map {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.coll.Maps[name]
+}
+
+// Attach invokes attach with a program of the Collection and takes ownership
+// of the resulting link. A nil link is ignored.
+//
+//	l, err := mgr.Attach("xdp_prog", func(prog *ebpf.Program) (link.Link, error) {
+//		return link.AttachXDP(link.XDPOptions{Program: prog, Interface: ifindex})
+//	})
+func (m *Manager) Attach(name string, attach func(*ebpf.Program) (Link, error)) (Link, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, errors.New("manager is closed")
+	}
+
+	prog := m.coll.Programs[name]
+	if prog == nil {
+		return nil, fmt.Errorf("unknown program %s", name)
+	}
+
+	l, err := attach(prog)
+	if err != nil {
+		return nil, fmt.Errorf("attach program %s: %w", name, err)
+	}
+
+	if l != nil {
+		m.links[name] = append(m.links[name], l)
+	}
+	return l, nil
+}
+
+// ReplaceProgram loads spec and atomically replaces the program called name
+// in all of its links.
+//
+// Maps referenced by spec are resolved against the maps of the Collection.
+// Either all links are updated or none are: if updating a link fails, the
+// links updated so far are reverted and the new program is closed. The
+// previous version of the program is closed on success.
+//
+// Returns an error wrapping ErrNotSupported if one of the links doesn't
+// support Update.
+func (m *Manager) ReplaceProgram(name string, spec *ebpf.ProgramSpec, opts ebpf.ProgramOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return errors.New("manager is closed")
+	}
+
+	old := m.coll.Programs[name]
+	if old == nil {
+		return fmt.Errorf("unknown program %s", name)
+	}
+
+	spec = spec.Copy()
+	for i := range spec.Instructions {
+		ins := &spec.Instructions[i]
+		if !ins.IsLoadFromMap() || ins.Reference() == "" {
+			continue
+		}
+
+		cm := m.coll.Maps[ins.Reference()]
+		if cm == nil {
+			return fmt.Errorf("program %s: unknown map %s", name, ins.Reference())
+		}
+
+		if err := ins.AssociateMap(cm); err != nil {
+			return fmt.Errorf("program %s: map %s: %w", name, ins.Reference(), err)
+		}
+	}
+
+	prog, err := ebpf.NewProgramWithOptions(spec, opts)
+	if err != nil {
+		return fmt.Errorf("load program %s: %w", name, err)
+	}
+
+	links := m.links[name]
+	for i, l := range links {
+		if err := l.Update(prog); err != nil {
+			// Revert the links updated so far.
+			for j := i - 1; j >= 0; j-- {
+				_ = links[j].Update(old)
+			}
+			prog.Close()
+			return fmt.Errorf("program %s: update link: %w", name, err)
+		}
+	}
+
+	m.coll.Programs[name] = prog
+	old.Close()
+	return nil
+}
+
+// Close detaches all links and closes the Collection.
+//
+// Returns the first error encountered, but attempts to release all resources.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+
+	err := m.closeLinks()
+	m.coll.Close()
+	return err
+}
+
+// closeLinks detaches all links without closing the Collection.
+//
+// The caller must hold mu or have exclusive access to m.
+func (m *Manager) closeLinks() error {
+	var firstErr error
+	for name, links := range m.links {
+		for _, l := range links {
+			if err := l.Close(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("close link of program %s: %w", name, err)
+			}
+		}
+	}
+	m.links = nil
+	return firstErr
+}
@@ -0,0 +1,100 @@
+package link
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+type failingLink struct {
+	RawLink
+}
+
+func (*failingLink) Update(*ebpf.Program) error { return ErrNotSupported }
+func (*failingLink) Close() error               { return nil }
+
+func xdpSpec(ret int32) *ebpf.ProgramSpec {
+	return &ebpf.ProgramSpec{
+		Type: ebpf.XDP,
+		Instructions: asm.Instructions{
+			asm.LoadMapPtr(asm.R1, 0).WithReference("array"),
+			asm.Mov.Imm(asm.R0, ret),
+			asm.Return(),
+		},
+		License: "MIT",
+	}
+}
+
+func TestManager(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "BPF_LINK_TYPE_XDP")
+
+	coll, err := ebpf.NewCollection(&ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"array": {Type: ebpf.Array, KeySize: 4, ValueSize: 4, MaxEntries: 1},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"xdp": xdpSpec(2),
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+
+	mgr := NewManager(coll)
+	defer mgr.Close()
+
+	var links []Link
+	for i := 0; i < 2; i++ {
+		ns := testutils.NewNetNS(t)
+		l, err := mgr.Attach("xdp", func(prog *ebpf.Program) (Link, error) {
+			return AttachXDP(XDPOptions{Program: prog, Interface: IfIndexLO, Namespace: ns})
+		})
+		qt.Assert(t, err, qt.IsNil)
+		links = append(links, l)
+	}
+
+	_, err = mgr.Attach("missing", nil)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	programID := func(l Link) ebpf.ProgramID {
+		t.Helper()
+		info, err := l.Info()
+		qt.Assert(t, err, qt.IsNil)
+		return info.Program
+	}
+
+	old := mgr.Program("xdp")
+	qt.Assert(t, mgr.ReplaceProgram("xdp", xdpSpec(1), ebpf.ProgramOptions{}), qt.IsNil)
+
+	prog := mgr.Program("xdp")
+	qt.Assert(t, prog, qt.Not(qt.Equals), old)
+	qt.Assert(t, old.FD(), qt.Equals, -1, qt.Commentf("old program wasn't closed"))
+
+	info, err := prog.Info()
+	qt.Assert(t, err, qt.IsNil)
+	id, _ := info.ID()
+	for _, l := range links {
+		qt.Assert(t, programID(l), qt.Equals, id)
+	}
+
+	// A link which can't be updated causes all links to be reverted.
+	mgr.links["xdp"] = append(mgr.links["xdp"], &failingLink{})
+	err = mgr.ReplaceProgram("xdp", xdpSpec(2), ebpf.ProgramOptions{})
+	qt.Assert(t, errors.Is(err, ErrNotSupported), qt.IsTrue, qt.Commentf("got %v", err))
+	qt.Assert(t, mgr.Program("xdp"), qt.Equals, prog)
+	for _, l := range links {
+		qt.Assert(t, programID(l), qt.Equals, id)
+	}
+
+	// Maps are resolved against the collection.
+	spec := xdpSpec(2)
+	spec.Instructions[0] = spec.Instructions[0].WithReference("missing")
+	qt.Assert(t, mgr.ReplaceProgram("xdp", spec, ebpf.ProgramOptions{}), qt.IsNotNil)
+
+	qt.Assert(t, mgr.Close(), qt.IsNil)
+	qt.Assert(t, prog.FD(), qt.Equals, -1)
+	qt.Assert(t, mgr.ReplaceProgram("xdp", xdpSpec(2), ebpf.ProgramOptions{}), qt.IsNotNil)
+}
@@ -0,0 +1,68 @@
+package link
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/sys"
+)
+
+// StructOpsOptions control attaching a struct_ops map.
+type StructOpsOptions struct {
+	// A StructOpsMap created with BPF_F_LINK, for example by declaring it in
+	// the .struct_ops.link ELF section. Its value must have been set.
+	Map *ebpf.Map
+}
+
+// AttachStructOps registers the struct held in a struct_ops map with the
+// kernel, for example a TCP congestion control algorithm.
+//
+// Closing the link unregisters the struct.
+//
+// Maps created without BPF_F_LINK are registered when their value is
+// written, and unregistered by deleting the value or closing all references
+// to the map.
+func AttachStructOps(opts StructOpsOptions) (Link, error) {
+	if opts.Map == nil {
+		return nil, errors.New("map cannot be nil")
+	}
+
+	if t := opts.Map.Type(); t != ebpf.StructOpsMap {
+		return nil, fmt.Errorf("invalid map type %s, expected %s", t, ebpf.StructOpsMap)
+	}
+
+	if opts.Map.Flags()&uint32(sys.BPF_F_LINK) == 0 {
+		return nil, errors.New("map must be created with BPF_F_LINK")
+	}
+
+	if err := haveBPFLink(); err != nil {
+		return nil, err
+	}
+
+	fd := opts.Map.FD()
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid map: %s", sys.ErrClosedFd)
+	}
+
+	// The map takes the place of the program.
+	linkFD, err := sys.LinkCreate(&sys.LinkCreateAttr{
+		ProgFd:     uint32(fd),
+		AttachType: sys.AttachType(ebpf.AttachStructOps),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("attach struct_ops: %w", err)
+	}
+
+	return &structOpsLink{RawLink{fd: linkFD}}, nil
+}
+
+type structOpsLink struct {
+	RawLink
+}
+
+var _ Link = (*structOpsLink)(nil)
+
+func (sl *structOpsLink) Update(_ *ebpf.Program) error {
+	return fmt.Errorf("update struct_ops: %w", ErrNotSupported)
+}
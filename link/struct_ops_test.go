@@ -0,0 +1,26 @@
+package link
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestAttachStructOpsInvalid(t *testing.T) {
+	_, err := AttachStructOps(StructOpsOptions{})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	_, err = AttachStructOps(StructOpsOptions{Map: m})
+	qt.Assert(t, err, qt.IsNotNil)
+}
@@ -0,0 +1,148 @@
+package link
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf"
+)
+
+// SwapOptions control how the old program is drained when swapping programs.
+type SwapOptions struct {
+	// Quiesce is the time to wait after installing the new program, so that
+	// invocations of the old program which are in flight can finish.
+	Quiesce time.Duration
+
+	// Drained is polled after Quiesce has passed and returns true once the
+	// old program is done, for example because it stopped updating a map.
+	// See MapQuiesced. Optional.
+	Drained func() (bool, error)
+
+	// The interval at which Drained is polled. Defaults to 10ms.
+	PollInterval time.Duration
+}
+
+// SwapProgram replaces the program attached via l with prog, and waits
+// until the old program is drained as configured by opts.
+//
+// Links based on bpf_link, like XDP links, replace the program atomically:
+// every packet is processed by either the old or the new program. Once
+// SwapProgram returns, the old program and any state it owns can be
+// released.
+//
+// Returns an error wrapping ErrNotSupported if l can't be updated. Returns
+// ctx.Err() if ctx is cancelled before the old program was drained, in
+// which case prog stays attached.
+func SwapProgram(ctx context.Context, l Link, prog *ebpf.Program, opts SwapOptions) error {
+	if err := l.Update(prog); err != nil {
+		return fmt.Errorf("swap program: %w", err)
+	}
+
+	if err := waitDrained(ctx, opts); err != nil {
+		return fmt.Errorf("swap program: %w", err)
+	}
+
+	return nil
+}
+
+// SwapLink attaches a new program using attach while old stays attached,
+// waits until old is drained as configured by opts and then closes old.
+//
+// Use SwapLink for hooks which allow attaching multiple programs, for
+// example cgroup hooks with the multi flag, where a link can't be updated
+// in place. Both programs run while old is draining.
+//
+// Returns the new link. If ctx is cancelled before old was drained, the new
+// link is detached again and ctx.Err() is returned.
+func SwapLink(ctx context.Context, old Link, attach func() (Link, error), opts SwapOptions) (Link, error) {
+	l, err := attach()
+	if err != nil {
+		return nil, fmt.Errorf("swap link: attach: %w", err)
+	}
+
+	if err := waitDrained(ctx, opts); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("swap link: %w", err)
+	}
+
+	if err := old.Close(); err != nil {
+		return l, fmt.Errorf("swap link: detach old link: %w", err)
+	}
+
+	return l, nil
+}
+
+// waitDrained waits for opts.Quiesce and then polls opts.Drained until it
+// returns true.
+func waitDrained(ctx context.Context, opts SwapOptions) error {
+	if err := sleep(ctx, opts.Quiesce); err != nil {
+		return err
+	}
+
+	if opts.Drained == nil {
+		return nil
+	}
+
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+
+	for {
+		drained, err := opts.Drained()
+		if err != nil {
+			return fmt.Errorf("check drained: %w", err)
+		}
+		if drained {
+			return nil
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// MapQuiesced returns a function for SwapOptions.Drained, which reports
+// true once the value of key in m didn't change since the previous call.
+//
+// This allows the old program to signal that it's still processing traffic
+// by incrementing a counter in m.
+func MapQuiesced(m *ebpf.Map, key interface{}) func() (bool, error) {
+	var last []byte
+	return func() (bool, error) {
+		value, err := m.LookupBytes(key)
+		if err != nil {
+			return false, err
+		}
+		if value == nil {
+			return false, errors.New("key not found")
+		}
+
+		quiesced := last != nil && bytes.Equal(last, value)
+		last = value
+		return quiesced, nil
+	}
+}
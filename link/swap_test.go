@@ -0,0 +1,107 @@
+package link
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestSwapProgram(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "BPF_LINK_TYPE_XDP")
+
+	old := mustLoadProgram(t, ebpf.XDP, 0, "")
+	l, err := AttachXDP(XDPOptions{
+		Program:   old,
+		Interface: IfIndexLO,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	counter, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer counter.Close()
+
+	polls := 0
+	quiesced := MapQuiesced(counter, uint32(0))
+	prog := mustLoadProgram(t, ebpf.XDP, 0, "")
+	err = SwapProgram(context.Background(), l, prog, SwapOptions{
+		Quiesce: time.Millisecond,
+		Drained: func() (bool, error) {
+			polls++
+			return quiesced()
+		},
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polls != 2 {
+		t.Errorf("Expected the counter to be polled twice, got %d", polls)
+	}
+
+	info, err := l.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	progInfo, err := prog.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := progInfo.ID(); info.Program != id {
+		t.Errorf("Link has program %d instead of %d after swap", info.Program, id)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = SwapProgram(ctx, l, old, SwapOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled, got", err)
+	}
+}
+
+func TestSwapLink(t *testing.T) {
+	cgroup, prog := mustCgroupFixtures(t)
+
+	attach := func() (Link, error) {
+		return AttachCgroup(CgroupOptions{
+			Path:    cgroup.Name(),
+			Attach:  ebpf.AttachCGroupInetEgress,
+			Program: prog,
+		})
+	}
+
+	old, err := attach()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drained := false
+	l, err := SwapLink(context.Background(), old, attach, SwapOptions{
+		Drained: func() (bool, error) {
+			drained = true
+			return true, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if !drained {
+		t.Error("Drained wasn't called")
+	}
+}
@@ -23,6 +23,7 @@ func (f *tracing) Update(new *ebpf.Program) error {
 // The program and name can either be provided at link time, or can be provided
 // at program load time. If they were provided at load time, they should be nil
 // and empty respectively here, as they will be ignored by the kernel.
+//
 // Examples:
 //
 //	AttachFreplace(dispatcher, "function", replacement)
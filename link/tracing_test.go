@@ -4,8 +4,12 @@ import (
 	"testing"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
 )
 
 func TestFreplace(t *testing.T) {
@@ -47,6 +51,89 @@ func TestFreplace(t *testing.T) {
 	})
 }
 
+func TestFreplaceDispatcher(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.10", "freplace")
+
+	// A dispatcher calls a global function which can be replaced by an
+	// extension, similar to libxdp.
+	proto := &btf.FuncProto{
+		Return: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed},
+		Params: []btf.FuncParam{
+			{Name: "ctx", Type: &btf.Pointer{Target: &btf.Struct{Name: "xdp_md"}}},
+		},
+	}
+	newDispatcher := func() *ebpf.Program {
+		t.Helper()
+
+		prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+			Name:    "dispatcher",
+			Type:    ebpf.XDP,
+			License: "MIT",
+			Instructions: asm.Instructions{
+				btf.WithFuncMetadata(asm.Call.Label("prog0"), &btf.Func{
+					Name: "dispatcher", Type: proto, Linkage: btf.GlobalFunc,
+				}).WithSymbol("dispatcher"),
+				asm.Return(),
+				btf.WithFuncMetadata(asm.Mov.Imm(asm.R0, 1), &btf.Func{
+					Name: "prog0", Type: proto, Linkage: btf.GlobalFunc,
+				}).WithSymbol("prog0"),
+				asm.Return(),
+			},
+		})
+		testutils.SkipIfNotSupported(t, err)
+		qt.Assert(t, err, qt.IsNil)
+		t.Cleanup(func() { prog.Close() })
+
+		return prog
+	}
+
+	run := func(prog *ebpf.Program) uint32 {
+		t.Helper()
+
+		ret, _, err := prog.Test(internal.EmptyBPFContext)
+		testutils.SkipIfNotSupported(t, err)
+		qt.Assert(t, err, qt.IsNil)
+		return ret
+	}
+
+	first, second := newDispatcher(), newDispatcher()
+	qt.Assert(t, run(first), qt.Equals, uint32(1))
+
+	// The extension doesn't have BTF, it's taken from the target.
+	ext, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         "ext",
+		Type:         ebpf.Extension,
+		License:      "MIT",
+		AttachTarget: first,
+		AttachTo:     "prog0",
+		Instructions: asm.Instructions{
+			asm.Mov.Imm(asm.R0, 2),
+			asm.Return(),
+		},
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer ext.Close()
+
+	l, err := AttachFreplace(nil, "", ext)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer l.Close()
+
+	qt.Assert(t, run(first), qt.Equals, uint32(2))
+
+	// The same extension can replace a function in another dispatcher.
+	l2, err := AttachFreplace(second, "prog0", ext)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer l2.Close()
+
+	qt.Assert(t, run(second), qt.Equals, uint32(2))
+
+	qt.Assert(t, l.Close(), qt.IsNil)
+	qt.Assert(t, run(first), qt.Equals, uint32(1))
+}
+
 func TestTracing(t *testing.T) {
 	testutils.SkipOnOldKernel(t, "5.11", "BPF_LINK_TYPE_TRACING")
 
@@ -2,6 +2,7 @@ package link
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/cilium/ebpf"
 )
@@ -31,6 +32,13 @@ type XDPOptions struct {
 	// Only one XDP mode should be set, without flag defaults
 	// to driver/generic mode (best effort).
 	Flags XDPAttachFlags
+
+	// Namespace is the network namespace Interface is in, for example opened
+	// from /proc/<pid>/ns/net. Defaults to the namespace of the calling
+	// thread.
+	//
+	// Requires CAP_SYS_ADMIN.
+	Namespace *os.File
 }
 
 // AttachXDP links an XDP BPF program to an XDP hook.
@@ -44,10 +52,11 @@ func AttachXDP(opts XDPOptions) (Link, error) {
 	}
 
 	rawLink, err := AttachRawLink(RawLinkOptions{
-		Program: opts.Program,
-		Attach:  ebpf.AttachXDP,
-		Target:  opts.Interface,
-		Flags:   uint32(opts.Flags),
+		Program:   opts.Program,
+		Attach:    ebpf.AttachXDP,
+		Target:    opts.Interface,
+		Flags:     uint32(opts.Flags),
+		Namespace: opts.Namespace,
 	})
 
 	return rawLink, err
@@ -24,3 +24,31 @@ func TestAttachXDP(t *testing.T) {
 
 	testLink(t, l, prog)
 }
+
+func TestAttachXDPNamespace(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.9", "BPF_LINK_TYPE_XDP")
+
+	ns := testutils.NewNetNS(t)
+	prog := mustLoadProgram(t, ebpf.XDP, 0, "")
+
+	l, err := AttachXDP(XDPOptions{
+		Program:   prog,
+		Interface: IfIndexLO,
+		Namespace: ns,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// Only a single XDP link may exist per interface, so this fails if the
+	// first link was created in the current namespace.
+	host, err := AttachXDP(XDPOptions{
+		Program:   prog,
+		Interface: IfIndexLO,
+	})
+	if err != nil {
+		t.Fatal("Attach to loopback of the current namespace:", err)
+	}
+	host.Close()
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"strings"
 
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/kallsyms"
 	"github.com/cilium/ebpf/internal/linux"
 )
 
@@ -101,6 +103,51 @@ func hasFunctionReferences(insns asm.Instructions) bool {
 	return false
 }
 
+// exceptionCallbackTag is the decl tag prefix which marks the exception
+// callback of a program using bpf_throw.
+const exceptionCallbackTag = "exception_callback:"
+
+// exceptionCallback returns the name of the exception callback declared for
+// the program starting with insns, or an empty string.
+func exceptionCallback(insns asm.Instructions) string {
+	if len(insns) == 0 {
+		return ""
+	}
+
+	fn := btf.FuncMetadata(&insns[0])
+	if fn == nil {
+		return ""
+	}
+
+	for _, tag := range fn.Tags {
+		if name, ok := strings.CutPrefix(tag, exceptionCallbackTag); ok {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// usesExceptions returns true if insns throw BPF exceptions or declare an
+// exception callback.
+func usesExceptions(insns asm.Instructions) bool {
+	if exceptionCallback(insns) != "" {
+		return true
+	}
+
+	for _, ins := range insns {
+		if !ins.IsKfuncCall() {
+			continue
+		}
+
+		if fn, _ := ins.Metadata.Get(kfuncMeta{}).(*btf.Func); fn != nil && fn.Name == "bpf_throw" {
+			return true
+		}
+	}
+
+	return false
+}
+
 // applyRelocations collects and applies any CO-RE relocations in insns.
 //
 // Passing a nil target will relocate against the running kernel. insns are
@@ -154,6 +201,12 @@ func flattenPrograms(progs map[string]*ProgramSpec, names []string) {
 	refs := make(map[*ProgramSpec][]string)
 	for _, prog := range progs {
 		refs[prog] = prog.Instructions.FunctionReferences()
+
+		// The exception callback is never called directly, but the verifier
+		// expects it to be part of the program.
+		if cb := exceptionCallback(prog.Instructions); cb != "" {
+			refs[prog] = append(refs[prog], cb)
+		}
 	}
 
 	// Create a flattened instruction stream, but don't modify progs yet to
@@ -279,6 +332,12 @@ fixups:
 		target := btf.Type((*btf.Func)(nil))
 		spec, module, err := findTargetInKernel(kernelSpec, kfm.Name, &target)
 		if errors.Is(err, btf.ErrNotFound) {
+			if kfm.Name == "bpf_throw" {
+				// Give a more helpful error on kernels without exceptions.
+				if err := haveBPFExceptions(); err != nil {
+					return nil, fmt.Errorf("kfunc %q: %w", kfm.Name, err)
+				}
+			}
 			return nil, fmt.Errorf("kfunc %q: %w", kfm.Name, ErrNotSupported)
 		}
 		if err != nil {
@@ -310,6 +369,101 @@ fixups:
 	return fdArray, nil
 }
 
+// fixupKsyms resolves loads of extern kernel variables declared in .ksyms.
+//
+// Typed variables are resolved to their BTF ID in the kernel or module BTF.
+// The modules are added to fdArray. Typeless variables, declared as
+// 'extern const void', are resolved to their address from /proc/kallsyms
+// and therefore don't require BTF.
+func fixupKsyms(insns asm.Instructions, fdArray *handles) error {
+	var (
+		kernelSpec *btf.Spec
+		typeless   []*asm.Instruction
+		addresses  = make(map[string]uint64)
+	)
+
+	iter := insns.Iterate()
+	for iter.Next() {
+		ins := iter.Ins
+		meta, _ := ins.Metadata.Get(ksymMetaKey{}).(*ksymMeta)
+		if meta == nil {
+			continue
+		}
+
+		name := meta.Var.Name
+		if _, ok := btf.UnderlyingType(meta.Var.Type).(*btf.Void); ok {
+			typeless = append(typeless, ins)
+			addresses[name] = 0
+			continue
+		}
+
+		if kernelSpec == nil {
+			var err error
+			kernelSpec, err = linux.TypesNoCopy()
+			if err != nil {
+				return fmt.Errorf("ksym %q: %w", name, err)
+			}
+		}
+
+		target := btf.Type((*btf.Var)(nil))
+		spec, module, err := findTargetInKernel(kernelSpec, name, &target)
+		if errors.Is(err, btf.ErrNotFound) && meta.Weak {
+			ins.Constant = 0
+			continue
+		}
+		if errors.Is(err, btf.ErrNotFound) {
+			return fmt.Errorf("ksym %q: %w", name, ErrNotSupported)
+		}
+		if err != nil {
+			return fmt.Errorf("ksym %q: %w", name, err)
+		}
+
+		if _, err := fdArray.add(module); err != nil {
+			module.Close()
+			return err
+		}
+
+		if err := btf.CheckTypeCompatibility(meta.Var.Type, target.(*btf.Var).Type); err != nil {
+			return fmt.Errorf("ksym %q: %w", name, err)
+		}
+
+		id, err := spec.TypeID(target)
+		if err != nil {
+			return fmt.Errorf("ksym %q: %w", name, err)
+		}
+
+		// The upper half of the constant is the fd of the module BTF, or zero
+		// for vmlinux.
+		var fd uint32
+		if module != nil {
+			fd = uint32(module.FD())
+		}
+
+		ins.Src = asm.PseudoBTFID
+		ins.Constant = int64(uint64(fd)<<32 | uint64(id))
+	}
+
+	if len(typeless) == 0 {
+		return nil
+	}
+
+	if err := kallsyms.AssignAddresses(addresses); err != nil {
+		return fmt.Errorf("resolve ksym addresses: %w", err)
+	}
+
+	for _, ins := range typeless {
+		meta := ins.Metadata.Get(ksymMetaKey{}).(*ksymMeta)
+		addr := addresses[meta.Var.Name]
+		if addr == 0 && !meta.Weak {
+			return fmt.Errorf("ksym %q: not found in /proc/kallsyms", meta.Var.Name)
+		}
+
+		ins.Constant = int64(addr)
+	}
+
+	return nil
+}
+
 type incompatibleKfuncError struct {
 	name string
 	err  error
@@ -350,12 +504,19 @@ func resolveKconfigReferences(insns asm.Instructions) (_ *Map, err error) {
 	}
 
 	var spec *MapSpec
+	weak := make(map[uint32]bool)
 	iter := insns.Iterate()
 	for iter.Next() {
 		meta, _ := iter.Ins.Metadata.Get(kconfigMetaKey{}).(*kconfigMeta)
-		if meta != nil {
+		if meta == nil {
+			continue
+		}
+
+		if spec == nil {
 			spec = meta.Map
-			break
+		}
+		if meta.Weak {
+			weak[meta.Offset] = true
 		}
 	}
 
@@ -364,7 +525,7 @@ func resolveKconfigReferences(insns asm.Instructions) (_ *Map, err error) {
 	}
 
 	cpy := spec.Copy()
-	if err := resolveKconfig(cpy); err != nil {
+	if err := resolveKconfig(cpy, weak); err != nil {
 		return nil, err
 	}
 
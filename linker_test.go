@@ -7,7 +7,9 @@ import (
 	"github.com/cilium/ebpf/asm"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/kallsyms"
 	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
 
 	qt "github.com/frankban/quicktest"
 )
@@ -58,6 +60,40 @@ func TestFindReferences(t *testing.T) {
 	}
 }
 
+func TestFlattenExceptionCallback(t *testing.T) {
+	fn := &btf.Func{
+		Name: "entrypoint",
+		Type: &btf.FuncProto{Return: &btf.Int{Size: 4}},
+		Tags: []string{"exception_callback:handler"},
+	}
+
+	entry := asm.Instructions{
+		btf.WithFuncMetadata(asm.Mov.Imm(asm.R0, 0), fn),
+		asm.Return(),
+	}
+	entry[0] = entry[0].WithSymbol("entrypoint")
+
+	progs := map[string]*ProgramSpec{
+		"entrypoint": {Instructions: entry},
+		"handler": {
+			Instructions: asm.Instructions{
+				asm.Mov.Imm(asm.R0, 1).WithSymbol("handler"),
+				asm.Return(),
+			},
+		},
+	}
+
+	qt.Assert(t, exceptionCallback(entry), qt.Equals, "handler")
+	qt.Assert(t, usesExceptions(entry), qt.IsTrue)
+	qt.Assert(t, usesExceptions(progs["handler"].Instructions), qt.IsFalse)
+
+	flattenPrograms(progs, []string{"entrypoint"})
+
+	insns := progs["entrypoint"].Instructions
+	qt.Assert(t, insns, qt.HasLen, 4)
+	qt.Assert(t, insns[2].Symbol(), qt.Equals, "handler")
+}
+
 func TestForwardFunctionDeclaration(t *testing.T) {
 	testutils.Files(t, testutils.Glob(t, "testdata/fwd_decl-*.elf"), func(t *testing.T, file string) {
 		coll, err := LoadCollectionSpec(file)
@@ -165,3 +201,87 @@ func TestSplitSymbols(t *testing.T) {
 	c.Assert(len(m["sym3"]), qt.Equals, 3)
 	c.Assert(len(m["sym4"]), qt.Equals, 4)
 }
+
+func TestFixupKsyms(t *testing.T) {
+	ksym := func(name string, typ btf.Type, weak bool) asm.Instruction {
+		ins := asm.LoadImm(asm.R0, 0, asm.DWord)
+		ins.Metadata.Set(ksymMetaKey{}, &ksymMeta{&btf.Var{Name: name, Type: typ}, weak})
+		return ins
+	}
+
+	newSpec := func(ins asm.Instruction) *ProgramSpec {
+		return &ProgramSpec{
+			Type: SocketFilter,
+			Instructions: asm.Instructions{
+				ins,
+				asm.Return(),
+			},
+			License: "MIT",
+		}
+	}
+
+	t.Run("typeless", func(t *testing.T) {
+		addrs := map[string]uint64{"bpf_prog_put": 0}
+		if err := kallsyms.AssignAddresses(addrs); err != nil {
+			t.Skip("Can't read /proc/kallsyms:", err)
+		}
+
+		prog, err := NewProgram(newSpec(ksym("bpf_prog_put", &btf.Void{}, false)))
+		qt.Assert(t, err, qt.IsNil)
+		defer prog.Close()
+
+		ret, _, err := prog.Test(internal.EmptyBPFContext)
+		testutils.SkipIfNotSupported(t, err)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, ret, qt.Equals, uint32(addrs["bpf_prog_put"]))
+
+		_, err = NewProgram(newSpec(ksym("does_not_exist", &btf.Void{}, false)))
+		qt.Assert(t, err, qt.IsNotNil)
+
+		prog, err = NewProgram(newSpec(ksym("does_not_exist", &btf.Void{}, true)))
+		qt.Assert(t, err, qt.IsNil)
+		defer prog.Close()
+
+		ret, _, err = prog.Test(internal.EmptyBPFContext)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, ret, qt.Equals, uint32(0))
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		testutils.SkipOnOldKernel(t, "5.10", "BPF_PSEUDO_BTF_ID for percpu variables")
+
+		integer := &btf.Int{Size: 4, Encoding: btf.Signed}
+		spec := newSpec(ksym("bpf_prog_active", integer, false))
+		spec.Instructions = asm.Instructions{
+			spec.Instructions[0],
+			asm.Mov.Imm(asm.R0, 0),
+			asm.Return(),
+		}
+
+		prog, err := NewProgram(spec)
+		testutils.SkipIfNotSupported(t, err)
+		if errors.Is(err, unix.ENOENT) {
+			// The verifier looks up the address of the variable in kallsyms,
+			// which only contains variables with CONFIG_KALLSYMS_ALL.
+			t.Log("Kernel doesn't expose variables in kallsyms:", err)
+		} else {
+			qt.Assert(t, err, qt.IsNil)
+			prog.Close()
+		}
+
+		insns := asm.Instructions{ksym("bpf_prog_active", &btf.Struct{Name: "foo"}, false)}
+		var fdArray handles
+		defer fdArray.close()
+		err = fixupKsyms(insns, &fdArray)
+		qt.Assert(t, err, qt.IsNotNil, qt.Commentf("incompatible types must be rejected"))
+
+		insns = asm.Instructions{ksym("does_not_exist", integer, false)}
+		err = fixupKsyms(insns, &fdArray)
+		qt.Assert(t, errors.Is(err, ErrNotSupported), qt.IsTrue, qt.Commentf("got %v", err))
+
+		insns = asm.Instructions{ksym("does_not_exist", integer, true)}
+		qt.Assert(t, fixupKsyms(insns, &fdArray), qt.IsNil)
+		qt.Assert(t, insns[0].Src, qt.Equals, asm.R0)
+		qt.Assert(t, insns[0].Constant, qt.Equals, int64(0))
+	})
+}
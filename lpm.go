@@ -0,0 +1,143 @@
+package ebpf
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// PrefixMap wraps an LPMTrie whose keys are IPv4 or IPv6 prefixes.
+//
+// Keys have the layout expected by the kernel:
+//
+//	struct {
+//		__u32 prefixlen; // host byte order
+//		__u8  addr[4];   // or addr[16], network byte order
+//	};
+type PrefixMap struct {
+	m *Map
+	// The length of addresses in bytes, 4 or 16.
+	addrLen int
+}
+
+// NewPrefixMap wraps m, which must be an LPMTrie with a key size of 8 bytes
+// for IPv4 or 20 bytes for IPv6.
+//
+// The PrefixMap doesn't take ownership of m.
+func NewPrefixMap(m *Map) (*PrefixMap, error) {
+	if m.typ != LPMTrie {
+		return nil, fmt.Errorf("%s is not an LPM trie", m.typ)
+	}
+
+	switch m.keySize {
+	case 4 + 4, 4 + 16:
+	default:
+		return nil, fmt.Errorf("key size %d doesn't hold an IPv4 or IPv6 prefix", m.keySize)
+	}
+
+	return &PrefixMap{m, int(m.keySize) - 4}, nil
+}
+
+// Map returns the underlying Map.
+func (pm *PrefixMap) Map() *Map {
+	return pm.m
+}
+
+// Update changes the value of prefix, see Map.Update.
+//
+// Bits of the address beyond the prefix length are ignored.
+func (pm *PrefixMap) Update(prefix netip.Prefix, value interface{}, flags MapUpdateFlags) error {
+	key, err := pm.marshalPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	return pm.m.Update(key, value, flags)
+}
+
+// Lookup retrieves the value of the longest prefix containing addr.
+//
+// Returns an error if no prefix contains addr, see ErrKeyNotExist.
+func (pm *PrefixMap) Lookup(addr netip.Addr, valueOut interface{}) error {
+	key, err := pm.marshalPrefix(netip.PrefixFrom(addr, addr.BitLen()))
+	if err != nil {
+		return err
+	}
+	return pm.m.Lookup(key, valueOut)
+}
+
+// Delete removes prefix. Longer or shorter prefixes aren't affected.
+//
+// Returns ErrKeyNotExist if the prefix does not exist.
+func (pm *PrefixMap) Delete(prefix netip.Prefix) error {
+	key, err := pm.marshalPrefix(prefix)
+	if err != nil {
+		return err
+	}
+	return pm.m.Delete(key)
+}
+
+// Iterate traverses the prefixes, see Map.Iterate.
+func (pm *PrefixMap) Iterate() *PrefixMapIterator {
+	return &PrefixMapIterator{pm, pm.m.Iterate()}
+}
+
+// marshalPrefix encodes prefix as a key. IPv4-mapped IPv6 addresses are
+// accepted by IPv4 tries.
+func (pm *PrefixMap) marshalPrefix(prefix netip.Prefix) ([]byte, error) {
+	if !prefix.IsValid() {
+		return nil, fmt.Errorf("invalid prefix %s", prefix)
+	}
+
+	prefix = prefix.Masked()
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if pm.addrLen == 4 && addr.Is4In6() && bits >= 96 {
+		addr, bits = addr.Unmap(), bits-96
+	}
+	if addr.BitLen() != pm.addrLen*8 {
+		return nil, fmt.Errorf("prefix %s doesn't fit into a key of %d bytes", prefix, 4+pm.addrLen)
+	}
+
+	key := make([]byte, 4, 4+pm.addrLen)
+	internal.NativeEndian.PutUint32(key, uint32(bits))
+	return append(key, addr.AsSlice()...), nil
+}
+
+func (pm *PrefixMap) unmarshalPrefix(key []byte) (netip.Prefix, error) {
+	if len(key) != 4+pm.addrLen {
+		return netip.Prefix{}, fmt.Errorf("key of %d bytes is not a prefix", len(key))
+	}
+
+	addr, _ := netip.AddrFromSlice(key[4:])
+	bits := int(internal.NativeEndian.Uint32(key))
+	prefix := netip.PrefixFrom(addr, bits)
+	if !prefix.IsValid() {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix length %d", bits)
+	}
+	return prefix, nil
+}
+
+// PrefixMapIterator iterates a PrefixMap.
+type PrefixMapIterator struct {
+	pm *PrefixMap
+	mi *MapIterator
+}
+
+// Next decodes the next prefix and value, see MapIterator.Next.
+//
+// Returns false if there are no more entries. You must check the result of
+// Err afterwards.
+func (it *PrefixMapIterator) Next(prefixOut *netip.Prefix, valueOut interface{}) bool {
+	var key []byte
+	if !it.mi.Next(&key, valueOut) {
+		return false
+	}
+
+	*prefixOut, it.mi.err = it.pm.unmarshalPrefix(key)
+	return it.mi.err == nil
+}
+
+// Err returns any encountered error, see MapIterator.Err.
+func (it *PrefixMapIterator) Err() error {
+	return it.mi.Err()
+}
@@ -0,0 +1,108 @@
+package ebpf
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func newPrefixMap(t *testing.T, keySize uint32) *PrefixMap {
+	t.Helper()
+
+	m, err := NewMap(&MapSpec{
+		Type:       LPMTrie,
+		KeySize:    keySize,
+		ValueSize:  4,
+		MaxEntries: 8,
+		Flags:      unix.BPF_F_NO_PREALLOC,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	t.Cleanup(func() { m.Close() })
+
+	pm, err := NewPrefixMap(m)
+	qt.Assert(t, err, qt.IsNil)
+	return pm
+}
+
+func TestPrefixMapIPv4(t *testing.T) {
+	pm := newPrefixMap(t, 8)
+
+	prefixes := map[netip.Prefix]uint32{
+		netip.MustParsePrefix("10.0.0.0/8"):  8,
+		netip.MustParsePrefix("10.1.0.0/16"): 16,
+	}
+	for prefix, value := range prefixes {
+		qt.Assert(t, pm.Update(prefix, value, UpdateAny), qt.IsNil)
+	}
+
+	// Bits beyond the prefix length are ignored.
+	qt.Assert(t, pm.Update(netip.MustParsePrefix("192.168.1.1/24"), uint32(24), UpdateAny), qt.IsNil)
+	prefixes[netip.MustParsePrefix("192.168.1.0/24")] = 24
+
+	for addr, want := range map[string]uint32{
+		"10.2.3.4":        8,
+		"10.1.3.4":        16,
+		"::ffff:10.1.3.4": 16,
+		"192.168.1.200":   24,
+	} {
+		var value uint32
+		qt.Assert(t, pm.Lookup(netip.MustParseAddr(addr), &value), qt.IsNil, qt.Commentf("%s", addr))
+		qt.Assert(t, value, qt.Equals, want, qt.Commentf("%s", addr))
+	}
+
+	var value uint32
+	err := pm.Lookup(netip.MustParseAddr("11.0.0.1"), &value)
+	qt.Assert(t, errors.Is(err, ErrKeyNotExist), qt.IsTrue)
+
+	err = pm.Update(netip.MustParsePrefix("fd00::/8"), uint32(0), UpdateAny)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	got := make(map[netip.Prefix]uint32)
+	var prefix netip.Prefix
+	it := pm.Iterate()
+	for it.Next(&prefix, &value) {
+		got[prefix] = value
+	}
+	qt.Assert(t, it.Err(), qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, prefixes)
+
+	qt.Assert(t, pm.Delete(netip.MustParsePrefix("10.1.0.0/16")), qt.IsNil)
+	qt.Assert(t, pm.Lookup(netip.MustParseAddr("10.1.3.4"), &value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(8))
+}
+
+func TestPrefixMapIPv6(t *testing.T) {
+	pm := newPrefixMap(t, 20)
+
+	qt.Assert(t, pm.Update(netip.MustParsePrefix("fd00::/8"), uint32(8), UpdateAny), qt.IsNil)
+	qt.Assert(t, pm.Update(netip.MustParsePrefix("fd00:1::/32"), uint32(32), UpdateAny), qt.IsNil)
+
+	var value uint32
+	qt.Assert(t, pm.Lookup(netip.MustParseAddr("fd00:1::1"), &value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(32))
+	qt.Assert(t, pm.Lookup(netip.MustParseAddr("fd00:2::1"), &value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(8))
+
+	err := pm.Update(netip.MustParsePrefix("10.0.0.0/8"), uint32(0), UpdateAny)
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestNewPrefixMapInvalid(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    8,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	_, err = NewPrefixMap(m)
+	qt.Assert(t, err, qt.IsNotNil)
+}
@@ -24,6 +24,9 @@ var (
 	ErrKeyExist         = errors.New("key already exists")
 	ErrIterationAborted = errors.New("iteration aborted")
 	ErrMapIncompatible  = errors.New("map spec is incompatible with existing map")
+	ErrEmpty            = errors.New("map is empty")
+	ErrFull             = errors.New("map is full")
+	ErrReadOnly         = errors.New("read-only")
 	errMapNoBTFValue    = errors.New("map spec does not contain a BTF Value")
 )
 
@@ -34,6 +37,17 @@ type MapOptions struct {
 	// error is returned.
 	PinPath        string
 	LoadPinOptions LoadPinOptions
+
+	// Namespace is entered while creating the map, for example a network
+	// namespace opened from /proc/<pid>/ns/net. Defaults to the namespaces of
+	// the calling thread.
+	//
+	// Requires CAP_SYS_ADMIN. User and mount namespaces are not supported.
+	Namespace *os.File
+
+	// Token grants the permission to create maps and load their BTF. The
+	// token is also used for inner maps.
+	Token *Token
 }
 
 // MapID represents the unique ID of an eBPF map
@@ -53,6 +67,15 @@ type MapSpec struct {
 	// creation attributes.
 	Flags uint32
 
+	// MapExtra is an opaque field whose meaning is map-specific.
+	//
+	// For Arena it holds the user space address the arena is mapped at, or
+	// zero to let the kernel pick one.
+	//
+	// For BloomFilter the lower 4 bits hold the number of hash functions,
+	// zero selects the kernel default of 5.
+	MapExtra uint64
+
 	// Automatically pin and load a map from MapOptions.PinPath.
 	// Generates an error if an existing pinned map is incompatible with the MapSpec.
 	Pinning PinType
@@ -153,29 +176,10 @@ type MapKV struct {
 // Compatible returns nil if an existing map may be used instead of creating
 // one from the spec.
 //
-// Returns an error wrapping [ErrMapIncompatible] otherwise.
+// Returns a [MapIncompatibleError] listing all differences otherwise, which
+// wraps [ErrMapIncompatible].
 func (ms *MapSpec) Compatible(m *Map) error {
-	switch {
-	case m.typ != ms.Type:
-		return fmt.Errorf("expected type %v, got %v: %w", ms.Type, m.typ, ErrMapIncompatible)
-
-	case m.keySize != ms.KeySize:
-		return fmt.Errorf("expected key size %v, got %v: %w", ms.KeySize, m.keySize, ErrMapIncompatible)
-
-	case m.valueSize != ms.ValueSize:
-		return fmt.Errorf("expected value size %v, got %v: %w", ms.ValueSize, m.valueSize, ErrMapIncompatible)
-
-	case !(ms.Type == PerfEventArray && ms.MaxEntries == 0) &&
-		m.maxEntries != ms.MaxEntries:
-		return fmt.Errorf("expected max entries %v, got %v: %w", ms.MaxEntries, m.maxEntries, ErrMapIncompatible)
-
-	// BPF_F_RDONLY_PROG is set unconditionally for devmaps. Explicitly allow
-	// this mismatch.
-	case !((ms.Type == DevMap || ms.Type == DevMapHash) && m.flags^ms.Flags == unix.BPF_F_RDONLY_PROG) &&
-		m.flags != ms.Flags:
-		return fmt.Errorf("expected flags %v, got %v: %w", ms.Flags, m.flags, ErrMapIncompatible)
-	}
-	return nil
+	return ms.compatible(m, MapCompatibilityDefault)
 }
 
 // Map represents a Map file descriptor.
@@ -195,6 +199,10 @@ type Map struct {
 	valueSize  uint32
 	maxEntries uint32
 	flags      uint32
+	mapExtra   uint64
+	// The kernel doesn't report the NUMA node, so it's only known for maps
+	// created from a MapSpec.
+	numaNode   uint32
 	pinnedPath string
 	// Per CPU maps return values larger than the size in the spec
 	fullValueSize int
@@ -219,7 +227,13 @@ func newMapFromFD(fd *sys.FD) (*Map, error) {
 		return nil, fmt.Errorf("get map info: %w", err)
 	}
 
-	return newMap(fd, info.Name, info.Type, info.KeySize, info.ValueSize, info.MaxEntries, info.Flags)
+	m, err := newMap(fd, info.Name, info.Type, info.KeySize, info.ValueSize, info.MaxEntries, info.Flags)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mapExtra = info.mapExtra
+	return m, nil
 }
 
 // NewMap creates a new Map.
@@ -350,6 +364,7 @@ func (spec *MapSpec) createMap(inner *sys.FD, opts MapOptions) (_ *Map, err erro
 		}
 	}
 
+	var vmlinuxValueTypeID btf.TypeID
 	switch spec.Type {
 	case ArrayOfMaps, HashOfMaps:
 		if err := haveNestedMaps(); err != nil {
@@ -383,8 +398,60 @@ func (spec *MapSpec) createMap(inner *sys.FD, opts MapOptions) (_ *Map, err erro
 			}
 			spec.MaxEntries = uint32(n)
 		}
+
+	case BloomFilter:
+		if spec.KeySize != 0 {
+			return nil, errors.New("KeySize must be zero for bloom filter")
+		}
+
+		if spec.MapExtra > 0xf {
+			return nil, errors.New("MapExtra of bloom filter must hold at most 15 hash functions")
+		}
+
+		if err := haveBloomFilterMaps(); err != nil {
+			return nil, fmt.Errorf("map create: %w", err)
+		}
+
+	case Arena:
+		if spec.KeySize != 0 || spec.ValueSize != 0 {
+			return nil, errors.New("KeySize and ValueSize must be zero for arena")
+		}
+
+		if spec.Flags&unix.BPF_F_MMAPABLE == 0 {
+			return nil, errors.New("arena requires BPF_F_MMAPABLE")
+		}
+
+		if err := haveArenaMaps(); err != nil {
+			return nil, fmt.Errorf("map create: %w", err)
+		}
+
+	case StructOpsMap:
+		if spec.KeySize != 0 && spec.KeySize != 4 {
+			return nil, errors.New("KeySize must be zero or four for struct_ops")
+		}
+
+		if spec.MaxEntries != 0 && spec.MaxEntries != 1 {
+			return nil, errors.New("MaxEntries must be zero or one for struct_ops")
+		}
+
+		kv, err := findStructOpsKernelValue(spec.Value)
+		if err != nil {
+			return nil, fmt.Errorf("map create: %w", err)
+		}
+
+		// The value is described by vmlinux BTF instead of BTF supplied by
+		// user space.
+		spec = spec.Copy()
+		spec.KeySize = 4
+		spec.ValueSize = kv.value.Size
+		spec.MaxEntries = 1
+		spec.Key, spec.Value = nil, nil
+		vmlinuxValueTypeID = kv.valueID
 	}
 
+	if spec.Flags&unix.BPF_F_RDONLY > 0 && len(spec.Contents) > 0 {
+		return nil, errors.New("can't populate a map which is read-only for user space")
+	}
 	if spec.Flags&(unix.BPF_F_RDONLY_PROG|unix.BPF_F_WRONLY_PROG) > 0 || spec.Freeze {
 		if err := haveMapMutabilityModifiers(); err != nil {
 			return nil, fmt.Errorf("map create: %w", err)
@@ -413,6 +480,14 @@ func (spec *MapSpec) createMap(inner *sys.FD, opts MapOptions) (_ *Map, err erro
 		MaxEntries: spec.MaxEntries,
 		MapFlags:   sys.MapFlags(spec.Flags),
 		NumaNode:   spec.NumaNode,
+		MapExtra:   spec.MapExtra,
+
+		BtfVmlinuxValueTypeId: vmlinuxValueTypeID,
+	}
+
+	if opts.Token != nil {
+		attr.MapFlags |= sys.BPF_F_TOKEN_FD
+		attr.MapTokenFd = int32(opts.Token.FD())
 	}
 
 	if inner != nil {
@@ -424,7 +499,7 @@ func (spec *MapSpec) createMap(inner *sys.FD, opts MapOptions) (_ *Map, err erro
 	}
 
 	if spec.Key != nil || spec.Value != nil {
-		handle, keyTypeID, valueTypeID, err := btf.MarshalMapKV(spec.Key, spec.Value)
+		handle, keyTypeID, valueTypeID, err := btf.MarshalMapKVWithOptions(spec.Key, spec.Value, btf.HandleOptions{TokenFD: opts.Token.tokenFD()})
 		if err != nil && !errors.Is(err, btf.ErrNotSupported) {
 			return nil, fmt.Errorf("load BTF: %w", err)
 		}
@@ -439,12 +514,27 @@ func (spec *MapSpec) createMap(inner *sys.FD, opts MapOptions) (_ *Map, err erro
 		}
 	}
 
-	fd, err := sys.MapCreate(&attr)
-	// Some map types don't support BTF k/v in earlier kernel versions.
-	// Remove BTF metadata and retry map creation.
-	if (errors.Is(err, sys.ENOTSUPP) || errors.Is(err, unix.EINVAL)) && attr.BtfFd != 0 {
-		attr.BtfFd, attr.BtfKeyTypeId, attr.BtfValueTypeId = 0, 0, 0
+	var fd *sys.FD
+	create := func() (err error) {
 		fd, err = sys.MapCreate(&attr)
+		// Some map types don't support BTF k/v in earlier kernel versions.
+		// Remove BTF metadata and retry map creation.
+		if (errors.Is(err, sys.ENOTSUPP) || errors.Is(err, unix.EINVAL)) && attr.BtfFd != 0 {
+			attr.BtfFd, attr.BtfKeyTypeId, attr.BtfValueTypeId = 0, 0, 0
+			fd, err = sys.MapCreate(&attr)
+		}
+		return err
+	}
+
+	if opts.Namespace != nil {
+		err = internal.InNamespace(opts.Namespace, create)
+		if err != nil && fd != nil {
+			// The map may have been created before restoring the namespace
+			// failed.
+			fd.Close()
+		}
+	} else {
+		err = create()
 	}
 
 	if err != nil {
@@ -469,6 +559,7 @@ func (spec *MapSpec) createMap(inner *sys.FD, opts MapOptions) (_ *Map, err erro
 		return nil, fmt.Errorf("map create: %w", err)
 	}
 
+	m.mapExtra, m.numaNode = spec.MapExtra, spec.NumaNode
 	return m, nil
 }
 
@@ -483,6 +574,8 @@ func newMap(fd *sys.FD, name string, typ MapType, keySize, valueSize, maxEntries
 		valueSize,
 		maxEntries,
 		flags,
+		0,
+		0,
 		"",
 		int(valueSize),
 	}
@@ -700,6 +793,30 @@ func (m *Map) Update(key, value interface{}, flags MapUpdateFlags) error {
 	return nil
 }
 
+// Contains checks whether a value was pushed to a BloomFilter.
+//
+// False positives are possible, false negatives are not.
+func (m *Map) Contains(value interface{}) (bool, error) {
+	if m.typ != BloomFilter {
+		return false, fmt.Errorf("%s is not a bloom filter", m.typ)
+	}
+
+	// The kernel reads the value instead of writing it.
+	valuePtr, err := m.marshalValue(value)
+	if err != nil {
+		return false, fmt.Errorf("can't marshal value: %w", err)
+	}
+
+	err = m.lookup(nil, valuePtr, 0)
+	if errors.Is(err, ErrKeyNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // Delete removes a value.
 //
 // Returns ErrKeyNotExist if the key does not exist.
@@ -1094,11 +1211,174 @@ func (m *Map) Clone() (*Map, error) {
 		m.valueSize,
 		m.maxEntries,
 		m.flags,
+		m.mapExtra,
+		m.numaNode,
 		"",
 		m.fullValueSize,
 	}, nil
 }
 
+// ResizeOptions control Map.Resize.
+type ResizeOptions struct {
+	// References are slots in maps of maps which refer to the map. They are
+	// pointed at the resized map once all entries have been copied.
+	//
+	// Both the map and the inner map of the outer maps must have been created
+	// with BPF_F_INNER_MAP, otherwise the kernel rejects maps with a different
+	// number of max entries.
+	References []MapReference
+}
+
+// MapReference is a slot in a map of maps.
+type MapReference struct {
+	Outer *Map
+	Key   interface{}
+}
+
+// Resize creates a copy of the map with a different number of max entries.
+//
+// Maps can't be resized in place. Instead, Resize creates a new map with the
+// same properties as m, copies all entries using batch operations if
+// possible and then replaces m in all references given in opts. Each
+// reference is replaced atomically. If replacing a reference fails, the
+// references replaced so far are reverted to m.
+//
+// Only array and hash maps are supported. Shrinking an array drops all
+// entries past the new size, shrinking a hash map fails if the entries don't
+// fit. Updates made to m while Resize is in progress may be lost.
+//
+// m is left untouched. The caller is responsible for closing both maps.
+func (m *Map) Resize(maxEntries uint32, opts *ResizeOptions) (*Map, error) {
+	switch m.typ {
+	case Array, PerCPUArray, Hash, PerCPUHash, LRUHash, LRUCPUHash:
+	default:
+		return nil, fmt.Errorf("resize %s: %w", m.typ, ErrNotSupported)
+	}
+
+	if maxEntries == 0 {
+		return nil, errors.New("resize: max entries must be non-zero")
+	}
+
+	if opts == nil {
+		opts = &ResizeOptions{}
+	}
+
+	key, value, err := m.btfKeyValue()
+	if err != nil {
+		return nil, fmt.Errorf("resize: get BTF: %w", err)
+	}
+
+	resized, err := NewMap(&MapSpec{
+		Name:       m.name,
+		Type:       m.typ,
+		KeySize:    m.keySize,
+		ValueSize:  m.valueSize,
+		MaxEntries: maxEntries,
+		Flags:      m.flags,
+		MapExtra:   m.mapExtra,
+		NumaNode:   m.numaNode,
+		Key:        key,
+		Value:      value,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("resize: %w", err)
+	}
+
+	if err := m.copyEntries(resized); err != nil {
+		resized.Close()
+		return nil, fmt.Errorf("resize: copy entries: %w", err)
+	}
+
+	for i, ref := range opts.References {
+		if err := ref.Outer.Update(ref.Key, resized, UpdateAny); err != nil {
+			for _, ref := range opts.References[:i] {
+				_ = ref.Outer.Update(ref.Key, m, UpdateAny)
+			}
+			resized.Close()
+			return nil, fmt.Errorf("resize: replace reference in %s: %w", ref.Outer, err)
+		}
+	}
+
+	return resized, nil
+}
+
+// copyEntries copies all entries of m into dst, which must have the same
+// type, key and value size.
+//
+// Entries of arrays which don't fit into dst are skipped.
+func (m *Map) copyEntries(dst *Map) error {
+	err := m.batchCopyEntries(dst)
+	if errors.Is(err, ErrNotSupported) {
+		return m.iterCopyEntries(dst)
+	}
+	return err
+}
+
+func (m *Map) batchCopyEntries(dst *Map) error {
+	if m.typ.hasPerCPUValue() {
+		return batchCopyEntries[[][]byte](m, dst)
+	}
+	return batchCopyEntries[[]byte](m, dst)
+}
+
+// batchCopyEntries copies entries using the batch helpers. V is []byte, or
+// [][]byte for per-CPU maps.
+func batchCopyEntries[V any](src, dst *Map) error {
+	keys, values, err := BatchLookupAll[[]byte, V](src, nil)
+	if err != nil {
+		return err
+	}
+
+	// Arrays are returned in order of their indices.
+	if (src.typ == Array || src.typ == PerCPUArray) && len(keys) > int(dst.maxEntries) {
+		keys, values = keys[:dst.maxEntries], values[:dst.maxEntries]
+	}
+
+	_, err = BatchUpdateAll(dst, keys, values, nil)
+	return err
+}
+
+func (m *Map) iterCopyEntries(dst *Map) error {
+	var (
+		prevKey interface{}
+		key     = make([]byte, m.keySize)
+		value   = make([]byte, m.fullValueSize)
+	)
+
+	for i := uint32(0); ; i++ {
+		if (m.typ == Array || m.typ == PerCPUArray) && i >= dst.maxEntries {
+			return nil
+		}
+
+		err := m.nextKey(prevKey, sys.NewSlicePointer(key))
+		if errors.Is(err, ErrKeyNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		prevKey = append([]byte(nil), key...)
+
+		err = m.lookup(key, sys.NewSlicePointer(value), 0)
+		if errors.Is(err, ErrKeyNotExist) {
+			// The entry was deleted concurrently.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		err = sys.MapUpdateElem(&sys.MapUpdateElemAttr{
+			MapFd: dst.fd.Uint(),
+			Key:   sys.NewSlicePointer(key),
+			Value: sys.NewSlicePointer(value),
+		})
+		if err != nil {
+			return fmt.Errorf("update: %w", wrapMapError(err))
+		}
+	}
+}
+
 // Pin persists the map on the BPF virtual file system past the lifetime of
 // the process that created it .
 //
@@ -1152,6 +1432,23 @@ func (m *Map) Freeze() error {
 	return nil
 }
 
+// IsFrozen returns true if the map can't be modified from user space, either
+// because Freeze was called or because it was created with MapReadOnly.
+//
+// Requires Linux 5.2.
+func (m *Map) IsFrozen() (bool, error) {
+	var frozen int
+	err := scanFdInfo(m.fd, map[string]interface{}{"frozen": &frozen})
+	if errors.Is(err, ErrNotSupported) {
+		return false, fmt.Errorf("frozen state: %w", ErrNotSupported)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return frozen != 0 || m.flags&unix.BPF_F_RDONLY > 0, nil
+}
+
 // finalize populates the Map according to the Contents specified
 // in spec and freezes the Map if requested by spec.
 func (m *Map) finalize(spec *MapSpec) error {
@@ -1434,3 +1731,66 @@ func NewMapFromID(id MapID) (*Map, error) {
 
 	return newMapFromFD(fd)
 }
+
+// LoadedMapIterator allows enumerating the maps loaded into the kernel.
+//
+// Requires CAP_SYS_ADMIN.
+type LoadedMapIterator struct {
+	// The ID of the current map. Only valid after a call to Next.
+	ID MapID
+	// The current Map. Only valid until a call to Next.
+	// See Take if you want to retain the map.
+	Map *Map
+	err error
+}
+
+// Next retrieves the next map.
+//
+// Returns true if another map was found. Call [LoadedMapIterator.Err] after
+// the function returns false.
+func (it *LoadedMapIterator) Next() bool {
+	id := it.ID
+	for {
+		var err error
+		id, err = MapGetNextID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			// There are no more maps.
+			break
+		} else if err != nil {
+			it.err = fmt.Errorf("get next map ID: %w", err)
+			break
+		}
+
+		m, err := NewMapFromID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			// The map was freed in the meantime.
+			continue
+		} else if err != nil {
+			it.err = fmt.Errorf("open map %d: %w", id, err)
+			break
+		}
+
+		it.Map.Close()
+		it.ID, it.Map = id, m
+		return true
+	}
+
+	// No more maps or we encountered an error.
+	it.Map.Close()
+	it.Map = nil
+	return false
+}
+
+// Take the ownership of the current map.
+//
+// It's the callers responsibility to close the map.
+func (it *LoadedMapIterator) Take() *Map {
+	m := it.Map
+	it.Map = nil
+	return m
+}
+
+// Err returns an error if iteration failed for some reason.
+func (it *LoadedMapIterator) Err() error {
+	return it.err
+}
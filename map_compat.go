@@ -0,0 +1,252 @@
+package ebpf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// MapCompatibility controls how an existing map is checked against a MapSpec.
+type MapCompatibility int
+
+const (
+	// The type, key and value size, max entries and flags must match. This is
+	// the check performed by MapSpec.Compatible.
+	MapCompatibilityDefault MapCompatibility = iota
+	// Only the type and key and value size must match.
+	MapCompatibilityLenient
+	// Like MapCompatibilityDefault, but the BTF of the key and value must also
+	// be equal if the MapSpec carries any.
+	MapCompatibilityStrict
+)
+
+// MapDiff is a property of a map which differs between a MapSpec and an
+// existing map.
+type MapDiff struct {
+	Field string
+	Spec  interface{}
+	Map   interface{}
+}
+
+// MapIncompatibleError is returned if an existing map doesn't match a
+// MapSpec. It wraps ErrMapIncompatible.
+type MapIncompatibleError struct {
+	Diffs []MapDiff
+}
+
+// Error formats the differences like a diff: lines prefixed with '-' are
+// taken from the spec, lines prefixed with '+' from the existing map.
+func (mie *MapIncompatibleError) Error() string {
+	var b strings.Builder
+	b.WriteString(ErrMapIncompatible.Error())
+	b.WriteString(":")
+	for _, d := range mie.Diffs {
+		fmt.Fprintf(&b, "\n-%s: %v\n+%s: %v", d.Field, d.Spec, d.Field, d.Map)
+	}
+	return b.String()
+}
+
+func (mie *MapIncompatibleError) Unwrap() error {
+	return ErrMapIncompatible
+}
+
+// compatible checks whether m may be used instead of creating a map from ms.
+//
+// Returns a *MapIncompatibleError listing all differences if m can't be used.
+func (ms *MapSpec) compatible(m *Map, compat MapCompatibility) error {
+	var diffs []MapDiff
+	add := func(field string, spec, m interface{}) {
+		diffs = append(diffs, MapDiff{field, spec, m})
+	}
+
+	if m.typ != ms.Type {
+		add("type", ms.Type, m.typ)
+	}
+	if m.keySize != ms.KeySize {
+		add("key size", ms.KeySize, m.keySize)
+	}
+	if m.valueSize != ms.ValueSize {
+		add("value size", ms.ValueSize, m.valueSize)
+	}
+
+	if compat != MapCompatibilityLenient {
+		if !(ms.Type == PerfEventArray && ms.MaxEntries == 0) &&
+			m.maxEntries != ms.MaxEntries {
+			add("max entries", ms.MaxEntries, m.maxEntries)
+		}
+
+		// BPF_F_RDONLY_PROG is set unconditionally for devmaps. Explicitly allow
+		// this mismatch.
+		if !((ms.Type == DevMap || ms.Type == DevMapHash) && m.flags^ms.Flags == unix.BPF_F_RDONLY_PROG) &&
+			m.flags != ms.Flags {
+			add("flags", fmt.Sprintf("%#x", ms.Flags), fmt.Sprintf("%#x", m.flags))
+		}
+	}
+
+	if compat == MapCompatibilityStrict && (ms.Key != nil || ms.Value != nil) {
+		key, value, err := m.btfKeyValue()
+		if err != nil {
+			return fmt.Errorf("get BTF of existing map: %w", err)
+		}
+
+		if ms.Key != nil && !btfTypesEqual(ms.Key, key) {
+			add("key BTF", ms.Key, key)
+		}
+		if ms.Value != nil && !btfTypesEqual(ms.Value, value) {
+			add("value BTF", ms.Value, value)
+		}
+	}
+
+	if len(diffs) > 0 {
+		return &MapIncompatibleError{diffs}
+	}
+	return nil
+}
+
+// btfKeyValue returns the BTF of the key and value of m.
+//
+// Either type is nil if the map doesn't carry BTF for it.
+func (m *Map) btfKeyValue() (key, value btf.Type, _ error) {
+	var info sys.MapInfo
+	if err := sys.ObjInfo(m.fd, &info); err != nil {
+		return nil, nil, err
+	}
+
+	if info.BtfId == 0 {
+		return nil, nil, nil
+	}
+
+	h, err := btf.NewHandleFromID(btf.ID(info.BtfId))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer h.Close()
+
+	spec, err := h.Spec(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.BtfKeyTypeId != 0 {
+		key, err = spec.TypeByID(btf.TypeID(info.BtfKeyTypeId))
+		if err != nil {
+			return nil, nil, fmt.Errorf("key: %w", err)
+		}
+	}
+
+	if info.BtfValueTypeId != 0 {
+		value, err = spec.TypeByID(btf.TypeID(info.BtfValueTypeId))
+		if err != nil {
+			return nil, nil, fmt.Errorf("value: %w", err)
+		}
+	}
+
+	return key, value, nil
+}
+
+// btfTypesEqual returns true if a and b describe the same type, including
+// the names of types and members.
+//
+// Only types which may appear in map keys and values are supported.
+func btfTypesEqual(a, b btf.Type) bool {
+	return btfTypesEqualVisited(a, b, make(map[[2]btf.Type]bool))
+}
+
+func btfTypesEqualVisited(a, b btf.Type, visited map[[2]btf.Type]bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	// Assume that types are equal if they are already being compared higher
+	// up in the stack. This terminates recursion for cyclical types.
+	pair := [2]btf.Type{a, b}
+	if visited[pair] {
+		return true
+	}
+	visited[pair] = true
+
+	equal := func(a, b btf.Type) bool {
+		return btfTypesEqualVisited(a, b, visited)
+	}
+
+	membersEqual := func(a, b []btf.Member) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i].Name != b[i].Name || a[i].Offset != b[i].Offset ||
+				a[i].BitfieldSize != b[i].BitfieldSize || !equal(a[i].Type, b[i].Type) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch a := a.(type) {
+	case *btf.Void:
+		_, ok := b.(*btf.Void)
+		return ok
+
+	case *btf.Int:
+		b, ok := b.(*btf.Int)
+		return ok && *a == *b
+
+	case *btf.Float:
+		b, ok := b.(*btf.Float)
+		return ok && *a == *b
+
+	case *btf.Fwd:
+		b, ok := b.(*btf.Fwd)
+		return ok && *a == *b
+
+	case *btf.Pointer:
+		b, ok := b.(*btf.Pointer)
+		return ok && equal(a.Target, b.Target)
+
+	case *btf.Array:
+		b, ok := b.(*btf.Array)
+		return ok && a.Nelems == b.Nelems && equal(a.Index, b.Index) && equal(a.Type, b.Type)
+
+	case *btf.Struct:
+		b, ok := b.(*btf.Struct)
+		return ok && a.Name == b.Name && a.Size == b.Size && membersEqual(a.Members, b.Members)
+
+	case *btf.Union:
+		b, ok := b.(*btf.Union)
+		return ok && a.Name == b.Name && a.Size == b.Size && membersEqual(a.Members, b.Members)
+
+	case *btf.Enum:
+		b, ok := b.(*btf.Enum)
+		if !ok || a.Name != b.Name || a.Size != b.Size || a.Signed != b.Signed || len(a.Values) != len(b.Values) {
+			return false
+		}
+		for i := range a.Values {
+			if a.Values[i] != b.Values[i] {
+				return false
+			}
+		}
+		return true
+
+	case *btf.Typedef:
+		b, ok := b.(*btf.Typedef)
+		return ok && a.Name == b.Name && equal(a.Type, b.Type)
+
+	case *btf.Volatile:
+		b, ok := b.(*btf.Volatile)
+		return ok && equal(a.Type, b.Type)
+
+	case *btf.Const:
+		b, ok := b.(*btf.Const)
+		return ok && equal(a.Type, b.Type)
+
+	case *btf.Restrict:
+		b, ok := b.(*btf.Restrict)
+		return ok && equal(a.Type, b.Type)
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,244 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+const (
+	dumpMagic   = "EBPFMAP\x00"
+	dumpVersion = 1
+)
+
+// dumpHeader starts the output of Map.Dump. It is followed by the name of the
+// map, the raw BTF of the map and the entries.
+//
+// All fields and the keys and values use the byte order of the host which
+// wrote the dump.
+type dumpHeader struct {
+	Magic        [8]byte
+	Version      uint32
+	Type         MapType
+	KeySize      uint32
+	ValueSize    uint32
+	MaxEntries   uint32
+	Flags        uint32
+	PossibleCPUs uint32
+	KeyTypeID    btf.TypeID
+	ValueTypeID  btf.TypeID
+	NameLen      uint32
+	BTFLen       uint32
+	_            uint32
+	Entries      uint64
+}
+
+// Dump writes the spec, BTF and contents of a map to w, which can be turned
+// into a new map by RestoreMap.
+//
+// Only maps which don't hold file descriptors or kernel objects can be
+// dumped, for example hash maps, arrays, LRU maps and their per-CPU
+// variants.
+//
+// The contents are read before anything is written to w. They are only
+// consistent if the map isn't modified concurrently, see Map.Iterate.
+func (m *Map) Dump(w io.Writer) error {
+	if !m.typ.canDump() {
+		return fmt.Errorf("can't dump %s", m.typ)
+	}
+
+	possibleCPUs, err := internal.PossibleCPUs()
+	if err != nil {
+		return err
+	}
+
+	hdr := dumpHeader{
+		Version:      dumpVersion,
+		Type:         m.typ,
+		KeySize:      m.keySize,
+		ValueSize:    m.valueSize,
+		MaxEntries:   m.maxEntries,
+		Flags:        m.flags,
+		PossibleCPUs: uint32(possibleCPUs),
+		NameLen:      uint32(len(m.name)),
+	}
+	copy(hdr.Magic[:], dumpMagic)
+
+	var rawBTF []byte
+	var info sys.MapInfo
+	if err := sys.ObjInfo(m.fd, &info); err == nil && info.BtfId != 0 && info.BtfValueTypeId != 0 {
+		rawBTF, err = loadRawBTF(info.BtfId)
+		if err != nil {
+			return fmt.Errorf("dump BTF: %w", err)
+		}
+		hdr.KeyTypeID = info.BtfKeyTypeId
+		hdr.ValueTypeID = info.BtfValueTypeId
+		hdr.BTFLen = uint32(len(rawBTF))
+	}
+
+	entries, err := m.snapshot(nil)
+	if err != nil {
+		return fmt.Errorf("dump: %w", err)
+	}
+	hdr.Entries = uint64(len(entries))
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(nil)
+	if err := binary.Write(buf, internal.NativeEndian, &hdr); err != nil {
+		return err
+	}
+	buf.WriteString(m.name)
+	buf.Write(rawBTF)
+	for _, key := range keys {
+		buf.WriteString(key)
+		buf.Write(entries[key])
+	}
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// RestoreMap creates a new map from the output of Map.Dump.
+//
+// Values of per-CPU maps which were dumped on a host with more possible CPUs
+// are truncated, missing values are zero.
+func RestoreMap(r io.Reader) (*Map, error) {
+	var hdr dumpHeader
+	if err := binary.Read(r, internal.NativeEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if string(hdr.Magic[:]) != dumpMagic {
+		return nil, errors.New("not a map dump")
+	}
+	if hdr.Version != dumpVersion {
+		if hdr.Version == bits.ReverseBytes32(dumpVersion) {
+			return nil, errors.New("map dump has a different byte order")
+		}
+		return nil, fmt.Errorf("unsupported map dump version %d", hdr.Version)
+	}
+	if !hdr.Type.canDump() {
+		return nil, fmt.Errorf("can't restore %s", hdr.Type)
+	}
+	if hdr.NameLen > unix.BPF_OBJ_NAME_LEN {
+		return nil, fmt.Errorf("name of %d bytes exceeds %d bytes", hdr.NameLen, unix.BPF_OBJ_NAME_LEN)
+	}
+
+	name := make([]byte, hdr.NameLen)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, fmt.Errorf("read name: %w", err)
+	}
+
+	spec := &MapSpec{
+		Name:       string(name),
+		Type:       hdr.Type,
+		KeySize:    hdr.KeySize,
+		ValueSize:  hdr.ValueSize,
+		MaxEntries: hdr.MaxEntries,
+		Flags:      hdr.Flags,
+	}
+
+	if hdr.BTFLen > 0 {
+		rawBTF := make([]byte, hdr.BTFLen)
+		if _, err := io.ReadFull(r, rawBTF); err != nil {
+			return nil, fmt.Errorf("read BTF: %w", err)
+		}
+
+		types, err := btf.LoadSpecFromReader(bytes.NewReader(rawBTF))
+		if err != nil {
+			return nil, fmt.Errorf("load BTF: %w", err)
+		}
+		if hdr.KeyTypeID != 0 {
+			if spec.Key, err = types.TypeByID(hdr.KeyTypeID); err != nil {
+				return nil, fmt.Errorf("key type: %w", err)
+			}
+		}
+		if spec.Value, err = types.TypeByID(hdr.ValueTypeID); err != nil {
+			return nil, fmt.Errorf("value type: %w", err)
+		}
+	}
+
+	m, err := NewMap(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.restoreEntries(r, &hdr); err != nil {
+		m.Close()
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *Map) restoreEntries(r io.Reader, hdr *dumpHeader) error {
+	valueLen := int(hdr.ValueSize)
+	if m.typ.hasPerCPUValue() {
+		valueLen = internal.Align(valueLen, 8) * int(hdr.PossibleCPUs)
+	}
+
+	key := make([]byte, hdr.KeySize)
+	value := make([]byte, valueLen)
+	fullValue := make([]byte, m.fullValueSize)
+	for i := uint64(0); i < hdr.Entries; i++ {
+		if _, err := io.ReadFull(r, key); err != nil {
+			return fmt.Errorf("read key %d: %w", i, err)
+		}
+		if _, err := io.ReadFull(r, value); err != nil {
+			return fmt.Errorf("read value %d: %w", i, err)
+		}
+
+		// The number of possible CPUs may differ between hosts.
+		n := copy(fullValue, value)
+		for j := n; j < len(fullValue); j++ {
+			fullValue[j] = 0
+		}
+
+		attr := sys.MapUpdateElemAttr{
+			MapFd: m.fd.Uint(),
+			Key:   sys.NewSlicePointer(key),
+			Value: sys.NewSlicePointer(fullValue),
+		}
+		if err := sys.MapUpdateElem(&attr); err != nil {
+			return fmt.Errorf("restore key %d: %w", i, wrapMapError(err))
+		}
+	}
+
+	return nil
+}
+
+// loadRawBTF returns the BTF blob of a BTF object.
+func loadRawBTF(id uint32) ([]byte, error) {
+	fd, err := sys.BtfGetFdById(&sys.BtfGetFdByIdAttr{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var info sys.BtfInfo
+	if err := sys.ObjInfo(fd, &info); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, info.BtfSize)
+	info = sys.BtfInfo{}
+	info.Btf, info.BtfSize = sys.NewSlicePointerLen(raw)
+	if err := sys.ObjInfo(fd, &info); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
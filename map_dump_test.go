@@ -0,0 +1,126 @@
+package ebpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMapDumpRestore(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Name:       "dump_test",
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 8,
+		Key:        &btf.Int{Name: "u32", Size: 4},
+		Value:      &btf.Int{Name: "u64", Size: 8},
+	})
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for i := uint32(0); i < 5; i++ {
+		qt.Assert(t, m.Put(i, uint64(i)*7), qt.IsNil)
+	}
+
+	var buf bytes.Buffer
+	qt.Assert(t, m.Dump(&buf), qt.IsNil)
+
+	restored, err := RestoreMap(&buf)
+	qt.Assert(t, err, qt.IsNil)
+	defer restored.Close()
+
+	qt.Assert(t, restored.Type(), qt.Equals, Hash)
+	qt.Assert(t, restored.MaxEntries(), qt.Equals, uint32(8))
+
+	var (
+		key   uint32
+		value uint64
+		count int
+	)
+	it := restored.Iterate()
+	for it.Next(&key, &value) {
+		qt.Assert(t, value, qt.Equals, uint64(key)*7)
+		count++
+	}
+	qt.Assert(t, it.Err(), qt.IsNil)
+	qt.Assert(t, count, qt.Equals, 5)
+
+	var info sys.MapInfo
+	qt.Assert(t, sys.ObjInfo(restored.fd, &info), qt.IsNil)
+	qt.Assert(t, info.BtfValueTypeId, qt.Not(qt.Equals), sys.TypeID(0))
+
+	if info, err := restored.Info(); err == nil && info.Name != "" {
+		qt.Assert(t, info.Name, qt.Equals, "dump_test")
+	}
+}
+
+func TestMapDumpRestorePerCPU(t *testing.T) {
+	possibleCPUs, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	values := make([]uint32, possibleCPUs)
+	for i := range values {
+		values[i] = uint32(i) + 1
+	}
+	qt.Assert(t, m.Put(uint32(1), values), qt.IsNil)
+
+	var buf bytes.Buffer
+	qt.Assert(t, m.Dump(&buf), qt.IsNil)
+
+	restored, err := RestoreMap(&buf)
+	qt.Assert(t, err, qt.IsNil)
+	defer restored.Close()
+
+	var got []uint32
+	qt.Assert(t, restored.Lookup(uint32(1), &got), qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, values)
+}
+
+func TestMapDumpInvalid(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	qt.Assert(t, m.Dump(&bytes.Buffer{}), qt.IsNotNil)
+
+	_, err = RestoreMap(bytes.NewReader(make([]byte, 64)))
+	qt.Assert(t, err, qt.ErrorMatches, "not a map dump")
+
+	var buf bytes.Buffer
+	hdr := dumpHeader{Version: dumpVersion + 1}
+	copy(hdr.Magic[:], dumpMagic)
+	qt.Assert(t, binary.Write(&buf, internal.NativeEndian, &hdr), qt.IsNil)
+	_, err = RestoreMap(&buf)
+	qt.Assert(t, err, qt.ErrorMatches, "unsupported map dump version .*")
+}
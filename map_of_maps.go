@@ -0,0 +1,147 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MapOfMaps is an ArrayOfMaps or HashOfMaps whose inner maps are created from
+// a fixed spec.
+type MapOfMaps struct {
+	outer *Map
+	inner *MapSpec
+}
+
+// NewMapOfMaps creates an ArrayOfMaps or HashOfMaps from spec, which must
+// have an InnerMap.
+func NewMapOfMaps(spec *MapSpec) (*MapOfMaps, error) {
+	if !spec.Type.canStoreMap() {
+		return nil, fmt.Errorf("%s can't store maps", spec.Type)
+	}
+	if spec.InnerMap == nil {
+		return nil, fmt.Errorf("%s requires InnerMap", spec.Type)
+	}
+
+	outer, err := NewMap(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MapOfMaps{outer, spec.InnerMap.Copy()}, nil
+}
+
+// NewMapOfMapsFromMap wraps an existing ArrayOfMaps or HashOfMaps, for
+// example from a Collection. inner must match the spec the map was created
+// with.
+//
+// m is cloned, the caller remains responsible for closing it.
+func NewMapOfMapsFromMap(m *Map, inner *MapSpec) (*MapOfMaps, error) {
+	if !m.typ.canStoreMap() {
+		return nil, fmt.Errorf("%s can't store maps", m.typ)
+	}
+	if inner == nil {
+		return nil, errors.New("missing inner map spec")
+	}
+
+	outer, err := m.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MapOfMaps{outer, inner.Copy()}, nil
+}
+
+// Map returns the outer map.
+func (mm *MapOfMaps) Map() *Map {
+	return mm.outer
+}
+
+// Close the outer map. Inner maps are not affected.
+func (mm *MapOfMaps) Close() error {
+	return mm.outer.Close()
+}
+
+// Create creates an inner map which holds contents and stores it at key.
+//
+// The inner map is populated before it is stored, so BPF programs either
+// see the previous inner map or the complete new one.
+//
+// The caller must Close the returned map. The outer map keeps the inner map
+// alive until it is replaced or deleted.
+func (mm *MapOfMaps) Create(key interface{}, contents []MapKV, flags MapUpdateFlags) (*Map, error) {
+	spec := mm.inner.Copy()
+	spec.Contents = contents
+
+	inner, err := NewMap(spec)
+	if err != nil {
+		return nil, fmt.Errorf("inner map: %w", err)
+	}
+
+	if err := mm.outer.Update(key, inner, flags); err != nil {
+		inner.Close()
+		return nil, err
+	}
+
+	return inner, nil
+}
+
+// Lookup returns the inner map stored at key. The caller must Close it.
+//
+// Returns an error if the key doesn't exist, see ErrKeyNotExist.
+func (mm *MapOfMaps) Lookup(key interface{}) (*Map, error) {
+	var inner *Map
+	if err := mm.outer.Lookup(key, &inner); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
+
+// Delete removes the inner map stored at key.
+//
+// Returns ErrKeyNotExist if the key does not exist.
+func (mm *MapOfMaps) Delete(key interface{}) error {
+	return mm.outer.Delete(key)
+}
+
+// Iterate traverses the inner maps, see Map.Iterate.
+func (mm *MapOfMaps) Iterate() *MapOfMapsIterator {
+	return &MapOfMapsIterator{mi: mm.outer.Iterate()}
+}
+
+// MapOfMapsIterator iterates a MapOfMaps.
+type MapOfMapsIterator struct {
+	mi    *MapIterator
+	inner *Map
+}
+
+// Next decodes the next key and inner map.
+//
+// The inner map is closed by the following call to Next or by Close. Use
+// Map.Clone to keep it.
+//
+// Returns false if there are no more entries. You must check the result of
+// Err afterwards.
+func (it *MapOfMapsIterator) Next(keyOut interface{}, innerOut **Map) bool {
+	// MapIterator closes the previous inner map.
+	if !it.mi.Next(keyOut, &it.inner) {
+		it.Close()
+		*innerOut = nil
+		return false
+	}
+
+	*innerOut = it.inner
+	return true
+}
+
+// Err returns any encountered error, see MapIterator.Err.
+func (it *MapOfMapsIterator) Err() error {
+	return it.mi.Err()
+}
+
+// Close the inner map returned by the last call to Next. It's only necessary
+// to call Close when stopping the iteration early.
+func (it *MapOfMapsIterator) Close() error {
+	inner := it.inner
+	it.inner = nil
+	return inner.Close()
+}
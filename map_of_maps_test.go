@@ -0,0 +1,98 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMapOfMaps(t *testing.T) {
+	for _, typ := range []MapType{ArrayOfMaps, HashOfMaps} {
+		t.Run(typ.String(), func(t *testing.T) {
+			mm, err := NewMapOfMaps(&MapSpec{
+				Type:       typ,
+				KeySize:    4,
+				MaxEntries: 2,
+				InnerMap: &MapSpec{
+					Type:       Array,
+					KeySize:    4,
+					ValueSize:  4,
+					MaxEntries: 1,
+				},
+			})
+			testutils.SkipIfNotSupported(t, err)
+			qt.Assert(t, err, qt.IsNil)
+			defer mm.Close()
+
+			inner, err := mm.Create(uint32(1), []MapKV{{uint32(0), uint32(42)}}, UpdateAny)
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, inner.Close(), qt.IsNil)
+
+			inner, err = mm.Lookup(uint32(1))
+			qt.Assert(t, err, qt.IsNil)
+			var value uint32
+			qt.Assert(t, inner.Lookup(uint32(0), &value), qt.IsNil)
+			qt.Assert(t, value, qt.Equals, uint32(42))
+			qt.Assert(t, inner.Close(), qt.IsNil)
+
+			var (
+				key   uint32
+				count int
+			)
+			it := mm.Iterate()
+			for it.Next(&key, &inner) {
+				qt.Assert(t, key, qt.Equals, uint32(1))
+				qt.Assert(t, inner.Lookup(uint32(0), &value), qt.IsNil)
+				count++
+			}
+			qt.Assert(t, it.Err(), qt.IsNil)
+			qt.Assert(t, count, qt.Equals, 1)
+			qt.Assert(t, inner, qt.IsNil)
+
+			qt.Assert(t, mm.Delete(uint32(1)), qt.IsNil)
+			_, err = mm.Lookup(uint32(1))
+			qt.Assert(t, errors.Is(err, ErrKeyNotExist), qt.IsTrue)
+		})
+	}
+}
+
+func TestMapOfMapsFromMap(t *testing.T) {
+	spec := &MapSpec{
+		Type:       HashOfMaps,
+		KeySize:    4,
+		MaxEntries: 1,
+		InnerMap: &MapSpec{
+			Type:       Hash,
+			KeySize:    4,
+			ValueSize:  4,
+			MaxEntries: 1,
+		},
+	}
+
+	m, err := NewMap(spec)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	_, err = NewMapOfMapsFromMap(m, nil)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	mm, err := NewMapOfMapsFromMap(m, spec.InnerMap)
+	qt.Assert(t, err, qt.IsNil)
+
+	inner, err := mm.Create(uint32(0), nil, UpdateNoExist)
+	qt.Assert(t, err, qt.IsNil)
+	defer inner.Close()
+
+	// Closing the wrapper doesn't affect m.
+	qt.Assert(t, mm.Close(), qt.IsNil)
+
+	var id MapID
+	qt.Assert(t, m.Lookup(uint32(0), &id), qt.IsNil)
+
+	_, err = NewMapOfMaps(&MapSpec{Type: Hash, KeySize: 4, ValueSize: 4, MaxEntries: 1})
+	qt.Assert(t, err, qt.IsNotNil)
+}
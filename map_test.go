@@ -12,6 +12,7 @@ import (
 	"unsafe"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/sys"
 	"github.com/cilium/ebpf/internal/testutils"
@@ -427,6 +428,21 @@ func TestMapCloneNil(t *testing.T) {
 	}
 }
 
+func TestMapNamespace(t *testing.T) {
+	ns := testutils.NewNetNS(t)
+
+	m, err := NewMapWithOptions(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	}, MapOptions{Namespace: ns})
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	qt.Assert(t, m.Put(uint32(0), uint32(42)), qt.IsNil)
+}
+
 func TestMapPin(t *testing.T) {
 	m := createArray(t)
 	c := qt.New(t)
@@ -825,6 +841,51 @@ func TestMapQueue(t *testing.T) {
 	}
 }
 
+func TestMapBloomFilter(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.16", "map type bloom filter")
+
+	_, err := NewMap(&MapSpec{
+		Type:       BloomFilter,
+		ValueSize:  4,
+		MaxEntries: 16,
+		MapExtra:   16,
+	})
+	if err == nil {
+		t.Fatal("Bloom filter with 16 hash functions doesn't return an error")
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       BloomFilter,
+		ValueSize:  4,
+		MaxEntries: 16,
+		MapExtra:   3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, v := range []uint32{1, 2, 3} {
+		if err := m.Push(v, UpdateAny); err != nil {
+			t.Fatalf("Can't push %d: %s", v, err)
+		}
+	}
+
+	for _, v := range []uint32{1, 2, 3} {
+		ok, err := m.Contains(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Errorf("Bloom filter doesn't contain %d", v)
+		}
+	}
+
+	if err := m.Peek(new(uint32)); err == nil {
+		t.Error("Peek on a bloom filter doesn't return an error")
+	}
+}
+
 func TestMapInMap(t *testing.T) {
 	for _, typ := range []MapType{ArrayOfMaps, HashOfMaps} {
 		t.Run(typ.String(), func(t *testing.T) {
@@ -1611,6 +1672,86 @@ func TestMapFreeze(t *testing.T) {
 	if err := arr.Put(uint32(0), uint32(1)); err == nil {
 		t.Error("Freeze doesn't prevent modification from user space")
 	}
+
+	frozen, err := arr.IsFrozen()
+	if err != nil {
+		t.Fatal("Can't get frozen state:", err)
+	}
+	if !frozen {
+		t.Error("IsFrozen returns false after Freeze")
+	}
+}
+
+func TestMapAccessFlags(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.2", "read-only maps")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		Flags:      MapReadOnly,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Put(uint32(0), uint32(1)); err == nil {
+		t.Error("MapReadOnly doesn't prevent modification from user space")
+	}
+	if frozen, err := m.IsFrozen(); err != nil || !frozen {
+		t.Errorf("IsFrozen of read-only map returns %v, %v", frozen, err)
+	}
+
+	_, err = NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		Flags:      MapReadOnly,
+		Contents:   []MapKV{{uint32(0), uint32(1)}},
+	})
+	if err == nil {
+		t.Error("Populating a read-only map doesn't return an error")
+	}
+
+	m, err = NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		Flags:      MapReadOnlyProg,
+		Contents:   []MapKV{{uint32(0), uint32(42)}},
+		Freeze:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var v uint32
+	if err := m.Lookup(uint32(0), &v); err != nil || v != 42 {
+		t.Errorf("Lookup of frozen map returns %d, %v", v, err)
+	}
+	if frozen, err := m.IsFrozen(); err != nil || !frozen {
+		t.Errorf("IsFrozen of frozen map returns %v, %v", frozen, err)
+	}
+
+	m, err = NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if frozen, err := m.IsFrozen(); err != nil || frozen {
+		t.Errorf("IsFrozen of writable map returns %v, %v", frozen, err)
+	}
 }
 
 func TestMapGetNextID(t *testing.T) {
@@ -1644,6 +1785,39 @@ func TestMapGetNextID(t *testing.T) {
 	}
 }
 
+func TestLoadedMapIterator(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.13", "bpf_map_get_next_id")
+
+	hash := newHash(t)
+	info, err := hash.Info()
+	if err != nil {
+		t.Fatal("Can't get map info:", err)
+	}
+	want, _ := info.ID()
+
+	var found *Map
+	it := new(LoadedMapIterator)
+	for it.Next() {
+		if it.ID == want {
+			found = it.Take()
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal("Iteration failed:", err)
+	}
+	if it.Map != nil {
+		t.Error("Map isn't reset after iteration")
+	}
+	if found == nil {
+		t.Fatalf("Map %d not found", want)
+	}
+	defer found.Close()
+
+	if found.Type() != Hash {
+		t.Error("Expected Hash, got", found.Type())
+	}
+}
+
 func TestNewMapFromID(t *testing.T) {
 	hash := newHash(t)
 
@@ -2152,3 +2326,248 @@ func ExampleMap_Iterate_nestedMapsAndProgramArrays() {
 		panic(fmt.Sprint("Iterator encountered an error:", err))
 	}
 }
+
+func TestMapSpecCompatibility(t *testing.T) {
+	spec := &MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+		Key:        &btf.Int{Name: "u32", Size: 4},
+		Value:      &btf.Typedef{Name: "value_t", Type: &btf.Int{Name: "u32", Size: 4}},
+	}
+
+	m, err := NewMap(spec)
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	for _, compat := range []MapCompatibility{MapCompatibilityLenient, MapCompatibilityDefault, MapCompatibilityStrict} {
+		qt.Assert(t, spec.compatible(m, compat), qt.IsNil)
+	}
+
+	other := spec.Copy()
+	other.MaxEntries = 3
+	other.Flags = unix.BPF_F_NO_PREALLOC
+	qt.Assert(t, other.compatible(m, MapCompatibilityLenient), qt.IsNil)
+
+	err = other.compatible(m, MapCompatibilityDefault)
+	qt.Assert(t, errors.Is(err, ErrMapIncompatible), qt.IsTrue)
+
+	var mie *MapIncompatibleError
+	qt.Assert(t, errors.As(err, &mie), qt.IsTrue)
+	qt.Assert(t, mie.Diffs, qt.HasLen, 2)
+	qt.Assert(t, mie.Diffs[0], qt.DeepEquals, MapDiff{"max entries", uint32(3), uint32(2)})
+	qt.Assert(t, err.Error(), qt.Contains, "\n-max entries: 3\n+max entries: 2")
+
+	other = spec.Copy()
+	other.Value = &btf.Typedef{Name: "other_t", Type: &btf.Int{Name: "u32", Size: 4}}
+	qt.Assert(t, other.compatible(m, MapCompatibilityDefault), qt.IsNil)
+
+	err = other.compatible(m, MapCompatibilityStrict)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, errors.As(err, &mie), qt.IsTrue)
+	qt.Assert(t, mie.Diffs, qt.HasLen, 1)
+	qt.Assert(t, mie.Diffs[0].Field, qt.Equals, "value BTF")
+}
+
+func TestBTFTypesEqual(t *testing.T) {
+	newList := func(name string) btf.Type {
+		s := &btf.Struct{Name: name, Size: 16}
+		s.Members = []btf.Member{
+			{Name: "value", Type: &btf.Int{Name: "u64", Size: 8}},
+			{Name: "next", Type: &btf.Pointer{Target: s}, Offset: 64},
+		}
+		return s
+	}
+
+	qt.Assert(t, btfTypesEqual(newList("list"), newList("list")), qt.IsTrue)
+	qt.Assert(t, btfTypesEqual(newList("list"), newList("other")), qt.IsFalse)
+	qt.Assert(t, btfTypesEqual(&btf.Int{Size: 4}, &btf.Int{Size: 8}), qt.IsFalse)
+	qt.Assert(t, btfTypesEqual(&btf.Int{Size: 4}, nil), qt.IsFalse)
+	qt.Assert(t, btfTypesEqual(&btf.Const{Type: &btf.Int{Size: 4}}, &btf.Volatile{Type: &btf.Int{Size: 4}}), qt.IsFalse)
+}
+
+func TestMapResize(t *testing.T) {
+	for _, typ := range []MapType{Hash, Array, PerCPUArray} {
+		t.Run(typ.String(), func(t *testing.T) {
+			m, err := NewMap(&MapSpec{
+				Type:       typ,
+				KeySize:    4,
+				ValueSize:  4,
+				MaxEntries: 4,
+			})
+			qt.Assert(t, err, qt.IsNil)
+			defer m.Close()
+
+			for i := uint32(0); i < 4; i++ {
+				var value interface{} = i + 100
+				if typ == PerCPUArray {
+					value = makePerCPU(i + 100)
+				}
+				qt.Assert(t, m.Put(i, value), qt.IsNil)
+			}
+
+			for _, copyEntries := range []func(*Map, *Map) error{
+				(*Map).batchCopyEntries,
+				(*Map).iterCopyEntries,
+			} {
+				grown, err := NewMap(&MapSpec{Type: typ, KeySize: 4, ValueSize: 4, MaxEntries: 8})
+				qt.Assert(t, err, qt.IsNil)
+
+				err = copyEntries(m, grown)
+				if errors.Is(err, ErrNotSupported) {
+					grown.Close()
+					continue
+				}
+				qt.Assert(t, err, qt.IsNil)
+				checkResized(t, grown, 4)
+				grown.Close()
+			}
+
+			grown, err := m.Resize(8, nil)
+			qt.Assert(t, err, qt.IsNil)
+			defer grown.Close()
+			qt.Assert(t, grown.MaxEntries(), qt.Equals, uint32(8))
+			checkResized(t, grown, 4)
+			qt.Assert(t, grown.Put(uint32(7), makeValue(typ, 0)), qt.IsNil)
+
+			shrunk, err := m.Resize(2, nil)
+			if typ == Hash {
+				qt.Assert(t, err, qt.IsNotNil, qt.Commentf("entries of hash map don't fit"))
+				return
+			}
+			qt.Assert(t, err, qt.IsNil)
+			defer shrunk.Close()
+			checkResized(t, shrunk, 2)
+		})
+	}
+}
+
+func TestMapResizeNumaNode(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		Flags:      unix.BPF_F_NUMA_NODE,
+		NumaNode:   0,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+	m.numaNode = 1
+
+	// Resizing must request the NUMA node of m, which doesn't exist on most
+	// machines.
+	_, err = m.Resize(2, nil)
+	if err == nil {
+		t.Skip("Machine has more than one NUMA node")
+	}
+	qt.Assert(t, err, qt.ErrorIs, unix.EINVAL)
+}
+
+func makeValue(typ MapType, v uint32) interface{} {
+	if typ.hasPerCPUValue() {
+		return makePerCPU(v)
+	}
+	return v
+}
+
+func makePerCPU(v uint32) []uint32 {
+	numCPU, err := internal.PossibleCPUs()
+	if err != nil {
+		panic(err)
+	}
+
+	values := make([]uint32, numCPU)
+	for i := range values {
+		values[i] = v
+	}
+	return values
+}
+
+func checkResized(t *testing.T, m *Map, n uint32) {
+	t.Helper()
+
+	for i := uint32(0); i < n; i++ {
+		if m.Type().hasPerCPUValue() {
+			var values []uint32
+			qt.Assert(t, m.Lookup(i, &values), qt.IsNil)
+			qt.Assert(t, values[0], qt.Equals, i+100)
+			continue
+		}
+
+		var value uint32
+		qt.Assert(t, m.Lookup(i, &value), qt.IsNil)
+		qt.Assert(t, value, qt.Equals, i+100)
+	}
+}
+
+func TestMapResizeReferences(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.10", "BPF_F_INNER_MAP")
+
+	innerSpec := &MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+		Flags:      unix.BPF_F_INNER_MAP,
+	}
+
+	outer, err := NewMap(&MapSpec{
+		Type:       ArrayOfMaps,
+		KeySize:    4,
+		MaxEntries: 2,
+		InnerMap:   innerSpec,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer outer.Close()
+
+	inner, err := NewMap(innerSpec)
+	qt.Assert(t, err, qt.IsNil)
+	defer inner.Close()
+
+	qt.Assert(t, outer.Put(uint32(0), inner), qt.IsNil)
+	qt.Assert(t, outer.Put(uint32(1), inner), qt.IsNil)
+
+	resized, err := inner.Resize(4, &ResizeOptions{
+		References: []MapReference{{outer, uint32(0)}, {outer, uint32(1)}},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer resized.Close()
+
+	info, err := resized.Info()
+	qt.Assert(t, err, qt.IsNil)
+	want, _ := info.ID()
+
+	for i := uint32(0); i < 2; i++ {
+		var id MapID
+		qt.Assert(t, outer.Lookup(i, &id), qt.IsNil)
+		qt.Assert(t, id, qt.Equals, want)
+	}
+
+	// Replacing a reference fails since slot 2 doesn't exist. The first
+	// reference must be reverted.
+	_, err = resized.Resize(8, &ResizeOptions{
+		References: []MapReference{{outer, uint32(0)}, {outer, uint32(2)}},
+	})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	var id MapID
+	qt.Assert(t, outer.Lookup(uint32(0), &id), qt.IsNil)
+	qt.Assert(t, id, qt.Equals, want)
+}
+
+func TestMapResizeUnsupported(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer m.Close()
+
+	_, err = m.Resize(2, nil)
+	qt.Assert(t, errors.Is(err, ErrNotSupported), qt.IsTrue)
+}
@@ -0,0 +1,125 @@
+package ebpf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+// MapChange is a change of the value of a key, see Map.Watch.
+type MapChange struct {
+	Key []byte
+	// The value before and after the change, in the format of
+	// Map.LookupBytes. Old is nil if the key was created, New is nil if the
+	// key was deleted.
+	Old, New []byte
+}
+
+// WatchOptions control the behaviour of Map.Watch.
+type WatchOptions struct {
+	// The keys to watch. All keys of the map are watched if empty.
+	Keys []interface{}
+	// The time between two reads of the map. Defaults to one second.
+	Interval time.Duration
+}
+
+// Watch calls fn for every change of the watched keys until ctx is cancelled
+// or fn returns an error.
+//
+// The kernel doesn't notify user space of updates by BPF programs, so the map
+// is read every WatchOptions.Interval. A key which changes more than once
+// between two reads is reported once, a key which is changed and changed
+// back isn't reported at all. Changes which exist when Watch is called aren't
+// reported.
+//
+// Returns ctx.Err() if ctx was cancelled, or the error returned by fn.
+func (m *Map) Watch(ctx context.Context, opts WatchOptions, fn func(MapChange) error) error {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+
+	keys := make([][]byte, 0, len(opts.Keys))
+	for _, key := range opts.Keys {
+		keyBytes, err := marshalBytes(key, int(m.keySize))
+		if err != nil {
+			return fmt.Errorf("can't marshal key: %w", err)
+		}
+		keys = append(keys, keyBytes)
+	}
+
+	prev, err := m.snapshot(keys)
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		next, err := m.snapshot(keys)
+		if err != nil {
+			return fmt.Errorf("watch: %w", err)
+		}
+
+		for key, value := range next {
+			if old, ok := prev[key]; !ok || !bytes.Equal(old, value) {
+				if err := fn(MapChange{[]byte(key), prev[key], value}); err != nil {
+					return err
+				}
+			}
+		}
+
+		for key, value := range prev {
+			if _, ok := next[key]; !ok {
+				if err := fn(MapChange{[]byte(key), value, nil}); err != nil {
+					return err
+				}
+			}
+		}
+
+		prev = next
+	}
+}
+
+// snapshot returns the values of keys, or of all keys in the map if keys
+// is empty. Keys which don't exist are omitted.
+func (m *Map) snapshot(keys [][]byte) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+
+	if len(keys) > 0 {
+		for _, key := range keys {
+			value, err := m.LookupBytes(key)
+			if err != nil {
+				return nil, err
+			}
+			if value != nil {
+				values[string(key)] = value
+			}
+		}
+		return values, nil
+	}
+
+	var (
+		key   []byte
+		value = make([]byte, m.fullValueSize)
+		it    = m.Iterate()
+	)
+	// Reading into an unsafe.Pointer skips decoding the value of per-CPU maps.
+	for it.Next(&key, unsafe.Pointer(&value[0])) {
+		values[string(key)] = value
+		value = make([]byte, m.fullValueSize)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
@@ -0,0 +1,91 @@
+package ebpf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMapWatch(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	qt.Assert(t, m.Put(uint32(1), uint32(1)), qt.IsNil)
+
+	for _, keys := range [][]interface{}{nil, {uint32(1), uint32(2)}} {
+		ctx, cancel := context.WithCancel(context.Background())
+		changes := make(chan MapChange)
+		errs := make(chan error, 1)
+		go func() {
+			errs <- m.Watch(ctx, WatchOptions{Keys: keys, Interval: time.Millisecond}, func(c MapChange) error {
+				changes <- c
+				return nil
+			})
+		}()
+
+		// Wait for the initial snapshot.
+		time.Sleep(10 * time.Millisecond)
+
+		qt.Assert(t, m.Put(uint32(2), uint32(2)), qt.IsNil)
+		c := <-changes
+		qt.Assert(t, c.Key, qt.DeepEquals, u32(2))
+		qt.Assert(t, c.Old, qt.IsNil)
+		qt.Assert(t, c.New, qt.DeepEquals, u32(2))
+
+		qt.Assert(t, m.Put(uint32(2), uint32(3)), qt.IsNil)
+		c = <-changes
+		qt.Assert(t, c.Old, qt.DeepEquals, u32(2))
+		qt.Assert(t, c.New, qt.DeepEquals, u32(3))
+
+		qt.Assert(t, m.Delete(uint32(2)), qt.IsNil)
+		c = <-changes
+		qt.Assert(t, c.Old, qt.DeepEquals, u32(3))
+		qt.Assert(t, c.New, qt.IsNil)
+
+		cancel()
+		qt.Assert(t, errors.Is(<-errs, context.Canceled), qt.IsTrue)
+	}
+}
+
+func TestMapWatchError(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	errStop := errors.New("stop")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Put(uint32(0), uint32(1))
+	}()
+
+	err = m.Watch(context.Background(), WatchOptions{Interval: time.Millisecond}, func(MapChange) error {
+		return errStop
+	})
+	qt.Assert(t, err, qt.Equals, errStop)
+}
+
+func u32(v uint32) []byte {
+	buf := make([]byte, 4)
+	internal.NativeEndian.PutUint32(buf, v)
+	return buf
+}
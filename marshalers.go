@@ -172,20 +172,30 @@ func unmarshalBytes(data interface{}, buf []byte) error {
 //
 // slice must have a type like []elementType.
 func marshalPerCPUValue(slice interface{}, elemLength int) (sys.Pointer, error) {
+	buf, err := marshalPerCPUBytes(slice, elemLength)
+	if err != nil {
+		return sys.Pointer{}, err
+	}
+
+	return sys.NewSlicePointer(buf), nil
+}
+
+// marshalPerCPUBytes is like marshalPerCPUValue but returns the buffer.
+func marshalPerCPUBytes(slice interface{}, elemLength int) ([]byte, error) {
 	sliceType := reflect.TypeOf(slice)
 	if sliceType.Kind() != reflect.Slice {
-		return sys.Pointer{}, errors.New("per-CPU value requires slice")
+		return nil, errors.New("per-CPU value requires slice")
 	}
 
 	possibleCPUs, err := internal.PossibleCPUs()
 	if err != nil {
-		return sys.Pointer{}, err
+		return nil, err
 	}
 
 	sliceValue := reflect.ValueOf(slice)
 	sliceLen := sliceValue.Len()
 	if sliceLen > possibleCPUs {
-		return sys.Pointer{}, fmt.Errorf("per-CPU value exceeds number of CPUs")
+		return nil, fmt.Errorf("per-CPU value exceeds number of CPUs")
 	}
 
 	alignedElemLength := internal.Align(elemLength, 8)
@@ -195,14 +205,14 @@ func marshalPerCPUValue(slice interface{}, elemLength int) (sys.Pointer, error)
 		elem := sliceValue.Index(i).Interface()
 		elemBytes, err := marshalBytes(elem, elemLength)
 		if err != nil {
-			return sys.Pointer{}, err
+			return nil, err
 		}
 
 		offset := i * alignedElemLength
 		copy(buf[offset:offset+elemLength], elemBytes)
 	}
 
-	return sys.NewSlicePointer(buf), nil
+	return buf, nil
 }
 
 // unmarshalPerCPUValue decodes a buffer into a slice containing one value per
@@ -0,0 +1,143 @@
+package memwatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mapping is a memory mapping of a process.
+type Mapping struct {
+	Start, End uint64
+	// The offset of Start in the mapped file.
+	Offset uint64
+	// True if the mapping contains code.
+	Exec bool
+	// The mapped file, or a pseudo path like "[stack]". Empty for anonymous
+	// mappings.
+	Path string
+}
+
+// Maps tracks the memory mappings of a process.
+//
+// The zero value is ready to use. Maps is not safe for concurrent use.
+type Maps struct {
+	// Sorted by Start and not overlapping.
+	mappings []Mapping
+}
+
+// ReadMaps reads the current mappings of pid from /proc/<pid>/maps.
+func ReadMaps(pid int) (*Maps, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/maps", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	maps, err := parseMaps(f)
+	if err != nil {
+		return nil, fmt.Errorf("read maps of pid %d: %w", pid, err)
+	}
+	return maps, nil
+}
+
+// parseMaps parses the format of /proc/<pid>/maps:
+//
+//	address           perms offset  dev   inode   pathname
+//	00400000-00452000 r-xp 00000000 08:02 173521  /usr/bin/dbus-daemon
+func parseMaps(r io.Reader) (*Maps, error) {
+	var maps Maps
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 6)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("invalid line %q", scanner.Text())
+		}
+
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid address range %q", fields[0])
+		}
+
+		var (
+			mapping Mapping
+			err     error
+		)
+		if mapping.Start, err = strconv.ParseUint(start, 16, 64); err != nil {
+			return nil, fmt.Errorf("invalid start address: %w", err)
+		}
+		if mapping.End, err = strconv.ParseUint(end, 16, 64); err != nil {
+			return nil, fmt.Errorf("invalid end address: %w", err)
+		}
+		if mapping.Offset, err = strconv.ParseUint(fields[2], 16, 64); err != nil {
+			return nil, fmt.Errorf("invalid offset: %w", err)
+		}
+		mapping.Exec = strings.Contains(fields[1], "x")
+		if len(fields) == 6 {
+			// The path is padded with spaces to align the columns.
+			mapping.Path = strings.TrimLeft(fields[5], " ")
+		}
+
+		maps.Add(mapping)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &maps, nil
+}
+
+// Add records a new mapping, which replaces the overlapping parts of
+// existing mappings.
+func (m *Maps) Add(mapping Mapping) {
+	if mapping.End <= mapping.Start {
+		return
+	}
+
+	mappings := make([]Mapping, 0, len(m.mappings)+1)
+	for _, old := range m.mappings {
+		if old.End <= mapping.Start || old.Start >= mapping.End {
+			mappings = append(mappings, old)
+			continue
+		}
+
+		if old.Start < mapping.Start {
+			head := old
+			head.End = mapping.Start
+			mappings = append(mappings, head)
+		}
+		if old.End > mapping.End {
+			tail := old
+			tail.Offset += mapping.End - old.Start
+			tail.Start = mapping.End
+			mappings = append(mappings, tail)
+		}
+	}
+
+	mappings = append(mappings, mapping)
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].Start < mappings[j].Start
+	})
+	m.mappings = mappings
+}
+
+// Find returns the mapping containing addr.
+func (m *Maps) Find(addr uint64) (Mapping, bool) {
+	i := sort.Search(len(m.mappings), func(i int) bool {
+		return m.mappings[i].End > addr
+	})
+	if i == len(m.mappings) || m.mappings[i].Start > addr {
+		return Mapping{}, false
+	}
+	return m.mappings[i], true
+}
+
+// Mappings returns all mappings ordered by address.
+func (m *Maps) Mappings() []Mapping {
+	return append([]Mapping(nil), m.mappings...)
+}
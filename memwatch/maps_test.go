@@ -0,0 +1,72 @@
+package memwatch
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestParseMaps(t *testing.T) {
+	maps, err := parseMaps(strings.NewReader(`00400000-00452000 r-xp 00001000 08:02 173521      /usr/bin/dbus-daemon
+00651000-00652000 rw-p 00051000 08:02 173521      /usr/bin/dbus-daemon
+00e03000-00e24000 rw-p 00000000 00:00 0           [heap]
+7f0000000000-7f0000001000 rw-p 00000000 00:00 0
+`))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, maps.Mappings(), qt.DeepEquals, []Mapping{
+		{0x400000, 0x452000, 0x1000, true, "/usr/bin/dbus-daemon"},
+		{0x651000, 0x652000, 0x51000, false, "/usr/bin/dbus-daemon"},
+		{0xe03000, 0xe24000, 0, false, "[heap]"},
+		{0x7f0000000000, 0x7f0000001000, 0, false, ""},
+	})
+
+	_, err = parseMaps(strings.NewReader("invalid\n"))
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestReadMaps(t *testing.T) {
+	maps, err := ReadMaps(os.Getpid())
+	qt.Assert(t, err, qt.IsNil)
+
+	exe, err := os.Executable()
+	qt.Assert(t, err, qt.IsNil)
+
+	var found bool
+	for _, mapping := range maps.Mappings() {
+		if mapping.Exec && mapping.Path == exe {
+			found = true
+		}
+	}
+	qt.Assert(t, found, qt.IsTrue, qt.Commentf("no executable mapping of %s", exe))
+}
+
+func TestMapsAdd(t *testing.T) {
+	var maps Maps
+	maps.Add(Mapping{Start: 0x1000, End: 0x5000, Offset: 0x1000, Path: "a"})
+	maps.Add(Mapping{Start: 0x2000, End: 0x3000, Exec: true, Path: "b"})
+	maps.Add(Mapping{Start: 0x4000, End: 0x6000, Path: "c"})
+	maps.Add(Mapping{Start: 0x6000, End: 0x6000, Path: "empty"})
+
+	qt.Assert(t, maps.Mappings(), qt.DeepEquals, []Mapping{
+		{0x1000, 0x2000, 0x1000, false, "a"},
+		{0x2000, 0x3000, 0, true, "b"},
+		{0x3000, 0x4000, 0x3000, false, "a"},
+		{0x4000, 0x6000, 0, false, "c"},
+	})
+
+	mapping, ok := maps.Find(0x3fff)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, mapping.Path, qt.Equals, "a")
+
+	mapping, ok = maps.Find(0x2000)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, mapping.Path, qt.Equals, "b")
+
+	_, ok = maps.Find(0xfff)
+	qt.Assert(t, ok, qt.IsFalse)
+
+	_, ok = maps.Find(0x6000)
+	qt.Assert(t, ok, qt.IsFalse)
+}
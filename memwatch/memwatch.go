@@ -0,0 +1,415 @@
+// Package memwatch finds the code which accesses a range of memory of
+// another process, for example to track down memory corruption.
+//
+// A hardware watchpoint is installed on every thread of the process. Each
+// hit captures the registers and a copy of the stack, which are resolved
+// against the memory mappings of the process at the time of the hit.
+package memwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/perf"
+	"golang.org/x/sys/unix"
+)
+
+// Access is the type of memory access which triggers a watchpoint, see
+// HW_BREAKPOINT_* in include/uapi/linux/hw_breakpoint.h.
+type Access uint32
+
+const (
+	// Write triggers on writes.
+	Write Access = 2
+	// ReadWrite triggers on reads and writes.
+	ReadWrite Access = 3
+)
+
+// Options control the behaviour of Watch.
+type Options struct {
+	// The type of access to watch for. Defaults to Write.
+	Access Access
+	// Regs selects the registers captured on each hit, as a mask of
+	// architecture specific PERF_REG_* bits. Defaults to the general
+	// purpose registers on amd64 and arm64. Other architectures have no
+	// default, and capture neither registers nor the stack unless Regs is
+	// set.
+	Regs uint64
+	// The number of bytes of the stack copied on each hit, rounded up to a
+	// multiple of 8. Defaults to 4096.
+	StackSize uint32
+	// NoUnwind disables capturing registers and the stack, which limits
+	// the frames of each hit to the accessing instruction.
+	NoUnwind bool
+	// The maximum number of frames of each hit. Defaults to 16.
+	MaxFrames int
+	// ExcludeKernel ignores accesses by the kernel, for example while
+	// copying the result of a system call. Required without CAP_PERFMON if
+	// perf_event_paranoid is 2 or higher.
+	ExcludeKernel bool
+	// The size of the ring buffer of each CPU in bytes. Defaults to 64
+	// pages.
+	PerCPUBuffer int
+}
+
+// Watcher records accesses to a range of memory of a process.
+type Watcher struct {
+	pid         int
+	addr, size  uint64
+	opts        Options
+	rd          *perf.Reader
+	cpus        []int
+	watchpoints []unix.PerfEventAttr
+	// The names of the registers in Options.Regs.
+	regs []string
+
+	// The thread whose events back the rings of rd.
+	leader int
+
+	mu       sync.Mutex
+	attached map[int]bool
+	maps     *Maps
+	symbols  *symbolizer
+	hits     []Hit
+	lost     uint64
+	// The index of the most recent hit of each thread.
+	lastHit map[uint32]int
+}
+
+// Watch installs watchpoints on [addr, addr+size) in all threads of pid.
+// Threads and processes created after Watch returns inherit the
+// watchpoints, only accesses by pid are reported.
+//
+// Watchpoints cover at most 8 aligned bytes, and most CPUs only support
+// four of them per thread. Watching more than 32 bytes usually fails.
+//
+// Hits are collected by Watcher.Run.
+func Watch(pid int, addr, size uint64, opts Options) (*Watcher, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid pid %d", pid)
+	}
+	if size == 0 {
+		return nil, errors.New("size must be larger than 0")
+	}
+	if addr+size < addr {
+		return nil, fmt.Errorf("address range %#x+%d overflows", addr, size)
+	}
+
+	if opts.Access == 0 {
+		opts.Access = Write
+	}
+	if opts.NoUnwind {
+		opts.Regs = 0
+	} else if opts.Regs == 0 {
+		opts.Regs = defaultRegs
+	}
+	if opts.StackSize == 0 {
+		opts.StackSize = 4096
+	}
+	opts.StackSize = uint32(internal.Align(int(opts.StackSize), 8))
+	if opts.MaxFrames <= 0 {
+		opts.MaxFrames = 16
+	}
+	if opts.PerCPUBuffer == 0 {
+		opts.PerCPUBuffer = 64 * os.Getpagesize()
+	}
+
+	maps, err := ReadMaps(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	threads, err := listThreads(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	eopts := perf.ExtraPerfOptions{
+		Pid:        threads[0],
+		Inherit:    true,
+		SampleTime: true,
+		SampleTID:  true,
+		SampleIP:   true,
+		SampleID:   true,
+	}
+	if opts.Regs != 0 {
+		eopts.UnwindStack = true
+		eopts.Sample_regs_user = opts.Regs
+		eopts.Sample_stack_user = opts.StackSize
+	}
+
+	// The rings are backed by dummy events of the first thread, which
+	// also report its mappings. The watchpoints are added to them.
+	rd, err := perf.NewSidebandReader(opts.PerCPUBuffer, perf.ReaderOptions{WakeupEvents: 1}, eopts)
+	if err != nil {
+		return nil, fmt.Errorf("create reader: %w", err)
+	}
+
+	w := &Watcher{
+		pid:      pid,
+		addr:     addr,
+		size:     size,
+		opts:     opts,
+		rd:       rd,
+		leader:   threads[0],
+		attached: make(map[int]bool),
+		lastHit:  make(map[uint32]int),
+		maps:     maps,
+		symbols:  newSymbolizer(pid),
+	}
+
+	for bit := 0; bit < 64; bit++ {
+		if opts.Regs&(1<<bit) != 0 {
+			w.regs = append(w.regs, regName(bit))
+		}
+	}
+
+	nCPU, err := internal.PossibleCPUs()
+	if err != nil {
+		rd.Close()
+		return nil, err
+	}
+	for cpu := 0; cpu < nCPU; cpu++ {
+		if _, err := rd.RingMeta(cpu); err != nil {
+			// The CPU is offline.
+			continue
+		}
+		w.cpus = append(w.cpus, cpu)
+	}
+
+	for _, c := range splitRange(addr, size) {
+		attr := unix.PerfEventAttr{
+			Type:    unix.PERF_TYPE_BREAKPOINT,
+			Sample:  1,
+			Bp_type: uint32(opts.Access),
+			Ext1:    c.addr,
+			Ext2:    c.len,
+			Bits:    unix.PerfBitInherit,
+		}
+		if opts.ExcludeKernel {
+			attr.Bits |= unix.PerfBitExcludeKernel | unix.PerfBitExcludeHv
+		}
+		w.watchpoints = append(w.watchpoints, attr)
+	}
+
+	// Threads which are created while attaching don't inherit the
+	// watchpoints if their parent wasn't attached yet. Repeat until no new
+	// threads show up. Hits of threads which were attached twice are
+	// reported once.
+	for {
+		var attached bool
+		for _, tid := range threads {
+			if w.attached[tid] {
+				continue
+			}
+
+			if err := w.attach(tid); err != nil {
+				rd.Close()
+				return nil, err
+			}
+			w.attached[tid] = true
+			attached = true
+		}
+
+		if !attached {
+			break
+		}
+
+		threads, err = listThreads(pid)
+		if err != nil {
+			rd.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// attach adds watchpoints and sideband events for tid to the reader.
+func (w *Watcher) attach(tid int) error {
+	attrs := w.watchpoints
+	if tid != w.leader {
+		// The mappings of the leader are reported by the events backing
+		// the rings.
+		attrs = append(attrs[:len(attrs):len(attrs)], unix.PerfEventAttr{
+			Type:   unix.PERF_TYPE_SOFTWARE,
+			Config: unix.PERF_COUNT_SW_DUMMY,
+			Bits:   unix.PerfBitMmap | unix.PerfBitMmap2 | unix.PerfBitMmapData | unix.PerfBitInherit,
+		})
+	}
+
+	for _, cpu := range w.cpus {
+		for _, attr := range attrs {
+			_, err := w.rd.AddEvent(attr, tid, cpu)
+			if errors.Is(err, unix.ESRCH) {
+				// The thread exited.
+				return nil
+			}
+			if err != nil && attr.Type == unix.PERF_TYPE_BREAKPOINT {
+				return fmt.Errorf("install watchpoint at %#x in thread %d: %w", attr.Ext1, tid, err)
+			}
+			if err != nil {
+				return fmt.Errorf("track mappings of thread %d: %w", tid, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Run collects hits until ctx is cancelled or the Watcher is closed.
+//
+// Returns nil if the Watcher was closed, or ctx.Err().
+func (w *Watcher) Run(ctx context.Context) error {
+	return w.rd.RunOrdered(ctx, func(rec perf.Record) error {
+		w.handle(&rec)
+		return nil
+	}, nil)
+}
+
+// Report returns the hits collected so far.
+func (w *Watcher) Report() *Report {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return &Report{
+		Pid:  w.pid,
+		Addr: w.addr,
+		Size: w.size,
+		Hits: append([]Hit(nil), w.hits...),
+		Lost: w.lost,
+	}
+}
+
+// Close removes the watchpoints and stops Run.
+func (w *Watcher) Close() error {
+	return w.rd.Close()
+}
+
+func (w *Watcher) handle(rec *perf.Record) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if rec.LostSamples > 0 {
+		w.lost += rec.LostSamples
+		return
+	}
+
+	if mmap, ok := rec.Mmap(); ok {
+		if int(mmap.Pid) != w.pid {
+			return
+		}
+
+		mapping := Mapping{
+			Start:  mmap.Addr,
+			End:    mmap.Addr + mmap.Len,
+			Offset: mmap.Pgoff,
+			Exec:   mmap.Prot&unix.PROT_EXEC != 0,
+			Path:   mmap.Filename,
+		}
+		if mapping.Path == "//anon" {
+			mapping.Path = ""
+		}
+		w.maps.Add(mapping)
+		return
+	}
+
+	if rec.RecordType != unix.PERF_RECORD_SAMPLE || int(rec.Pid) != w.pid {
+		return
+	}
+
+	// Added events share the sample type of the rings, which doesn't
+	// include the address.
+	attr, ok := w.rd.EventAttr(rec.ID)
+	if !ok || attr.Type != unix.PERF_TYPE_BREAKPOINT {
+		return
+	}
+
+	hit := Hit{
+		Time: rec.Time,
+		Tid:  rec.Tid,
+		Addr: attr.Ext1,
+	}
+
+	for i, value := range rec.RegsUser {
+		if i < len(w.regs) {
+			hit.Regs = append(hit.Regs, Register{w.regs[i], value})
+		}
+	}
+
+	// A thread which was attached explicitly and also inherited watchpoints
+	// from its parent reports each access twice, with slightly different
+	// timestamps.
+	if i, ok := w.lastHit[hit.Tid]; ok && w.hits[i].duplicate(&hit, rec.IP) {
+		return
+	}
+
+	hit.Frames = append(hit.Frames, w.symbols.frame(w.maps, rec.IP))
+
+	// Without unwind information, return addresses are guessed by scanning
+	// the stack for pointers into code.
+	for off := 0; off+8 <= len(rec.StackUser) && len(hit.Frames) < w.opts.MaxFrames; off += 8 {
+		addr := internal.NativeEndian.Uint64(rec.StackUser[off:])
+		if mapping, ok := w.maps.Find(addr); ok && mapping.Exec {
+			hit.Frames = append(hit.Frames, w.symbols.frame(w.maps, addr))
+		}
+	}
+
+	w.lastHit[hit.Tid] = len(w.hits)
+	w.hits = append(w.hits, hit)
+}
+
+func regName(bit int) string {
+	if bit < len(regNames) {
+		return regNames[bit]
+	}
+	return "r" + strconv.Itoa(bit)
+}
+
+// listThreads returns the threads of pid in ascending order.
+func listThreads(pid int) ([]int, error) {
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/task", pid))
+	if err != nil {
+		return nil, fmt.Errorf("list threads: %w", err)
+	}
+
+	var tids []int
+	for _, entry := range entries {
+		tid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		tids = append(tids, tid)
+	}
+	if len(tids) == 0 {
+		return nil, fmt.Errorf("list threads: process %d has no threads", pid)
+	}
+
+	sort.Ints(tids)
+	return tids, nil
+}
+
+type chunk struct {
+	addr, len uint64
+}
+
+// splitRange splits [addr, addr+size) into naturally aligned chunks of at
+// most 8 bytes, which can be covered by a single watchpoint.
+func splitRange(addr, size uint64) []chunk {
+	var chunks []chunk
+	for end := addr + size; addr < end; {
+		n := uint64(8)
+		for addr%n != 0 || addr+n > end {
+			n /= 2
+		}
+		chunks = append(chunks, chunk{addr, n})
+		addr += n
+	}
+	return chunks
+}
@@ -0,0 +1,88 @@
+package memwatch
+
+import (
+	"context"
+	"errors"
+	"math/bits"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"unsafe"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+var watched uint64
+
+//go:noinline
+func corrupt(value uint64) {
+	watched = value
+}
+
+func TestWatch(t *testing.T) {
+	addr := uint64(uintptr(unsafe.Pointer(&watched)))
+	w, err := Watch(os.Getpid(), addr, 8, Options{})
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENODEV) {
+		t.Skip("Hardware breakpoints are not available:", err)
+	}
+	qt.Assert(t, err, qt.IsNil)
+	defer w.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- w.Run(context.Background())
+	}()
+
+	corrupt(1)
+	corrupt(2)
+
+	// Run holds back records to order them.
+	deadline := time.Now().Add(time.Second)
+	for len(w.Report().Hits) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	qt.Assert(t, w.Close(), qt.IsNil)
+	qt.Assert(t, <-errs, qt.IsNil)
+
+	report := w.Report()
+	qt.Assert(t, report.Hits, qt.HasLen, 2)
+	qt.Assert(t, report.Hits[0].Time <= report.Hits[1].Time, qt.IsTrue)
+
+	for _, hit := range report.Hits {
+		qt.Assert(t, hit.Addr, qt.Equals, addr)
+		qt.Assert(t, hit.Tid, qt.Not(qt.Equals), uint32(0))
+		qt.Assert(t, hit.Regs, qt.HasLen, bits.OnesCount64(defaultRegs))
+		qt.Assert(t, hit.Frames[0].Symbol, qt.Equals, "github.com/cilium/ebpf/memwatch.corrupt")
+	}
+
+	var out strings.Builder
+	_, err = report.WriteTo(&out)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, out.String(), qt.Contains, "memwatch.corrupt+")
+}
+
+func TestWatchNoUnwind(t *testing.T) {
+	addr := uint64(uintptr(unsafe.Pointer(&watched)))
+	w, err := Watch(os.Getpid(), addr, 8, Options{Regs: defaultRegs | 1, NoUnwind: true})
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENODEV) {
+		t.Skip("Hardware breakpoints are not available:", err)
+	}
+	qt.Assert(t, err, qt.IsNil)
+	defer w.Close()
+
+	qt.Assert(t, w.opts.Regs, qt.Equals, uint64(0))
+	qt.Assert(t, w.regs, qt.HasLen, 0)
+}
+
+func TestSplitRange(t *testing.T) {
+	qt.Assert(t, splitRange(0x1003, 13), qt.CmpEquals(cmp.AllowUnexported(chunk{})), []chunk{
+		{0x1003, 1},
+		{0x1004, 4},
+		{0x1008, 8},
+	})
+	qt.Assert(t, splitRange(0x1000, 2), qt.CmpEquals(cmp.AllowUnexported(chunk{})), []chunk{{0x1000, 2}})
+}
@@ -0,0 +1,8 @@
+package memwatch
+
+// defaultRegs selects the general purpose registers up to IP, see
+// PERF_REG_X86_* in arch/x86/include/uapi/asm/perf_regs.h.
+const defaultRegs uint64 = 1<<9 - 1
+
+var regNames = []string{"ax", "bx", "cx", "dx", "si", "di", "bp", "sp", "ip", "flags",
+	"cs", "ss", "ds", "es", "fs", "gs", "r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15"}
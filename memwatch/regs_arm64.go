@@ -0,0 +1,9 @@
+package memwatch
+
+// defaultRegs selects x0 to x30, sp and pc, see PERF_REG_ARM64_* in
+// arch/arm64/include/uapi/asm/perf_regs.h.
+const defaultRegs uint64 = 1<<33 - 1
+
+var regNames = []string{"x0", "x1", "x2", "x3", "x4", "x5", "x6", "x7", "x8", "x9",
+	"x10", "x11", "x12", "x13", "x14", "x15", "x16", "x17", "x18", "x19", "x20",
+	"x21", "x22", "x23", "x24", "x25", "x26", "x27", "x28", "x29", "lr", "sp", "pc"}
@@ -0,0 +1,9 @@
+//go:build !amd64 && !arm64
+
+package memwatch
+
+// Registers aren't captured by default on this architecture, which also
+// disables capturing the stack. See Options.Regs.
+const defaultRegs uint64 = 0
+
+var regNames []string
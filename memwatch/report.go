@@ -0,0 +1,88 @@
+package memwatch
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Report lists the accesses to the watched memory in the order they
+// happened.
+type Report struct {
+	Pid        int
+	Addr, Size uint64
+	Hits       []Hit
+	// The number of hits which were lost because the ring buffer was full.
+	Lost uint64
+}
+
+// Hit is an access to the watched memory.
+type Hit struct {
+	// The time of the access in nanoseconds, see perf.Record.Time.
+	Time uint64
+	// The thread which accessed the memory.
+	Tid uint32
+	// The start of the watched chunk which was accessed. Watchpoints cover
+	// at most 8 aligned bytes, see Watch.
+	Addr uint64
+	// The user space registers selected by Options.Regs.
+	Regs []Register
+	// The instruction which accessed the memory, or the one following it on
+	// architectures like amd64, and guesses of the return addresses found
+	// on the stack. Innermost first.
+	Frames []Frame
+}
+
+// duplicate returns true if other is the same access as hit, taken at ip.
+func (hit *Hit) duplicate(other *Hit, ip uint64) bool {
+	if hit.Addr != other.Addr || hit.Frames[0].Addr != ip || len(hit.Regs) != len(other.Regs) {
+		return false
+	}
+	for i := range hit.Regs {
+		if hit.Regs[i] != other.Regs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Register is the value of a register at the time of an access.
+type Register struct {
+	Name  string
+	Value uint64
+}
+
+// WriteTo writes a human readable version of the report to w.
+func (r *Report) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pid %d: %d accesses to %#x-%#x", r.Pid, len(r.Hits), r.Addr, r.Addr+r.Size)
+	if r.Lost > 0 {
+		fmt.Fprintf(&b, ", %d lost", r.Lost)
+	}
+	b.WriteString("\n")
+
+	for _, hit := range r.Hits {
+		// Timestamps are relative to the first hit, since the clock of
+		// perf samples doesn't start at a known point in time.
+		elapsed := time.Duration(hit.Time - r.Hits[0].Time)
+		fmt.Fprintf(&b, "\n+%s thread %d accessed %#x\n", elapsed, hit.Tid, hit.Addr)
+
+		for i, frame := range hit.Frames {
+			fmt.Fprintf(&b, "  #%d %s\n", i, frame)
+		}
+
+		for i, reg := range hit.Regs {
+			if i%4 == 0 {
+				b.WriteString(" ")
+			}
+			fmt.Fprintf(&b, " %s=%#x", reg.Name, reg.Value)
+			if i%4 == 3 || i == len(hit.Regs)-1 {
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
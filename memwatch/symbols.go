@@ -0,0 +1,179 @@
+package memwatch
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// Frame is a code address resolved against the mappings of a process.
+type Frame struct {
+	Addr uint64
+	// The mapped file containing Addr and the offset of Addr in it. Empty
+	// if Addr isn't part of a file mapping.
+	Path   string
+	Offset uint64
+	// The function containing Addr and the offset of Addr in it. Empty if
+	// the file has no symbols.
+	Symbol       string
+	SymbolOffset uint64
+}
+
+func (f Frame) String() string {
+	switch {
+	case f.Symbol != "":
+		return fmt.Sprintf("%#x %s+%#x (%s+%#x)", f.Addr, f.Path, f.Offset, f.Symbol, f.SymbolOffset)
+	case f.Path != "":
+		return fmt.Sprintf("%#x %s+%#x", f.Addr, f.Path, f.Offset)
+	default:
+		return fmt.Sprintf("%#x", f.Addr)
+	}
+}
+
+// symbolizer resolves addresses to functions using the symbol tables of the
+// mapped files.
+type symbolizer struct {
+	// The root directory of the process, which may be in a different
+	// mount namespace.
+	root  string
+	files map[string]*symbolTable
+}
+
+type symbolTable struct {
+	loads []*elf.Prog
+	// Functions sorted by address.
+	funcs []elf.Symbol
+	// The line table of Go binaries without symbols.
+	gosym *gosym.Table
+}
+
+func newSymbolizer(pid int) *symbolizer {
+	return &symbolizer{
+		root:  fmt.Sprintf("/proc/%d/root", pid),
+		files: make(map[string]*symbolTable),
+	}
+}
+
+// frame resolves addr using maps.
+func (s *symbolizer) frame(maps *Maps, addr uint64) Frame {
+	frame := Frame{Addr: addr}
+
+	mapping, ok := maps.Find(addr)
+	if !ok || mapping.Path == "" || mapping.Path[0] == '[' {
+		return frame
+	}
+
+	frame.Path = mapping.Path
+	frame.Offset = addr - mapping.Start + mapping.Offset
+
+	table := s.table(mapping.Path)
+	if table == nil {
+		return frame
+	}
+
+	// Symbols are relative to the virtual addresses of the file.
+	for _, load := range table.loads {
+		if frame.Offset < load.Off || frame.Offset >= load.Off+load.Filesz {
+			continue
+		}
+
+		vaddr := frame.Offset - load.Off + load.Vaddr
+		if table.gosym != nil {
+			if fn := table.gosym.PCToFunc(vaddr); fn != nil {
+				frame.Symbol = fn.Name
+				frame.SymbolOffset = vaddr - fn.Entry
+			}
+			break
+		}
+
+		i := sort.Search(len(table.funcs), func(i int) bool {
+			return table.funcs[i].Value > vaddr
+		})
+		if i == 0 {
+			break
+		}
+
+		sym := table.funcs[i-1]
+		if vaddr < sym.Value+sym.Size {
+			frame.Symbol = sym.Name
+			frame.SymbolOffset = vaddr - sym.Value
+		}
+		break
+	}
+
+	return frame
+}
+
+// table returns the symbol table of path, or nil if the file can't be read.
+func (s *symbolizer) table(path string) *symbolTable {
+	if table, ok := s.files[path]; ok {
+		return table
+	}
+
+	table := readSymbolTable(filepath.Join(s.root, path))
+	if table == nil {
+		// The process may have exited, which makes its root inaccessible.
+		table = readSymbolTable(path)
+	}
+	s.files[path] = table
+	return table
+}
+
+func readSymbolTable(path string) *symbolTable {
+	f, err := internal.OpenSafeELFFile(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var table symbolTable
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_LOAD {
+			table.loads = append(table.loads, prog)
+		}
+	}
+
+	// Stripped files only have dynamic symbols.
+	syms, _ := f.Symbols()
+	if len(syms) == 0 {
+		// Go binaries are often stripped, but always contain a line table.
+		// Prefer it over the few dynamic symbols they may export.
+		if table.gosym = readGoSymbols(f); table.gosym != nil {
+			return &table
+		}
+	}
+
+	dynSyms, _ := f.DynamicSymbols()
+	for _, sym := range append(syms, dynSyms...) {
+		if elf.ST_TYPE(sym.Info) == elf.STT_FUNC && sym.Value != 0 {
+			table.funcs = append(table.funcs, sym)
+		}
+	}
+	sort.Slice(table.funcs, func(i, j int) bool {
+		return table.funcs[i].Value < table.funcs[j].Value
+	})
+
+	return &table
+}
+
+func readGoSymbols(f *internal.SafeELFFile) *gosym.Table {
+	pclntab, text := f.Section(".gopclntab"), f.Section(".text")
+	if pclntab == nil || text == nil {
+		return nil
+	}
+
+	data, err := pclntab.Data()
+	if err != nil {
+		return nil
+	}
+
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return nil
+	}
+	return table
+}
@@ -0,0 +1,136 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/sys"
+)
+
+// LookupPerCPU retrieves the value of key on each possible CPU from a
+// per-CPU map.
+//
+// The kernel pads each value to a multiple of 8 bytes. The padding is
+// removed, every returned value is exactly ValueSize bytes long.
+//
+// Returns an error if the key doesn't exist, see ErrKeyNotExist.
+func (m *Map) LookupPerCPU(key interface{}) ([][]byte, error) {
+	if !m.typ.hasPerCPUValue() {
+		return nil, fmt.Errorf("%s doesn't have per-CPU values", m.typ)
+	}
+
+	buf := make([]byte, m.fullValueSize)
+	if err := m.lookup(key, sys.NewSlicePointer(buf), 0); err != nil {
+		return nil, err
+	}
+
+	return m.splitPerCPU(buf), nil
+}
+
+// LookupSum retrieves the sum of the values of key on all CPUs from a per-CPU
+// map of unsigned integers of 1, 2, 4 or 8 bytes.
+//
+// Returns an error if the key doesn't exist, see ErrKeyNotExist.
+func (m *Map) LookupSum(key interface{}) (uint64, error) {
+	values, err := m.LookupPerCPU(key)
+	if err != nil {
+		return 0, err
+	}
+	return sumPerCPU(values)
+}
+
+// LookupMax retrieves the largest value of key on any CPU from a per-CPU map
+// of unsigned integers of 1, 2, 4 or 8 bytes.
+//
+// Returns an error if the key doesn't exist, see ErrKeyNotExist.
+func (m *Map) LookupMax(key interface{}) (uint64, error) {
+	values, err := m.LookupPerCPU(key)
+	if err != nil {
+		return 0, err
+	}
+	return maxPerCPU(values)
+}
+
+// NextPerCPU is like Next, except that it returns the value of each possible
+// CPU of a per-CPU map, see Map.LookupPerCPU.
+func (mi *MapIterator) NextPerCPU(keyOut interface{}, valuesOut *[][]byte) bool {
+	if mi.err == nil && !mi.target.typ.hasPerCPUValue() {
+		mi.err = fmt.Errorf("%s doesn't have per-CPU values", mi.target.typ)
+	}
+	return mi.Next(keyOut, valuesOut)
+}
+
+// NextSum is like Next, except that it returns the sum of the values on all
+// CPUs, see Map.LookupSum.
+func (mi *MapIterator) NextSum(keyOut interface{}, sumOut *uint64) bool {
+	return mi.nextAggregate(keyOut, sumOut, sumPerCPU)
+}
+
+// NextMax is like Next, except that it returns the largest value on any CPU,
+// see Map.LookupMax.
+func (mi *MapIterator) NextMax(keyOut interface{}, maxOut *uint64) bool {
+	return mi.nextAggregate(keyOut, maxOut, maxPerCPU)
+}
+
+func (mi *MapIterator) nextAggregate(keyOut interface{}, out *uint64, fn func([][]byte) (uint64, error)) bool {
+	var values [][]byte
+	if !mi.NextPerCPU(keyOut, &values) {
+		return false
+	}
+
+	*out, mi.err = fn(values)
+	return mi.err == nil
+}
+
+// splitPerCPU splits the value of a per-CPU map into the values of each CPU.
+func (m *Map) splitPerCPU(buf []byte) [][]byte {
+	size := int(m.valueSize)
+	stride := internal.Align(size, 8)
+
+	values := make([][]byte, 0, len(buf)/stride)
+	for off := 0; off+stride <= len(buf); off += stride {
+		values = append(values, buf[off:off+size:off+size])
+	}
+	return values
+}
+
+func sumPerCPU(values [][]byte) (uint64, error) {
+	var sum uint64
+	for _, value := range values {
+		n, err := perCPUUint(value)
+		if err != nil {
+			return 0, err
+		}
+		sum += n
+	}
+	return sum, nil
+}
+
+func maxPerCPU(values [][]byte) (uint64, error) {
+	var max uint64
+	for _, value := range values {
+		n, err := perCPUUint(value)
+		if err != nil {
+			return 0, err
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max, nil
+}
+
+func perCPUUint(value []byte) (uint64, error) {
+	switch len(value) {
+	case 1:
+		return uint64(value[0]), nil
+	case 2:
+		return uint64(internal.NativeEndian.Uint16(value)), nil
+	case 4:
+		return uint64(internal.NativeEndian.Uint32(value)), nil
+	case 8:
+		return internal.NativeEndian.Uint64(value), nil
+	default:
+		return 0, fmt.Errorf("can't aggregate values of %d bytes", len(value))
+	}
+}
@@ -0,0 +1,113 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLookupPerCPU(t *testing.T) {
+	possibleCPUs, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A value size of 2 bytes is padded to 8 bytes per CPU.
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUHash,
+		KeySize:    4,
+		ValueSize:  2,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	values := make([]uint16, possibleCPUs)
+	var sum uint64
+	for i := range values {
+		values[i] = uint16(i*10 + 1)
+		sum += uint64(values[i])
+	}
+	qt.Assert(t, m.Put(uint32(1), values), qt.IsNil)
+
+	perCPU, err := m.LookupPerCPU(uint32(1))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, perCPU, qt.HasLen, possibleCPUs)
+	for i, value := range perCPU {
+		qt.Assert(t, value, qt.HasLen, 2)
+		qt.Assert(t, internal.NativeEndian.Uint16(value), qt.Equals, values[i])
+	}
+
+	got, err := m.LookupSum(uint32(1))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.Equals, sum)
+
+	got, err = m.LookupMax(uint32(1))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.Equals, uint64(values[possibleCPUs-1]))
+
+	_, err = m.LookupSum(uint32(2))
+	qt.Assert(t, errors.Is(err, ErrKeyNotExist), qt.IsTrue)
+
+	var key uint32
+	it := m.Iterate()
+	qt.Assert(t, it.NextSum(&key, &got), qt.IsTrue)
+	qt.Assert(t, key, qt.Equals, uint32(1))
+	qt.Assert(t, got, qt.Equals, sum)
+	qt.Assert(t, it.NextSum(&key, &got), qt.IsFalse)
+	qt.Assert(t, it.Err(), qt.IsNil)
+
+	it = m.Iterate()
+	qt.Assert(t, it.NextMax(&key, &got), qt.IsTrue)
+	qt.Assert(t, got, qt.Equals, uint64(values[possibleCPUs-1]))
+
+	it = m.Iterate()
+	qt.Assert(t, it.NextPerCPU(&key, &perCPU), qt.IsTrue)
+	qt.Assert(t, perCPU, qt.HasLen, possibleCPUs)
+	qt.Assert(t, perCPU[0], qt.HasLen, 2)
+}
+
+func TestLookupPerCPUInvalid(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	_, err = m.LookupSum(uint32(0))
+	qt.Assert(t, err, qt.IsNotNil)
+
+	var key uint32
+	var sum uint64
+	it := m.Iterate()
+	qt.Assert(t, it.NextSum(&key, &sum), qt.IsFalse)
+	qt.Assert(t, it.Err(), qt.IsNotNil)
+
+	m, err = NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  12,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	perCPU, err := m.LookupPerCPU(uint32(0))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, perCPU[0], qt.HasLen, 12)
+
+	_, err = m.LookupSum(uint32(0))
+	qt.Assert(t, err, qt.ErrorMatches, ".* 12 bytes")
+}
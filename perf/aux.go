@@ -0,0 +1,119 @@
+package perf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	linux "golang.org/x/sys/unix"
+)
+
+// PMUType returns the perf event type of a dynamic PMU, for example
+// "intel_pt" or "cs_etm", for use with ExtraPerfOptions.PMUType.
+//
+// Returns ErrNotSupported if the PMU doesn't exist.
+func PMUType(name string) (uint32, error) {
+	typ, err := internal.ReadUint64FromFileOnce("%d\n", "/sys/bus/event_source/devices", name, "type")
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("PMU %s: %w", name, internal.ErrNotSupported)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("PMU %s: %w", name, err)
+	}
+	return uint32(typ), nil
+}
+
+// auxRing is the AUX area of a perf event, which holds the output of hardware
+// tracing PMUs like Intel PT or ARM CoreSight.
+type auxRing struct {
+	meta *unix.PerfEventMmapPage
+	data []byte
+}
+
+func newAuxRing(fd int, meta *unix.PerfEventMmapPage, auxBuffer int) (*auxRing, error) {
+	// The AUX area follows the data area, and is also a power of two pages.
+	size := perfBufferSize(auxBuffer) - os.Getpagesize()
+	meta.Aux_offset = meta.Data_offset + meta.Data_size
+	meta.Aux_size = uint64(size)
+
+	data, err := unix.Mmap(fd, int64(meta.Aux_offset), size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("can't mmap AUX area: %w", err)
+	}
+
+	return &auxRing{meta, data}, nil
+}
+
+// read appends size bytes starting at offset to buf, and hands the space
+// back to the kernel.
+func (ar *auxRing) read(offset, size uint64, buf []byte) []byte {
+	mask := uint64(len(ar.data) - 1)
+	end := offset + size
+	for offset < end {
+		start := offset & mask
+		n := end - offset
+		if remainder := uint64(len(ar.data)) - start; n > remainder {
+			n = remainder
+		}
+
+		buf = append(buf, ar.data[start:start+n]...)
+		offset += n
+	}
+
+	atomic.StoreUint64(&ar.meta.Aux_tail, end)
+	return buf
+}
+
+func (ar *auxRing) close() {
+	_ = unix.Munmap(ar.data)
+	ar.data = nil
+}
+
+// Aux decodes a PERF_RECORD_AUX record, which announces new data in the AUX
+// area of a ring. See Reader.ReadAux.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) Aux() (offset, size, flags uint64, ok bool) {
+	if r.RecordType != linux.PERF_RECORD_AUX || len(r.RawSample) < 24 {
+		return 0, 0, 0, false
+	}
+
+	return internal.NativeEndian.Uint64(r.RawSample[0:]),
+		internal.NativeEndian.Uint64(r.RawSample[8:]),
+		internal.NativeEndian.Uint64(r.RawSample[16:]),
+		true
+}
+
+// ReadAux appends the AUX data announced by a PERF_RECORD_AUX record to buf,
+// and makes the space available to the kernel again. Requires
+// ExtraPerfOptions.AuxBuffer.
+//
+// AUX records must be passed in the order they were read for each CPU.
+func (pr *Reader) ReadAux(rec *Record, buf []byte) ([]byte, error) {
+	offset, size, _, ok := rec.Aux()
+	if !ok {
+		return buf, errors.New("not an AUX record")
+	}
+
+	// Rings are only unmapped while holding pauseMu.
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return buf, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if rec.CPU < 0 || rec.CPU >= len(pr.rings) || pr.rings[rec.CPU] == nil || pr.rings[rec.CPU].aux == nil {
+		return buf, fmt.Errorf("no AUX area for CPU %d", rec.CPU)
+	}
+
+	aux := pr.rings[rec.CPU].aux
+	if size > uint64(len(aux.data)) {
+		return buf, fmt.Errorf("AUX record size %d exceeds AUX area", size)
+	}
+
+	return aux.read(offset, size, buf), nil
+}
@@ -0,0 +1,75 @@
+package perf
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
+)
+
+func TestAuxRingRead(t *testing.T) {
+	var meta unix.PerfEventMmapPage
+	ar := &auxRing{&meta, makeBuffer(8)}
+
+	buf := ar.read(6, 4, nil)
+	qt.Assert(t, buf, qt.DeepEquals, []byte{6, 7, 0, 1})
+	qt.Assert(t, meta.Aux_tail, qt.Equals, uint64(10))
+
+	buf = ar.read(10, 2, buf[:0])
+	qt.Assert(t, buf, qt.DeepEquals, []byte{2, 3})
+	qt.Assert(t, meta.Aux_tail, qt.Equals, uint64(12))
+}
+
+func TestRecordAux(t *testing.T) {
+	body := make([]byte, 24)
+	internal.NativeEndian.PutUint64(body[0:], 1)
+	internal.NativeEndian.PutUint64(body[8:], 2)
+	internal.NativeEndian.PutUint64(body[16:], 3)
+
+	rec := Record{RecordType: linux.PERF_RECORD_AUX, RawSample: body}
+	offset, size, flags, ok := rec.Aux()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, []uint64{offset, size, flags}, qt.DeepEquals, []uint64{1, 2, 3})
+
+	rec.RecordType = linux.PERF_RECORD_SAMPLE
+	_, _, _, ok = rec.Aux()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestPMUType(t *testing.T) {
+	typ, err := PMUType("software")
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, internal.ErrNotSupported) {
+		t.Skip("sysfs is not available:", err)
+	}
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, typ, qt.Equals, uint32(linux.PERF_TYPE_SOFTWARE))
+
+	_, err = PMUType("nonexistent")
+	qt.Assert(t, err, qt.ErrorIs, internal.ErrNotSupported)
+}
+
+func TestReaderAuxBuffer(t *testing.T) {
+	events := perfEventArray(t)
+
+	_, err := NewReaderWithOptions(events, 4096, ReaderOptions{Overwritable: true}, ExtraPerfOptions{AuxBuffer: 4096})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	pt, err := PMUType("intel_pt")
+	if err != nil {
+		t.Skip("Intel PT is not available:", err)
+	}
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{PMUType: pt, AuxBuffer: 4096})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	for _, ring := range rd.rings {
+		if ring != nil {
+			qt.Assert(t, ring.aux, qt.IsNotNil)
+		}
+	}
+}
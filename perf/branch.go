@@ -0,0 +1,70 @@
+package perf
+
+import (
+	"encoding/binary"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+// BranchEntry is a taken branch recorded by the hardware, for example by the
+// Last Branch Record facility on x86.
+//
+// It mirrors struct perf_branch_entry.
+type BranchEntry struct {
+	From, To uint64
+	// Flags is a bitfield, use the accessors to decode it.
+	Flags uint64
+}
+
+// bits extracts n bits starting at the least significant bit off of the
+// bitfield, which the compiler lays out in reverse on big endian machines.
+func (be *BranchEntry) bits(off, n uint) uint64 {
+	if internal.NativeEndian == binary.BigEndian {
+		off = 64 - off - n
+	}
+	return (be.Flags >> off) & (1<<n - 1)
+}
+
+// Mispredicted returns true if the branch target was mispredicted.
+func (be *BranchEntry) Mispredicted() bool { return be.bits(0, 1) != 0 }
+
+// Predicted returns true if the branch target was predicted.
+func (be *BranchEntry) Predicted() bool { return be.bits(1, 1) != 0 }
+
+// InTx returns true if the branch was in a hardware transaction.
+func (be *BranchEntry) InTx() bool { return be.bits(2, 1) != 0 }
+
+// Abort returns true if the branch was a transaction abort.
+func (be *BranchEntry) Abort() bool { return be.bits(3, 1) != 0 }
+
+// Cycles returns the number of cycles since the previous branch, or zero if
+// unknown.
+func (be *BranchEntry) Cycles() uint16 { return uint16(be.bits(4, 16)) }
+
+// Type returns the PERF_BR_* type of the branch. Requires
+// PERF_SAMPLE_BRANCH_TYPE_SAVE.
+func (be *BranchEntry) Type() uint8 { return uint8(be.bits(20, 4)) }
+
+// decodeBranches decodes a PERF_SAMPLE_BRANCH_STACK field into entries,
+// reusing its capacity.
+func (sl *sampleLayout) decodeBranches(field []byte, entries []BranchEntry) []BranchEntry {
+	entries = entries[:0]
+	if len(field) < 8 {
+		return entries
+	}
+
+	nr := int(internal.NativeEndian.Uint64(field))
+	off := 8
+	if sl.branchHWIndex {
+		off += 8
+	}
+
+	for i := 0; i < nr && off+24 <= len(field); i, off = i+1, off+24 {
+		entries = append(entries, BranchEntry{
+			internal.NativeEndian.Uint64(field[off:]),
+			internal.NativeEndian.Uint64(field[off+8:]),
+			internal.NativeEndian.Uint64(field[off+16:]),
+		})
+	}
+	return entries
+}
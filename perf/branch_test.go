@@ -0,0 +1,49 @@
+package perf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
+)
+
+func TestSampleLayoutBranches(t *testing.T) {
+	attr := linux.PerfEventAttr{
+		Sample_type:        linux.PERF_SAMPLE_BRANCH_STACK | linux.PERF_SAMPLE_CGROUP,
+		Branch_sample_type: linux.PERF_SAMPLE_BRANCH_ANY | linux.PERF_SAMPLE_BRANCH_HW_INDEX,
+	}
+	layout := newSampleLayout(&attr)
+
+	mispredicted := uint64(1)
+	cycles := uint64(42) << 4
+	if internal.NativeEndian == binary.BigEndian {
+		// Big endian bitfield layout.
+		mispredicted = 1 << 63
+		cycles = uint64(42) << (64 - 4 - 16)
+	}
+
+	values := []uint64{2, 0, 0x10, 0x20, mispredicted | cycles, 0x30, 0x40, 0, 0xcafe}
+	body := make([]byte, len(values)*8)
+	for i, v := range values {
+		internal.NativeEndian.PutUint64(body[i*8:], v)
+	}
+
+	rec := Record{RawSample: body}
+	layout.decodeSample(&rec)
+	qt.Assert(t, rec.CgroupID, qt.Equals, uint64(0xcafe))
+	qt.Assert(t, rec.Branches, qt.HasLen, 2)
+	qt.Assert(t, rec.Branches[0].From, qt.Equals, uint64(0x10))
+	qt.Assert(t, rec.Branches[0].To, qt.Equals, uint64(0x20))
+	qt.Assert(t, rec.Branches[0].Mispredicted(), qt.IsTrue)
+	qt.Assert(t, rec.Branches[0].Predicted(), qt.IsFalse)
+	qt.Assert(t, rec.Branches[0].Cycles(), qt.Equals, uint16(42))
+	qt.Assert(t, rec.Branches[1].Mispredicted(), qt.IsFalse)
+	qt.Assert(t, rec.Branches[1].To, qt.Equals, uint64(0x40))
+
+	// Capacity is reused.
+	before := &rec.Branches[0]
+	layout.decodeSample(&rec)
+	qt.Assert(t, &rec.Branches[0], qt.Equals, before)
+}
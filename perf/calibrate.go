@@ -0,0 +1,164 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// CalibrationOptions control the behaviour of Calibrate.
+type CalibrationOptions struct {
+	// The time spent observing records. Defaults to one second.
+	Duration time.Duration
+	// Process is called for each record, so that the cost of handling
+	// records, for example symbolization, is included in the measurement.
+	// The record is only valid until Process returns.
+	Process func(*Record)
+	// Latency is the time the rings should be able to absorb records
+	// without being read, for example while the reading goroutine is
+	// descheduled on a busy device. Defaults to 250ms.
+	Latency time.Duration
+}
+
+// Calibration describes the records observed by Calibrate and the settings
+// it recommends for them.
+type Calibration struct {
+	Elapsed time.Duration
+	// The number of records read and lost.
+	Records, Lost uint64
+	// The average size of a record in bytes, including the header.
+	RecordSize int
+	// The largest rate of bytes written to the ring of a single CPU per
+	// second, including lost records.
+	PeakCPUBytes float64
+	// The average time spent in CalibrationOptions.Process per record.
+	ProcessCost time.Duration
+
+	// MaxRate is the number of records per second which can be processed
+	// with some headroom, based on ProcessCost. Lower the frequency of the
+	// sampled events if the observed rate exceeds it. Zero if Process
+	// wasn't set.
+	MaxRate float64
+	// The recommended perCPUBuffer for NewReaderWithOptions.
+	PerCPUBuffer int
+	// The recommended ReaderOptions.Watermark.
+	Watermark int
+}
+
+// Rate returns the number of records written per second, including lost
+// records.
+func (c *Calibration) Rate() float64 {
+	if c.Elapsed <= 0 {
+		return 0
+	}
+	return float64(c.Records+c.Lost) / c.Elapsed.Seconds()
+}
+
+// Apply returns opts with the recommended Watermark. The recommended buffer
+// size has to be passed to NewReaderWithOptions separately.
+func (c *Calibration) Apply(opts ReaderOptions) ReaderOptions {
+	opts.Watermark = c.Watermark
+	opts.WakeupEvents = 0
+	return opts
+}
+
+// Calibrate reads records from rd for a short time to measure the rate at
+// which they are produced and the cost of processing them, and recommends
+// settings for a Reader.
+//
+// Records read during calibration are passed to opts.Process and are not
+// returned by rd afterwards. Calibrate uses the deadline of rd, which is
+// restored once it returns.
+func Calibrate(ctx context.Context, rd *Reader, opts CalibrationOptions) (*Calibration, error) {
+	duration := opts.Duration
+	if duration <= 0 {
+		duration = time.Second
+	}
+	latency := opts.Latency
+	if latency <= 0 {
+		latency = 250 * time.Millisecond
+	}
+
+	rd.mu.Lock()
+	deadline := rd.deadline
+	rd.mu.Unlock()
+	defer rd.SetDeadline(deadline)
+
+	start := time.Now()
+	rd.SetDeadline(start.Add(duration))
+
+	var (
+		c           Calibration
+		bytes       uint64
+		lostByCPU   = make(map[int]uint64)
+		bytesByCPU  = make(map[int]uint64)
+		processTime time.Duration
+		rec         Record
+	)
+	for {
+		err := rd.readInto(ctx, &rec)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if rec.LostSamples > 0 {
+			c.Lost += rec.LostSamples
+			lostByCPU[rec.CPU] += rec.LostSamples
+			continue
+		}
+
+		size := uint64(perfEventHeaderSize + len(rec.RawSample))
+		c.Records++
+		bytes += size
+		bytesByCPU[rec.CPU] += size
+
+		if opts.Process != nil {
+			begin := time.Now()
+			opts.Process(&rec)
+			processTime += time.Since(begin)
+		}
+	}
+	c.Elapsed = time.Since(start)
+
+	if c.Records > 0 {
+		c.RecordSize = int(bytes / c.Records)
+		c.ProcessCost = processTime / time.Duration(c.Records)
+	}
+
+	// Lost records are assumed to be of average size.
+	for cpu, lost := range lostByCPU {
+		bytesByCPU[cpu] += lost * uint64(c.RecordSize)
+	}
+	for _, n := range bytesByCPU {
+		if rate := float64(n) / c.Elapsed.Seconds(); rate > c.PeakCPUBytes {
+			c.PeakCPUBytes = rate
+		}
+	}
+
+	if opts.Process != nil && c.ProcessCost > 0 {
+		// Leave headroom for reading the rings and bursts.
+		c.MaxRate = 0.8 / c.ProcessCost.Seconds()
+	}
+
+	buffer := int(c.PeakCPUBytes * latency.Seconds())
+	if buffer < 1 {
+		buffer = 1
+	}
+	c.PerCPUBuffer = perfBufferSize(buffer) - os.Getpagesize()
+
+	// Limit wakeups to about a hundred per second and CPU, while keeping
+	// enough space in the ring for records arriving until the reader runs.
+	c.Watermark = int(c.PeakCPUBytes / 100)
+	if max := c.PerCPUBuffer / 4; c.Watermark > max {
+		c.Watermark = max
+	}
+	if c.Watermark < c.RecordSize {
+		c.Watermark = 0
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,50 @@
+package perf
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCalibrate(t *testing.T) {
+	events := perfEventArray(t)
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5, 5, 5)
+
+	var processed int
+	c, err := Calibrate(context.Background(), rd, CalibrationOptions{
+		Duration: 100 * time.Millisecond,
+		Process: func(*Record) {
+			processed++
+			time.Sleep(time.Millisecond)
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, processed, qt.Equals, 3)
+	qt.Assert(t, c.Records, qt.Equals, uint64(3))
+	qt.Assert(t, c.Rate() > 0, qt.IsTrue)
+	qt.Assert(t, c.RecordSize > perfEventHeaderSize, qt.IsTrue)
+	qt.Assert(t, c.PeakCPUBytes > 0, qt.IsTrue)
+	qt.Assert(t, c.ProcessCost >= time.Millisecond, qt.IsTrue)
+	qt.Assert(t, c.MaxRate > 0 && c.MaxRate < 1000, qt.IsTrue)
+	qt.Assert(t, c.PerCPUBuffer, qt.Equals, os.Getpagesize())
+	qt.Assert(t, c.Watermark, qt.Equals, 0)
+
+	opts := c.Apply(ReaderOptions{Watermark: 100, WakeupEvents: 1})
+	qt.Assert(t, opts.Watermark, qt.Equals, 0)
+	qt.Assert(t, opts.WakeupEvents, qt.Equals, 0)
+
+	// The deadline is restored.
+	qt.Assert(t, rd.deadline.IsZero(), qt.IsTrue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Calibrate(ctx, rd, CalibrationOptions{})
+	qt.Assert(t, err, qt.ErrorIs, context.Canceled)
+}
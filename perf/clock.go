@@ -0,0 +1,92 @@
+package perf
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// Bits of perf_event_mmap_page.capabilities.
+const (
+	capUserTime      = 1 << 3
+	capUserTimeZero  = 1 << 4
+	capUserTimeShort = 1 << 5
+)
+
+// TimeConversion converts hardware timestamps, like the TSC on x86, into perf
+// timestamps without a syscall. It is exported by the kernel in the metadata
+// page of each ring.
+type TimeConversion struct {
+	Shift uint16
+	Mult  uint32
+	Zero  uint64
+	// Cycles and Mask are only used if the hardware counter is narrower than
+	// 64 bits.
+	Cycles uint64
+	Mask   uint64
+	Short  bool
+}
+
+// Nanoseconds converts a raw hardware timestamp into the clock used for
+// Record.Time when ExtraPerfOptions.UseClockID isn't set.
+func (tc *TimeConversion) Nanoseconds(cyc uint64) uint64 {
+	if tc.Short {
+		cyc = tc.Cycles + ((cyc - tc.Cycles) & tc.Mask)
+	}
+
+	quot := cyc >> tc.Shift
+	rem := cyc & (uint64(1)<<tc.Shift - 1)
+	return tc.Zero + quot*uint64(tc.Mult) + (rem*uint64(tc.Mult))>>tc.Shift
+}
+
+// timeConversion reads the time conversion parameters from the metadata page.
+func (ring *perfEventRing) timeConversion() (*TimeConversion, error) {
+	meta := (*unix.PerfEventMmapPage)(unsafe.Pointer(&ring.mmap[0]))
+
+	for {
+		// The kernel updates the page under a seqlock.
+		seq := atomic.LoadUint32(&meta.Lock)
+		caps := atomic.LoadUint64(&meta.Capabilities)
+		tc := &TimeConversion{
+			Shift:  meta.Time_shift,
+			Mult:   meta.Time_mult,
+			Zero:   meta.Time_zero,
+			Cycles: meta.Time_cycles,
+			Mask:   meta.Time_mask,
+			Short:  caps&capUserTimeShort != 0,
+		}
+		if atomic.LoadUint32(&meta.Lock) != seq {
+			continue
+		}
+
+		if caps&capUserTime == 0 || caps&capUserTimeZero == 0 {
+			return nil, fmt.Errorf("hardware timestamps on CPU %d: %w", ring.cpu, internal.ErrNotSupported)
+		}
+		return tc, nil
+	}
+}
+
+// TimeConversion returns the parameters to convert hardware timestamps taken
+// on cpu into nanoseconds.
+//
+// Returns ErrNotSupported if the kernel doesn't expose the parameters, for
+// example because the TSC is unstable.
+func (pr *Reader) TimeConversion(cpu int) (*TimeConversion, error) {
+	// Rings are only unmapped while holding pauseMu, which isn't held while
+	// waiting for data.
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return nil, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if cpu < 0 || cpu >= len(pr.rings) || pr.rings[cpu] == nil {
+		return nil, fmt.Errorf("no ring for CPU %d", cpu)
+	}
+
+	return pr.rings[cpu].timeConversion()
+}
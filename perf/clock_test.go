@@ -0,0 +1,42 @@
+package perf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTimeConversion(t *testing.T) {
+	tc := TimeConversion{Shift: 2, Mult: 3, Zero: 100}
+	qt.Assert(t, tc.Nanoseconds(0), qt.Equals, uint64(100))
+	// (9 >> 2) * 3 + ((9 & 3) * 3) >> 2
+	qt.Assert(t, tc.Nanoseconds(9), qt.Equals, uint64(100+6+0))
+
+	// A 32 bit counter which wrapped since Cycles was recorded.
+	tc = TimeConversion{Mult: 1, Cycles: 0xffff_fff0, Mask: 0xffff_ffff, Short: true}
+	qt.Assert(t, tc.Nanoseconds(0x10), qt.Equals, uint64(0x1_0000_0010))
+}
+
+func TestReaderTimeConversion(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	_, err = rd.TimeConversion(-1)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	tc, err := rd.TimeConversion(0)
+	if errors.Is(err, internal.ErrNotSupported) {
+		t.Skip(err)
+	}
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tc.Mult, qt.Not(qt.Equals), uint32(0))
+
+	rd.Close()
+	_, err = rd.TimeConversion(0)
+	qt.Assert(t, err, qt.ErrorIs, ErrClosed)
+}
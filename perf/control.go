@@ -0,0 +1,77 @@
+package perf
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// SignalControl maps signals to actions on a Reader, see Reader.HandleSignals.
+//
+// Signals which are nil are ignored.
+type SignalControl struct {
+	// Pause and Resume call Reader.Pause and Reader.Resume respectively.
+	Pause, Resume os.Signal
+	// Rotate invokes OnRotate, for example to reopen the files records are
+	// written to.
+	Rotate   os.Signal
+	OnRotate func()
+	// OnError is called with errors from Pause and Resume. Errors are
+	// discarded if it is nil.
+	OnError func(error)
+}
+
+// HandleSignals allows controlling a long-running Reader from outside of the
+// process, for example via kill -USR1.
+//
+// The returned function stops handling signals and waits until pending
+// actions are finished. It must be called before closing the Reader.
+func (pr *Reader) HandleSignals(ctl SignalControl) (stop func()) {
+	actions := make(map[os.Signal]func() error)
+	add := func(sig os.Signal, fn func() error) {
+		if sig != nil {
+			actions[sig] = fn
+		}
+	}
+	add(ctl.Pause, pr.Pause)
+	add(ctl.Resume, pr.Resume)
+	if ctl.OnRotate != nil {
+		add(ctl.Rotate, func() error { ctl.OnRotate(); return nil })
+	}
+
+	sigs := make([]os.Signal, 0, len(actions))
+	for sig := range actions {
+		sigs = append(sigs, sig)
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	if len(sigs) > 0 {
+		signal.Notify(ch, sigs...)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case sig := <-ch:
+				if err := actions[sig](); err != nil && ctl.OnError != nil {
+					ctl.OnError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package perf
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReaderHandleSignals(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	rotated := make(chan struct{}, 1)
+	stop := rd.HandleSignals(SignalControl{
+		Pause:    syscall.SIGUSR1,
+		Resume:   syscall.SIGUSR2,
+		Rotate:   syscall.SIGHUP,
+		OnRotate: func() { rotated <- struct{}{} },
+		OnError:  func(err error) { t.Error(err) },
+	})
+	defer stop()
+
+	paused := func() bool {
+		rd.pauseMu.Lock()
+		defer rd.pauseMu.Unlock()
+		return rd.paused
+	}
+
+	waitFor := func(want bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for paused() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Reader paused is not %v", want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	qt.Assert(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1), qt.IsNil)
+	waitFor(true)
+
+	qt.Assert(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2), qt.IsNil)
+	waitFor(false)
+
+	qt.Assert(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP), qt.IsNil)
+	select {
+	case <-rotated:
+	case <-time.After(time.Second):
+		t.Fatal("OnRotate wasn't called")
+	}
+
+	stop()
+	stop()
+}
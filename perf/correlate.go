@@ -0,0 +1,103 @@
+package perf
+
+import (
+	"sort"
+	"time"
+)
+
+// CorrelatorOptions control the behaviour of a Correlator.
+type CorrelatorOptions struct {
+	// How far back in Record.Time to look for related records.
+	Window time.Duration
+	// Source identifies where a record originated, for example the event
+	// added via Reader.AddEvent. Only the most recent record of each source
+	// and thread is retained. Defaults to Record.ID.
+	Source func(*Record) uint64
+}
+
+// Correlation is a record together with the records of the same thread
+// that preceded it.
+type Correlation struct {
+	Record Record
+	// The most recent record of every other source within the window,
+	// newest first.
+	Related []Record
+}
+
+// Correlator joins records from different sources by thread and time, for
+// example a breakpoint hit with the scheduler event that preceded it.
+//
+// Records must be passed to Add in time order, see Reader.RunOrdered, and
+// carry a thread and timestamp, see ExtraPerfOptions.SampleTID and
+// ExtraPerfOptions.SampleTime. A Correlator is not safe for concurrent use.
+type Correlator struct {
+	window  uint64
+	source  func(*Record) uint64
+	threads map[uint32]map[uint64]Record
+	pruned  uint64
+}
+
+// NewCorrelator creates a Correlator.
+func NewCorrelator(opts CorrelatorOptions) *Correlator {
+	source := opts.Source
+	if source == nil {
+		source = func(rec *Record) uint64 { return rec.ID }
+	}
+
+	return &Correlator{
+		uint64(opts.Window),
+		source,
+		make(map[uint32]map[uint64]Record),
+		0,
+	}
+}
+
+// Add retains rec and returns it together with related records.
+func (c *Correlator) Add(rec Record) Correlation {
+	src := c.source(&rec)
+	corr := Correlation{Record: rec}
+
+	recent := c.threads[rec.Tid]
+	for s, prev := range recent {
+		if s != src && c.within(prev.Time, rec.Time) {
+			corr.Related = append(corr.Related, prev)
+		}
+	}
+
+	sort.Slice(corr.Related, func(i, j int) bool {
+		return corr.Related[i].Time > corr.Related[j].Time
+	})
+
+	if recent == nil {
+		recent = make(map[uint64]Record)
+		c.threads[rec.Tid] = recent
+	}
+	recent[src] = rec
+
+	c.prune(rec.Time)
+	return corr
+}
+
+func (c *Correlator) within(prev, now uint64) bool {
+	return prev <= now && now-prev <= c.window
+}
+
+// prune drops records which can't be related to any future record. It is
+// amortised by only running once per window.
+func (c *Correlator) prune(now uint64) {
+	if now < c.pruned+c.window {
+		return
+	}
+	c.pruned = now
+
+	for tid, recent := range c.threads {
+		for src, prev := range recent {
+			if prev.Time < now && now-prev.Time > c.window {
+				delete(recent, src)
+			}
+		}
+		if len(recent) == 0 {
+			delete(c.threads, tid)
+		}
+	}
+}
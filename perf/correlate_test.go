@@ -0,0 +1,52 @@
+package perf
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestCorrelator(t *testing.T) {
+	c := NewCorrelator(CorrelatorOptions{Window: 100})
+
+	rec := func(id uint64, tid uint32, time uint64) Record {
+		return Record{ID: id, Tid: tid, Time: time}
+	}
+
+	corr := c.Add(rec(1, 10, 1000))
+	qt.Assert(t, corr.Related, qt.HasLen, 0)
+
+	c.Add(rec(1, 10, 1010))
+	c.Add(rec(2, 10, 1020))
+	c.Add(rec(2, 11, 1030))
+
+	corr = c.Add(rec(3, 10, 1050))
+	qt.Assert(t, corr.Record.ID, qt.Equals, uint64(3))
+	qt.Assert(t, corr.Related, qt.DeepEquals, []Record{
+		rec(2, 10, 1020),
+		rec(1, 10, 1010),
+	})
+
+	// Records of the same source aren't related.
+	corr = c.Add(rec(3, 10, 1060))
+	qt.Assert(t, corr.Related, qt.HasLen, 2)
+
+	// Records outside of the window aren't related.
+	corr = c.Add(rec(3, 10, 1115))
+	qt.Assert(t, corr.Related, qt.DeepEquals, []Record{rec(2, 10, 1020)})
+
+	c.Add(rec(1, 12, 5000))
+	qt.Assert(t, c.threads, qt.HasLen, 1)
+}
+
+func TestCorrelatorSource(t *testing.T) {
+	c := NewCorrelator(CorrelatorOptions{
+		Window: 100,
+		Source: func(rec *Record) uint64 { return uint64(rec.RecordType) },
+	})
+
+	c.Add(Record{RecordType: 1, Tid: 1, Time: 10})
+	corr := c.Add(Record{RecordType: 2, Tid: 1, Time: 20})
+	qt.Assert(t, corr.Related, qt.HasLen, 1)
+	qt.Assert(t, corr.Related[0].RecordType, qt.Equals, uint32(1))
+}
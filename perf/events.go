@@ -0,0 +1,133 @@
+package perf
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+	linux "golang.org/x/sys/unix"
+)
+
+// Bits of perf_event_attr which must match between events sharing a ring.
+const sharedAttrBits = linux.PerfBitWatermark | linux.PerfBitWriteBackward |
+	linux.PerfBitSampleIDAll | linux.PerfBitUseClockID
+
+// AddEvent opens an additional perf event for pid on cpu and redirects its
+// output into the ring of cpu using PERF_EVENT_IOC_SET_OUTPUT.
+//
+// Records of all events in a ring are decoded using the same layout, so the
+// sample type, clock and wakeup settings of attr are overwritten with those
+// of the Reader. The event is closed together with the Reader.
+//
+// Returns the ID of the event, which allows mapping records back to attr via
+// Record.ID. Requires ExtraPerfOptions.SampleID.
+func (pr *Reader) AddEvent(attr linux.PerfEventAttr, pid, cpu int) (uint64, error) {
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return 0, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if pr.events == nil {
+		return 0, fmt.Errorf("add event: requires ExtraPerfOptions.SampleID")
+	}
+
+	if cpu < 0 || cpu >= len(pr.pauseFds) || pr.pauseFds[cpu] == -1 {
+		return 0, fmt.Errorf("add event: no ring for CPU %d", cpu)
+	}
+
+	attr.Sample_type = pr.attr.Sample_type
+	attr.Read_format = pr.attr.Read_format
+	attr.Sample_regs_user = pr.attr.Sample_regs_user
+	attr.Sample_stack_user = pr.attr.Sample_stack_user
+	attr.Branch_sample_type = pr.attr.Branch_sample_type
+	attr.Bits = attr.Bits&^sharedAttrBits | pr.attr.Bits&sharedAttrBits
+	attr.Wakeup = pr.attr.Wakeup
+	attr.Clockid = pr.attr.Clockid
+	attr.Size = uint32(unsafe.Sizeof(attr))
+
+	fd, err := perfEventOpen(&attr, pid, cpu, -1, unix.PERF_FLAG_FD_CLOEXEC)
+	if err != nil {
+		return 0, fmt.Errorf("add event: %w", err)
+	}
+
+	if err := unix.IoctlSetInt(fd, linux.PERF_EVENT_IOC_SET_OUTPUT, pr.pauseFds[cpu]); err != nil {
+		unix.Close(fd)
+		return 0, fmt.Errorf("add event: set output: %w", err)
+	}
+
+	id, err := eventID(fd)
+	if err != nil {
+		unix.Close(fd)
+		return 0, fmt.Errorf("add event: %w", err)
+	}
+
+	pr.eventFds = append(pr.eventFds, fd)
+	pr.events[id] = attr
+	return id, nil
+}
+
+// EventAttr returns the attributes of the perf event with the given ID, as
+// found in Record.ID.
+//
+// Returns false if the event is unknown or ExtraPerfOptions.SampleID wasn't
+// set.
+func (pr *Reader) EventAttr(id uint64) (linux.PerfEventAttr, bool) {
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	attr, ok := pr.events[id]
+	return attr, ok
+}
+
+// AttachProgram attaches prog to the perf events backing the rings using
+// PERF_EVENT_IOC_SET_BPF. prog then runs whenever one of the events
+// overflows, for example on every hit of a hardware breakpoint, before the
+// sample is written to the ring. Returning zero from prog discards the
+// sample.
+//
+// prog must be of type ebpf.PerfEvent. This is intended for breakpoint and
+// PMU events, see ExtraPerfOptions.BrkAddr and ExtraPerfOptions.PMUType:
+// samples of the default bpf-output events would be discarded, since they
+// are written from BPF programs themselves.
+//
+// The program stays attached until the Reader is closed. If attaching to
+// the event of a CPU fails, events of preceding CPUs remain attached.
+func (pr *Reader) AttachProgram(prog *ebpf.Program) error {
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if err := ioctlRings(pr.pauseFds, unix.PERF_EVENT_IOC_SET_BPF, prog.FD()); err != nil {
+		return fmt.Errorf("attach program: %w", err)
+	}
+	return nil
+}
+
+// eventID returns the ID the kernel assigned to a perf event.
+func eventID(fd int) (uint64, error) {
+	var id uint64
+	_, _, errno := unix.Syscall(linux.SYS_IOCTL, uintptr(fd), linux.PERF_EVENT_IOC_ID, uintptr(unsafe.Pointer(&id)))
+	if errno != 0 {
+		return 0, fmt.Errorf("get event id: %w", errno)
+	}
+	return id, nil
+}
+
+// PreciseIP returns the skid constraint the events backing the rings were
+// created with, which may be lower than ExtraPerfOptions.PreciseIP.
+func (pr *Reader) PreciseIP() uint8 {
+	var precise uint8
+	if pr.attr.Bits&linux.PerfBitPreciseIPBit1 != 0 {
+		precise |= 1
+	}
+	if pr.attr.Bits&linux.PerfBitPreciseIPBit2 != 0 {
+		precise |= 2
+	}
+	return precise
+}
@@ -0,0 +1,109 @@
+package perf
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
+)
+
+func TestReaderSampleID(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{SampleID: true})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.ID, qt.Not(qt.Equals), uint64(0))
+	qt.Assert(t, rec.StreamID, qt.Equals, rec.ID)
+
+	attr, ok := rd.EventAttr(rec.ID)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, attr.Config, qt.Equals, uint64(linux.PERF_COUNT_SW_BPF_OUTPUT))
+
+	id, err := rd.AddEvent(linux.PerfEventAttr{
+		Type:   linux.PERF_TYPE_SOFTWARE,
+		Config: linux.PERF_COUNT_SW_DUMMY,
+	}, -1, rec.CPU)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, id, qt.Not(qt.Equals), rec.ID)
+
+	attr, ok = rd.EventAttr(id)
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, attr.Config, qt.Equals, uint64(linux.PERF_COUNT_SW_DUMMY))
+	qt.Assert(t, attr.Sample_type, qt.Equals, rd.attr.Sample_type)
+
+	_, err = rd.AddEvent(linux.PerfEventAttr{}, -1, -1)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	qt.Assert(t, rd.Close(), qt.IsNil)
+	_, err = rd.AddEvent(linux.PerfEventAttr{}, -1, 0)
+	qt.Assert(t, err, qt.ErrorIs, ErrClosed)
+}
+
+func TestReaderAddEventWithoutSampleID(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	_, err = rd.AddEvent(linux.PerfEventAttr{Type: linux.PERF_TYPE_SOFTWARE}, -1, 0)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	_, ok := rd.EventAttr(0)
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestReaderPreciseIP(t *testing.T) {
+	events := perfEventArray(t)
+
+	_, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{PreciseIP: 4})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{PreciseIP: 3})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	qt.Assert(t, rd.PreciseIP() <= 3, qt.IsTrue)
+	t.Log("Precise IP:", rd.PreciseIP())
+}
+
+func TestReaderAttachProgram(t *testing.T) {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.PerfEvent,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 1, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer prog.Close()
+
+	events := perfEventArray(t)
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{
+		PMUType:   linux.PERF_TYPE_SOFTWARE,
+		PMUConfig: linux.PERF_COUNT_SW_CPU_CLOCK,
+	})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+
+	qt.Assert(t, rd.AttachProgram(prog), qt.IsNil)
+	// An event can only have a single program.
+	qt.Assert(t, rd.AttachProgram(prog), qt.IsNotNil)
+
+	rd.Close()
+	qt.Assert(t, errors.Is(rd.AttachProgram(prog), ErrClosed), qt.IsTrue)
+}
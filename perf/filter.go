@@ -0,0 +1,93 @@
+package perf
+
+import (
+	"errors"
+
+	"github.com/cilium/ebpf/internal/unix"
+	linux "golang.org/x/sys/unix"
+)
+
+// Filter selects the records returned by a Reader.
+//
+// A record is returned if it matches all non-empty sets. Records of lost
+// samples are always returned.
+type Filter struct {
+	// The CPUs to return records of.
+	CPUs []int
+	// The processes and threads to return samples of. Other records are
+	// returned regardless. Requires ExtraPerfOptions.SampleTID.
+	Pids []uint32
+	Tids []uint32
+}
+
+type recordFilter struct {
+	cpus map[int]struct{}
+	pids map[uint32]struct{}
+	tids map[uint32]struct{}
+}
+
+func makeSet[T comparable](values []T) map[T]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+
+	set := make(map[T]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return set
+}
+
+func (rf *recordFilter) matches(rec *Record) bool {
+	if rec.RecordType == unix.PERF_RECORD_LOST {
+		return true
+	}
+
+	if rf.cpus != nil {
+		if _, ok := rf.cpus[rec.CPU]; !ok {
+			return false
+		}
+	}
+
+	if rec.RecordType != unix.PERF_RECORD_SAMPLE {
+		return true
+	}
+
+	if rf.pids != nil {
+		if _, ok := rf.pids[rec.Pid]; !ok {
+			return false
+		}
+	}
+
+	if rf.tids != nil {
+		if _, ok := rf.tids[rec.Tid]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetFilter drops records which don't match f before they are returned by
+// any of the Read methods. Records are still copied out of the ring and
+// decoded, but filtering in the Reader avoids handing them to the caller.
+//
+// SetFilter may be called concurrently with reads. The zero Filter removes
+// any filtering.
+func (pr *Reader) SetFilter(f Filter) error {
+	if (len(f.Pids) > 0 || len(f.Tids) > 0) && pr.attr.Sample_type&linux.PERF_SAMPLE_TID == 0 {
+		return errors.New("filtering by process requires ExtraPerfOptions.SampleTID")
+	}
+
+	rf := &recordFilter{
+		makeSet(f.CPUs),
+		makeSet(f.Pids),
+		makeSet(f.Tids),
+	}
+	if rf.cpus == nil && rf.pids == nil && rf.tids == nil {
+		rf = nil
+	}
+
+	pr.filter.Store(rf)
+	return nil
+}
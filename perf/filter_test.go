@@ -0,0 +1,62 @@
+package perf
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/unix"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRecordFilter(t *testing.T) {
+	rf := &recordFilter{
+		cpus: makeSet([]int{1}),
+		pids: makeSet([]uint32{42}),
+	}
+
+	sample := func(cpu int, pid uint32) *Record {
+		return &Record{CPU: cpu, RecordType: unix.PERF_RECORD_SAMPLE, Pid: pid}
+	}
+
+	qt.Assert(t, rf.matches(sample(1, 42)), qt.IsTrue)
+	qt.Assert(t, rf.matches(sample(0, 42)), qt.IsFalse)
+	qt.Assert(t, rf.matches(sample(1, 23)), qt.IsFalse)
+	qt.Assert(t, rf.matches(&Record{CPU: 0, RecordType: unix.PERF_RECORD_LOST}), qt.IsTrue)
+	qt.Assert(t, rf.matches(&Record{CPU: 1, RecordType: 3 /* PERF_RECORD_COMM */}), qt.IsTrue)
+}
+
+func TestReaderSetFilter(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rd.SetFilter(Filter{Pids: []uint32{1}}), qt.IsNotNil)
+	rd.Close()
+
+	rd, err = NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{SampleTID: true})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	// Samples of other processes are dropped.
+	qt.Assert(t, rd.SetFilter(Filter{Pids: []uint32{uint32(os.Getpid()) + 1}}), qt.IsNil)
+	outputSamples(t, events, 5)
+
+	rd.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = rd.Read()
+	qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue, qt.Commentf("got error: %v", err))
+
+	qt.Assert(t, rd.SetFilter(Filter{Pids: []uint32{uint32(os.Getpid())}}), qt.IsNil)
+	outputSamples(t, events, 5)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.Pid, qt.Equals, uint32(os.Getpid()))
+	qt.Assert(t, rec.Tid, qt.Not(qt.Equals), uint32(0))
+
+	// The zero Filter matches everything.
+	qt.Assert(t, rd.SetFilter(Filter{}), qt.IsNil)
+	qt.Assert(t, rd.accept(&Record{RecordType: unix.PERF_RECORD_SAMPLE, Pid: 1}), qt.IsTrue)
+}
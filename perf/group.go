@@ -0,0 +1,66 @@
+package perf
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal/unix"
+	linux "golang.org/x/sys/unix"
+)
+
+// Counter is a perf event which is read whenever a sample is written.
+type Counter struct {
+	// Name identifies the counter in Record.Counters.
+	Name string
+	// Type and Config select the event, for example PERF_TYPE_HARDWARE and
+	// PERF_COUNT_HW_INSTRUCTIONS.
+	Type   uint32
+	Config uint64
+}
+
+// openCounters opens counters in the group of leader.
+//
+// Returns the file descriptors of the counters and a map from their IDs to
+// their names.
+func openCounters(leader, cpu int, counters []Counter) (_ []int, _ map[uint64]string, err error) {
+	if len(counters) == 0 {
+		return nil, nil, nil
+	}
+
+	var fds []int
+	defer func() {
+		if err != nil {
+			closeCounters(fds)
+		}
+	}()
+
+	ids := make(map[uint64]string, len(counters))
+	for _, counter := range counters {
+		attr := linux.PerfEventAttr{
+			Type:        counter.Type,
+			Config:      counter.Config,
+			Read_format: linux.PERF_FORMAT_GROUP | linux.PERF_FORMAT_ID,
+		}
+		attr.Size = uint32(unsafe.Sizeof(attr))
+
+		fd, err := perfEventOpen(&attr, -1, cpu, leader, unix.PERF_FLAG_FD_CLOEXEC)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open counter %s: %w", counter.Name, err)
+		}
+		fds = append(fds, fd)
+
+		id, err := eventID(fd)
+		if err != nil {
+			return nil, nil, fmt.Errorf("counter %s: %w", counter.Name, err)
+		}
+		ids[id] = counter.Name
+	}
+
+	return fds, ids, nil
+}
+
+func closeCounters(fds []int) {
+	for _, fd := range fds {
+		_ = unix.Close(fd)
+	}
+}
@@ -0,0 +1,52 @@
+package perf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
+)
+
+func TestSampleLayoutCounters(t *testing.T) {
+	attr := linux.PerfEventAttr{
+		Sample_type: linux.PERF_SAMPLE_READ | linux.PERF_SAMPLE_CGROUP,
+		Read_format: linux.PERF_FORMAT_GROUP | linux.PERF_FORMAT_ID | linux.PERF_FORMAT_TOTAL_TIME_ENABLED,
+	}
+	layout := newSampleLayout(&attr)
+	layout.counterNames = map[uint64]string{10: "cycles", 11: "instructions"}
+
+	values := []uint64{3, 1000, 5, 99, 1, 10, 2, 11, 0xcafe}
+	body := make([]byte, len(values)*8)
+	for i, v := range values {
+		internal.NativeEndian.PutUint64(body[i*8:], v)
+	}
+
+	var rec Record
+	rec.RawSample = body
+	layout.decodeSample(&rec)
+	qt.Assert(t, rec.Counters, qt.DeepEquals, map[string]uint64{"cycles": 1, "instructions": 2})
+	qt.Assert(t, rec.CgroupID, qt.Equals, uint64(0xcafe))
+}
+
+func TestReaderCounters(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{
+		Counters: []Counter{
+			{"cpu-clock", linux.PERF_TYPE_SOFTWARE, linux.PERF_COUNT_SW_CPU_CLOCK},
+			{"page-faults", linux.PERF_TYPE_SOFTWARE, linux.PERF_COUNT_SW_PAGE_FAULTS},
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.Counters, qt.HasLen, 2)
+	qt.Assert(t, rec.Counters["cpu-clock"], qt.Not(qt.Equals), uint64(0))
+}
@@ -0,0 +1,153 @@
+package perf
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// LatencyOptions control the behaviour of a LatencyTracker.
+type LatencyOptions struct {
+	// Match extracts the key which pairs a start record with an end record,
+	// for example a request ID from RawSample. ok is false for records
+	// which are neither.
+	Match func(rec *Record) (key uint64, start, ok bool)
+	// The maximum number of unmatched start records. Further start records
+	// are dropped. Defaults to DefaultMaxPending.
+	MaxPending int
+}
+
+// DefaultMaxPending is the number of unmatched start records retained by a
+// LatencyTracker if LatencyOptions.MaxPending is zero.
+const DefaultMaxPending = 4096
+
+// LatencyTracker computes the time between pairs of records and maintains
+// a histogram of the results.
+//
+// Records must carry a timestamp, see ExtraPerfOptions.SampleTime. It is safe
+// to call methods of a LatencyTracker concurrently.
+type LatencyTracker struct {
+	match      func(*Record) (uint64, bool, bool)
+	maxPending int
+
+	mu      sync.Mutex
+	pending map[uint64]uint64
+	dropped uint64
+	hist    Histogram
+}
+
+// NewLatencyTracker creates a LatencyTracker.
+func NewLatencyTracker(opts LatencyOptions) *LatencyTracker {
+	maxPending := opts.MaxPending
+	if maxPending <= 0 {
+		maxPending = DefaultMaxPending
+	}
+
+	return &LatencyTracker{
+		match:      opts.Match,
+		maxPending: maxPending,
+		pending:    make(map[uint64]uint64),
+	}
+}
+
+// Add processes a record.
+//
+// Returns the latency and true if rec ends a pair.
+func (lt *LatencyTracker) Add(rec *Record) (time.Duration, bool) {
+	key, start, ok := lt.match(rec)
+	if !ok {
+		return 0, false
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if start {
+		if _, ok := lt.pending[key]; !ok && len(lt.pending) >= lt.maxPending {
+			lt.dropped++
+			return 0, false
+		}
+		lt.pending[key] = rec.Time
+		return 0, false
+	}
+
+	begin, ok := lt.pending[key]
+	if !ok {
+		return 0, false
+	}
+	delete(lt.pending, key)
+
+	if rec.Time < begin {
+		// Records were passed out of order.
+		return 0, false
+	}
+
+	latency := rec.Time - begin
+	lt.hist.add(latency)
+	return time.Duration(latency), true
+}
+
+// Histogram returns a copy of the histogram of latencies.
+func (lt *LatencyTracker) Histogram() Histogram {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	return lt.hist
+}
+
+// Dropped returns the number of start records dropped because MaxPending
+// was exceeded.
+func (lt *LatencyTracker) Dropped() uint64 {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	return lt.dropped
+}
+
+// Histogram is a histogram of durations with exponentially sized buckets.
+type Histogram struct {
+	// Buckets[0] counts durations of zero, Buckets[i] counts durations in
+	// the range [2^(i-1), 2^i) nanoseconds.
+	Buckets [65]uint64
+	Count   uint64
+	Sum     time.Duration
+}
+
+func (h *Histogram) add(ns uint64) {
+	h.Buckets[bits.Len64(ns)]++
+	h.Count++
+	h.Sum += time.Duration(ns)
+}
+
+// Quantile returns an upper bound for the duration below which the given
+// fraction of durations lie.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	rank := uint64(q * float64(h.Count))
+	if rank >= h.Count {
+		rank = h.Count - 1
+	}
+
+	var seen uint64
+	for i, n := range h.Buckets {
+		seen += n
+		if seen > rank {
+			return bucketLimit(i)
+		}
+	}
+	return bucketLimit(len(h.Buckets) - 1)
+}
+
+// bucketLimit returns the largest duration counted in bucket i.
+func bucketLimit(i int) time.Duration {
+	if i == 0 {
+		return 0
+	}
+	if i >= 63 {
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(uint64(1)<<i - 1)
+}
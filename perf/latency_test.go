@@ -0,0 +1,73 @@
+package perf
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	lt := NewLatencyTracker(LatencyOptions{
+		Match: func(rec *Record) (uint64, bool, bool) {
+			if len(rec.RawSample) != 2 {
+				return 0, false, false
+			}
+			return uint64(rec.RawSample[1]), rec.RawSample[0] == 0, true
+		},
+		MaxPending: 2,
+	})
+
+	rec := func(start bool, key byte, time uint64) *Record {
+		var typ byte = 1
+		if start {
+			typ = 0
+		}
+		return &Record{RawSample: []byte{typ, key}, Time: time}
+	}
+
+	_, ok := lt.Add(rec(true, 1, 100))
+	qt.Assert(t, ok, qt.IsFalse)
+	lt.Add(rec(true, 2, 110))
+	lt.Add(rec(true, 3, 120))
+	qt.Assert(t, lt.Dropped(), qt.Equals, uint64(1))
+
+	// Unrelated records are ignored.
+	_, ok = lt.Add(&Record{})
+	qt.Assert(t, ok, qt.IsFalse)
+
+	d, ok := lt.Add(rec(false, 2, 150))
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, d, qt.Equals, 40*time.Nanosecond)
+
+	d, ok = lt.Add(rec(false, 1, 1100))
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, d, qt.Equals, 1000*time.Nanosecond)
+
+	// Unmatched end records are ignored.
+	_, ok = lt.Add(rec(false, 3, 1200))
+	qt.Assert(t, ok, qt.IsFalse)
+
+	hist := lt.Histogram()
+	qt.Assert(t, hist.Count, qt.Equals, uint64(2))
+	qt.Assert(t, hist.Sum, qt.Equals, 1040*time.Nanosecond)
+	qt.Assert(t, hist.Buckets[6], qt.Equals, uint64(1))
+	qt.Assert(t, hist.Buckets[10], qt.Equals, uint64(1))
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	var h Histogram
+	qt.Assert(t, h.Quantile(0.5), qt.Equals, time.Duration(0))
+
+	for i := 0; i < 99; i++ {
+		h.add(100)
+	}
+	h.add(1 << 20)
+
+	qt.Assert(t, h.Quantile(0), qt.Equals, 127*time.Nanosecond)
+	qt.Assert(t, h.Quantile(0.5), qt.Equals, 127*time.Nanosecond)
+	qt.Assert(t, h.Quantile(1), qt.Equals, time.Duration(1<<21-1))
+
+	h.add(1<<64 - 1)
+	qt.Assert(t, h.Quantile(1), qt.Equals, time.Duration(1<<63-1))
+}
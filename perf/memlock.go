@@ -0,0 +1,189 @@
+package perf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+const (
+	perfMlockPath  = "/proc/sys/kernel/perf_event_mlock_kb"
+	onlineCPUsPath = "/sys/devices/system/cpu/online"
+	// CAP_IPC_LOCK lifts the limits on locked memory.
+	capIPCLock = 14
+)
+
+// MemlockError is returned when creating a Reader with
+// ReaderOptions.CheckMemlock if its rings would exceed the budget for
+// locked memory.
+type MemlockError struct {
+	// The number of bytes locked by the rings.
+	Required uint64
+	// The number of bytes which may be locked, see MemlockBudget.
+	Budget uint64
+}
+
+func (me *MemlockError) Error() string {
+	return fmt.Sprintf("perf rings need %d bytes of locked memory but only %d bytes are available: "+
+		"raise RLIMIT_MEMLOCK (see RaiseMemlock) or perf_event_mlock_kb, or reduce perCPUBuffer",
+		me.Required, me.Budget)
+}
+
+// RingMemory returns the number of bytes of locked memory used by the rings
+// of a Reader with the given configuration.
+func RingMemory(nCPU, perCPUBuffer int, eopts ExtraPerfOptions) uint64 {
+	size := perfBufferSize(perCPUBuffer)
+	if eopts.AuxBuffer > 0 {
+		size += perfBufferSize(eopts.AuxBuffer) - os.Getpagesize()
+	}
+	return uint64(nCPU) * uint64(size)
+}
+
+// MemlockBudget returns the number of bytes the current process can lock
+// for perf rings: perf_event_mlock_kb for each online CPU plus
+// RLIMIT_MEMLOCK.
+//
+// The result is an upper bound, since perf_event_mlock_kb is shared by all
+// processes of a user and RLIMIT_MEMLOCK by all locked memory of the
+// process. Returns math.MaxUint64 if locked memory isn't limited.
+func MemlockBudget() (uint64, error) {
+	if paranoid, err := readParanoid(); err == nil && paranoid < 0 {
+		return math.MaxUint64, nil
+	}
+
+	if ok, err := haveCapability(capIPCLock); err != nil {
+		return 0, err
+	} else if ok {
+		return math.MaxUint64, nil
+	}
+
+	var limit unix.Rlimit
+	if err := unix.Prlimit(0, unix.RLIMIT_MEMLOCK, nil, &limit); err != nil {
+		return 0, fmt.Errorf("get memlock rlimit: %w", err)
+	}
+	if limit.Cur == unix.RLIM_INFINITY {
+		return math.MaxUint64, nil
+	}
+
+	mlockKB, err := internal.ReadUint64FromFile("%d\n", perfMlockPath)
+	if err != nil {
+		return 0, err
+	}
+
+	cpus, err := onlineCPUs()
+	if err != nil {
+		return 0, err
+	}
+
+	return mlockKB*1024*uint64(cpus) + limit.Cur, nil
+}
+
+// checkMemlock returns a MemlockError if required exceeds MemlockBudget.
+func checkMemlock(required uint64) error {
+	budget, err := MemlockBudget()
+	if err != nil {
+		return fmt.Errorf("check locked memory: %w", err)
+	}
+	if required > budget {
+		return &MemlockError{required, budget}
+	}
+	return nil
+}
+
+// RaiseMemlock increases RLIMIT_MEMLOCK of the current process by n bytes,
+// for example by the amount a MemlockError exceeds the budget.
+//
+// Raising the limit beyond its maximum requires CAP_SYS_RESOURCE. Since the
+// limit is process wide, the function should be invoked at program start up.
+func RaiseMemlock(n uint64) error {
+	var limit unix.Rlimit
+	if err := unix.Prlimit(0, unix.RLIMIT_MEMLOCK, nil, &limit); err != nil {
+		return fmt.Errorf("get memlock rlimit: %w", err)
+	}
+	if limit.Cur == unix.RLIM_INFINITY {
+		return nil
+	}
+
+	cur := limit.Cur + n
+	if cur < limit.Cur || cur >= unix.RLIM_INFINITY {
+		cur = unix.RLIM_INFINITY
+	}
+	limit.Cur = cur
+	if limit.Max != unix.RLIM_INFINITY && limit.Max < cur {
+		limit.Max = cur
+	}
+
+	if err := unix.Prlimit(0, unix.RLIMIT_MEMLOCK, &limit, nil); err != nil {
+		return fmt.Errorf("set memlock rlimit: %w", err)
+	}
+	return nil
+}
+
+// haveCapability returns true if the current thread has capability cap in
+// its effective set.
+func haveCapability(cap uint) (bool, error) {
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return false, err
+	}
+
+	fields, err := procStatusField(status, "CapEff")
+	if err != nil {
+		return false, err
+	}
+	if len(fields) != 1 {
+		return false, fmt.Errorf("invalid CapEff: %q", fields)
+	}
+
+	caps, err := strconv.ParseUint(fields[0], 16, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid CapEff: %w", err)
+	}
+	return caps&(1<<cap) != 0, nil
+}
+
+// onlineCPUs returns the number of online CPUs.
+func onlineCPUs() (int, error) {
+	spec, err := os.ReadFile(onlineCPUsPath)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := countCPUs(string(bytes.TrimSpace(spec)))
+	if err != nil {
+		return 0, fmt.Errorf("can't parse %s: %w", onlineCPUsPath, err)
+	}
+	return n, nil
+}
+
+// countCPUs counts the CPUs in a list like "0-3,5".
+func countCPUs(spec string) (int, error) {
+	var n int
+	for _, span := range strings.Split(spec, ",") {
+		lowStr, highStr, isRange := strings.Cut(span, "-")
+		low, err := strconv.Atoi(lowStr)
+		if err != nil {
+			return 0, err
+		}
+
+		high := low
+		if isRange {
+			high, err = strconv.Atoi(highStr)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if high < low {
+			return 0, fmt.Errorf("invalid range %s", span)
+		}
+
+		n += high - low + 1
+	}
+	return n, nil
+}
@@ -0,0 +1,70 @@
+package perf
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRingMemory(t *testing.T) {
+	page := uint64(os.Getpagesize())
+
+	qt.Assert(t, RingMemory(2, 1, ExtraPerfOptions{}), qt.Equals, 2*2*page)
+	qt.Assert(t, RingMemory(1, 3*int(page), ExtraPerfOptions{AuxBuffer: 1}), qt.Equals, 5*page+page)
+}
+
+func TestCountCPUs(t *testing.T) {
+	for spec, want := range map[string]int{
+		"0":         1,
+		"0-3":       4,
+		"0-3,5,7-8": 7,
+	} {
+		n, err := countCPUs(spec)
+		qt.Assert(t, err, qt.IsNil, qt.Commentf("%s", spec))
+		qt.Assert(t, n, qt.Equals, want, qt.Commentf("%s", spec))
+	}
+
+	for _, spec := range []string{"", "3-1", "a"} {
+		_, err := countCPUs(spec)
+		qt.Assert(t, err, qt.IsNotNil, qt.Commentf("%s", spec))
+	}
+}
+
+func TestReaderCheckMemlock(t *testing.T) {
+	budget, err := MemlockBudget()
+	qt.Assert(t, err, qt.IsNil)
+	if budget == math.MaxUint64 {
+		t.Skip("Locked memory isn't limited")
+	}
+
+	events := perfEventArray(t)
+	_, err = NewReaderWithOptions(events, int(budget), ReaderOptions{CheckMemlock: true}, ExtraPerfOptions{})
+	var me *MemlockError
+	qt.Assert(t, errors.As(err, &me), qt.IsTrue)
+	qt.Assert(t, me.Budget, qt.Equals, budget)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{CheckMemlock: true}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	rd.Close()
+}
+
+func TestRaiseMemlock(t *testing.T) {
+	var old unix.Rlimit
+	qt.Assert(t, unix.Prlimit(0, unix.RLIMIT_MEMLOCK, nil, &old), qt.IsNil)
+	defer unix.Prlimit(0, unix.RLIMIT_MEMLOCK, &old, nil)
+	if old.Max < 8192 {
+		t.Skip("RLIMIT_MEMLOCK maximum is too low")
+	}
+
+	limit := unix.Rlimit{Cur: 4096, Max: old.Max}
+	qt.Assert(t, unix.Prlimit(0, unix.RLIMIT_MEMLOCK, &limit, nil), qt.IsNil)
+
+	qt.Assert(t, RaiseMemlock(4096), qt.IsNil)
+	qt.Assert(t, unix.Prlimit(0, unix.RLIMIT_MEMLOCK, nil, &limit), qt.IsNil)
+	qt.Assert(t, limit.Cur, qt.Equals, uint64(8192))
+}
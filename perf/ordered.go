@@ -0,0 +1,127 @@
+package perf
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// DefaultReorderWindow is the reordering window used by RunOrdered if
+// OrderedOptions.Window is zero.
+const DefaultReorderWindow = 10 * time.Millisecond
+
+// OrderedOptions control the behaviour of Reader.RunOrdered.
+type OrderedOptions struct {
+	// How long records are held back to wait for older records from other
+	// CPUs, measured in Record.Time. Larger windows tolerate more skew
+	// between CPUs at the cost of latency. Defaults to DefaultReorderWindow.
+	Window time.Duration
+}
+
+// RunOrdered is like Run, except that records of all CPUs are merged into a
+// single stream ordered by Record.Time. The reader must have been created
+// with ExtraPerfOptions.SampleTime.
+//
+// A record is passed to handler once a record which is newer by at least
+// Window has been read, or no record was read for Window. Records which
+// arrive after a newer record was already passed to handler are passed on
+// immediately, out of order.
+//
+// The handler is invoked from the calling goroutine. Pending records are passed
+// to handler before RunOrdered returns due to ctx being cancelled. The deadline
+// set via SetDeadline doesn't apply while RunOrdered is active and is restored
+// once it returns. Returns nil if the Reader was closed, the first error
+// returned by handler, or ctx.Err().
+func (pr *Reader) RunOrdered(ctx context.Context, handler func(Record) error, opts *OrderedOptions) error {
+	if pr.layout == nil || pr.layout.sampleTime < 0 {
+		return errors.New("perf ringbuffer: RunOrdered requires ExtraPerfOptions.SampleTime")
+	}
+
+	restore := pr.suspendDeadline()
+	defer restore()
+
+	window := DefaultReorderWindow
+	if opts != nil && opts.Window > 0 {
+		window = opts.Window
+	}
+
+	var (
+		pending recordHeap
+		newest  uint64
+	)
+
+	flush := func(until uint64) error {
+		for len(pending) > 0 && pending[0].Time <= until {
+			if err := handler(heap.Pop(&pending).(Record)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for {
+		// Only wait for the window to pass if records are pending.
+		var deadline time.Time
+		if len(pending) > 0 {
+			deadline = time.Now().Add(window)
+		}
+		pr.SetDeadline(deadline)
+
+		rec, err := pr.ReadContext(ctx)
+
+		switch {
+		case err == nil:
+		case errors.Is(err, os.ErrDeadlineExceeded):
+			// Nothing arrived within the window, so all pending records are
+			// assumed to be in order.
+			if err := flush(math.MaxUint64); err != nil {
+				return err
+			}
+			continue
+		case errors.Is(err, ErrClosed):
+			return flush(math.MaxUint64)
+		case ctx.Err() != nil:
+			if err := flush(math.MaxUint64); err != nil {
+				return err
+			}
+			return ctx.Err()
+		default:
+			return fmt.Errorf("read record: %w", err)
+		}
+
+		heap.Push(&pending, rec)
+		if rec.Time > newest {
+			newest = rec.Time
+		}
+
+		if newest > uint64(window) {
+			if err := flush(newest - uint64(window)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// recordHeap is a min-heap of records ordered by time.
+type recordHeap []Record
+
+func (h recordHeap) Len() int           { return len(h) }
+func (h recordHeap) Less(i, j int) bool { return h[i].Time < h[j].Time }
+func (h recordHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *recordHeap) Push(x any) {
+	*h = append(*h, x.(Record))
+}
+
+func (h *recordHeap) Pop() any {
+	old := *h
+	n := len(old)
+	rec := old[n-1]
+	old[n-1] = Record{}
+	*h = old[:n-1]
+	return rec
+}
@@ -0,0 +1,150 @@
+package perf
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+	linux "golang.org/x/sys/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSampleLayout(t *testing.T) {
+	attr := perfEventAttr(ReaderOptions{}, ExtraPerfOptions{})
+	layout := newSampleLayout(&attr)
+	qt.Assert(t, layout.time(unix.PERF_RECORD_SAMPLE, make([]byte, 16)), qt.Equals, uint64(0))
+
+	// Breakpoints sample pid and tid before the timestamp.
+	attr = perfEventAttr(ReaderOptions{}, ExtraPerfOptions{BrkAddr: 1, SampleTime: true})
+	layout = newSampleLayout(&attr)
+	qt.Assert(t, layout.sampleTime, qt.Equals, 8)
+	qt.Assert(t, layout.idTime, qt.Equals, 8)
+
+	body := make([]byte, 24)
+	internal.NativeEndian.PutUint64(body[8:], 42)
+	qt.Assert(t, layout.time(unix.PERF_RECORD_SAMPLE, body), qt.Equals, uint64(42))
+
+	trailer := make([]byte, 16)
+	internal.NativeEndian.PutUint64(trailer[8:], 23)
+	qt.Assert(t, layout.time(linux.PERF_RECORD_COMM, trailer), qt.Equals, uint64(23))
+	qt.Assert(t, layout.time(linux.PERF_RECORD_COMM, trailer[:4]), qt.Equals, uint64(0))
+}
+
+func TestReaderClockID(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{
+		SampleTime: true,
+		UseClockID: true,
+		ClockID:    linux.CLOCK_MONOTONIC,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	var before, after linux.Timespec
+	qt.Assert(t, linux.ClockGettime(linux.CLOCK_MONOTONIC, &before), qt.IsNil)
+	outputSamples(t, events, 5)
+	qt.Assert(t, linux.ClockGettime(linux.CLOCK_MONOTONIC, &after), qt.IsNil)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.Time >= uint64(before.Nano()), qt.IsTrue, qt.Commentf("%d < %d", rec.Time, before.Nano()))
+	qt.Assert(t, rec.Time <= uint64(after.Nano()), qt.IsTrue, qt.Commentf("%d > %d", rec.Time, after.Nano()))
+}
+
+func TestRecordHeap(t *testing.T) {
+	var h recordHeap
+	for _, ts := range []uint64{3, 1, 2} {
+		heap.Push(&h, Record{Time: ts})
+	}
+
+	var got []uint64
+	for h.Len() > 0 {
+		got = append(got, heap.Pop(&h).(Record).Time)
+	}
+	qt.Assert(t, got, qt.DeepEquals, []uint64{1, 2, 3})
+}
+
+func TestReaderRunOrdered(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{SampleTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	outputSamples(t, events, 5, 6, 7)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var recs []Record
+	err = rd.RunOrdered(ctx, func(rec Record) error {
+		recs = append(recs, rec)
+		if len(recs) == 3 {
+			cancel()
+		}
+		return nil
+	}, nil)
+	qt.Assert(t, errors.Is(err, context.Canceled), qt.IsTrue, qt.Commentf("got error: %v", err))
+
+	for i, rec := range recs {
+		qt.Assert(t, rec.Time, qt.Not(qt.Equals), uint64(0))
+		if i > 0 {
+			qt.Assert(t, rec.Time >= recs[i-1].Time, qt.IsTrue)
+		}
+
+		// The timestamp precedes the size of the raw sample.
+		sample := rec.RawSample[8+perfEventSampleSize:]
+		qt.Assert(t, int(sample[1]), qt.Equals, i)
+	}
+}
+
+func TestReaderRunOrderedCancelFlushes(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{SampleTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	deadline := time.Now().Add(-time.Second)
+	rd.SetDeadline(deadline)
+
+	outputSamples(t, events, 5, 6, 7)
+
+	// The window never passes, so records are only handled once ctx expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var ids []int
+	err = rd.RunOrdered(ctx, func(rec Record) error {
+		sample := rec.RawSample[8+perfEventSampleSize:]
+		ids = append(ids, int(sample[1]))
+		return nil
+	}, &OrderedOptions{Window: time.Hour})
+	qt.Assert(t, errors.Is(err, context.DeadlineExceeded), qt.IsTrue, qt.Commentf("got error: %v", err))
+	qt.Assert(t, ids, qt.DeepEquals, []int{0, 1, 2})
+	qt.Assert(t, rd.deadline, qt.Equals, deadline)
+}
+
+func TestReaderRunOrderedWithoutTime(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	err = rd.RunOrdered(context.Background(), func(Record) error { return nil }, nil)
+	qt.Assert(t, err, qt.IsNotNil)
+}
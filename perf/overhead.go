@@ -0,0 +1,173 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// OverheadOptions control the behaviour of an OverheadGuard.
+type OverheadOptions struct {
+	// MaxCPU is the fraction of a single CPU the process may use, for
+	// example 0.05 for five percent.
+	MaxCPU float64
+	// The interval at which CPU usage is measured. Defaults to one second.
+	Interval time.Duration
+	// CPUTime returns the CPU time consumed so far. Defaults to the user
+	// and system time of the whole process, as reported by
+	// getrusage(RUSAGE_SELF), since the time of individual goroutines
+	// can't be measured.
+	CPUTime func() (time.Duration, error)
+}
+
+// OverheadGuard pauses a Reader while the CPU usage of the process exceeds
+// a budget, which stops the events from generating samples and caps the
+// cost of reading and processing them.
+//
+// Samples written while the Reader is paused are lost, see Reader.Pause.
+// The Reader must not be paused or resumed by other means while the guard
+// is running.
+type OverheadGuard struct {
+	rd       *Reader
+	maxCPU   float64
+	interval time.Duration
+	cpuTime  func() (time.Duration, error)
+
+	mu        sync.Mutex
+	usage     float64
+	paused    bool
+	throttles uint64
+	throttled time.Duration
+}
+
+// NewOverheadGuard creates a guard for rd. Call Run to start it.
+func NewOverheadGuard(rd *Reader, opts OverheadOptions) (*OverheadGuard, error) {
+	if opts.MaxCPU <= 0 {
+		return nil, errors.New("MaxCPU must be larger than zero")
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	cpuTime := opts.CPUTime
+	if cpuTime == nil {
+		cpuTime = processCPUTime
+	}
+
+	return &OverheadGuard{
+		rd:       rd,
+		maxCPU:   opts.MaxCPU,
+		interval: interval,
+		cpuTime:  cpuTime,
+	}, nil
+}
+
+// Run measures CPU usage every interval, pausing the Reader while it
+// exceeds MaxCPU and resuming it once it drops below, until ctx is
+// cancelled or the Reader is closed.
+//
+// The Reader is resumed when Run returns. Returns ctx.Err() if ctx was
+// cancelled.
+func (g *OverheadGuard) Run(ctx context.Context) (err error) {
+	defer func() {
+		if resumeErr := g.set(false, 0); err == nil && !errors.Is(resumeErr, ErrClosed) {
+			err = resumeErr
+		}
+	}()
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	lastCPU, err := g.cpuTime()
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		cpu, err := g.cpuTime()
+		if err != nil {
+			return err
+		}
+
+		elapsed := now.Sub(last)
+		usage := float64(cpu-lastCPU) / float64(elapsed)
+		last, lastCPU = now, cpu
+
+		if err := g.set(usage > g.maxCPU, elapsed); errors.Is(err, ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		g.mu.Lock()
+		g.usage = usage
+		g.mu.Unlock()
+	}
+}
+
+// set pauses or resumes the Reader if necessary. elapsed is the time since
+// the previous call.
+func (g *OverheadGuard) set(pause bool, elapsed time.Duration) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused {
+		g.throttled += elapsed
+	}
+
+	if pause == g.paused {
+		return nil
+	}
+
+	if pause {
+		if err := g.rd.Pause(); err != nil {
+			return err
+		}
+		g.throttles++
+	} else if err := g.rd.Resume(); err != nil {
+		return err
+	}
+
+	g.paused = pause
+	return nil
+}
+
+// Usage returns the fraction of a CPU used during the last interval.
+func (g *OverheadGuard) Usage() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.usage
+}
+
+// Throttled returns how often the Reader was paused, and the time it spent
+// paused.
+func (g *OverheadGuard) Throttled() (uint64, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.throttles, g.throttled
+}
+
+// processCPUTime returns the user and system time used by the process.
+func processCPUTime() (time.Duration, error) {
+	var ru unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &ru); err != nil {
+		return 0, fmt.Errorf("getrusage: %w", err)
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), nil
+}
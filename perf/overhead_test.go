@@ -0,0 +1,74 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestOverheadGuard(t *testing.T) {
+	events := perfEventArray(t)
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	_, err = NewOverheadGuard(rd, OverheadOptions{})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	// Pretend to use a whole CPU until busy is cleared.
+	var busy int32 = 1
+	var cpu time.Duration
+	guard, err := NewOverheadGuard(rd, OverheadOptions{
+		MaxCPU:   0.5,
+		Interval: 10 * time.Millisecond,
+		CPUTime: func() (time.Duration, error) {
+			if atomic.LoadInt32(&busy) == 1 {
+				cpu += time.Second
+			}
+			return cpu, nil
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- guard.Run(ctx) }()
+
+	waitFor(t, func() bool { n, _ := guard.Throttled(); return n == 1 })
+	qt.Assert(t, guard.Usage() > 0.5, qt.IsTrue)
+
+	// Output fails while the Reader is paused.
+	ret, _, err := outputSamplesProg(t, events, 5).Test(internal.EmptyBPFContext)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ret, qt.Not(qt.Equals), uint32(0))
+
+	atomic.StoreInt32(&busy, 0)
+	waitFor(t, func() bool { return guard.Usage() == 0 })
+
+	outputSamples(t, events, 5)
+	rd.SetDeadline(time.Now().Add(time.Second))
+	checkRecord(t, rd)
+
+	_, throttled := guard.Throttled()
+	qt.Assert(t, throttled > 0, qt.IsTrue)
+
+	cancel()
+	qt.Assert(t, errors.Is(<-done, context.Canceled), qt.IsTrue)
+}
+
+func waitFor(tb testing.TB, cond func() bool) {
+	tb.Helper()
+
+	for deadline := time.Now().Add(time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			tb.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,116 @@
+package perf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/internal/unix"
+	linux "golang.org/x/sys/unix"
+)
+
+const (
+	paranoidPath = "/proc/sys/kernel/perf_event_paranoid"
+	lockdownPath = "/sys/kernel/security/lockdown"
+)
+
+// PermissionError is returned if the kernel refuses to open a perf event.
+// It describes the most likely reason.
+//
+// Use errors.Is(err, os.ErrPermission) to check for it.
+type PermissionError struct {
+	// EACCES or EPERM.
+	Err error
+	// The value of /proc/sys/kernel/perf_event_paranoid, valid if
+	// ParanoidKnown is set.
+	Paranoid      int
+	ParanoidKnown bool
+	// The active mode of kernel lockdown, for example "confidentiality", or
+	// empty if unknown.
+	Lockdown string
+	// The capability which would allow opening the event.
+	Capability string
+}
+
+func (pe *PermissionError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "perf_event_open: %v", pe.Err)
+
+	switch {
+	case pe.Lockdown == "confidentiality":
+		b.WriteString(": kernel lockdown is in confidentiality mode")
+	case pe.ParanoidKnown && pe.Paranoid > 2:
+		fmt.Fprintf(&b, ": perf_event_paranoid is %d, which disallows perf events without %s", pe.Paranoid, pe.Capability)
+	case pe.ParanoidKnown:
+		fmt.Fprintf(&b, ": perf_event_paranoid is %d, lower it or grant %s", pe.Paranoid, pe.Capability)
+	default:
+		fmt.Fprintf(&b, ": missing %s", pe.Capability)
+	}
+
+	return b.String()
+}
+
+func (pe *PermissionError) Unwrap() error {
+	return pe.Err
+}
+
+// perfEventOpen is like unix.PerfEventOpen, except that permission errors are
+// turned into a PermissionError.
+func perfEventOpen(attr *linux.PerfEventAttr, pid, cpu, groupFd, flags int) (int, error) {
+	fd, err := unix.PerfEventOpen(attr, pid, cpu, groupFd, flags)
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPERM) {
+		paranoid, paranoidErr := readParanoid()
+		return fd, newPermissionError(err, paranoid, paranoidErr == nil, readLockdown())
+	}
+	return fd, err
+}
+
+func newPermissionError(err error, paranoid int, paranoidKnown bool, lockdown string) *PermissionError {
+	// CAP_PERFMON was split from CAP_SYS_ADMIN in Linux 5.8.
+	capability := "CAP_PERFMON (CAP_SYS_ADMIN before Linux 5.8)"
+	if lockdown == "confidentiality" {
+		// Lockdown applies regardless of capabilities.
+		capability = ""
+	}
+
+	return &PermissionError{
+		Err:           err,
+		Paranoid:      paranoid,
+		ParanoidKnown: paranoidKnown,
+		Lockdown:      lockdown,
+		Capability:    capability,
+	}
+}
+
+func readParanoid() (int, error) {
+	raw, err := os.ReadFile(paranoidPath)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(raw)))
+}
+
+// readLockdown returns the active lockdown mode or an empty string.
+func readLockdown() string {
+	raw, err := os.ReadFile(lockdownPath)
+	if err != nil {
+		return ""
+	}
+	return parseLockdown(string(raw))
+}
+
+// parseLockdown extracts the active mode from the contents of the lockdown
+// file, for example "none [integrity] confidentiality".
+func parseLockdown(modes string) string {
+	_, rest, ok := strings.Cut(modes, "[")
+	if !ok {
+		return ""
+	}
+	mode, _, ok := strings.Cut(rest, "]")
+	if !ok {
+		return ""
+	}
+	return mode
+}
@@ -0,0 +1,40 @@
+package perf
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/unix"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestPermissionError(t *testing.T) {
+	var err error = newPermissionError(unix.EACCES, 3, true, "none")
+	qt.Assert(t, errors.Is(err, os.ErrPermission), qt.IsTrue)
+	qt.Assert(t, errors.Is(err, unix.EACCES), qt.IsTrue)
+	qt.Assert(t, err.Error(), qt.Contains, "perf_event_paranoid is 3")
+	qt.Assert(t, err.Error(), qt.Contains, "CAP_PERFMON")
+
+	var pe *PermissionError
+	qt.Assert(t, errors.As(err, &pe), qt.IsTrue)
+	qt.Assert(t, pe.Paranoid, qt.Equals, 3)
+
+	err = newPermissionError(unix.EPERM, 2, false, "confidentiality")
+	qt.Assert(t, errors.Is(err, os.ErrPermission), qt.IsTrue)
+	qt.Assert(t, err.Error(), qt.Contains, "lockdown")
+
+	err = newPermissionError(unix.EPERM, 0, false, "")
+	qt.Assert(t, err.Error(), qt.Contains, "missing CAP_PERFMON")
+}
+
+func TestParseLockdown(t *testing.T) {
+	for in, want := range map[string]string{
+		"[none] integrity confidentiality\n": "none",
+		"none integrity [confidentiality]\n": "confidentiality",
+		"none":                               "",
+		"[none":                              "",
+	} {
+		qt.Assert(t, parseLockdown(in), qt.Equals, want, qt.Commentf("input %q", in))
+	}
+}
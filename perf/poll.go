@@ -0,0 +1,86 @@
+package perf
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/cilium/ebpf/internal/epoll"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// poller waits for rings to become readable, see epoll.Poller.
+type poller interface {
+	Add(fd, id int) error
+	Wait(events []unix.EpollEvent, deadline time.Time) (int, error)
+	Interrupt() error
+	Close() error
+}
+
+// newPoller creates a poller for nRings rings.
+func newPoller(backend Backend, nRings int) (poller, error) {
+	switch backend {
+	case BackendEpoll:
+		return epoll.New()
+	case BackendIOUring:
+		return epoll.NewURing(nRings)
+	default:
+		return nil, fmt.Errorf("unknown backend %d", backend)
+	}
+}
+
+// busyPolling updates the rate at which the rings are polled and returns
+// true if it exceeds ReaderOptions.BusyPollThreshold.
+func (pr *Reader) busyPolling() bool {
+	if pr.busyPoll <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	if !pr.lastPoll.IsZero() {
+		if elapsed := now.Sub(pr.lastPoll); elapsed > 0 {
+			// Exponentially weighted moving average, to avoid flapping
+			// between modes on bursty input.
+			rate := float64(time.Second) / float64(elapsed)
+			pr.pollRate = 0.8*pr.pollRate + 0.2*rate
+		}
+	}
+	pr.lastPoll = now
+
+	return pr.pollRate >= float64(pr.busyPollThreshold)
+}
+
+// spin checks the rings for data until ReaderOptions.BusyPoll elapses, the
+// deadline is reached or ctx is cancelled.
+//
+// Returns the rings which contain data, or nil.
+func (pr *Reader) spin(ctx context.Context) []*perfEventRing {
+	end := time.Now().Add(pr.busyPoll)
+	if !pr.deadline.IsZero() && pr.deadline.Before(end) {
+		end = pr.deadline
+	}
+
+	for ctx.Err() == nil && time.Now().Before(end) {
+		var ready []*perfEventRing
+		for _, ring := range pr.rings {
+			if ring != nil && ring.pending() {
+				ready = append(ready, ring)
+			}
+		}
+		if len(ready) > 0 {
+			return ready
+		}
+
+		runtime.Gosched()
+	}
+
+	return nil
+}
+
+// pending returns true if the kernel wrote data which wasn't read yet.
+func (ring *perfEventRing) pending() bool {
+	fr, ok := ring.ringReader.(*forwardReader)
+	return ok && atomic.LoadUint64(&fr.meta.Data_head) != fr.tail
+}
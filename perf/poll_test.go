@@ -0,0 +1,73 @@
+package perf
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReaderBusyPoll(t *testing.T) {
+	events := perfEventArray(t)
+
+	_, err := NewReaderWithOptions(events, 4096, ReaderOptions{BusyPoll: time.Millisecond, Overwritable: true}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{
+		BusyPoll:          100 * time.Millisecond,
+		BusyPollThreshold: 1000,
+	}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	// The first poll establishes a baseline.
+	qt.Assert(t, rd.busyPolling(), qt.IsFalse)
+	rd.lastPoll = time.Now().Add(-time.Microsecond)
+	qt.Assert(t, rd.busyPolling(), qt.IsTrue)
+
+	qt.Assert(t, rd.spin(context.Background()), qt.HasLen, 0)
+
+	outputSamples(t, events, 5)
+	qt.Assert(t, rd.spin(context.Background()), qt.HasLen, 1)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	checkRecord(t, rd)
+
+	// Reading continues to work if the rate drops.
+	rd.pollRate = 0
+	outputSamples(t, events, 5)
+	checkRecord(t, rd)
+}
+
+func TestReaderIOUringBackend(t *testing.T) {
+	events := perfEventArray(t)
+
+	_, err := NewReaderWithOptions(events, 4096, ReaderOptions{Backend: -1}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{Backend: BackendIOUring}, ExtraPerfOptions{})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5)
+	rd.SetDeadline(time.Now().Add(time.Second))
+	checkRecord(t, rd)
+
+	rd.SetDeadline(time.Now().Add(readTimeout))
+	_, err = rd.Read()
+	qt.Assert(t, err, qt.ErrorIs, os.ErrDeadlineExceeded)
+
+	ctx, cancel := context.WithTimeout(context.Background(), readTimeout)
+	defer cancel()
+	rd.SetDeadline(time.Time{})
+	_, err = rd.ReadContext(ctx)
+	qt.Assert(t, err, qt.ErrorIs, context.DeadlineExceeded)
+
+	outputSamples(t, events, 5)
+	checkRecord(t, rd)
+}
@@ -0,0 +1,147 @@
+package perf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"unsafe"
+
+	linux "golang.org/x/sys/unix"
+)
+
+// RingSnapshot is a copy of the unread part of a ring, for inspection by
+// external decoders. Offset zero is the header of the next record a Read
+// would return, records follow each other without gaps.
+type RingSnapshot struct {
+	CPU  int
+	data []byte
+}
+
+// ReadAt implements io.ReaderAt.
+func (rs *RingSnapshot) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(rs.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, rs.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Size returns the number of bytes in the snapshot.
+func (rs *RingSnapshot) Size() int64 {
+	return int64(len(rs.data))
+}
+
+// RingSnapshot copies the unread contents of the ring of a CPU without
+// consuming them.
+//
+// Output to overwritable rings is paused while they are copied, see Snapshot.
+func (pr *Reader) RingSnapshot(cpu int) (*RingSnapshot, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if pr.rings == nil {
+		return nil, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if cpu < 0 || cpu >= len(pr.rings) || pr.rings[cpu] == nil {
+		return nil, fmt.Errorf("perf ringbuffer: no ring for CPU %d", cpu)
+	}
+
+	ring := pr.rings[cpu]
+	if pr.overwritable {
+		if err := ring.pauseOutput(true); err != nil {
+			return nil, err
+		}
+		defer ring.pauseOutput(false)
+	}
+
+	return &RingSnapshot{cpu, ring.unread()}, nil
+}
+
+// RingFD returns the fd of the perf event backing the ring of a CPU, for
+// issuing ioctls like PERF_EVENT_IOC_ID or integrating into an external
+// event loop.
+//
+// This is an advanced interface. The fd is owned by the Reader and closed by
+// Close. Disabling, resetting or redirecting the event interferes with the
+// Reader.
+func (pr *Reader) RingFD(cpu int) (int, error) {
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return -1, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if cpu < 0 || cpu >= len(pr.pauseFds) || pr.pauseFds[cpu] == -1 {
+		return -1, fmt.Errorf("perf ringbuffer: no ring for CPU %d", cpu)
+	}
+
+	return pr.pauseFds[cpu], nil
+}
+
+// RingMeta returns the metadata page of the ring of a CPU, see struct
+// perf_event_mmap_page.
+//
+// This is an advanced and unsafe interface. The page is unmapped by Close,
+// after which accessing it crashes the program. Data_head and Data_tail
+// must be accessed atomically, and modifying Data_tail corrupts the state
+// of the Reader.
+func (pr *Reader) RingMeta(cpu int) (*linux.PerfEventMmapPage, error) {
+	// Close clears rings while holding both locks, so pauseMu is enough
+	// and avoids blocking on a concurrent Read.
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.pauseFds == nil {
+		return nil, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	if cpu < 0 || cpu >= len(pr.rings) || pr.rings[cpu] == nil {
+		return nil, fmt.Errorf("perf ringbuffer: no ring for CPU %d", cpu)
+	}
+
+	return (*linux.PerfEventMmapPage)(unsafe.Pointer(&pr.rings[cpu].mmap[0])), nil
+}
+
+// unread copies the data which the next reads of the ring would return,
+// without changing the state of the reader.
+func (ring *perfEventRing) unread() []byte {
+	var (
+		start, n uint64
+		data     []byte
+	)
+
+	switch rr := ring.ringReader.(type) {
+	case *forwardReader:
+		start = rr.tail
+		n = atomic.LoadUint64(&rr.meta.Data_head) - rr.tail
+		data = rr.ring
+
+	case *reverseReader:
+		// Mirrors loadHead: everything written since the last head, at
+		// most one full ring.
+		start = atomic.LoadUint64(&rr.meta.Data_head)
+		n = rr.head - start
+		if n > uint64(cap(rr.ring)) {
+			n = uint64(cap(rr.ring))
+		}
+		data = rr.ring
+	}
+
+	mask := uint64(cap(data) - 1)
+	buf := make([]byte, n)
+	for copied := uint64(0); copied < n; {
+		off := (start + copied) & mask
+		copied += uint64(copy(buf[copied:], data[off:]))
+	}
+	return buf
+}
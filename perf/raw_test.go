@@ -0,0 +1,112 @@
+package perf
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestRingSnapshotReadAt(t *testing.T) {
+	rs := &RingSnapshot{data: []byte{1, 2, 3}}
+	qt.Assert(t, rs.Size(), qt.Equals, int64(3))
+
+	buf := make([]byte, 2)
+	n, err := rs.ReadAt(buf, 1)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, buf[:n], qt.DeepEquals, []byte{2, 3})
+
+	n, err = rs.ReadAt(buf, 2)
+	qt.Assert(t, err, qt.Equals, io.EOF)
+	qt.Assert(t, buf[:n], qt.DeepEquals, []byte{3})
+
+	_, err = rs.ReadAt(buf, 3)
+	qt.Assert(t, err, qt.Equals, io.EOF)
+
+	_, err = rs.ReadAt(buf, -1)
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestReaderRingSnapshot(t *testing.T) {
+	for _, overwritable := range []bool{false, true} {
+		events := perfEventArray(t)
+		rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{Overwritable: overwritable}, ExtraPerfOptions{})
+		qt.Assert(t, err, qt.IsNil)
+		defer rd.Close()
+
+		_, err = rd.RingSnapshot(-1)
+		qt.Assert(t, err, qt.IsNotNil)
+
+		outputSamples(t, events, 5, 9)
+
+		var rs *RingSnapshot
+		for cpu := range rd.rings {
+			snap, err := rd.RingSnapshot(cpu)
+			if err != nil {
+				continue
+			}
+			if snap.Size() > 0 {
+				rs = snap
+			}
+		}
+		qt.Assert(t, rs, qt.IsNotNil)
+
+		var rec Record
+		sr := io.NewSectionReader(rs, 0, rs.Size())
+		qt.Assert(t, readRecord(sr, &rec, make([]byte, perfEventHeaderSize), overwritable, nil, nil), qt.IsNil)
+		qt.Assert(t, rec.RawSample[perfEventSampleSize], qt.Not(qt.Equals), byte(0))
+
+		// The snapshot doesn't consume records.
+		if overwritable {
+			qt.Assert(t, rd.Pause(), qt.IsNil)
+		}
+		rd.SetDeadline(time.Now().Add(time.Second))
+		want, err := rd.Read()
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, want.RawSample, qt.DeepEquals, rec.RawSample)
+
+		rd.Close()
+		_, err = rd.RingSnapshot(rs.CPU)
+		qt.Assert(t, err, qt.ErrorIs, ErrClosed)
+	}
+}
+
+func TestReaderRingFDAndMeta(t *testing.T) {
+	events := perfEventArray(t)
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	_, err = rd.RingFD(-1)
+	qt.Assert(t, err, qt.IsNotNil)
+	_, err = rd.RingMeta(int(events.MaxEntries()))
+	qt.Assert(t, err, qt.IsNotNil)
+
+	fd, err := rd.RingFD(0)
+	qt.Assert(t, err, qt.IsNil)
+	id, err := eventID(fd)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, id, qt.Not(qt.Equals), uint64(0))
+
+	outputSamples(t, events, 5)
+
+	var head uint64
+	for cpu := 0; cpu < int(events.MaxEntries()); cpu++ {
+		meta, err := rd.RingMeta(cpu)
+		if err != nil {
+			// Offline CPU.
+			continue
+		}
+		head += atomic.LoadUint64(&meta.Data_head)
+	}
+	qt.Assert(t, head, qt.Not(qt.Equals), uint64(0))
+
+	rd.Close()
+	_, err = rd.RingFD(0)
+	qt.Assert(t, errors.Is(err, ErrClosed), qt.IsTrue)
+	_, err = rd.RingMeta(0)
+	qt.Assert(t, errors.Is(err, ErrClosed), qt.IsTrue)
+}
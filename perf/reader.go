@@ -1,13 +1,16 @@
 package perf
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math/bits"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cilium/ebpf"
@@ -20,10 +23,17 @@ import (
 var (
 	ErrClosed = os.ErrClosed
 	errEOR    = errors.New("end of ring")
+	// ErrCorruptRing is returned if a ring contains a record header with an
+	// impossible size. The unread contents of the ring are discarded.
+	ErrCorruptRing = errors.New("perf ringbuffer: corrupt record header")
 )
 
 var perfEventHeaderSize = binary.Size(perfEventHeader{})
 
+// maxPreciseIP is the highest value of the two bit precise_ip field of
+// perf_event_attr.
+const maxPreciseIP = 3
+
 // perfEventHeader must match 'struct perf_event_header` in <linux/perf_event.h>.
 type perfEventHeader struct {
 	Type uint32
@@ -52,6 +62,68 @@ type Record struct {
 	LostSamples  uint64
 	ExtraOptions *ExtraPerfOptions
 	RecordType   uint32
+	// The misc field of the record header, a combination of
+	// PERF_RECORD_MISC_* flags.
+	Misc uint16
+
+	// The PERF_SAMPLE_TIME timestamp of the record in nanoseconds. Only
+	// populated if ExtraPerfOptions.SampleTime is set. See
+	// ExtraPerfOptions.UseClockID for the clock it is taken from.
+	Time uint64
+
+	// The ID of the cgroup v2 the sample was generated in. Only populated
+	// for samples if ExtraPerfOptions.SampleCgroup is set.
+	CgroupID uint64
+
+	// The weight of the sample. Only populated if ExtraPerfOptions.SampleWeight
+	// is set.
+	Weight SampleWeight
+
+	// The outcome of the hardware transaction the sample was taken in. Only
+	// populated if ExtraPerfOptions.SampleTransaction is set.
+	Transaction Transaction
+
+	// The ID of the perf event which generated the sample, and the ID of the
+	// event it inherits from. Only populated if ExtraPerfOptions.SampleID is
+	// set. See Reader.EventAttr.
+	ID       uint64
+	StreamID uint64
+
+	// The process and thread which generated the sample. Only populated if
+	// ExtraPerfOptions.SampleTID is set.
+	Pid, Tid uint32
+
+	// The values of ExtraPerfOptions.Counters at the time the sample was
+	// written, keyed by Counter.Name.
+	Counters map[string]uint64
+
+	// The branches taken before the sample was written, most recent first.
+	// Only populated if ExtraPerfOptions.BranchSampleType is set.
+	Branches []BranchEntry
+
+	// The registers selected by ExtraPerfOptions.Sample_regs_intr at the
+	// time of the sample, in order of their bit. This is the kernel state
+	// if the sample was taken in kernel mode. Empty if registers weren't
+	// available.
+	RegsIntr []uint64
+
+	// The instruction pointer at the time of the sample. Only populated if
+	// ExtraPerfOptions.SampleIP is set.
+	IP uint64
+
+	// The address of the access which triggered a hardware breakpoint. Only
+	// populated for samples of breakpoints, see ExtraPerfOptions.BrkAddr.
+	Addr uint64
+
+	// The user space registers selected by ExtraPerfOptions.Sample_regs_user
+	// at the time of the sample, in order of their bit. Empty if the sample
+	// was taken in a kernel thread.
+	RegsUser []uint64
+
+	// A copy of the user space stack starting at the stack pointer, at most
+	// ExtraPerfOptions.Sample_stack_user bytes long. Only populated if
+	// ExtraPerfOptions.UnwindStack is set. Aliases RawSample.
+	StackUser []byte
 }
 
 type ExtraPerfOptions struct {
@@ -64,15 +136,210 @@ type ExtraPerfOptions struct {
 	BrkType           uint32
 	Sample_regs_user  uint64
 	Sample_stack_user uint32
+	// Sample_regs_intr adds the registers at the time of the interrupt
+	// which generated the sample to samples if non-zero, see
+	// Record.RegsIntr. Unlike Sample_regs_user this includes kernel state.
+	// It is a mask of architecture specific PERF_REG_* bits. This changes
+	// the layout of RawSample.
+	//
+	// Requires at least Linux 3.19.
+	Sample_regs_intr uint64
+	// SampleTime adds a timestamp to every record, see Record.Time. This
+	// changes the layout of RawSample.
+	SampleTime bool
+	// SampleCgroup adds the cgroup ID to samples, see Record.CgroupID, and
+	// enables PERF_RECORD_CGROUP records mapping IDs to paths. This changes
+	// the layout of RawSample.
+	//
+	// Requires at least Linux 5.7.
+	SampleCgroup bool
+	// Namespaces enables PERF_RECORD_NAMESPACES records, which are emitted
+	// whenever a task is created with new namespaces.
+	//
+	// Requires at least Linux 4.12.
+	Namespaces bool
+	// SampleWeight adds a weight to samples, see Record.Weight. Its meaning
+	// depends on the event, for memory access events it is the latency of
+	// the access. This changes the layout of RawSample.
+	SampleWeight bool
+	// SampleWeightStruct requests the weight in the format of
+	// PERF_SAMPLE_WEIGHT_STRUCT, which splits it into several latencies.
+	// Implies SampleWeight.
+	//
+	// Requires at least Linux 5.12.
+	SampleWeightStruct bool
+	// SampleTransaction adds the transaction flags of hardware transactional
+	// memory to samples, see Record.Transaction. This changes the layout of
+	// RawSample.
+	SampleTransaction bool
+	// UseClockID makes the kernel take timestamps from the clock given by
+	// ClockID instead of the perf clock, for example unix.CLOCK_MONOTONIC
+	// to match bpf_ktime_get_ns or unix.CLOCK_BOOTTIME to match
+	// bpf_ktime_get_boot_ns.
+	//
+	// Requires at least Linux 4.1.
+	UseClockID bool
+	ClockID    int32
+	// SampleID adds the ID of the originating perf event to samples, see
+	// Record.ID. This allows telling apart samples of events added to the
+	// Reader via AddEvent. This changes the layout of RawSample.
+	SampleID bool
+	// SampleTID adds the process and thread ID to samples, see Record.Pid.
+	// This is required to filter by process, see Reader.SetFilter. This
+	// changes the layout of RawSample.
+	SampleTID bool
+	// SampleIP adds the instruction pointer to samples, see Record.IP. This
+	// changes the layout of RawSample.
+	SampleIP bool
+	// Counters are opened in a group with the event of each ring, and read
+	// whenever a sample is written. See Record.Counters. This changes the
+	// layout of RawSample.
+	Counters []Counter
+	// BranchSampleType enables sampling of the branch stack if non-zero,
+	// see Record.Branches. It is a combination of PERF_SAMPLE_BRANCH_* flags
+	// selecting which branches to record. Requires hardware support. This
+	// changes the layout of RawSample.
+	BranchSampleType uint64
+	// PMUType and PMUConfig replace the bpf_perf_event_output event backing
+	// each ring with an event of the given PMU, see PMUType.
+	PMUType   uint32
+	PMUConfig uint64
+	// AuxBuffer is the size of the AUX area of each ring in bytes, rounded
+	// up to a power of two pages. The AUX area holds the output of hardware
+	// tracing PMUs like Intel PT and requires setting PMUType accordingly,
+	// see Reader.ReadAux. Not supported for overwritable rings.
+	AuxBuffer int
+	// Task enables PERF_RECORD_FORK and PERF_RECORD_EXIT records.
+	Task bool
+	// ContextSwitch enables PERF_RECORD_SWITCH_CPU_WIDE records, which are
+	// emitted whenever a task is scheduled in or out. See Record.Switch.
+	//
+	// Requires at least Linux 4.3.
+	ContextSwitch bool
+	// Ksymbol enables PERF_RECORD_KSYMBOL records, which are emitted when
+	// kernel symbols like JITed BPF programs are added or removed. See
+	// Record.Ksymbol.
+	//
+	// Requires at least Linux 5.1.
+	Ksymbol bool
+	// BPFEvent enables PERF_RECORD_BPF_EVENT records, which are emitted
+	// when BPF programs are loaded or unloaded. See Record.BPFEvent.
+	//
+	// Requires at least Linux 5.1.
+	BPFEvent bool
+	// Cgroup is an open cgroup v2 directory. If set, the events backing the
+	// rings only count while a task of the cgroup or its descendants runs,
+	// so that bpf_perf_event_output only succeeds from within the cgroup.
+	// Can't be combined with BrkAddr.
+	Cgroup *os.File
+	// Pid restricts the events backing the rings to a single thread, so
+	// that bpf_perf_event_output only succeeds while it runs. Zero means
+	// all threads. Can't be combined with Cgroup and is ignored if BrkAddr
+	// is set, use BrkPid instead.
+	Pid int
+	// Inherit copies the events backing the rings to threads and processes
+	// created by Pid or BrkPid after the Reader was created. Their records
+	// are written to the same rings.
+	Inherit bool
+	// PreciseIP requests the skid constraint of samples from 0 (arbitrary
+	// skid) to 3 (no skid), see the precise_ip field of perf_event_attr.
+	// The precision is lowered until the PMU accepts it, see
+	// Reader.PreciseIP.
+	PreciseIP uint8
+	// ExcludeKernel, ExcludeUser, ExcludeHv and ExcludeIdle stop the events
+	// backing the rings from counting in the respective context. Sampling
+	// without CAP_PERFMON requires ExcludeKernel if perf_event_paranoid is
+	// 2 or higher.
+	ExcludeKernel bool
+	ExcludeUser   bool
+	ExcludeHv     bool
+	ExcludeIdle   bool
+}
+
+// sampleLayout describes the position of optional fields in records, which
+// depends on the sample_type of the perf event.
+type sampleLayout struct {
+	// Offset of the timestamp in the body of PERF_RECORD_SAMPLE, or -1.
+	sampleTime int
+	// Offset of the timestamp from the end of other records, or -1. Those
+	// carry a struct sample_id trailer if sample_id_all is set.
+	idTime int
+
+	// Information required to locate fields following variable sized ones,
+	// see sampleLayout.field.
+	sampleType    uint64
+	readFormat    uint64
+	regsUser      int
+	regsIntr      int
+	branchHWIndex bool
+
+	// counterNames maps the IDs of ExtraPerfOptions.Counters to their names.
+	counterNames map[uint64]string
+}
+
+func newSampleLayout(attr *linux.PerfEventAttr) *sampleLayout {
+	layout := &sampleLayout{
+		sampleTime:    -1,
+		idTime:        -1,
+		sampleType:    attr.Sample_type,
+		readFormat:    attr.Read_format,
+		regsUser:      bits.OnesCount64(attr.Sample_regs_user),
+		regsIntr:      bits.OnesCount64(attr.Sample_regs_intr),
+		branchHWIndex: attr.Branch_sample_type&linux.PERF_SAMPLE_BRANCH_HW_INDEX != 0,
+	}
+	if attr.Sample_type&linux.PERF_SAMPLE_TIME == 0 {
+		return layout
+	}
+
+	// Fields preceding the timestamp, see perf_event_open(2).
+	layout.sampleTime = 0
+	for _, bit := range []uint64{linux.PERF_SAMPLE_IDENTIFIER, linux.PERF_SAMPLE_IP, linux.PERF_SAMPLE_TID} {
+		if attr.Sample_type&bit != 0 {
+			layout.sampleTime += 8
+		}
+	}
+
+	if attr.Bits&linux.PerfBitSampleIDAll != 0 {
+		// Fields following the timestamp in struct sample_id.
+		layout.idTime = 8
+		for _, bit := range []uint64{linux.PERF_SAMPLE_ID, linux.PERF_SAMPLE_STREAM_ID, linux.PERF_SAMPLE_CPU, linux.PERF_SAMPLE_IDENTIFIER} {
+			if attr.Sample_type&bit != 0 {
+				layout.idTime += 8
+			}
+		}
+	}
+
+	return layout
+}
+
+// time extracts the timestamp from the body of a record.
+func (sl *sampleLayout) time(typ uint32, body []byte) uint64 {
+	if sl == nil {
+		return 0
+	}
+
+	off := sl.sampleTime
+	if typ != unix.PERF_RECORD_SAMPLE {
+		if sl.idTime < 0 {
+			return 0
+		}
+		off = len(body) - sl.idTime
+	}
+
+	if off < 0 || off+8 > len(body) {
+		return 0
+	}
+	return internal.NativeEndian.Uint64(body[off:])
 }
 
 // Read a record from a reader and tag it as being from the given CPU.
 //
-// buf must be at least perfEventHeaderSize bytes long.
-func readRecord(rd io.Reader, rec *Record, buf []byte, overwritable bool) error {
+// buf must be at least perfEventHeaderSize bytes long. layout may be nil if
+// records don't carry timestamps. alloc may be nil to allocate on the heap.
+func readRecord(rd io.Reader, rec *Record, buf []byte, overwritable bool, layout *sampleLayout, alloc Allocator) (err error) {
 	// Assert that the buffer is large enough.
 	buf = buf[:perfEventHeaderSize]
-	_, err := io.ReadFull(rd, buf)
+	_, err = io.ReadFull(rd, buf)
 	if errors.Is(err, io.EOF) {
 		return errEOR
 	} else if err != nil {
@@ -85,35 +352,65 @@ func readRecord(rd io.Reader, rec *Record, buf []byte, overwritable bool) error
 		internal.NativeEndian.Uint16(buf[6:8]),
 	}
 	rec.RecordType = header.Type
+	rec.Misc = header.Misc
+	rec.Time = 0
+	rec.CgroupID = 0
+	rec.Weight = SampleWeight{}
+	rec.Transaction = 0
+	rec.ID, rec.StreamID = 0, 0
+	rec.Pid, rec.Tid = 0, 0
+	for name := range rec.Counters {
+		delete(rec.Counters, name)
+	}
+	rec.Branches = rec.Branches[:0]
+	rec.RegsIntr = rec.RegsIntr[:0]
+	rec.IP, rec.Addr = 0, 0
+	rec.RegsUser = rec.RegsUser[:0]
+	rec.StackUser = nil
+	defer func() {
+		if err == nil && header.Type != unix.PERF_RECORD_LOST {
+			rec.Time = layout.time(header.Type, rec.RawSample)
+		}
+		if err == nil && header.Type == unix.PERF_RECORD_SAMPLE {
+			layout.decodeSample(rec)
+		}
+	}()
 
 	switch header.Type {
 	case unix.PERF_RECORD_LOST:
 		rec.RawSample = rec.RawSample[:0]
-		rec.LostSamples, err = readLostRecords(rd)
+		rec.LostSamples, rec.Time, err = readLostRecords(rd, header, layout)
 		return err
 
 	case unix.PERF_RECORD_SAMPLE:
 		rec.LostSamples = 0
 		// We can reuse buf here because perfEventHeaderSize > perfEventSampleSize.
 		// err = readRawSample(rd, buf, rec)
-		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header)
+		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header, alloc)
 		return err
 
 	case linux.PERF_RECORD_MMAP2:
 		// 直接完整读取 解析工作后面做 免得对当前模块做大改动
-		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header)
+		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header, alloc)
 		return err
 
 	case linux.PERF_RECORD_EXIT:
-		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header)
+		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header, alloc)
 		return err
 
 	case linux.PERF_RECORD_FORK:
-		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header)
+		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header, alloc)
 		return err
 
 	case linux.PERF_RECORD_COMM:
-		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header)
+		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header, alloc)
+		return err
+
+	case linux.PERF_RECORD_NAMESPACES, linux.PERF_RECORD_CGROUP,
+		linux.PERF_RECORD_AUX, linux.PERF_RECORD_ITRACE_START,
+		linux.PERF_RECORD_SWITCH, linux.PERF_RECORD_SWITCH_CPU_WIDE,
+		linux.PERF_RECORD_KSYMBOL, linux.PERF_RECORD_BPF_EVENT:
+		rec.RawSample, err = readLeftFull(rd, rec.RawSample, header, alloc)
 		return err
 
 	default:
@@ -121,7 +418,7 @@ func readRecord(rd io.Reader, rec *Record, buf []byte, overwritable bool) error
 	}
 }
 
-func readLostRecords(rd io.Reader) (uint64, error) {
+func readLostRecords(rd io.Reader, header perfEventHeader, layout *sampleLayout) (lost, time uint64, _ error) {
 	// lostHeader must match 'struct perf_event_lost in kernel sources.
 	var lostHeader struct {
 		ID   uint64
@@ -130,10 +427,23 @@ func readLostRecords(rd io.Reader) (uint64, error) {
 
 	err := binary.Read(rd, internal.NativeEndian, &lostHeader)
 	if err != nil {
-		return 0, fmt.Errorf("can't read lost records header: %v", err)
+		return 0, 0, fmt.Errorf("can't read lost records header: %v", err)
+	}
+
+	// Consume the struct sample_id trailer, if any.
+	var trailer [64]byte
+	n := int(header.Size) - perfEventHeaderSize - binary.Size(lostHeader)
+	if n <= 0 {
+		return lostHeader.Lost, 0, nil
+	}
+	if n > len(trailer) {
+		return 0, 0, fmt.Errorf("lost record trailer of %d bytes is too long", n)
+	}
+	if _, err := io.ReadFull(rd, trailer[:n]); err != nil {
+		return 0, 0, fmt.Errorf("can't read lost records trailer: %v", err)
 	}
 
-	return lostHeader.Lost, nil
+	return lostHeader.Lost, layout.time(unix.PERF_RECORD_LOST, trailer[:n]), nil
 }
 
 var perfEventSampleSize = binary.Size(uint32(0))
@@ -143,14 +453,52 @@ type perfEventSample struct {
 	Size uint32
 }
 
-func readLeftFull(rd io.Reader, sampleBuf []byte, header perfEventHeader) ([]byte, error) {
-	buf := make([]byte, int(header.Size)-perfEventHeaderSize)
+// readLeftFull reads the remainder of the record described by header into buf,
+// growing it if necessary.
+//
+// If rd is a ringView the returned slice aliases the ring instead.
+func readLeftFull(rd io.Reader, buf []byte, header perfEventHeader, alloc Allocator) ([]byte, error) {
+	size := int(header.Size) - perfEventHeaderSize
+	if size < 0 {
+		return buf[:0], fmt.Errorf("record size %d is smaller than header", header.Size)
+	}
+
+	if rv, ok := rd.(*ringView); ok {
+		if view := rv.view(size); view != nil {
+			return view, nil
+		}
+
+		// The record wraps around the end of the ring, fall back to
+		// copying into a buffer owned by the Reader.
+		buf = rv.scratch
+		defer func() { rv.scratch = buf[:0] }()
+	}
+
+	if cap(buf) >= size {
+		buf = buf[:size]
+	} else if alloc != nil {
+		buf = alloc(size)
+		if cap(buf) < size {
+			return nil, fmt.Errorf("allocator returned %d bytes instead of %d", cap(buf), size)
+		}
+		buf = buf[:size]
+	} else {
+		buf = make([]byte, size)
+	}
+
 	if _, err := io.ReadFull(rd, buf); err != nil {
-		return sampleBuf[:0], fmt.Errorf("readLeftFull err: %v", err)
+		return buf[:0], fmt.Errorf("readLeftFull err: %w", err)
 	}
 	return buf, nil
 }
 
+// ringView reads records from a ring without copying their bodies.
+type ringView struct {
+	ringReader
+	// scratch holds records which wrap around the end of the ring.
+	scratch []byte
+}
+
 func readRawSample(rd io.Reader, buf []byte, rec *Record) error {
 	buf = buf[:perfEventSampleSize]
 	if _, err := io.ReadFull(rd, buf); err != nil {
@@ -190,7 +538,7 @@ func readRawSample(rd io.Reader, buf []byte, rec *Record) error {
 // Reader allows reading bpf_perf_event_output
 // from user space.
 type Reader struct {
-	poller   *epoll.Poller
+	poller   poller
 	deadline time.Time
 
 	// mu protects read/write access to the Reader structure with the
@@ -214,6 +562,53 @@ type Reader struct {
 
 	paused       bool
 	overwritable bool
+	attr         linux.PerfEventAttr
+	layout       *sampleLayout
+	// pauseOutput is set if the output of rings in epollRings is paused
+	// while they are read.
+	pauseOutput bool
+
+	// viewRing is the ring which holds the record returned by the last call
+	// to ReadView. Its tail is committed by the next read.
+	viewRing *perfEventRing
+	view     ringView
+
+	// events maps the IDs of the perf events writing into the rings to their
+	// attributes. Only populated if ExtraPerfOptions.SampleID is set.
+	// Protected by pauseMu, like eventFds.
+	events map[uint64]linux.PerfEventAttr
+	// eventFds are the perf events added via AddEvent.
+	eventFds []int
+
+	// filter holds the *recordFilter set via SetFilter.
+	filter atomic.Value
+	// transform holds the Transform set via SetTransform.
+	transform atomic.Value
+	// viewCopy holds the sample returned by ReadView if a Transform is set.
+	viewCopy []byte
+
+	maxRecordSize int
+	alloc         Allocator
+	// oversized counts records discarded due to maxRecordSize. Accessed
+	// atomically.
+	oversized uint64
+	// corrupt counts occurrences of ErrCorruptRing. Accessed atomically.
+	corrupt uint64
+	// lost counts samples reported lost by the kernel. Accessed atomically.
+	lost uint64
+
+	busyPoll          time.Duration
+	busyPollThreshold int
+
+	// The configuration the Reader was created with, for CloneForPid.
+	// eopts.PreciseIP is the value accepted by the PMU.
+	perCPUBuffer int
+	opts         ReaderOptions
+	eopts        ExtraPerfOptions
+	// lastPoll and pollRate track how often the rings are polled, in
+	// polls per second. Protected by mu.
+	lastPoll time.Time
+	pollRate float64
 }
 
 // ReaderOptions control the behaviour of the user
@@ -223,11 +618,63 @@ type ReaderOptions struct {
 	// Read will process data. Must be smaller than PerCPUBuffer.
 	// The default is to start processing as soon as data is available.
 	Watermark int
+	// The number of records required in any per CPU buffer before Read will
+	// process data. This is mutually exclusive with Watermark and suits
+	// small, fixed size samples better.
+	WakeupEvents int
 	// This perf ring buffer is overwritable, once full the oldest event will be
 	// overwritten by newest.
 	Overwritable bool
+	// PauseOutput stops the kernel from writing to an overwritable ring while
+	// it is being read, which would otherwise tear records. Records submitted
+	// to the ring in the meantime are lost. Output is resumed once all records
+	// of the ring have been read.
+	//
+	// Requires Overwritable.
+	PauseOutput bool
+	// MaxRecordSize is the size in bytes, including the header, above which
+	// records are discarded instead of being returned. Discarded records
+	// are counted, see Reader.OversizedRecords. The default is no limit.
+	MaxRecordSize int
+	// BusyPoll enables adaptive polling. If the Reader waits for records
+	// more often than BusyPollThreshold times per second, it spins for up
+	// to BusyPoll checking the rings for data before falling back to epoll.
+	// This trades CPU time for fewer wakeups at high event rates, and
+	// ignores Watermark and WakeupEvents while spinning. Not supported for
+	// overwritable rings.
+	BusyPoll          time.Duration
+	BusyPollThreshold int
+	// Backend selects how the Reader waits for rings to become readable.
+	// Defaults to BackendEpoll.
+	Backend Backend
+	// Allocator provides buffers for Record.RawSample when the buffer of a
+	// Record passed to ReadInto is too small, instead of allocating them on
+	// the Go heap. This allows using arenas or free lists to reduce the
+	// load on the garbage collector. Buffers are never handed back, callers
+	// recycle them via the Records they read into.
+	Allocator Allocator
+	// CheckMemlock compares the locked memory needed by the rings with
+	// MemlockBudget before creating them, and fails with a MemlockError
+	// instead of an opaque EPERM from mmap if it is exceeded.
+	CheckMemlock bool
 }
 
+// Allocator returns a buffer with a capacity of at least size bytes.
+type Allocator func(size int) []byte
+
+// Backend is a mechanism to wait for rings to become readable.
+type Backend int
+
+const (
+	// BackendEpoll uses epoll.
+	BackendEpoll Backend = iota
+	// BackendIOUring uses io_uring multishot poll requests, which may
+	// reduce the number of syscalls per wakeup when waiting on many rings.
+	//
+	// Experimental, requires at least Linux 5.13.
+	BackendIOUring
+)
+
 // NewReader creates a new reader with default options.
 //
 // array must be a PerfEventArray. perCPUBuffer gives the size of the
@@ -239,18 +686,112 @@ func NewReader(array *ebpf.Map, perCPUBuffer int) (*Reader, error) {
 
 // NewReaderWithOptions creates a new reader with the given options.
 func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions, eopts ExtraPerfOptions) (pr *Reader, err error) {
+	return newReader(array, int(array.MaxEntries()), perCPUBuffer, opts, eopts)
+}
+
+// NewSidebandReader creates a reader which only receives records about the
+// lifecycle of processes on the system: PERF_RECORD_COMM, PERF_RECORD_FORK,
+// PERF_RECORD_EXIT and PERF_RECORD_MMAP2. It is not associated with a
+// PerfEventArray and doesn't receive samples.
+//
+// The rings are backed by PERF_COUNT_SW_DUMMY events, eopts.PMUType and
+// eopts.PMUConfig are ignored. Pause and Resume disable and enable the events.
+func NewSidebandReader(perCPUBuffer int, opts ReaderOptions, eopts ExtraPerfOptions) (*Reader, error) {
+	nCPU, err := internal.PossibleCPUs()
+	if err != nil {
+		return nil, err
+	}
+
+	eopts.PMUType = linux.PERF_TYPE_SOFTWARE
+	eopts.PMUConfig = linux.PERF_COUNT_SW_DUMMY
+	eopts.PerfMmap = true
+	eopts.Task = true
+	return newReader(nil, nCPU, perCPUBuffer, opts, eopts)
+}
+
+// CloneForPid creates a new Reader with the same configuration and buffer
+// sizes as pr, whose events are restricted to the thread pid. See
+// ExtraPerfOptions.Pid and ExtraPerfOptions.BrkPid.
+//
+// A Reader created via NewReaderWithOptions is cloned onto a new
+// PerfEventArray of the same size, since each array slot holds a single
+// event. BPF programs writing to the original array don't output to the
+// clone.
+func (pr *Reader) CloneForPid(pid int) (*Reader, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid pid %d", pid)
+	}
+
+	pr.pauseMu.Lock()
+	closed := pr.pauseFds == nil
+	nCPU := len(pr.pauseFds)
+	pr.pauseMu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	eopts := pr.eopts
+	if eopts.BrkAddr != 0 {
+		eopts.BrkPid = pid
+	} else {
+		eopts.Pid = pid
+	}
+
+	if pr.array == nil {
+		return newReader(nil, nCPU, pr.perCPUBuffer, pr.opts, eopts)
+	}
+
+	array, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.PerfEventArray,
+		MaxEntries: pr.array.MaxEntries(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create perf event array: %w", err)
+	}
+	// newReader keeps a clone of the array.
+	defer array.Close()
+
+	return newReader(array, nCPU, pr.perCPUBuffer, pr.opts, eopts)
+}
+
+func newReader(array *ebpf.Map, nCPU, perCPUBuffer int, opts ReaderOptions, eopts ExtraPerfOptions) (pr *Reader, err error) {
 	if perCPUBuffer < 1 {
 		return nil, errors.New("perCPUBuffer must be larger than 0")
 	}
+	if opts.Watermark > 0 && opts.WakeupEvents > 0 {
+		return nil, errors.New("Watermark and WakeupEvents are mutually exclusive")
+	}
+	if eopts.AuxBuffer > 0 && opts.Overwritable {
+		return nil, errors.New("AuxBuffer is not supported for overwritable rings")
+	}
+	if eopts.Cgroup != nil && eopts.BrkAddr != 0 {
+		return nil, errors.New("Cgroup and BrkAddr are mutually exclusive")
+	}
+	if eopts.Cgroup != nil && eopts.Pid != 0 {
+		return nil, errors.New("Cgroup and Pid are mutually exclusive")
+	}
+	if eopts.PreciseIP > maxPreciseIP {
+		return nil, fmt.Errorf("PreciseIP %d exceeds %d", eopts.PreciseIP, maxPreciseIP)
+	}
+	if opts.BusyPoll > 0 && opts.Overwritable {
+		return nil, errors.New("BusyPoll is not supported for overwritable rings")
+	}
+	if opts.PauseOutput && !opts.Overwritable {
+		return nil, errors.New("PauseOutput requires Overwritable")
+	}
+	if opts.CheckMemlock {
+		if err := checkMemlock(RingMemory(nCPU, perCPUBuffer, eopts)); err != nil {
+			return nil, err
+		}
+	}
 
 	var (
 		fds      []int
-		nCPU     = int(array.MaxEntries())
 		rings    = make([]*perfEventRing, 0, nCPU)
 		pauseFds = make([]int, 0, nCPU)
 	)
 
-	poller, err := epoll.New()
+	poller, err := newPoller(opts.Backend, nCPU)
 	if err != nil {
 		return nil, err
 	}
@@ -269,11 +810,35 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions,
 		}
 	}()
 
+	// The PMU is shared by all CPUs, so the precision it accepts is only
+	// probed until the first ring was created.
+	var (
+		created      bool
+		precisionErr error
+	)
+
 	// bpf_perf_event_output checks which CPU an event is enabled on,
 	// but doesn't allow using a wildcard like -1 to specify "all CPUs".
 	// Hence we have to create a ring for each CPU.
 	for i := 0; i < nCPU; i++ {
-		ring, err := newPerfEventRing(i, perCPUBuffer, opts.Watermark, opts.Overwritable, eopts)
+		ring, err := newPerfEventRing(i, perCPUBuffer, opts, eopts)
+		if !created && (errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL)) {
+			if precisionErr == nil {
+				precisionErr = err
+			}
+
+			if eopts.PreciseIP > 0 {
+				// The PMU may not support the requested precision, try a
+				// lower one. This is retried at most maxPreciseIP times.
+				eopts.PreciseIP--
+				i--
+				continue
+			}
+
+			// The error isn't caused by the precision, report the one
+			// for the requested value.
+			return nil, fmt.Errorf("failed to create perf ring for CPU %d: %w", i, precisionErr)
+		}
 		if errors.Is(err, unix.ENODEV) {
 			// The requested CPU is currently offline, skip it.
 			rings = append(rings, nil)
@@ -282,8 +847,9 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions,
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("failed to create perf ring for CPU %d: %v", i, err)
+			return nil, fmt.Errorf("failed to create perf ring for CPU %d: %w", i, err)
 		}
+		created = true
 		rings = append(rings, ring)
 		pauseFds = append(pauseFds, ring.fd)
 
@@ -292,12 +858,49 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions,
 		}
 	}
 
-	array, err = array.Clone()
-	if err != nil {
-		return nil, err
+	attr := perfEventAttr(opts, eopts)
+
+	var events map[uint64]linux.PerfEventAttr
+	if eopts.SampleID {
+		events = make(map[uint64]linux.PerfEventAttr)
+		for _, ring := range rings {
+			if ring == nil {
+				continue
+			}
+
+			id, err := eventID(ring.fd)
+			if err != nil {
+				return nil, err
+			}
+			events[id] = attr
+		}
+	}
+
+	layout := newSampleLayout(&attr)
+	for _, ring := range rings {
+		if ring == nil || ring.counterIDs == nil {
+			continue
+		}
+
+		if layout.counterNames == nil {
+			layout.counterNames = make(map[uint64]string)
+		}
+		for id, name := range ring.counterIDs {
+			layout.counterNames[id] = name
+		}
+	}
+
+	if array != nil {
+		array, err = array.Clone()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	pr = &Reader{
+		attr:         attr,
+		layout:       layout,
+		events:       events,
 		array:        array,
 		rings:        rings,
 		poller:       poller,
@@ -307,6 +910,17 @@ func NewReaderWithOptions(array *ebpf.Map, perCPUBuffer int, opts ReaderOptions,
 		eventHeader:  make([]byte, perfEventHeaderSize),
 		pauseFds:     pauseFds,
 		overwritable: opts.Overwritable,
+		pauseOutput:  opts.PauseOutput,
+
+		maxRecordSize: opts.MaxRecordSize,
+		alloc:         opts.Allocator,
+
+		busyPoll:          opts.BusyPoll,
+		busyPollThreshold: opts.BusyPollThreshold,
+
+		perCPUBuffer: perCPUBuffer,
+		opts:         opts,
+		eopts:        eopts,
 	}
 	if err = pr.Resume(); err != nil {
 		return nil, err
@@ -334,19 +948,30 @@ func (pr *Reader) Close() error {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
 
+	pr.pauseMu.Lock()
 	for _, ring := range pr.rings {
 		if ring != nil {
 			ring.Close()
 		}
 	}
+	for _, fd := range pr.eventFds {
+		_ = unix.Close(fd)
+	}
 	pr.rings = nil
+	pr.eventFds = nil
 	pr.pauseFds = nil
-	pr.array.Close()
+	pr.pauseMu.Unlock()
+
+	pr.viewRing = nil
+	if pr.array != nil {
+		pr.array.Close()
+	}
 
 	return nil
 }
 
-// SetDeadline controls how long Read and ReadInto will block waiting for samples.
+// SetDeadline controls how long Read, ReadInto and ReadContext will block waiting
+// for samples.
 //
 // Passing a zero time.Time will remove the deadline. Passing a deadline in the
 // past will prevent the reader from blocking if there are no records to be read.
@@ -371,7 +996,7 @@ func (pr *Reader) SetDeadline(t time.Time) {
 // Returns os.ErrDeadlineExceeded if a deadline was set.
 func (pr *Reader) Read() (Record, error) {
 	var r Record
-	r.ExtraOptions = &ExtraPerfOptions{false, false, false, -1, 0, 0, 0, 0, 0}
+	r.ExtraOptions = &ExtraPerfOptions{BrkPid: -1}
 	// r.UnwindStack = false
 	// r.ShowRegs = false
 	return r, pr.ReadInto(&r)
@@ -383,10 +1008,29 @@ func (pr *Reader) ReadWithExtraOptions(opt *ExtraPerfOptions) (Record, error) {
 	return r, pr.ReadInto(&r)
 }
 
+// ReadContext is like Read except that it returns ctx.Err() as soon as ctx is
+// cancelled.
+//
+// The deadline set via SetDeadline still applies.
+func (pr *Reader) ReadContext(ctx context.Context) (Record, error) {
+	var r Record
+	r.ExtraOptions = &ExtraPerfOptions{BrkPid: -1}
+	return r, pr.readInto(ctx, &r)
+}
+
 var errMustBePaused = fmt.Errorf("perf ringbuffer: must have been paused before reading overwritable buffer")
 
 // ReadInto is like Read except that it allows reusing Record and associated buffers.
+//
+// rec.RawSample is overwritten in place if it has enough capacity, and is
+// only reallocated if the record doesn't fit. The contents of rec.RawSample
+// are therefore stable until the next call to ReadInto with the same rec.
+// Callers that retain samples must copy them.
 func (pr *Reader) ReadInto(rec *Record) error {
+	return pr.readInto(context.Background(), rec)
+}
+
+func (pr *Reader) readInto(ctx context.Context, rec *Record) error {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
 
@@ -401,31 +1045,13 @@ func (pr *Reader) ReadInto(rec *Record) error {
 		return fmt.Errorf("perf ringbuffer: %w", ErrClosed)
 	}
 
+	pr.releaseView()
+
 	for {
 		if len(pr.epollRings) == 0 {
-			// NB: The deferred pauseMu.Unlock will panic if Wait panics, which
-			// might obscure the original panic.
-			pr.pauseMu.Unlock()
-			nEvents, err := pr.poller.Wait(pr.epollEvents, pr.deadline)
-			pr.pauseMu.Lock()
-			if err != nil {
+			if err := pr.pollRings(ctx); err != nil {
 				return err
 			}
-
-			// Re-validate pr.paused since we dropped pauseMu.
-			if pr.overwritable && !pr.paused {
-				return errMustBePaused
-			}
-
-			for _, event := range pr.epollEvents[:nEvents] {
-				ring := pr.rings[cpuForEvent(&event)]
-				pr.epollRings = append(pr.epollRings, ring)
-
-				// Read the current head pointer now, not every time
-				// we read a record. This prevents a single fast producer
-				// from keeping the reader busy.
-				ring.loadHead()
-			}
 		}
 
 		// Start at the last available event. The order in which we
@@ -435,12 +1061,274 @@ func (pr *Reader) ReadInto(rec *Record) error {
 		if err == errEOR {
 			// We've emptied the current ring buffer, process
 			// the next one.
-			pr.epollRings = pr.epollRings[:len(pr.epollRings)-1]
+			if err := pr.popRing(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == nil && !pr.accept(rec) {
+			continue
+		}
+
+		return err
+	}
+}
+
+// ReadBatch reads multiple records at once, reusing the buffers of recs.
+//
+// It blocks until at least one record is available and then fills recs with
+// as many records as are available without blocking again. The tail of each
+// ring is only committed once all available records have been consumed from
+// it or recs is full, which makes ReadBatch cheaper than repeated calls to
+// ReadInto under high event rates.
+//
+// The buffers of recs are reused as described in ReadInto.
+//
+// Returns the number of records read. n may be non-zero even if an error is
+// returned.
+func (pr *Reader) ReadBatch(recs []Record) (n int, err error) {
+	if len(recs) == 0 {
+		return 0, nil
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.overwritable && !pr.paused {
+		return 0, errMustBePaused
+	}
+
+	if pr.rings == nil {
+		return 0, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	pr.releaseView()
+
+	defer func() {
+		// Commit the progress made on rings which still contain data.
+		for _, ring := range pr.epollRings {
+			ring.writeTail()
+		}
+	}()
+
+	for n < len(recs) {
+		if len(pr.epollRings) == 0 {
+			if n > 0 {
+				break
+			}
+
+			if err := pr.pollRings(context.Background()); err != nil {
+				return 0, err
+			}
+		}
+
+		ring := pr.epollRings[len(pr.epollRings)-1]
+		err := pr.readFromRing(&recs[n], ring)
+		if err == errEOR {
+			ring.writeTail()
+			if err := pr.popRing(); err != nil {
+				return n, err
+			}
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+		if !pr.accept(&recs[n]) {
 			continue
 		}
 
+		n++
+	}
+
+	return n, nil
+}
+
+// ReadView is like ReadInto, except that rec.RawSample points directly into
+// the memory shared with the kernel instead of being copied. Records which
+// wrap around the end of a ring are copied into a buffer owned by the Reader.
+//
+// The kernel may overwrite rec.RawSample as soon as the Reader is used again
+// or closed, since the space occupied by the record is only handed back to the
+// kernel at that point. Callers must decode or copy the sample before the next
+// call to any of the Read methods. Modifying rec.RawSample is not allowed.
+// If a Transform is set, samples are always copied.
+//
+// For the same reason, rec must not be passed to ReadInto or ReadBatch
+// afterwards without setting rec.RawSample to nil first.
+func (pr *Reader) ReadView(rec *Record) error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	if pr.overwritable && !pr.paused {
+		return errMustBePaused
+	}
+
+	if pr.rings == nil {
+		return fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	pr.releaseView()
+
+	for {
+		if len(pr.epollRings) == 0 {
+			if err := pr.pollRings(context.Background()); err != nil {
+				return err
+			}
+		}
+
+		ring := pr.epollRings[len(pr.epollRings)-1]
+		rec.CPU = ring.cpu
+		if err := pr.skipInvalid(ring); err != nil {
+			return err
+		}
+		pr.view.ringReader = ring.ringReader
+		err := readRecord(&pr.view, rec, pr.eventHeader, pr.overwritable, pr.layout, pr.alloc)
+		pr.view.ringReader = nil
+		pr.countLost(rec, err)
+		if pr.overwritable && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			err = errEOR
+		}
+		if err == errEOR {
+			ring.writeTail()
+			if err := pr.popRing(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err == nil && pr.transforms() {
+			// Transforms may modify the sample, which mustn't happen in the ring.
+			pr.viewCopy = append(pr.viewCopy[:0], rec.RawSample...)
+			rec.RawSample = pr.viewCopy
+		}
+		if err == nil && !pr.accept(rec) {
+			continue
+		}
+
+		// Don't commit the tail until the caller is done with the view.
+		pr.viewRing = ring
+		return err
+	}
+}
+
+// releaseView hands the space occupied by the record returned from ReadView
+// back to the kernel.
+//
+// pr.mu must be held.
+func (pr *Reader) releaseView() {
+	if pr.viewRing != nil {
+		pr.viewRing.writeTail()
+		pr.viewRing = nil
+	}
+}
+
+// pollRings waits until at least one ring has data and queues it in
+// epollRings.
+//
+// pr.mu and pr.pauseMu must be held. pauseMu is released while waiting.
+func (pr *Reader) pollRings(ctx context.Context) error {
+	// NB: The deferred pauseMu.Unlock in the caller will panic if Wait
+	// panics, which might obscure the original panic.
+	pr.pauseMu.Unlock()
+	if pr.busyPolling() {
+		if ready := pr.spin(ctx); len(ready) > 0 {
+			pr.pauseMu.Lock()
+			for _, ring := range ready {
+				pr.epollRings = append(pr.epollRings, ring)
+				ring.loadHead()
+			}
+			return nil
+		}
+	}
+	nEvents, err := pr.wait(ctx)
+	pr.pauseMu.Lock()
+	if err != nil {
 		return err
 	}
+
+	// Re-validate pr.paused since we dropped pauseMu.
+	if pr.overwritable && !pr.paused {
+		return errMustBePaused
+	}
+
+	for _, event := range pr.epollEvents[:nEvents] {
+		ring := pr.rings[cpuForEvent(&event)]
+		if pr.pauseOutput {
+			if err := ring.pauseOutput(true); err != nil {
+				return err
+			}
+		}
+		pr.epollRings = append(pr.epollRings, ring)
+
+		// Read the current head pointer now, not every time
+		// we read a record. This prevents a single fast producer
+		// from keeping the reader busy.
+		ring.loadHead()
+	}
+
+	return nil
+}
+
+// popRing removes a drained ring from epollRings.
+//
+// pr.mu must be held.
+func (pr *Reader) popRing() error {
+	ring := pr.epollRings[len(pr.epollRings)-1]
+	pr.epollRings = pr.epollRings[:len(pr.epollRings)-1]
+
+	if pr.pauseOutput {
+		return ring.pauseOutput(false)
+	}
+	return nil
+}
+
+// wait blocks until one of the rings has data, the deadline expires or ctx is
+// cancelled.
+func (pr *Reader) wait(ctx context.Context) (int, error) {
+	for {
+		nEvents, err := pr.waitOnce(ctx)
+		if errors.Is(err, epoll.ErrInterrupted) {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+
+			// The interrupt was issued on behalf of a previous call whose
+			// context was cancelled after it returned.
+			continue
+		}
+
+		return nEvents, err
+	}
+}
+
+func (pr *Reader) waitOnce(ctx context.Context) (int, error) {
+	done := ctx.Done()
+	if done == nil {
+		return pr.poller.Wait(pr.epollEvents, pr.deadline)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// Forward cancellation of ctx to the poller.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-done:
+			_ = pr.poller.Interrupt()
+		case <-stop:
+		}
+	}()
+
+	return pr.poller.Wait(pr.epollEvents, pr.deadline)
 }
 
 // Pause stops all notifications from this Reader.
@@ -457,6 +1345,14 @@ func (pr *Reader) Pause() error {
 		return fmt.Errorf("%w", ErrClosed)
 	}
 
+	if pr.array == nil {
+		if err := ioctlRings(pr.pauseFds, unix.PERF_EVENT_IOC_DISABLE, 0); err != nil {
+			return err
+		}
+		pr.paused = true
+		return nil
+	}
+
 	for i := range pr.pauseFds {
 		if err := pr.array.Delete(uint32(i)); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
 			return fmt.Errorf("could't delete event fd for CPU %d: %w", i, err)
@@ -479,6 +1375,14 @@ func (pr *Reader) Resume() error {
 		return fmt.Errorf("%w", ErrClosed)
 	}
 
+	if pr.array == nil {
+		if err := ioctlRings(pr.pauseFds, unix.PERF_EVENT_IOC_ENABLE, 0); err != nil {
+			return err
+		}
+		pr.paused = false
+		return nil
+	}
+
 	for i, fd := range pr.pauseFds {
 		if fd == -1 {
 			continue
@@ -494,18 +1398,169 @@ func (pr *Reader) Resume() error {
 	return nil
 }
 
+// skipInvalid discards data at the read position of ring which can't be
+// returned as a record.
+//
+// Returns ErrCorruptRing if the ring was discarded.
+func (pr *Reader) skipInvalid(ring *perfEventRing) error {
+	for {
+		ring.resync()
+		if err := ring.validate(); err != nil {
+			atomic.AddUint64(&pr.corrupt, 1)
+			return fmt.Errorf("CPU %d: %w", ring.cpu, err)
+		}
+
+		size := ring.peekSize()
+		if pr.maxRecordSize <= 0 || size <= pr.maxRecordSize {
+			return nil
+		}
+
+		// Records are never larger than 64KiB, so this is cheap.
+		_, _ = io.CopyN(io.Discard, ring, int64(size))
+		atomic.AddUint64(&pr.oversized, 1)
+	}
+}
+
+// CorruptRings returns the number of times the contents of a ring were
+// discarded due to ErrCorruptRing.
+func (pr *Reader) CorruptRings() uint64 {
+	return atomic.LoadUint64(&pr.corrupt)
+}
+
+// LostSamples returns the number of samples the kernel reported as lost in
+// the records read so far, including records rejected by a Filter.
+func (pr *Reader) LostSamples() uint64 {
+	return atomic.LoadUint64(&pr.lost)
+}
+
+// OversizedRecords returns the number of records discarded because they
+// exceeded ReaderOptions.MaxRecordSize.
+func (pr *Reader) OversizedRecords() uint64 {
+	return atomic.LoadUint64(&pr.oversized)
+}
+
+// SkippedBytes returns the number of bytes discarded from overwritable rings
+// because they didn't start with a valid record header. This happens if the
+// kernel overwrites a record while it is read, see ReaderOptions.PauseOutput.
+func (pr *Reader) SkippedBytes() uint64 {
+	pr.pauseMu.Lock()
+	defer pr.pauseMu.Unlock()
+
+	var skipped uint64
+	for _, ring := range pr.rings {
+		if ring != nil {
+			skipped += ring.skipped()
+		}
+	}
+	return skipped
+}
+
+// Snapshot drains all rings of an overwritable Reader, for example to inspect
+// the events leading up to an error.
+//
+// Output to the rings is stopped via PERF_EVENT_IOC_PAUSE_OUTPUT while they are
+// read, and resumed before Snapshot returns. Events submitted in the meantime
+// are lost. Unlike Pause, BPF programs aren't notified of this.
+//
+// Records are grouped by CPU and ordered from oldest to newest within each
+// group. A subsequent call only returns records written after the previous one.
+func (pr *Reader) Snapshot() ([]Record, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	if !pr.overwritable {
+		return nil, errors.New("perf ringbuffer: snapshot requires an overwritable reader")
+	}
+
+	if pr.rings == nil {
+		return nil, fmt.Errorf("perf ringbuffer: %w", ErrClosed)
+	}
+
+	pr.releaseView()
+
+	var records []Record
+	for _, ring := range pr.rings {
+		if ring == nil {
+			continue
+		}
+
+		recs, err := pr.snapshotRing(ring)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot: %w", err)
+		}
+		records = append(records, recs...)
+	}
+
+	return records, nil
+}
+
+// snapshotRing reads all records from a ring while its output is paused.
+//
+// pr.mu must be held.
+func (pr *Reader) snapshotRing(ring *perfEventRing) (_ []Record, err error) {
+	if err := ring.pauseOutput(true); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rerr := ring.pauseOutput(false); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	// The ring may also be queued in epollRings, in which case a later read
+	// will find it empty.
+	ring.loadHead()
+
+	var records []Record
+	for {
+		rec := Record{ExtraOptions: &ExtraPerfOptions{BrkPid: -1}}
+		err := pr.readFromRing(&rec, ring)
+		if err == errEOR {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+
+	// Overwritable rings are read from newest to oldest.
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	return records, nil
+}
+
 // NB: Has to be preceded by a call to ring.loadHead.
 func (pr *Reader) readRecordFromRing(rec *Record, ring *perfEventRing) error {
 	defer ring.writeTail()
 
+	return pr.readFromRing(rec, ring)
+}
+
+// readFromRing reads the next record from ring without committing the tail.
+//
+// NB: Has to be preceded by a call to ring.loadHead.
+func (pr *Reader) readFromRing(rec *Record, ring *perfEventRing) error {
 	rec.CPU = ring.cpu
-	err := readRecord(ring, rec, pr.eventHeader, pr.overwritable)
+	if err := pr.skipInvalid(ring); err != nil {
+		return err
+	}
+	err := readRecord(ring, rec, pr.eventHeader, pr.overwritable, pr.layout, pr.alloc)
 	if pr.overwritable && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
 		return errEOR
 	}
+	pr.countLost(rec, err)
 	return err
 }
 
+func (pr *Reader) countLost(rec *Record, err error) {
+	if err == nil && rec.LostSamples > 0 {
+		atomic.AddUint64(&pr.lost, rec.LostSamples)
+	}
+}
+
 type unknownEventError struct {
 	eventType uint32
 }
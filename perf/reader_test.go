@@ -2,11 +2,13 @@ package perf
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
 	"os"
+	"os/exec"
 	"syscall"
 	"testing"
 	"time"
@@ -19,6 +21,7 @@ import (
 	"github.com/cilium/ebpf/internal/unix"
 
 	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
 )
 
 var (
@@ -65,6 +68,228 @@ func TestReaderSetDeadline(t *testing.T) {
 	}
 }
 
+func TestReaderWakeupEvents(t *testing.T) {
+	events := perfEventArray(t)
+
+	_, err := NewReaderWithOptions(events, 4096, ReaderOptions{Watermark: 1, WakeupEvents: 1}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{WakeupEvents: 2}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	// A single record doesn't wake up the reader.
+	outputSamples(t, events, 5)
+	rd.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	_, err = rd.Read()
+	qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue, qt.Commentf("got error: %v", err))
+
+	outputSamples(t, events, 5, 5)
+	rd.SetDeadline(time.Now().Add(time.Second))
+	checkRecord(t, rd)
+}
+
+func TestReaderCgroup(t *testing.T) {
+	events := perfEventArray(t)
+	cgroup := testutils.CreateCgroup(t)
+
+	_, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{Cgroup: cgroup, BrkAddr: 1})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{Cgroup: cgroup})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	// The test doesn't run in the cgroup, so the event is inactive.
+	prog := outputSamplesProg(t, events, 5)
+	ret, _, err := prog.Test(internal.EmptyBPFContext)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ret, qt.Not(qt.Equals), uint32(0))
+}
+
+func TestSidebandReader(t *testing.T) {
+	rd, err := NewSidebandReader(4096, ReaderOptions{}, ExtraPerfOptions{})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	qt.Assert(t, exec.Command("true").Run(), qt.IsNil)
+
+	types := make(map[uint32]bool)
+	rd.SetDeadline(time.Now().Add(time.Second))
+	for !types[linux.PERF_RECORD_EXIT] {
+		rec, err := rd.Read()
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, rec.RecordType, qt.Not(qt.Equals), uint32(unix.PERF_RECORD_SAMPLE))
+		types[rec.RecordType] = true
+	}
+	qt.Assert(t, types[linux.PERF_RECORD_FORK], qt.IsTrue)
+
+	qt.Assert(t, rd.Pause(), qt.IsNil)
+	qt.Assert(t, rd.Resume(), qt.IsNil)
+}
+
+func TestReaderCloneForPid(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{Watermark: 1}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	_, err = rd.CloneForPid(0)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	clone, err := rd.CloneForPid(os.Getpid())
+	qt.Assert(t, err, qt.IsNil)
+	defer clone.Close()
+
+	qt.Assert(t, clone.array.MaxEntries(), qt.Equals, events.MaxEntries())
+	qt.Assert(t, clone.eopts.Pid, qt.Equals, os.Getpid())
+	qt.Assert(t, clone.opts, qt.DeepEquals, rd.opts)
+	qt.Assert(t, clone.perCPUBuffer, qt.Equals, rd.perCPUBuffer)
+
+	// The original reader still receives samples.
+	outputSamples(t, events, 5)
+	checkRecord(t, rd)
+
+	rd.Close()
+	_, err = rd.CloneForPid(os.Getpid())
+	qt.Assert(t, errors.Is(err, ErrClosed), qt.IsTrue)
+}
+
+func TestReaderMaxRecordSize(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{MaxRecordSize: 32}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 40, 5, 40)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, int(rec.RawSample[perfEventSampleSize]), qt.Equals, 5)
+
+	rd.SetDeadline(time.Now().Add(readTimeout))
+	_, err = rd.Read()
+	qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue, qt.Commentf("got error: %v", err))
+	qt.Assert(t, rd.OversizedRecords(), qt.Equals, uint64(2))
+}
+
+func TestReaderReadContext(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	outputSamples(t, events, 5)
+
+	rec, err := rd.ReadContext(context.Background())
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.RawSample, qt.Not(qt.HasLen), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := rd.ReadContext(ctx)
+		errs <- err
+	}()
+
+	// Give ReadContext a chance to block.
+	time.Sleep(readTimeout)
+	cancel()
+
+	select {
+	case err := <-errs:
+		qt.Assert(t, err, qt.ErrorIs, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("Cancelling the context doesn't interrupt ReadContext")
+	}
+
+	_, err = rd.ReadContext(ctx)
+	qt.Assert(t, err, qt.ErrorIs, context.Canceled)
+
+	// A cancelled context must not affect subsequent reads.
+	outputSamples(t, events, 5)
+	checkRecord(t, rd)
+
+	rd.SetDeadline(time.Now().Add(4 * time.Millisecond))
+	_, err = rd.ReadContext(context.Background())
+	qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue, qt.Commentf("expected os.ErrDeadlineExceeded"))
+}
+
+func TestReaderReadBatch(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	n, err := rd.ReadBatch(nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, 0)
+
+	outputSamples(t, events, 5, 6, 7)
+
+	recs := make([]Record, 2)
+	n, err = rd.ReadBatch(recs)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, 2)
+
+	// The remaining record is returned without waiting for a new wakeup.
+	rd.SetDeadline(time.Now())
+	n, err = rd.ReadBatch(recs)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, n, qt.Equals, 1)
+
+	n, err = rd.ReadBatch(recs)
+	qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue, qt.Commentf("expected os.ErrDeadlineExceeded"))
+	qt.Assert(t, n, qt.Equals, 0)
+}
+
+func TestReaderReadView(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	prog := outputSamplesProg(t, events, 200, 201, 202)
+
+	// Write enough data to wrap around the end of the ring multiple times.
+	var rec Record
+	for round := 0; round < 20; round++ {
+		ret, _, err := prog.Test(internal.EmptyBPFContext)
+		testutils.SkipIfNotSupported(t, err)
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, ret, qt.Equals, uint32(0))
+
+		for i := 0; i < 3; i++ {
+			qt.Assert(t, rd.ReadView(&rec), qt.IsNil)
+
+			sample := rec.RawSample[perfEventSampleSize:]
+			qt.Assert(t, int(sample[0]), qt.Equals, 200+i)
+			qt.Assert(t, int(sample[1]), qt.Equals, i)
+			for j, v := range sample[2:sample[0]] {
+				qt.Assert(t, v, qt.Equals, byte(0xff), qt.Commentf("round %d sample %d position %d", round, i, j+2))
+			}
+		}
+	}
+
+	rd.SetDeadline(time.Now())
+	err = rd.ReadView(&rec)
+	qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue, qt.Commentf("expected os.ErrDeadlineExceeded"))
+}
+
 func outputSamples(tb testing.TB, events *ebpf.Map, sampleSizes ...byte) {
 	prog := outputSamplesProg(tb, events, sampleSizes...)
 
@@ -163,16 +388,20 @@ func checkRecord(tb testing.TB, rd *Reader) (id int) {
 
 	qt.Assert(tb, rec.CPU >= 0, qt.IsTrue, qt.Commentf("Record has invalid CPU number"))
 
-	size := int(rec.RawSample[0])
-	qt.Assert(tb, len(rec.RawSample) >= size, qt.IsTrue, qt.Commentf("RawSample is at least size bytes"))
+	// RawSample contains the whole sample body, which starts with the size
+	// of the raw data.
+	sample := rec.RawSample[perfEventSampleSize:]
+
+	size := int(sample[0])
+	qt.Assert(tb, len(sample) >= size, qt.IsTrue, qt.Commentf("RawSample is at least size bytes"))
 
-	for i, v := range rec.RawSample[2:size] {
+	for i, v := range sample[2:size] {
 		qt.Assert(tb, v, qt.Equals, byte(0xff), qt.Commentf("filler at position %d should match", i+2))
 	}
 
 	// padding is ignored since it's value is undefined.
 
-	return int(rec.RawSample[1])
+	return int(sample[1])
 }
 
 func TestPerfReaderLostSample(t *testing.T) {
@@ -268,6 +497,10 @@ func TestPerfReaderLostSample(t *testing.T) {
 			t.Fatal("Expected a record with LostSamples 1, got", record.LostSamples)
 		}
 	}
+
+	if lost := rd.LostSamples(); lost != 1 {
+		t.Fatal("Expected LostSamples to return 1, got", lost)
+	}
 }
 
 func TestPerfReaderOverwritable(t *testing.T) {
@@ -289,7 +522,7 @@ func TestPerfReaderOverwritable(t *testing.T) {
 
 	events := perfEventArray(t)
 
-	rd, err := NewReaderWithOptions(events, pageSize, ReaderOptions{Overwritable: true})
+	rd, err := NewReaderWithOptions(events, pageSize, ReaderOptions{Overwritable: true}, ExtraPerfOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -311,9 +544,86 @@ func TestPerfReaderOverwritable(t *testing.T) {
 	}
 }
 
+func TestPerfReaderPauseOutput(t *testing.T) {
+	events := perfEventArray(t)
+
+	_, err := NewReaderWithOptions(events, os.Getpagesize(), ReaderOptions{PauseOutput: true}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNotNil)
+
+	rd, err := NewReaderWithOptions(events, os.Getpagesize(), ReaderOptions{Overwritable: true, PauseOutput: true}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	for i := 0; i < 2; i++ {
+		outputSamples(t, events, 5, 6)
+
+		qt.Assert(t, rd.Pause(), qt.IsNil)
+		rd.SetDeadline(time.Now().Add(100 * time.Millisecond))
+		qt.Assert(t, checkRecord(t, rd), qt.Equals, 1)
+		qt.Assert(t, checkRecord(t, rd), qt.Equals, 0)
+
+		// Draining the ring resumes output.
+		_, err = rd.Read()
+		qt.Assert(t, errors.Is(err, os.ErrDeadlineExceeded), qt.IsTrue)
+		qt.Assert(t, rd.Resume(), qt.IsNil)
+	}
+}
+
+func TestPerfReaderSnapshot(t *testing.T) {
+	pageSize := os.Getpagesize()
+
+	const sampleSize = math.MaxUint8
+
+	realSampleSize := internal.Align(sampleSize+8+4, 8)
+	maxEvents := pageSize / realSampleSize
+
+	var sampleSizes []byte
+	for i := 0; i <= maxEvents; i++ {
+		sampleSizes = append(sampleSizes, sampleSize)
+	}
+
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, pageSize, ReaderOptions{Overwritable: true}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, sampleSizes...)
+
+	recs, err := rd.Snapshot()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, recs, qt.HasLen, maxEvents)
+
+	// The first sample was overwritten, the rest is returned oldest first.
+	for i, rec := range recs {
+		sample := rec.RawSample[perfEventSampleSize:]
+		qt.Assert(t, int(sample[1]), qt.Equals, i+1)
+	}
+
+	// Output is resumed and the previous records were consumed.
+	outputSamples(t, events, sampleSize)
+	recs, err = rd.Snapshot()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, recs, qt.HasLen, 1)
+
+	qt.Assert(t, rd.Close(), qt.IsNil)
+	_, err = rd.Snapshot()
+	qt.Assert(t, err, qt.ErrorIs, ErrClosed)
+}
+
+func TestPerfReaderSnapshotNotOverwritable(t *testing.T) {
+	events := perfEventArray(t)
+	rd, err := NewReader(events, os.Getpagesize())
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	_, err = rd.Snapshot()
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
 func TestPerfReaderOverwritableEmpty(t *testing.T) {
 	events := perfEventArray(t)
-	rd, err := NewReaderWithOptions(events, os.Getpagesize(), ReaderOptions{Overwritable: true})
+	rd, err := NewReaderWithOptions(events, os.Getpagesize(), ReaderOptions{Overwritable: true}, ExtraPerfOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -375,7 +685,7 @@ func TestPerfReaderClose(t *testing.T) {
 }
 
 func TestCreatePerfEvent(t *testing.T) {
-	fd, err := createPerfEvent(0, 1, false)
+	fd, err := createPerfEvent(0, ReaderOptions{Watermark: 1}, ExtraPerfOptions{})
 	if err != nil {
 		t.Fatal("Can't create perf event:", err)
 	}
@@ -391,12 +701,78 @@ func TestReadRecord(t *testing.T) {
 	}
 
 	var rec Record
-	err = readRecord(&buf, &rec, make([]byte, perfEventHeaderSize), false)
+	err = readRecord(&buf, &rec, make([]byte, perfEventHeaderSize), false, nil, nil)
 	if !IsUnknownEvent(err) {
 		t.Error("readRecord should return unknown event error, got", err)
 	}
 }
 
+func TestReadRecordReusesBuffer(t *testing.T) {
+	record := func(payload []byte) *bytes.Buffer {
+		var buf bytes.Buffer
+		header := perfEventHeader{
+			Type: unix.PERF_RECORD_SAMPLE,
+			Size: uint16(perfEventHeaderSize + perfEventSampleSize + len(payload)),
+		}
+		qt.Assert(t, binary.Write(&buf, internal.NativeEndian, &header), qt.IsNil)
+		qt.Assert(t, binary.Write(&buf, internal.NativeEndian, uint32(len(payload))), qt.IsNil)
+		buf.Write(payload)
+		return &buf
+	}
+
+	eventHeader := make([]byte, perfEventHeaderSize)
+	rec := Record{RawSample: make([]byte, 0, 64)}
+	backing := &rec.RawSample[:1][0]
+
+	err := readRecord(record([]byte{1, 2, 3, 4}), &rec, eventHeader, false, nil, nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.RawSample[perfEventSampleSize:], qt.DeepEquals, []byte{1, 2, 3, 4})
+	qt.Assert(t, &rec.RawSample[0] == backing, qt.IsTrue, qt.Commentf("RawSample was reallocated"))
+
+	err = readRecord(record([]byte{5, 6}), &rec, eventHeader, false, nil, nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.RawSample[perfEventSampleSize:], qt.DeepEquals, []byte{5, 6})
+	qt.Assert(t, &rec.RawSample[0] == backing, qt.IsTrue, qt.Commentf("RawSample was reallocated"))
+
+	large := make([]byte, 128)
+	err = readRecord(record(large), &rec, eventHeader, false, nil, nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.RawSample, qt.HasLen, perfEventSampleSize+len(large))
+}
+
+func TestReaderAllocator(t *testing.T) {
+	events := perfEventArray(t)
+
+	var sizes []int
+	arena := make([]byte, 4096)
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{
+		Allocator: func(size int) []byte {
+			sizes = append(sizes, size)
+			buf := arena[:size:size]
+			arena = arena[size:]
+			return buf
+		},
+	}, ExtraPerfOptions{})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5, 5)
+
+	var rec Record
+	rd.SetDeadline(time.Now().Add(time.Second))
+	qt.Assert(t, rd.ReadInto(&rec), qt.IsNil)
+	qt.Assert(t, sizes, qt.HasLen, 1)
+	qt.Assert(t, len(rec.RawSample), qt.Equals, sizes[0])
+
+	// The buffer of rec is reused.
+	qt.Assert(t, rd.ReadInto(&rec), qt.IsNil)
+	qt.Assert(t, sizes, qt.HasLen, 1)
+
+	_, err = readLeftFull(bytes.NewReader(make([]byte, 8)), nil, perfEventHeader{Size: uint16(perfEventHeaderSize + 8)},
+		func(int) []byte { return nil })
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
 func TestPause(t *testing.T) {
 	t.Parallel()
 
@@ -542,6 +918,76 @@ func BenchmarkReadInto(b *testing.B) {
 	}
 }
 
+func BenchmarkReadView(b *testing.B) {
+	events := perfEventArray(b)
+	prog := outputSamplesProg(b, events, 80)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rd.Close()
+
+	buf := internal.EmptyBPFContext
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	var rec Record
+	for i := 0; i < b.N; i++ {
+		ret, _, err := prog.Test(buf)
+		if err != nil {
+			b.Fatal(err)
+		} else if errno := syscall.Errno(-int32(ret)); errno != 0 {
+			b.Fatal("Expected 0 as return value, got", errno)
+		}
+
+		if err := rd.ReadView(&rec); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadBatch(b *testing.B) {
+	const batchSize = 16
+
+	events := perfEventArray(b)
+	sampleSizes := make([]byte, batchSize)
+	for i := range sampleSizes {
+		sampleSizes[i] = 80
+	}
+	prog := outputSamplesProg(b, events, sampleSizes...)
+
+	rd, err := NewReader(events, 4096*batchSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rd.Close()
+
+	buf := internal.EmptyBPFContext
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	recs := make([]Record, batchSize)
+	for i := 0; i < b.N; i++ {
+		ret, _, err := prog.Test(buf)
+		if err != nil {
+			b.Fatal(err)
+		} else if errno := syscall.Errno(-int32(ret)); errno != 0 {
+			b.Fatal("Expected 0 as return value, got", errno)
+		}
+
+		for n := 0; n < batchSize; {
+			read, err := rd.ReadBatch(recs[n:])
+			if err != nil {
+				b.Fatal(err)
+			}
+			n += read
+		}
+	}
+}
+
 // This exists just to make the example below nicer.
 func bpfPerfEventOutputProgram() (*ebpf.Program, *ebpf.Map) {
 	return nil, nil
@@ -10,6 +10,7 @@ import (
 	"sync/atomic"
 	"unsafe"
 
+	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/unix"
 	linux "golang.org/x/sys/unix"
 )
@@ -21,14 +22,22 @@ type perfEventRing struct {
 	cpu  int
 	mmap []byte
 	ringReader
+
+	// counters are the events in the group of fd, see
+	// ExtraPerfOptions.Counters. counterIDs maps their IDs to names.
+	counters   []int
+	counterIDs map[uint64]string
+
+	// aux is the AUX area, or nil. See ExtraPerfOptions.AuxBuffer.
+	aux *auxRing
 }
 
-func newPerfEventRing(cpu, perCPUBuffer, watermark int, overwritable bool, eopts ExtraPerfOptions) (*perfEventRing, error) {
-	if watermark >= perCPUBuffer {
+func newPerfEventRing(cpu, perCPUBuffer int, opts ReaderOptions, eopts ExtraPerfOptions) (*perfEventRing, error) {
+	if opts.Watermark >= perCPUBuffer {
 		return nil, errors.New("watermark must be smaller than perCPUBuffer")
 	}
 
-	fd, err := createPerfEvent(cpu, watermark, overwritable, eopts)
+	fd, err := createPerfEvent(cpu, opts, eopts)
 	if err != nil {
 		return nil, err
 	}
@@ -38,13 +47,20 @@ func newPerfEventRing(cpu, perCPUBuffer, watermark int, overwritable bool, eopts
 		return nil, err
 	}
 
+	counters, counterIDs, err := openCounters(fd, cpu, eopts.Counters)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
 	protections := unix.PROT_READ
-	if !overwritable {
+	if !opts.Overwritable {
 		protections |= unix.PROT_WRITE
 	}
 
 	mmap, err := unix.Mmap(fd, 0, perfBufferSize(perCPUBuffer), protections, unix.MAP_SHARED)
 	if err != nil {
+		closeCounters(counters)
 		unix.Close(fd)
 		return nil, fmt.Errorf("can't mmap: %v", err)
 	}
@@ -55,8 +71,19 @@ func newPerfEventRing(cpu, perCPUBuffer, watermark int, overwritable bool, eopts
 	// documentation, since a byte is smaller than sampledPerfEvent.
 	meta := (*unix.PerfEventMmapPage)(unsafe.Pointer(&mmap[0]))
 
+	var aux *auxRing
+	if eopts.AuxBuffer > 0 {
+		aux, err = newAuxRing(fd, meta, eopts.AuxBuffer)
+		if err != nil {
+			_ = unix.Munmap(mmap)
+			closeCounters(counters)
+			unix.Close(fd)
+			return nil, err
+		}
+	}
+
 	var reader ringReader
-	if overwritable {
+	if opts.Overwritable {
 		reader = newReverseReader(meta, mmap[meta.Data_offset:meta.Data_offset+meta.Data_size])
 	} else {
 		reader = newForwardReader(meta, mmap[meta.Data_offset:meta.Data_offset+meta.Data_size])
@@ -67,6 +94,9 @@ func newPerfEventRing(cpu, perCPUBuffer, watermark int, overwritable bool, eopts
 		cpu:        cpu,
 		mmap:       mmap,
 		ringReader: reader,
+		counters:   counters,
+		counterIDs: counterIDs,
+		aux:        aux,
 	}
 	runtime.SetFinalizer(ring, (*perfEventRing).Close)
 
@@ -92,6 +122,10 @@ func perfBufferSize(perCPUBuffer int) int {
 func (ring *perfEventRing) Close() {
 	runtime.SetFinalizer(ring, nil)
 
+	if ring.aux != nil {
+		ring.aux.close()
+	}
+	closeCounters(ring.counters)
 	_ = unix.Close(ring.fd)
 	_ = unix.Munmap(ring.mmap)
 
@@ -99,6 +133,80 @@ func (ring *perfEventRing) Close() {
 	ring.mmap = nil
 }
 
+// pauseOutput stops the kernel from writing to the ring, or allows it again.
+// Records submitted while output is paused are lost.
+func (ring *perfEventRing) pauseOutput(pause bool) error {
+	value := 0
+	if pause {
+		value = 1
+	}
+
+	if err := unix.IoctlSetInt(ring.fd, unix.PERF_EVENT_IOC_PAUSE_OUTPUT, value); err != nil {
+		return fmt.Errorf("pause output of CPU %d: %w", ring.cpu, err)
+	}
+	return nil
+}
+
+// resync skips corrupt data at the read position of overwritable rings.
+func (ring *perfEventRing) resync() {
+	if rr, ok := ring.ringReader.(*reverseReader); ok {
+		rr.resync()
+	}
+}
+
+// validate checks the header at the read position of the ring. If its size
+// is impossible, all unread data is discarded and ErrCorruptRing returned.
+func (ring *perfEventRing) validate() error {
+	switch rr := ring.ringReader.(type) {
+	case *forwardReader:
+		avail := rr.head - rr.tail
+		if avail == 0 {
+			return nil
+		}
+
+		// The kernel only publishes complete records.
+		size := uint64(rr.peekSize())
+		if size < uint64(perfEventHeaderSize) || size%8 != 0 || size > avail {
+			rr.tail = rr.head
+			return ErrCorruptRing
+		}
+
+	case *reverseReader:
+		// Invalid headers are skipped by resync, and records may be
+		// truncated by the start of the ring. Only the ring size is a
+		// hard limit.
+		if rr.peekSize() > len(rr.ring) {
+			rr.read = rr.tail
+			return ErrCorruptRing
+		}
+	}
+
+	return nil
+}
+
+// skipped returns the number of bytes discarded by resync.
+func (ring *perfEventRing) skipped() uint64 {
+	if rr, ok := ring.ringReader.(*reverseReader); ok {
+		return atomic.LoadUint64(&rr.skipped)
+	}
+	return 0
+}
+
+// ioctlRings issues req with value on the event of each ring, skipping
+// offline CPUs.
+func ioctlRings(fds []int, req uint, value int) error {
+	for cpu, fd := range fds {
+		if fd == -1 {
+			continue
+		}
+
+		if err := unix.IoctlSetInt(fd, req, value); err != nil {
+			return fmt.Errorf("ioctl event fd %d for CPU %d: %w", fd, cpu, err)
+		}
+	}
+	return nil
+}
+
 const (
 	HW_BREAKPOINT_LEN_1 = 1
 	HW_BREAKPOINT_LEN_2 = 2
@@ -110,21 +218,46 @@ const (
 	HW_BREAKPOINT_LEN_8 = 8
 )
 
-func createPerfEvent(cpu, watermark int, overwritable bool, eopts ExtraPerfOptions) (int, error) {
-	if watermark == 0 {
-		watermark = 1
+func createPerfEvent(cpu int, opts ReaderOptions, eopts ExtraPerfOptions) (int, error) {
+	attr := perfEventAttr(opts, eopts)
+
+	watch_pid := -1
+	flags := unix.PERF_FLAG_FD_CLOEXEC
+	if eopts.BrkAddr != 0 {
+		watch_pid = eopts.BrkPid
+	} else if eopts.Cgroup != nil {
+		watch_pid = int(eopts.Cgroup.Fd())
+		flags |= unix.PERF_FLAG_PID_CGROUP
+	} else if eopts.Pid != 0 {
+		watch_pid = eopts.Pid
+	}
+
+	fd, err := perfEventOpen(&attr, watch_pid, cpu, -1, flags)
+	if err != nil {
+		return -1, fmt.Errorf("can't create perf event: %w", err)
 	}
+	return fd, nil
+}
 
-	bits := linux.PerfBitWatermark
-	if overwritable {
+// perfEventAttr returns the attributes of the perf events backing the rings.
+func perfEventAttr(opts ReaderOptions, eopts ExtraPerfOptions) linux.PerfEventAttr {
+	var bits int
+	wakeup := opts.WakeupEvents
+	if wakeup == 0 {
+		wakeup = opts.Watermark
+		if wakeup == 0 {
+			wakeup = 1
+		}
+		bits |= linux.PerfBitWatermark
+	}
+
+	if opts.Overwritable {
 		bits |= linux.PerfBitWriteBackward
 	}
 
 	var attr linux.PerfEventAttr
 
-	watch_pid := -1
 	if eopts.BrkAddr != 0 {
-		watch_pid = eopts.BrkPid
 		attr = unix.PerfEventAttr{
 			Type:        linux.PERF_TYPE_BREAKPOINT,
 			Config:      linux.PERF_COUNT_SW_CPU_CLOCK,
@@ -138,13 +271,20 @@ func createPerfEvent(cpu, watermark int, overwritable bool, eopts ExtraPerfOptio
 			Ext2:    eopts.BrkLen,
 			// Ext2:    HW_BREAKPOINT_LEN_4,
 		}
+	} else if eopts.PMUType != 0 {
+		attr = unix.PerfEventAttr{
+			Type:   eopts.PMUType,
+			Config: eopts.PMUConfig,
+			Bits:   uint64(bits),
+			Wakeup: uint32(wakeup),
+		}
 	} else {
 		attr = unix.PerfEventAttr{
 			Type:        linux.PERF_TYPE_SOFTWARE,
 			Config:      linux.PERF_COUNT_SW_BPF_OUTPUT,
 			Bits:        uint64(bits),
 			Sample_type: linux.PERF_SAMPLE_RAW,
-			Wakeup:      uint32(watermark),
+			Wakeup:      uint32(wakeup),
 		}
 	}
 
@@ -157,6 +297,10 @@ func createPerfEvent(cpu, watermark int, overwritable bool, eopts ExtraPerfOptio
 		attr.Sample_type |= linux.PERF_SAMPLE_REGS_USER
 		attr.Sample_regs_user = eopts.Sample_regs_user
 	}
+	if eopts.Sample_regs_intr != 0 {
+		attr.Sample_type |= linux.PERF_SAMPLE_REGS_INTR
+		attr.Sample_regs_intr = eopts.Sample_regs_intr
+	}
 	if eopts.PerfMmap {
 		attr.Bits |= linux.PerfBitMmap
 		attr.Bits |= linux.PerfBitComm
@@ -169,21 +313,127 @@ func createPerfEvent(cpu, watermark int, overwritable bool, eopts ExtraPerfOptio
 		attr.Bits |= linux.PerfBitMmap2
 	}
 
-	attr.Size = uint32(unsafe.Sizeof(attr))
-	fd, err := unix.PerfEventOpen(&attr, watch_pid, cpu, -1, unix.PERF_FLAG_FD_CLOEXEC)
-	if err != nil {
-		return -1, fmt.Errorf("can't create perf event: %w", err)
+	if eopts.SampleTime {
+		attr.Sample_type |= linux.PERF_SAMPLE_TIME
+		// Also timestamp MMAP2, COMM, LOST, etc.
+		attr.Bits |= linux.PerfBitSampleIDAll
 	}
-	return fd, nil
+
+	if eopts.UseClockID {
+		attr.Bits |= linux.PerfBitUseClockID
+		attr.Clockid = eopts.ClockID
+	}
+
+	if len(eopts.Counters) > 0 {
+		attr.Sample_type |= linux.PERF_SAMPLE_READ
+		attr.Read_format = linux.PERF_FORMAT_GROUP | linux.PERF_FORMAT_ID
+	}
+
+	if eopts.BranchSampleType != 0 {
+		attr.Sample_type |= linux.PERF_SAMPLE_BRANCH_STACK
+		attr.Branch_sample_type = eopts.BranchSampleType
+	}
+
+	if eopts.SampleTID {
+		attr.Sample_type |= linux.PERF_SAMPLE_TID
+	}
+
+	if eopts.SampleIP {
+		attr.Sample_type |= linux.PERF_SAMPLE_IP
+	}
+
+	if eopts.Inherit {
+		attr.Bits |= linux.PerfBitInherit
+	}
+
+	if eopts.SampleID {
+		attr.Sample_type |= linux.PERF_SAMPLE_ID | linux.PERF_SAMPLE_STREAM_ID
+	}
+
+	if eopts.SampleCgroup {
+		attr.Sample_type |= linux.PERF_SAMPLE_CGROUP
+		attr.Bits |= perfBitCgroup
+	}
+
+	if eopts.SampleWeightStruct {
+		attr.Sample_type |= linux.PERF_SAMPLE_WEIGHT_STRUCT
+	} else if eopts.SampleWeight {
+		attr.Sample_type |= linux.PERF_SAMPLE_WEIGHT
+	}
+
+	if eopts.SampleTransaction {
+		attr.Sample_type |= linux.PERF_SAMPLE_TRANSACTION
+	}
+
+	if eopts.Namespaces {
+		attr.Bits |= perfBitNamespaces
+	}
+
+	if eopts.Task {
+		attr.Bits |= linux.PerfBitTask
+	}
+
+	if eopts.ContextSwitch {
+		attr.Bits |= linux.PerfBitContextSwitch
+	}
+
+	if eopts.Ksymbol {
+		attr.Bits |= perfBitKsymbol
+	}
+
+	if eopts.BPFEvent {
+		attr.Bits |= perfBitBPFEvent
+	}
+
+	if eopts.ExcludeKernel {
+		attr.Bits |= linux.PerfBitExcludeKernel
+	}
+	if eopts.ExcludeUser {
+		attr.Bits |= linux.PerfBitExcludeUser
+	}
+	if eopts.ExcludeHv {
+		attr.Bits |= linux.PerfBitExcludeHv
+	}
+	if eopts.ExcludeIdle {
+		attr.Bits |= linux.PerfBitExcludeIdle
+	}
+
+	if eopts.PreciseIP&1 != 0 {
+		attr.Bits |= linux.PerfBitPreciseIPBit1
+	}
+	if eopts.PreciseIP&2 != 0 {
+		attr.Bits |= linux.PerfBitPreciseIPBit2
+	}
+
+	attr.Size = uint32(unsafe.Sizeof(attr))
+	return attr
 }
 
 type ringReader interface {
 	loadHead()
 	size() int
 	writeTail()
+	// view returns the next n bytes of the ring without copying them.
+	//
+	// Returns nil if the bytes wrap around the end of the ring or aren't
+	// available.
+	view(n int) []byte
+	// peekSize returns the size of the next record including its header,
+	// or zero if no complete header is available. It doesn't consume data.
+	peekSize() int
 	Read(p []byte) (int, error)
 }
 
+// peekSize returns the size field of the header at pos, or zero if avail is
+// too small to hold a header. Records are aligned to 8 bytes, so
+// headers never wrap around the end of the ring.
+func peekSize(ring []byte, mask, pos, avail uint64) int {
+	if avail < uint64(perfEventHeaderSize) {
+		return 0
+	}
+	return int(internal.NativeEndian.Uint16(ring[pos&mask+6:]))
+}
+
 type forwardReader struct {
 	meta       *unix.PerfEventMmapPage
 	head, tail uint64
@@ -216,6 +466,20 @@ func (rr *forwardReader) writeTail() {
 	atomic.StoreUint64(&rr.meta.Data_tail, rr.tail)
 }
 
+func (rr *forwardReader) view(n int) []byte {
+	start := int(rr.tail & rr.mask)
+	if n > cap(rr.ring)-start || uint64(n) > rr.head-rr.tail {
+		return nil
+	}
+
+	rr.tail += uint64(n)
+	return rr.ring[start : start+n : start+n]
+}
+
+func (rr *forwardReader) peekSize() int {
+	return peekSize(rr.ring, rr.mask, rr.tail, rr.head-rr.tail)
+}
+
 func (rr *forwardReader) Read(p []byte) (int, error) {
 	start := int(rr.tail & rr.mask)
 
@@ -250,6 +514,9 @@ type reverseReader struct {
 	tail uint64
 	mask uint64
 	ring []byte
+	// skipped is the number of bytes discarded by resync. Accessed
+	// atomically.
+	skipped uint64
 }
 
 func newReverseReader(meta *unix.PerfEventMmapPage, ring []byte) *reverseReader {
@@ -302,6 +569,46 @@ func (rr *reverseReader) writeTail() {
 	// So, this function is noop.
 }
 
+func (rr *reverseReader) view(n int) []byte {
+	start := int(rr.read & rr.mask)
+	if n > cap(rr.ring)-start || uint64(n) > rr.tail-rr.read {
+		return nil
+	}
+
+	rr.read += uint64(n)
+	return rr.ring[start : start+n : start+n]
+}
+
+// resync skips data at the read position which can't be the header of a
+// record, which happens if the kernel overwrote a record while it was read.
+//
+// A header which claims more bytes than are left is kept, since the record
+// was merely truncated by the start of the ring.
+func (rr *reverseReader) resync() {
+	for rr.read != rr.tail {
+		if rr.tail-rr.read < uint64(perfEventHeaderSize) {
+			atomic.AddUint64(&rr.skipped, rr.tail-rr.read)
+			rr.read = rr.tail
+			return
+		}
+
+		// Records are aligned to 8 bytes, so headers never wrap.
+		start := int(rr.read & rr.mask)
+		typ := internal.NativeEndian.Uint32(rr.ring[start:])
+		size := internal.NativeEndian.Uint16(rr.ring[start+6:])
+		if typ != 0 && typ < linux.PERF_RECORD_MAX && int(size) >= perfEventHeaderSize && size%8 == 0 {
+			return
+		}
+
+		rr.read += 8
+		atomic.AddUint64(&rr.skipped, 8)
+	}
+}
+
+func (rr *reverseReader) peekSize() int {
+	return peekSize(rr.ring, rr.mask, rr.read, rr.tail-rr.read)
+}
+
 func (rr *reverseReader) Read(p []byte) (int, error) {
 	start := int(rr.read & rr.mask)
 
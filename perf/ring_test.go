@@ -1,12 +1,16 @@
 package perf
 
 import (
+	"errors"
 	"io"
+	"math/rand"
 	"os"
 	"testing"
 
+	"github.com/cilium/ebpf/internal"
 	"github.com/cilium/ebpf/internal/unix"
 	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
 )
 
 func TestRingBufferReader(t *testing.T) {
@@ -74,6 +78,18 @@ func TestRingBufferReverseReader(t *testing.T) {
 	checkRead(t, ring, []byte{0, 1}, io.EOF)
 }
 
+func TestRingBufferView(t *testing.T) {
+	ring := makeForwardRing(4, 2)
+	qt.Assert(t, ring.view(3), qt.IsNil, qt.Commentf("view wraps"))
+	qt.Assert(t, ring.view(2), qt.DeepEquals, []byte{2, 3})
+	qt.Assert(t, ring.view(3), qt.IsNil, qt.Commentf("view exceeds data"))
+	qt.Assert(t, ring.view(2), qt.DeepEquals, []byte{0, 1})
+
+	rr := makeReverseRing(4, 2)
+	qt.Assert(t, rr.view(2), qt.DeepEquals, []byte{2, 3})
+	qt.Assert(t, rr.view(1), qt.DeepEquals, []byte{0})
+}
+
 // ensure that the next call to Read() yields the correct result.
 //
 // Read is called with a buffer that is larger than want so
@@ -130,7 +146,7 @@ func makeForwardRing(size, offset int) *forwardReader {
 
 func TestPerfEventRing(t *testing.T) {
 	check := func(buffer, watermark int, overwritable bool) {
-		ring, err := newPerfEventRing(0, buffer, watermark, overwritable)
+		ring, err := newPerfEventRing(0, buffer, ReaderOptions{Watermark: watermark, Overwritable: overwritable}, ExtraPerfOptions{})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -153,21 +169,21 @@ func TestPerfEventRing(t *testing.T) {
 	}
 
 	// watermark > buffer
-	_, err := newPerfEventRing(0, 8192, 8193, false)
+	_, err := newPerfEventRing(0, 8192, ReaderOptions{Watermark: 8193}, ExtraPerfOptions{})
 	if err == nil {
 		t.Fatal("watermark > buffer allowed")
 	}
-	_, err = newPerfEventRing(0, 8192, 8193, true)
+	_, err = newPerfEventRing(0, 8192, ReaderOptions{Watermark: 8193, Overwritable: true}, ExtraPerfOptions{})
 	if err == nil {
 		t.Fatal("watermark > buffer allowed")
 	}
 
 	// watermark == buffer
-	_, err = newPerfEventRing(0, 8192, 8192, false)
+	_, err = newPerfEventRing(0, 8192, ReaderOptions{Watermark: 8192}, ExtraPerfOptions{})
 	if err == nil {
 		t.Fatal("watermark == buffer allowed")
 	}
-	_, err = newPerfEventRing(0, 8192, 8192, true)
+	_, err = newPerfEventRing(0, 8192, ReaderOptions{Watermark: 8192, Overwritable: true}, ExtraPerfOptions{})
 	if err == nil {
 		t.Fatal("watermark == buffer allowed")
 	}
@@ -180,3 +196,270 @@ func TestPerfEventRing(t *testing.T) {
 	check(65537, 8192, false)
 	check(65537, 8192, true)
 }
+
+func TestPerfEventAttrExclude(t *testing.T) {
+	attr := perfEventAttr(ReaderOptions{}, ExtraPerfOptions{})
+	qt.Assert(t, attr.Bits&(linux.PerfBitExcludeKernel|linux.PerfBitExcludeUser|linux.PerfBitExcludeHv|linux.PerfBitExcludeIdle), qt.Equals, uint64(0))
+
+	attr = perfEventAttr(ReaderOptions{}, ExtraPerfOptions{ExcludeKernel: true, ExcludeHv: true})
+	qt.Assert(t, attr.Bits&linux.PerfBitExcludeKernel, qt.Not(qt.Equals), uint64(0))
+	qt.Assert(t, attr.Bits&linux.PerfBitExcludeHv, qt.Not(qt.Equals), uint64(0))
+	qt.Assert(t, attr.Bits&linux.PerfBitExcludeUser, qt.Equals, uint64(0))
+
+	attr = perfEventAttr(ReaderOptions{}, ExtraPerfOptions{ExcludeUser: true, ExcludeIdle: true})
+	qt.Assert(t, attr.Bits&linux.PerfBitExcludeUser, qt.Not(qt.Equals), uint64(0))
+	qt.Assert(t, attr.Bits&linux.PerfBitExcludeIdle, qt.Not(qt.Equals), uint64(0))
+}
+
+func TestReverseReaderResync(t *testing.T) {
+	ring := make([]byte, 64)
+	// Garbage, followed by a lost record and a truncated sample.
+	copy(ring[0:], []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 3, 0})
+	copy(ring[8:], make([]byte, 8))
+	internal.NativeEndian.PutUint32(ring[16:], unix.PERF_RECORD_LOST)
+	internal.NativeEndian.PutUint16(ring[22:], 24)
+	internal.NativeEndian.PutUint32(ring[40:], unix.PERF_RECORD_SAMPLE)
+	internal.NativeEndian.PutUint16(ring[46:], 64)
+
+	meta := unix.PerfEventMmapPage{Data_head: ^uint64(64 - 1), Data_size: 64}
+	rr := newReverseReader(&meta, ring)
+
+	rr.resync()
+	qt.Assert(t, rr.read&rr.mask, qt.Equals, uint64(16))
+	qt.Assert(t, rr.skipped, qt.Equals, uint64(16))
+
+	// Valid headers aren't skipped.
+	rr.resync()
+	qt.Assert(t, rr.skipped, qt.Equals, uint64(16))
+
+	// Truncated records aren't skipped either.
+	rr.read += 24
+	rr.resync()
+	qt.Assert(t, rr.read&rr.mask, qt.Equals, uint64(40))
+
+	// Partial headers at the end of the data are.
+	rr.read = rr.tail - 4
+	rr.resync()
+	qt.Assert(t, rr.read, qt.Equals, rr.tail)
+	qt.Assert(t, rr.skipped, qt.Equals, uint64(20))
+}
+
+// TestForwardReaderModel checks the forward reader against a simulated
+// kernel which writes arbitrary amounts of data whenever there is space.
+func TestForwardReaderModel(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		size := 8 << rng.Intn(6)
+
+		var meta unix.PerfEventMmapPage
+		ring := make([]byte, size)
+		fr := newForwardReader(&meta, ring)
+
+		var got []byte
+		for step := 0; step < 200; step++ {
+			if rng.Intn(2) == 0 {
+				// The kernel never overwrites data which wasn't committed.
+				free := uint64(size) - (meta.Data_head - meta.Data_tail)
+				n := uint64(rng.Intn(size + 1))
+				if n > free {
+					n = free
+				}
+				for i := uint64(0); i < n; i++ {
+					pos := meta.Data_head + i
+					ring[pos&fr.mask] = byte(pos)
+				}
+				meta.Data_head += n
+				continue
+			}
+
+			fr.loadHead()
+			for rng.Intn(4) != 0 {
+				if rng.Intn(2) == 0 {
+					if view := fr.view(rng.Intn(size + 1)); view != nil {
+						got = append(got, view...)
+					}
+					continue
+				}
+
+				buf := make([]byte, rng.Intn(size+1))
+				n, err := fr.Read(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				qt.Assert(t, err, qt.IsNil)
+			}
+
+			if fr.head-fr.tail > uint64(size) {
+				t.Fatalf("seed %d: tail %d is past head %d", seed, fr.tail, fr.head)
+			}
+
+			if rng.Intn(2) == 0 {
+				fr.writeTail()
+			}
+		}
+
+		for i, b := range got {
+			if b != byte(i) {
+				t.Fatalf("seed %d: byte %d is %d", seed, i, b)
+			}
+		}
+		qt.Assert(t, uint64(len(got)), qt.Equals, fr.tail, qt.Commentf("seed %d", seed))
+	}
+}
+
+// reverseModel simulates the kernel writing records backwards into an
+// overwritable ring. The payload of each record repeats its sequence number.
+type reverseModel struct {
+	meta  unix.PerfEventMmapPage
+	ring  []byte
+	mask  uint64
+	sizes []uint64
+}
+
+func (m *reverseModel) write(size uint64) {
+	m.meta.Data_head -= size
+	seq := uint64(len(m.sizes))
+	m.sizes = append(m.sizes, size)
+
+	var record [64]byte
+	internal.NativeEndian.PutUint32(record[0:], linux.PERF_RECORD_COMM)
+	internal.NativeEndian.PutUint16(record[6:], uint16(size))
+	for off := 8; off < int(size); off += 8 {
+		internal.NativeEndian.PutUint64(record[off:], seq)
+	}
+	for i := uint64(0); i < size; i++ {
+		m.ring[(m.meta.Data_head+i)&m.mask] = record[i]
+	}
+}
+
+// TestReverseReaderModel checks the reverse reader against a simulated kernel
+// which overwrites old records with arbitrarily sized new ones.
+func TestReverseReaderModel(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		size := 64 << rng.Intn(4)
+
+		m := &reverseModel{ring: make([]byte, size), mask: uint64(size - 1)}
+		rr := newReverseReader(&m.meta, m.ring)
+
+		// The newest sequence number returned by the previous batch.
+		newest := -1
+		for step := 0; step < 100; step++ {
+			for n := rng.Intn(8); n > 0; n-- {
+				m.write(uint64(16 + 8*rng.Intn(7)))
+			}
+
+			// Records newer than the previous batch which fit into the ring.
+			var want []int
+			var used uint64
+			for seq := len(m.sizes) - 1; seq > newest; seq-- {
+				used += m.sizes[seq]
+				if used > uint64(size) {
+					break
+				}
+				want = append(want, seq)
+			}
+
+			rr.loadHead()
+			var got []int
+			for {
+				rr.resync()
+
+				var rec Record
+				err := readRecord(rr, &rec, make([]byte, perfEventHeaderSize), true, nil, nil)
+				if err == errEOR || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+					break
+				}
+				qt.Assert(t, err, qt.IsNil, qt.Commentf("seed %d", seed))
+
+				seq := internal.NativeEndian.Uint64(rec.RawSample)
+				for off := 8; off < len(rec.RawSample); off += 8 {
+					if internal.NativeEndian.Uint64(rec.RawSample[off:]) != seq {
+						t.Fatalf("seed %d: record %d is corrupt", seed, seq)
+					}
+				}
+				got = append(got, int(seq))
+			}
+
+			qt.Assert(t, got, qt.DeepEquals, want, qt.Commentf("seed %d step %d", seed, step))
+			qt.Assert(t, rr.skipped, qt.Equals, uint64(0))
+			if len(got) > 0 {
+				newest = got[0]
+			}
+		}
+	}
+}
+
+// TestReverseReaderOverwriteModel checks that the reverse reader terminates
+// and doesn't panic if the kernel overwrites records while they are read.
+func TestReverseReaderOverwriteModel(t *testing.T) {
+	for seed := int64(0); seed < 100; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		size := 64 << rng.Intn(4)
+
+		m := &reverseModel{ring: make([]byte, size), mask: uint64(size - 1)}
+		rr := newReverseReader(&m.meta, m.ring)
+
+		for step := 0; step < 100; step++ {
+			for n := rng.Intn(8); n > 0; n-- {
+				m.write(uint64(16 + 8*rng.Intn(7)))
+			}
+
+			rr.loadHead()
+			for reads := 0; ; reads++ {
+				if reads > size/8 {
+					t.Fatalf("seed %d: reader doesn't terminate", seed)
+				}
+
+				// Write while reading.
+				for n := rng.Intn(3); n > 0; n-- {
+					m.write(uint64(16 + 8*rng.Intn(7)))
+				}
+
+				rr.resync()
+				var rec Record
+				err := readRecord(rr, &rec, make([]byte, perfEventHeaderSize), true, nil, nil)
+				if err != nil {
+					break
+				}
+				if len(rec.RawSample)+perfEventHeaderSize > size {
+					t.Fatalf("seed %d: record exceeds ring", seed)
+				}
+			}
+		}
+	}
+}
+
+func TestPerfEventRingValidate(t *testing.T) {
+	for _, size := range []uint16{0, 3, 32} {
+		fr := makeForwardRing(16, 0)
+		internal.NativeEndian.PutUint16(fr.ring[6:], size)
+
+		ring := &perfEventRing{ringReader: fr}
+		qt.Assert(t, ring.validate(), qt.Equals, ErrCorruptRing, qt.Commentf("size %d", size))
+		qt.Assert(t, fr.tail, qt.Equals, fr.head)
+	}
+
+	fr := makeForwardRing(16, 0)
+	internal.NativeEndian.PutUint16(fr.ring[6:], 16)
+	ring := &perfEventRing{ringReader: fr}
+	qt.Assert(t, ring.validate(), qt.IsNil)
+	qt.Assert(t, fr.tail, qt.Equals, uint64(0))
+
+	rr := makeReverseRing(16, 0)
+	internal.NativeEndian.PutUint16(rr.ring[6:], 24)
+	ring = &perfEventRing{ringReader: rr}
+	qt.Assert(t, ring.validate(), qt.Equals, ErrCorruptRing)
+	qt.Assert(t, rr.read, qt.Equals, rr.tail)
+}
+
+func TestReaderSkipInvalid(t *testing.T) {
+	fr := makeForwardRing(16, 0)
+	internal.NativeEndian.PutUint16(fr.ring[6:], 0)
+
+	var pr Reader
+	err := pr.skipInvalid(&perfEventRing{ringReader: fr})
+	qt.Assert(t, err, qt.ErrorIs, ErrCorruptRing)
+	qt.Assert(t, pr.CorruptRings(), qt.Equals, uint64(1))
+}
@@ -0,0 +1,125 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// runQueueSize is the number of records buffered per worker in Reader.Run.
+const runQueueSize = 64
+
+// RunOptions control the behaviour of Reader.Run.
+type RunOptions struct {
+	// The number of goroutines invoking the handler. Records from the same
+	// CPU are always passed to the same goroutine, in the order they were
+	// read. Defaults to 1.
+	Workers int
+	// Called with the number of samples lost on a CPU. If nil, records
+	// reporting lost samples are passed to the handler.
+	LostSamples func(cpu int, lost uint64)
+}
+
+// Run reads records until ctx is cancelled, the Reader is closed or the
+// handler returns an error.
+//
+// Each record is passed to handler from one of RunOptions.Workers goroutines.
+// Records are owned by the handler and may be retained. Pause and Resume may be
+// called concurrently. The deadline set via SetDeadline doesn't apply while Run
+// is active and is restored once it returns.
+//
+// Returns nil if the Reader was closed, the first error returned by handler,
+// or ctx.Err().
+func (pr *Reader) Run(ctx context.Context, handler func(Record) error, opts *RunOptions) error {
+	restore := pr.suspendDeadline()
+	defer restore()
+
+	var o RunOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg         sync.WaitGroup
+		failOnce   sync.Once
+		handlerErr error
+	)
+
+	queues := make([]chan Record, o.Workers)
+	for i := range queues {
+		queues[i] = make(chan Record, runQueueSize)
+
+		wg.Add(1)
+		go func(queue <-chan Record) {
+			defer wg.Done()
+
+			for rec := range queue {
+				if ctx.Err() != nil {
+					// Drain the queue after a failure.
+					continue
+				}
+
+				if err := handler(rec); err != nil {
+					failOnce.Do(func() {
+						handlerErr = err
+						cancel()
+					})
+				}
+			}
+		}(queues[i])
+	}
+
+	err := pr.dispatch(ctx, queues, o.LostSamples)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	if handlerErr != nil {
+		return handlerErr
+	}
+	return err
+}
+
+// suspendDeadline clears the deadline of the Reader and returns a function
+// which restores it.
+func (pr *Reader) suspendDeadline() (restore func()) {
+	pr.mu.Lock()
+	deadline := pr.deadline
+	pr.deadline = time.Time{}
+	pr.mu.Unlock()
+
+	return func() { pr.SetDeadline(deadline) }
+}
+
+// dispatch reads records and distributes them to queues based on their CPU.
+func (pr *Reader) dispatch(ctx context.Context, queues []chan Record, lost func(int, uint64)) error {
+	for {
+		rec, err := pr.ReadContext(ctx)
+		if errors.Is(err, ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if rec.LostSamples > 0 && lost != nil {
+			lost(rec.CPU, rec.LostSamples)
+			continue
+		}
+
+		select {
+		case queues[rec.CPU%len(queues)] <- rec:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestReaderRun(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	outputSamples(t, events, 5, 6, 7)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		ids []int
+	)
+	err = rd.Run(ctx, func(rec Record) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		sample := rec.RawSample[perfEventSampleSize:]
+		ids = append(ids, int(sample[1]))
+		if len(ids) == 3 {
+			cancel()
+		}
+		return nil
+	}, &RunOptions{Workers: 2})
+	qt.Assert(t, errors.Is(err, context.Canceled), qt.IsTrue, qt.Commentf("got error: %v", err))
+
+	// All samples were submitted on the same CPU, so they are handled in order.
+	qt.Assert(t, ids, qt.DeepEquals, []int{0, 1, 2})
+}
+
+func TestReaderRunHandlerError(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	outputSamples(t, events, 5, 6)
+
+	// Run ignores the deadline while active and restores it afterwards.
+	deadline := time.Now().Add(-time.Second)
+	rd.SetDeadline(deadline)
+
+	errHandler := errors.New("handler failed")
+	calls := 0
+	err = rd.Run(context.Background(), func(Record) error {
+		calls++
+		return errHandler
+	}, nil)
+	qt.Assert(t, errors.Is(err, errHandler), qt.IsTrue, qt.Commentf("got error: %v", err))
+	qt.Assert(t, calls, qt.Equals, 1)
+	qt.Assert(t, rd.deadline, qt.Equals, deadline)
+}
+
+func TestReaderRunClose(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Run ignores deadlines set before it was invoked.
+	rd.SetDeadline(time.Now().Add(-time.Second))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rd.Run(context.Background(), func(Record) error { return nil }, nil)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	qt.Assert(t, rd.Close(), qt.IsNil)
+
+	select {
+	case err := <-done:
+		qt.Assert(t, err, qt.IsNil)
+	case <-time.After(time.Second):
+		t.Fatal("Close doesn't stop Run")
+	}
+}
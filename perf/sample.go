@@ -0,0 +1,585 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	linux "golang.org/x/sys/unix"
+)
+
+// Bits of perf_event_attr which are missing from x/sys/unix.
+const (
+	perfBitNamespaces uint64 = 1 << 28
+	perfBitKsymbol    uint64 = 1 << 29
+	perfBitBPFEvent   uint64 = 1 << 30
+	perfBitCgroup     uint64 = 1 << 32
+)
+
+// sampleFields lists the fields of PERF_RECORD_SAMPLE in the order the kernel
+// writes them, see perf_output_sample.
+var sampleFields = []uint64{
+	linux.PERF_SAMPLE_IDENTIFIER,
+	linux.PERF_SAMPLE_IP,
+	linux.PERF_SAMPLE_TID,
+	linux.PERF_SAMPLE_TIME,
+	linux.PERF_SAMPLE_ADDR,
+	linux.PERF_SAMPLE_ID,
+	linux.PERF_SAMPLE_STREAM_ID,
+	linux.PERF_SAMPLE_CPU,
+	linux.PERF_SAMPLE_PERIOD,
+	linux.PERF_SAMPLE_READ,
+	linux.PERF_SAMPLE_CALLCHAIN,
+	linux.PERF_SAMPLE_RAW,
+	linux.PERF_SAMPLE_BRANCH_STACK,
+	linux.PERF_SAMPLE_REGS_USER,
+	linux.PERF_SAMPLE_STACK_USER,
+	linux.PERF_SAMPLE_WEIGHT | linux.PERF_SAMPLE_WEIGHT_STRUCT,
+	linux.PERF_SAMPLE_DATA_SRC,
+	linux.PERF_SAMPLE_TRANSACTION,
+	linux.PERF_SAMPLE_REGS_INTR,
+	linux.PERF_SAMPLE_PHYS_ADDR,
+	linux.PERF_SAMPLE_CGROUP,
+	linux.PERF_SAMPLE_DATA_PAGE_SIZE,
+	linux.PERF_SAMPLE_CODE_PAGE_SIZE,
+	linux.PERF_SAMPLE_AUX,
+}
+
+// field returns the bytes of the sample field identified by bit, or nil if
+// the field isn't part of body.
+func (sl *sampleLayout) field(body []byte, bit uint64) []byte {
+	if sl == nil || sl.sampleType&bit == 0 {
+		return nil
+	}
+
+	off := 0
+	for _, f := range sampleFields {
+		if sl.sampleType&f == 0 {
+			continue
+		}
+
+		n := sl.fieldSize(f, body[off:])
+		if n < 0 || off+n > len(body) {
+			return nil
+		}
+
+		if f&bit != 0 {
+			return body[off : off+n]
+		}
+		off += n
+	}
+
+	return nil
+}
+
+// fieldSize returns the size of the field f at the start of body, or -1 if
+// the size can't be determined.
+func (sl *sampleLayout) fieldSize(f uint64, body []byte) int {
+	u64 := func(off int) int {
+		if off+8 > len(body) {
+			return -1
+		}
+		v := internal.NativeEndian.Uint64(body[off:])
+		if v > uint64(len(body)) {
+			return -1
+		}
+		return int(v)
+	}
+
+	switch f {
+	case linux.PERF_SAMPLE_READ:
+		return sl.readSize(body)
+
+	case linux.PERF_SAMPLE_CALLCHAIN:
+		if nr := u64(0); nr >= 0 {
+			return 8 + nr*8
+		}
+		return -1
+
+	case linux.PERF_SAMPLE_RAW:
+		if len(body) < 4 {
+			return -1
+		}
+		return 4 + int(internal.NativeEndian.Uint32(body))
+
+	case linux.PERF_SAMPLE_BRANCH_STACK:
+		nr := u64(0)
+		if nr < 0 {
+			return -1
+		}
+		size := 8 + nr*24
+		if sl.branchHWIndex {
+			size += 8
+		}
+		return size
+
+	case linux.PERF_SAMPLE_REGS_USER, linux.PERF_SAMPLE_REGS_INTR:
+		abi := u64(0)
+		if abi <= 0 {
+			return 8
+		}
+		if f == linux.PERF_SAMPLE_REGS_USER {
+			return 8 + sl.regsUser*8
+		}
+		return 8 + sl.regsIntr*8
+
+	case linux.PERF_SAMPLE_STACK_USER:
+		size := u64(0)
+		if size <= 0 {
+			return 8
+		}
+		// The stack is followed by its dynamic size.
+		return 8 + size + 8
+
+	case linux.PERF_SAMPLE_AUX:
+		if size := u64(0); size >= 0 {
+			return 8 + size
+		}
+		return -1
+
+	default:
+		return 8
+	}
+}
+
+// readSize returns the size of the PERF_SAMPLE_READ field at the start of
+// body, which depends on read_format.
+func (sl *sampleLayout) readSize(body []byte) int {
+	values := 1
+	if sl.readFormat&linux.PERF_FORMAT_ID != 0 {
+		values++
+	}
+	if sl.readFormat&linux.PERF_FORMAT_LOST != 0 {
+		values++
+	}
+
+	times := 0
+	if sl.readFormat&linux.PERF_FORMAT_TOTAL_TIME_ENABLED != 0 {
+		times++
+	}
+	if sl.readFormat&linux.PERF_FORMAT_TOTAL_TIME_RUNNING != 0 {
+		times++
+	}
+
+	if sl.readFormat&linux.PERF_FORMAT_GROUP == 0 {
+		return (values + times) * 8
+	}
+
+	if len(body) < 8 {
+		return -1
+	}
+	nr := internal.NativeEndian.Uint64(body)
+	if nr > uint64(len(body)) {
+		return -1
+	}
+	return 8 + times*8 + int(nr)*values*8
+}
+
+// counters decodes a PERF_SAMPLE_READ field in group format into m.
+func (sl *sampleLayout) counters(read []byte, m map[string]uint64) {
+	off := 8
+	if sl.readFormat&linux.PERF_FORMAT_TOTAL_TIME_ENABLED != 0 {
+		off += 8
+	}
+	if sl.readFormat&linux.PERF_FORMAT_TOTAL_TIME_RUNNING != 0 {
+		off += 8
+	}
+
+	// Each value is followed by its ID and optionally the number of lost
+	// samples.
+	entry := 16
+	if sl.readFormat&linux.PERF_FORMAT_LOST != 0 {
+		entry += 8
+	}
+
+	for ; off+entry <= len(read); off += entry {
+		id := internal.NativeEndian.Uint64(read[off+8:])
+		if name, ok := sl.counterNames[id]; ok {
+			m[name] = internal.NativeEndian.Uint64(read[off:])
+		}
+	}
+}
+
+// uint64 returns the value of a fixed size sample field, or zero if the
+// field isn't present.
+func (sl *sampleLayout) uint64(body []byte, bit uint64) uint64 {
+	field := sl.field(body, bit)
+	if len(field) != 8 {
+		return 0
+	}
+	return internal.NativeEndian.Uint64(field)
+}
+
+// SampleWeight is the weight of a sample.
+type SampleWeight struct {
+	// The full weight for PERF_SAMPLE_WEIGHT, or the first 32 bits of
+	// PERF_SAMPLE_WEIGHT_STRUCT. Usually the latency of a memory access.
+	Latency uint64
+	// The latency of the sampled instruction. Only available with
+	// PERF_SAMPLE_WEIGHT_STRUCT.
+	InstructionLatency uint16
+	// An architecture specific latency, for example pipeline stage cycles on
+	// POWER or retire latency on x86. Only available with
+	// PERF_SAMPLE_WEIGHT_STRUCT.
+	Var3 uint16
+}
+
+// Transaction describes a hardware transaction, for example Intel TSX.
+//
+// The lower 32 bits are flags, the upper 32 bits the abort code.
+type Transaction uint64
+
+const (
+	// The sample is from an elided lock region.
+	TxnElision Transaction = linux.PERF_TXN_ELISION
+	// The sample is from a transaction.
+	TxnTransaction Transaction = linux.PERF_TXN_TRANSACTION
+	// The transaction aborted due to the sampled instruction.
+	TxnSync Transaction = linux.PERF_TXN_SYNC
+	// The transaction aborted asynchronously.
+	TxnAsync Transaction = linux.PERF_TXN_ASYNC
+	// Retrying the transaction may succeed.
+	TxnRetry Transaction = linux.PERF_TXN_RETRY
+	// The transaction aborted due to a memory conflict.
+	TxnConflict Transaction = linux.PERF_TXN_CONFLICT
+	// The transaction aborted due to exceeding the write capacity.
+	TxnCapacityWrite Transaction = linux.PERF_TXN_CAPACITY_WRITE
+	// The transaction aborted due to exceeding the read capacity.
+	TxnCapacityRead Transaction = linux.PERF_TXN_CAPACITY_READ
+)
+
+// Flags returns t without the abort code.
+func (t Transaction) Flags() Transaction {
+	return t & 0xffffffff
+}
+
+// AbortCode returns the code passed to the instruction which explicitly
+// aborted the transaction, for example XABORT.
+func (t Transaction) AbortCode() uint32 {
+	return uint32(t >> 32)
+}
+
+// decodeSample populates the fields of rec which are derived from optional
+// fields of PERF_RECORD_SAMPLE.
+func (sl *sampleLayout) decodeSample(rec *Record) {
+	if sl == nil {
+		return
+	}
+
+	rec.CgroupID = sl.uint64(rec.RawSample, linux.PERF_SAMPLE_CGROUP)
+	rec.Transaction = Transaction(sl.uint64(rec.RawSample, linux.PERF_SAMPLE_TRANSACTION))
+	if tid := sl.field(rec.RawSample, linux.PERF_SAMPLE_TID); len(tid) == 8 {
+		rec.Pid = internal.NativeEndian.Uint32(tid[0:])
+		rec.Tid = internal.NativeEndian.Uint32(tid[4:])
+	}
+	if len(sl.counterNames) > 0 {
+		if read := sl.field(rec.RawSample, linux.PERF_SAMPLE_READ); read != nil {
+			if rec.Counters == nil {
+				rec.Counters = make(map[string]uint64, len(sl.counterNames))
+			}
+			sl.counters(read, rec.Counters)
+		}
+	}
+	if sl.sampleType&linux.PERF_SAMPLE_BRANCH_STACK != 0 {
+		rec.Branches = sl.decodeBranches(sl.field(rec.RawSample, linux.PERF_SAMPLE_BRANCH_STACK), rec.Branches)
+	}
+	if sl.sampleType&linux.PERF_SAMPLE_REGS_INTR != 0 {
+		rec.RegsIntr = decodeRegs(sl.field(rec.RawSample, linux.PERF_SAMPLE_REGS_INTR), rec.RegsIntr)
+	}
+	if sl.sampleType&linux.PERF_SAMPLE_REGS_USER != 0 {
+		rec.RegsUser = decodeRegs(sl.field(rec.RawSample, linux.PERF_SAMPLE_REGS_USER), rec.RegsUser)
+	}
+	if sl.sampleType&linux.PERF_SAMPLE_STACK_USER != 0 {
+		rec.StackUser = decodeStack(sl.field(rec.RawSample, linux.PERF_SAMPLE_STACK_USER))
+	}
+	rec.IP = sl.uint64(rec.RawSample, linux.PERF_SAMPLE_IP)
+	rec.Addr = sl.uint64(rec.RawSample, linux.PERF_SAMPLE_ADDR)
+	rec.ID = sl.uint64(rec.RawSample, linux.PERF_SAMPLE_ID)
+	rec.StreamID = sl.uint64(rec.RawSample, linux.PERF_SAMPLE_STREAM_ID)
+
+	weight := sl.uint64(rec.RawSample, linux.PERF_SAMPLE_WEIGHT|linux.PERF_SAMPLE_WEIGHT_STRUCT)
+	if sl.sampleType&linux.PERF_SAMPLE_WEIGHT_STRUCT == 0 {
+		rec.Weight = SampleWeight{Latency: weight}
+		return
+	}
+
+	// union perf_sample_weight stores the fields in native endianness.
+	var raw [8]byte
+	internal.NativeEndian.PutUint64(raw[:], weight)
+	rec.Weight = SampleWeight{
+		Latency:            uint64(internal.NativeEndian.Uint32(raw[0:])),
+		InstructionLatency: internal.NativeEndian.Uint16(raw[4:]),
+		Var3:               internal.NativeEndian.Uint16(raw[6:]),
+	}
+}
+
+// Cgroup decodes a PERF_RECORD_CGROUP record, which announces the path of a
+// cgroup identified by Record.CgroupID.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) Cgroup() (id uint64, path string, ok bool) {
+	if r.RecordType != linux.PERF_RECORD_CGROUP || len(r.RawSample) < 8 {
+		return 0, "", false
+	}
+
+	id = internal.NativeEndian.Uint64(r.RawSample)
+	// The path is padded with NUL bytes and may be followed by a sample_id.
+	raw, _, ok := bytes.Cut(r.RawSample[8:], []byte{0})
+	if !ok {
+		return 0, "", false
+	}
+	return id, string(raw), true
+}
+
+// Namespace identifies a namespace by the device and inode of its entry in
+// /proc/<pid>/ns.
+type Namespace struct {
+	Dev   uint64
+	Inode uint64
+}
+
+// NamespacesRecord describes the namespaces of a task.
+type NamespacesRecord struct {
+	Pid, Tid uint32
+	// Indexed by the NET_NS_INDEX, UTS_NS_INDEX, etc. constants of
+	// linux/perf_event.h.
+	Namespaces []Namespace
+}
+
+// Namespaces decodes a PERF_RECORD_NAMESPACES record.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) Namespaces() (*NamespacesRecord, bool) {
+	body := r.RawSample
+	if r.RecordType != linux.PERF_RECORD_NAMESPACES || len(body) < 16 {
+		return nil, false
+	}
+
+	nr := internal.NativeEndian.Uint64(body[8:])
+	if nr > uint64(len(body)-16)/16 {
+		return nil, false
+	}
+
+	rec := &NamespacesRecord{
+		Pid:        internal.NativeEndian.Uint32(body[0:]),
+		Tid:        internal.NativeEndian.Uint32(body[4:]),
+		Namespaces: make([]Namespace, nr),
+	}
+	for i := range rec.Namespaces {
+		off := 16 + i*16
+		rec.Namespaces[i] = Namespace{
+			internal.NativeEndian.Uint64(body[off:]),
+			internal.NativeEndian.Uint64(body[off+8:]),
+		}
+	}
+
+	return rec, true
+}
+
+// SwitchRecord describes a context switch.
+type SwitchRecord struct {
+	// Out is true if the task was scheduled out, and false if it was
+	// scheduled in.
+	Out bool
+	// Preempt is true if the task was preempted while still runnable.
+	Preempt bool
+	// The task scheduled in next if Out is true, otherwise the task which
+	// was scheduled out before. Only populated for
+	// PERF_RECORD_SWITCH_CPU_WIDE.
+	Pid, Tid uint32
+}
+
+// Switch decodes a PERF_RECORD_SWITCH or PERF_RECORD_SWITCH_CPU_WIDE record.
+//
+// Returns false if the record is of a different type.
+func (r *Record) Switch() (*SwitchRecord, bool) {
+	rec := &SwitchRecord{
+		Out:     r.Misc&linux.PERF_RECORD_MISC_SWITCH_OUT != 0,
+		Preempt: r.Misc&linux.PERF_RECORD_MISC_SWITCH_OUT_PREEMPT != 0,
+	}
+
+	switch r.RecordType {
+	case linux.PERF_RECORD_SWITCH:
+		return rec, true
+
+	case linux.PERF_RECORD_SWITCH_CPU_WIDE:
+		if len(r.RawSample) < 8 {
+			return nil, false
+		}
+		rec.Pid = internal.NativeEndian.Uint32(r.RawSample[0:])
+		rec.Tid = internal.NativeEndian.Uint32(r.RawSample[4:])
+		return rec, true
+
+	default:
+		return nil, false
+	}
+}
+
+// TaskRecord describes a task being created or exiting.
+type TaskRecord struct {
+	// Exit is true for PERF_RECORD_EXIT and false for PERF_RECORD_FORK.
+	Exit bool
+	// The process and thread of the task, and of its parent.
+	Pid, Ppid uint32
+	Tid, Ptid uint32
+}
+
+// Task decodes a PERF_RECORD_FORK or PERF_RECORD_EXIT record.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) Task() (*TaskRecord, bool) {
+	body := r.RawSample
+	if (r.RecordType != linux.PERF_RECORD_FORK && r.RecordType != linux.PERF_RECORD_EXIT) || len(body) < 16 {
+		return nil, false
+	}
+
+	return &TaskRecord{
+		Exit: r.RecordType == linux.PERF_RECORD_EXIT,
+		Pid:  internal.NativeEndian.Uint32(body[0:]),
+		Ppid: internal.NativeEndian.Uint32(body[4:]),
+		Tid:  internal.NativeEndian.Uint32(body[8:]),
+		Ptid: internal.NativeEndian.Uint32(body[12:]),
+	}, true
+}
+
+// decodeRegs appends the registers in a PERF_SAMPLE_REGS_* field to regs.
+// The field is empty if the ABI is PERF_SAMPLE_REGS_ABI_NONE, which happens
+// if no registers were available.
+func decodeRegs(field []byte, regs []uint64) []uint64 {
+	if len(field) < 8 || internal.NativeEndian.Uint64(field) == linux.PERF_SAMPLE_REGS_ABI_NONE {
+		return regs
+	}
+
+	for off := 8; off+8 <= len(field); off += 8 {
+		regs = append(regs, internal.NativeEndian.Uint64(field[off:]))
+	}
+	return regs
+}
+
+// decodeStack returns the part of a PERF_SAMPLE_STACK_USER field which was
+// actually copied from the stack.
+func decodeStack(field []byte) []byte {
+	if len(field) < 16 {
+		return nil
+	}
+
+	size := internal.NativeEndian.Uint64(field)
+	if size == 0 || size+16 > uint64(len(field)) {
+		return nil
+	}
+
+	// The stack is followed by the number of bytes which were copied.
+	dyn := internal.NativeEndian.Uint64(field[8+size:])
+	if dyn > size {
+		dyn = size
+	}
+	return field[8 : 8+dyn : 8+dyn]
+}
+
+// MmapRecord describes a memory mapping created by a task.
+type MmapRecord struct {
+	Pid, Tid uint32
+	// The address, length and file offset of the mapping.
+	Addr, Len, Pgoff uint64
+	// The protection and flags passed to mmap, see PROT_* and MAP_*.
+	Prot, Flags uint32
+	// The path of the mapped file, or a pseudo path like "[heap]" or
+	// "//anon".
+	Filename string
+}
+
+// Mmap decodes a PERF_RECORD_MMAP2 record, see ExtraPerfOptions.PerfMmap.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) Mmap() (*MmapRecord, bool) {
+	// pid, tid, addr, len, pgoff, a 24 byte union identifying the file,
+	// prot and flags precede the filename.
+	const filenameOff = 64
+
+	body := r.RawSample
+	if r.RecordType != linux.PERF_RECORD_MMAP2 || len(body) < filenameOff {
+		return nil, false
+	}
+
+	// The filename is padded with NUL bytes and may be followed by a
+	// sample_id.
+	filename, _, ok := bytes.Cut(body[filenameOff:], []byte{0})
+	if !ok {
+		return nil, false
+	}
+
+	return &MmapRecord{
+		Pid:      internal.NativeEndian.Uint32(body[0:]),
+		Tid:      internal.NativeEndian.Uint32(body[4:]),
+		Addr:     internal.NativeEndian.Uint64(body[8:]),
+		Len:      internal.NativeEndian.Uint64(body[16:]),
+		Pgoff:    internal.NativeEndian.Uint64(body[24:]),
+		Prot:     internal.NativeEndian.Uint32(body[56:]),
+		Flags:    internal.NativeEndian.Uint32(body[60:]),
+		Filename: string(filename),
+	}, true
+}
+
+// KsymbolRecord describes a kernel symbol which was registered or
+// unregistered at runtime, for example the image of a JITed BPF program.
+type KsymbolRecord struct {
+	Addr uint64
+	Len  uint32
+	// One of PERF_RECORD_KSYMBOL_TYPE_*.
+	Type       uint16
+	Unregister bool
+	Name       string
+}
+
+// Ksymbol decodes a PERF_RECORD_KSYMBOL record.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) Ksymbol() (*KsymbolRecord, bool) {
+	body := r.RawSample
+	if r.RecordType != linux.PERF_RECORD_KSYMBOL || len(body) < 16 {
+		return nil, false
+	}
+
+	// The name is padded with NUL bytes and may be followed by a sample_id.
+	name, _, ok := bytes.Cut(body[16:], []byte{0})
+	if !ok {
+		return nil, false
+	}
+
+	flags := internal.NativeEndian.Uint16(body[14:])
+	return &KsymbolRecord{
+		Addr:       internal.NativeEndian.Uint64(body[0:]),
+		Len:        internal.NativeEndian.Uint32(body[8:]),
+		Type:       internal.NativeEndian.Uint16(body[12:]),
+		Unregister: flags&linux.PERF_RECORD_KSYMBOL_FLAGS_UNREGISTER != 0,
+		Name:       string(name),
+	}, true
+}
+
+// BPFEventRecord describes a BPF program being loaded or unloaded.
+type BPFEventRecord struct {
+	// One of PERF_BPF_EVENT_*.
+	Type  uint16
+	Flags uint16
+	ID    ebpf.ProgramID
+	// The tag of the program, see ebpf.ProgramInfo.Tag.
+	Tag string
+}
+
+// BPFEvent decodes a PERF_RECORD_BPF_EVENT record.
+//
+// Returns false if the record is of a different type or malformed.
+func (r *Record) BPFEvent() (*BPFEventRecord, bool) {
+	body := r.RawSample
+	if r.RecordType != linux.PERF_RECORD_BPF_EVENT || len(body) < 16 {
+		return nil, false
+	}
+
+	return &BPFEventRecord{
+		Type:  internal.NativeEndian.Uint16(body[0:]),
+		Flags: internal.NativeEndian.Uint16(body[2:]),
+		ID:    ebpf.ProgramID(internal.NativeEndian.Uint32(body[4:])),
+		Tag:   hex.EncodeToString(body[8:16]),
+	}, true
+}
@@ -0,0 +1,394 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
+)
+
+func TestSampleLayoutField(t *testing.T) {
+	attr := linux.PerfEventAttr{
+		Sample_type: linux.PERF_SAMPLE_TIME | linux.PERF_SAMPLE_RAW |
+			linux.PERF_SAMPLE_REGS_USER | linux.PERF_SAMPLE_STACK_USER |
+			linux.PERF_SAMPLE_CGROUP,
+		Sample_regs_user: 0b101,
+	}
+	layout := newSampleLayout(&attr)
+
+	var buf bytes.Buffer
+	write := func(v any) { binary.Write(&buf, internal.NativeEndian, v) }
+	write(uint64(42))         // time
+	write(uint32(4))          // raw size
+	write([]byte{1, 2, 3, 4}) // raw data
+	write(uint64(linux.PERF_SAMPLE_REGS_ABI_64))
+	write([]uint64{1, 2})   // regs
+	write(uint64(16))       // stack size
+	write(make([]byte, 16)) // stack
+	write(uint64(8))        // dyn size
+	write(uint64(0xcafe))   // cgroup
+	body := buf.Bytes()
+
+	qt.Assert(t, layout.uint64(body, linux.PERF_SAMPLE_TIME), qt.Equals, uint64(42))
+	qt.Assert(t, layout.uint64(body, linux.PERF_SAMPLE_CGROUP), qt.Equals, uint64(0xcafe))
+	qt.Assert(t, layout.field(body, linux.PERF_SAMPLE_RAW), qt.DeepEquals, body[8:16])
+	qt.Assert(t, layout.uint64(body, linux.PERF_SAMPLE_CPU), qt.Equals, uint64(0))
+
+	// Truncated records don't panic.
+	for i := range body {
+		layout.field(body[:i], linux.PERF_SAMPLE_CGROUP)
+	}
+}
+
+func TestSampleLayoutWeight(t *testing.T) {
+	body := make([]byte, 16)
+	internal.NativeEndian.PutUint32(body[0:], 4)
+	internal.NativeEndian.PutUint32(body[8:], 100)
+	internal.NativeEndian.PutUint16(body[12:], 7)
+	internal.NativeEndian.PutUint16(body[14:], 3)
+
+	attr := linux.PerfEventAttr{Sample_type: linux.PERF_SAMPLE_RAW | linux.PERF_SAMPLE_WEIGHT}
+	var rec Record
+	rec.RawSample = body
+	newSampleLayout(&attr).decodeSample(&rec)
+	qt.Assert(t, rec.Weight, qt.Equals, SampleWeight{Latency: internal.NativeEndian.Uint64(body[8:])})
+
+	attr.Sample_type = linux.PERF_SAMPLE_RAW | linux.PERF_SAMPLE_WEIGHT_STRUCT
+	newSampleLayout(&attr).decodeSample(&rec)
+	qt.Assert(t, rec.Weight, qt.Equals, SampleWeight{100, 7, 3})
+}
+
+func TestSampleLayoutTransaction(t *testing.T) {
+	body := make([]byte, 24)
+	internal.NativeEndian.PutUint64(body[0:], 3)
+	internal.NativeEndian.PutUint64(body[8:], 0xffff)
+	internal.NativeEndian.PutUint64(body[16:], 0x2a<<32|uint64(TxnTransaction|TxnSync))
+
+	attr := linux.PerfEventAttr{
+		Sample_type: linux.PERF_SAMPLE_TIME | linux.PERF_SAMPLE_WEIGHT | linux.PERF_SAMPLE_TRANSACTION,
+	}
+	var rec Record
+	rec.RawSample = body
+	newSampleLayout(&attr).decodeSample(&rec)
+	qt.Assert(t, rec.Weight.Latency, qt.Equals, uint64(0xffff))
+	qt.Assert(t, rec.Transaction.Flags(), qt.Equals, TxnTransaction|TxnSync)
+	qt.Assert(t, rec.Transaction.AbortCode(), qt.Equals, uint32(0x2a))
+}
+
+func TestRecordCgroup(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, internal.NativeEndian, uint64(7))
+	buf.WriteString("/system.slice\x00\x00\x00")
+
+	rec := Record{RecordType: linux.PERF_RECORD_CGROUP, RawSample: buf.Bytes()}
+	id, path, ok := rec.Cgroup()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, id, qt.Equals, uint64(7))
+	qt.Assert(t, path, qt.Equals, "/system.slice")
+
+	rec.RecordType = linux.PERF_RECORD_SAMPLE
+	_, _, ok = rec.Cgroup()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestRecordNamespaces(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, internal.NativeEndian, []uint32{1, 2})
+	binary.Write(&buf, internal.NativeEndian, []uint64{2, 3, 4, 5, 6})
+
+	rec := Record{RecordType: linux.PERF_RECORD_NAMESPACES, RawSample: buf.Bytes()}
+	ns, ok := rec.Namespaces()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, ns, qt.DeepEquals, &NamespacesRecord{
+		Pid:        1,
+		Tid:        2,
+		Namespaces: []Namespace{{3, 4}, {5, 6}},
+	})
+
+	rec.RawSample = rec.RawSample[:24]
+	_, ok = rec.Namespaces()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestReaderSampleCgroup(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{SampleCgroup: true})
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rec.CgroupID, qt.Not(qt.Equals), uint64(0))
+
+	// The raw sample still comes first.
+	sample := rec.RawSample[perfEventSampleSize:]
+	qt.Assert(t, int(sample[0]), qt.Equals, 5)
+}
+
+func TestRecordSwitch(t *testing.T) {
+	body := make([]byte, 8)
+	internal.NativeEndian.PutUint32(body[0:], 1)
+	internal.NativeEndian.PutUint32(body[4:], 2)
+
+	rec := Record{
+		RecordType: linux.PERF_RECORD_SWITCH_CPU_WIDE,
+		Misc:       linux.PERF_RECORD_MISC_SWITCH_OUT | linux.PERF_RECORD_MISC_SWITCH_OUT_PREEMPT,
+		RawSample:  body,
+	}
+	sw, ok := rec.Switch()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, sw, qt.DeepEquals, &SwitchRecord{Out: true, Preempt: true, Pid: 1, Tid: 2})
+
+	rec = Record{RecordType: linux.PERF_RECORD_SWITCH}
+	sw, ok = rec.Switch()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, sw, qt.DeepEquals, &SwitchRecord{})
+
+	rec.RecordType = linux.PERF_RECORD_SAMPLE
+	_, ok = rec.Switch()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestRecordTask(t *testing.T) {
+	rec := taskRecord(linux.PERF_RECORD_FORK, 3, 2, 4)
+	task, ok := rec.Task()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, task, qt.DeepEquals, &TaskRecord{Pid: 3, Ppid: 2, Tid: 4, Ptid: 2})
+
+	rec.RecordType = linux.PERF_RECORD_EXIT
+	task, ok = rec.Task()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, task.Exit, qt.IsTrue)
+
+	rec.RecordType = linux.PERF_RECORD_SAMPLE
+	_, ok = rec.Task()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+// taskRecord builds a PERF_RECORD_FORK or PERF_RECORD_EXIT record. The
+// parent thread is ppid.
+func taskRecord(typ uint32, pid, ppid, tid uint32) *Record {
+	body := make([]byte, 24)
+	internal.NativeEndian.PutUint32(body[0:], pid)
+	internal.NativeEndian.PutUint32(body[4:], ppid)
+	internal.NativeEndian.PutUint32(body[8:], tid)
+	internal.NativeEndian.PutUint32(body[12:], ppid)
+	return &Record{RecordType: typ, RawSample: body}
+}
+
+func TestReaderContextSwitch(t *testing.T) {
+	rd, err := NewSidebandReader(4096, ReaderOptions{}, ExtraPerfOptions{ContextSwitch: true})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	time.Sleep(time.Millisecond)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	for {
+		rec, err := rd.Read()
+		qt.Assert(t, err, qt.IsNil)
+		if _, ok := rec.Switch(); ok {
+			qt.Assert(t, rec.RecordType, qt.Equals, uint32(linux.PERF_RECORD_SWITCH_CPU_WIDE))
+			break
+		}
+	}
+}
+
+func TestRecordKsymbol(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, internal.NativeEndian, uint64(0xffff0000))
+	binary.Write(&buf, internal.NativeEndian, uint32(64))
+	binary.Write(&buf, internal.NativeEndian, uint16(linux.PERF_RECORD_KSYMBOL_TYPE_BPF))
+	binary.Write(&buf, internal.NativeEndian, uint16(linux.PERF_RECORD_KSYMBOL_FLAGS_UNREGISTER))
+	buf.WriteString("bpf_prog_0123_foo\x00\x00\x00\x00\x00\x00\x00")
+
+	rec := Record{RecordType: linux.PERF_RECORD_KSYMBOL, RawSample: buf.Bytes()}
+	sym, ok := rec.Ksymbol()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, sym, qt.DeepEquals, &KsymbolRecord{
+		Addr:       0xffff0000,
+		Len:        64,
+		Type:       linux.PERF_RECORD_KSYMBOL_TYPE_BPF,
+		Unregister: true,
+		Name:       "bpf_prog_0123_foo",
+	})
+
+	rec.RawSample = rec.RawSample[:20]
+	_, ok = rec.Ksymbol()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestRecordBPFEvent(t *testing.T) {
+	var buf bytes.Buffer
+	binary.Write(&buf, internal.NativeEndian, uint16(linux.PERF_BPF_EVENT_PROG_LOAD))
+	binary.Write(&buf, internal.NativeEndian, uint16(0))
+	binary.Write(&buf, internal.NativeEndian, uint32(42))
+	buf.Write([]byte{0xde, 0xad, 0xbe, 0xef, 0, 1, 2, 3})
+
+	rec := Record{RecordType: linux.PERF_RECORD_BPF_EVENT, RawSample: buf.Bytes()}
+	ev, ok := rec.BPFEvent()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, ev, qt.DeepEquals, &BPFEventRecord{
+		Type: linux.PERF_BPF_EVENT_PROG_LOAD,
+		ID:   42,
+		Tag:  "deadbeef00010203",
+	})
+
+	rec.RecordType = linux.PERF_RECORD_KSYMBOL
+	_, ok = rec.BPFEvent()
+	qt.Assert(t, ok, qt.IsFalse)
+}
+
+func TestReaderBPFEvent(t *testing.T) {
+	rd, err := NewSidebandReader(4096, ReaderOptions{}, ExtraPerfOptions{Ksymbol: true, BPFEvent: true})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	prog := outputSamplesProg(t, perfEventArray(t), 5)
+	info, err := prog.Info()
+	qt.Assert(t, err, qt.IsNil)
+	id, ok := info.ID()
+	qt.Assert(t, ok, qt.IsTrue)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	var sawKsymbol bool
+	for {
+		rec, err := rd.Read()
+		qt.Assert(t, err, qt.IsNil)
+
+		if sym, ok := rec.Ksymbol(); ok && sym.Type == linux.PERF_RECORD_KSYMBOL_TYPE_BPF {
+			sawKsymbol = true
+		}
+
+		if ev, ok := rec.BPFEvent(); ok && ev.ID == id {
+			qt.Assert(t, ev.Type, qt.Equals, uint16(linux.PERF_BPF_EVENT_PROG_LOAD))
+			qt.Assert(t, ev.Tag, qt.Equals, info.Tag)
+			break
+		}
+	}
+	qt.Assert(t, sawKsymbol, qt.IsTrue)
+}
+
+func TestSampleLayoutRegsIntr(t *testing.T) {
+	attr := linux.PerfEventAttr{
+		Sample_type:      linux.PERF_SAMPLE_REGS_USER | linux.PERF_SAMPLE_REGS_INTR,
+		Sample_regs_user: 0b1,
+		Sample_regs_intr: 0b1011,
+	}
+
+	var buf bytes.Buffer
+	write := func(v any) { binary.Write(&buf, internal.NativeEndian, v) }
+	write(uint64(linux.PERF_SAMPLE_REGS_ABI_64))
+	write(uint64(42)) // user regs
+	write(uint64(linux.PERF_SAMPLE_REGS_ABI_64))
+	write([]uint64{1, 2, 3}) // intr regs
+
+	var rec Record
+	rec.RawSample = buf.Bytes()
+	newSampleLayout(&attr).decodeSample(&rec)
+	qt.Assert(t, rec.RegsIntr, qt.DeepEquals, []uint64{1, 2, 3})
+
+	// No registers were available.
+	buf.Reset()
+	write(uint64(linux.PERF_SAMPLE_REGS_ABI_NONE))
+	write(uint64(linux.PERF_SAMPLE_REGS_ABI_NONE))
+	rec.RawSample = buf.Bytes()
+	rec.RegsIntr = rec.RegsIntr[:0]
+	newSampleLayout(&attr).decodeSample(&rec)
+	qt.Assert(t, rec.RegsIntr, qt.HasLen, 0)
+}
+
+func TestReaderRegsIntr(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReaderWithOptions(events, 4096, ReaderOptions{}, ExtraPerfOptions{Sample_regs_intr: 0b111})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	outputSamples(t, events, 5)
+
+	rd.SetDeadline(time.Now().Add(time.Second))
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	if len(rec.RegsIntr) > 0 {
+		qt.Assert(t, rec.RegsIntr, qt.HasLen, 3)
+	}
+
+	// The raw sample still comes first.
+	sample := rec.RawSample[perfEventSampleSize:]
+	qt.Assert(t, int(sample[0]), qt.Equals, 5)
+}
+
+func TestSampleLayoutUser(t *testing.T) {
+	attr := linux.PerfEventAttr{
+		Sample_type: linux.PERF_SAMPLE_IP | linux.PERF_SAMPLE_TID | linux.PERF_SAMPLE_ADDR |
+			linux.PERF_SAMPLE_REGS_USER | linux.PERF_SAMPLE_STACK_USER,
+		Sample_regs_user: 0b11,
+	}
+
+	var buf bytes.Buffer
+	write := func(v any) { binary.Write(&buf, internal.NativeEndian, v) }
+	write(uint64(0x1000)) // ip
+	write([]uint32{1, 2}) // pid, tid
+	write(uint64(0x2000)) // addr
+	write(uint64(linux.PERF_SAMPLE_REGS_ABI_64))
+	write([]uint64{3, 4})   // regs
+	write(uint64(16))       // stack size
+	write(make([]byte, 16)) // stack
+	write(uint64(8))        // dyn size
+
+	var rec Record
+	rec.RawSample = buf.Bytes()
+	newSampleLayout(&attr).decodeSample(&rec)
+	qt.Assert(t, rec.IP, qt.Equals, uint64(0x1000))
+	qt.Assert(t, rec.Addr, qt.Equals, uint64(0x2000))
+	qt.Assert(t, rec.Tid, qt.Equals, uint32(2))
+	qt.Assert(t, rec.RegsUser, qt.DeepEquals, []uint64{3, 4})
+	qt.Assert(t, rec.StackUser, qt.HasLen, 8)
+}
+
+func TestRecordMmap(t *testing.T) {
+	body := make([]byte, 64, 80)
+	internal.NativeEndian.PutUint32(body[0:], 1)
+	internal.NativeEndian.PutUint32(body[4:], 2)
+	internal.NativeEndian.PutUint64(body[8:], 0x1000)
+	internal.NativeEndian.PutUint64(body[16:], 0x2000)
+	internal.NativeEndian.PutUint64(body[24:], 0x3000)
+	internal.NativeEndian.PutUint32(body[56:], linux.PROT_READ|linux.PROT_EXEC)
+	internal.NativeEndian.PutUint32(body[60:], linux.MAP_PRIVATE)
+	body = append(body, "/bin/sh\x00"...)
+
+	rec := &Record{RecordType: linux.PERF_RECORD_MMAP2, RawSample: body}
+	mmap, ok := rec.Mmap()
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, mmap, qt.DeepEquals, &MmapRecord{
+		Pid:      1,
+		Tid:      2,
+		Addr:     0x1000,
+		Len:      0x2000,
+		Pgoff:    0x3000,
+		Prot:     linux.PROT_READ | linux.PROT_EXEC,
+		Flags:    linux.MAP_PRIVATE,
+		Filename: "/bin/sh",
+	})
+
+	rec.RawSample = body[:70]
+	_, ok = rec.Mmap()
+	qt.Assert(t, ok, qt.IsFalse)
+
+	rec.RecordType = linux.PERF_RECORD_SAMPLE
+	_, ok = rec.Mmap()
+	qt.Assert(t, ok, qt.IsFalse)
+}
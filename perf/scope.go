@@ -0,0 +1,158 @@
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Scope is a set of processes, for example all processes of a user, which
+// follows processes created by its members.
+//
+// Use Pids and Reader.CloneForPid to create a Reader per process, and feed
+// records of a NewSidebandReader to Update to learn about new processes.
+// It is safe to call methods of a Scope concurrently.
+type Scope struct {
+	mu   sync.Mutex
+	pids map[uint32]struct{}
+}
+
+// UIDScope returns a Scope containing the running processes whose real
+// user ID is uid.
+func UIDScope(uid uint32) (*Scope, error) {
+	return newScope("/proc", func(dir string) (bool, error) {
+		status, err := os.ReadFile(filepath.Join(dir, "status"))
+		if err != nil {
+			return false, err
+		}
+		ids, err := procStatusField(status, "Uid")
+		if err != nil {
+			return false, err
+		}
+		return len(ids) > 0 && ids[0] == strconv.FormatUint(uint64(uid), 10), nil
+	})
+}
+
+// SessionScope returns a Scope containing the running processes of the
+// audit login session id, as found in /proc/<pid>/sessionid and used by
+// systemd-logind.
+//
+// Requires a kernel with CONFIG_AUDIT.
+func SessionScope(id uint32) (*Scope, error) {
+	return newScope("/proc", func(dir string) (bool, error) {
+		raw, err := os.ReadFile(filepath.Join(dir, "sessionid"))
+		if err != nil {
+			return false, err
+		}
+		return strings.TrimSpace(string(raw)) == strconv.FormatUint(uint64(id), 10), nil
+	})
+}
+
+// newScope creates a Scope with the processes in root for which match
+// returns true.
+func newScope(root string, match func(dir string) (bool, error)) (*Scope, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("list processes: %w", err)
+	}
+
+	pids := make(map[uint32]struct{})
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+
+		ok, err := match(filepath.Join(root, entry.Name()))
+		if errors.Is(err, os.ErrNotExist) {
+			// The process exited while listing.
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("process %d: %w", pid, err)
+		}
+		if ok {
+			pids[uint32(pid)] = struct{}{}
+		}
+	}
+
+	return &Scope{pids: pids}, nil
+}
+
+// procStatusField returns the whitespace separated values of a field in
+// /proc/<pid>/status.
+func procStatusField(status []byte, name string) ([]string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if ok && key == name {
+			return strings.Fields(value), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("missing field %s", name)
+}
+
+// Pids returns the processes in the Scope in ascending order.
+func (s *Scope) Pids() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pids := make([]int, 0, len(s.pids))
+	for pid := range s.pids {
+		pids = append(pids, int(pid))
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// Contains returns true if pid is in the Scope.
+func (s *Scope) Contains(pid uint32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.pids[pid]
+	return ok
+}
+
+// Update adds processes created by members of the Scope and removes
+// processes which exited, based on PERF_RECORD_FORK and PERF_RECORD_EXIT
+// records. Other records are ignored.
+//
+// Returns the pid of the process and true if the Scope changed.
+func (s *Scope) Update(rec *Record) (int, bool) {
+	task, ok := rec.Task()
+	if !ok || task.Pid == 0 {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, member := s.pids[task.Pid]
+	switch {
+	case task.Exit && member && task.Tid == task.Pid:
+		// Only the exit of the thread group leader ends the process.
+		delete(s.pids, task.Pid)
+		return int(task.Pid), true
+
+	case !task.Exit && !member:
+		if _, parent := s.pids[task.Ppid]; !parent {
+			return 0, false
+		}
+		s.pids[task.Pid] = struct{}{}
+		return int(task.Pid), true
+
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,80 @@
+package perf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	linux "golang.org/x/sys/unix"
+)
+
+func TestUIDScope(t *testing.T) {
+	scope, err := UIDScope(uint32(os.Getuid()))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, scope.Contains(uint32(os.Getpid())), qt.IsTrue)
+
+	scope, err = UIDScope(uint32(os.Getuid()) + 1)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, scope.Contains(uint32(os.Getpid())), qt.IsFalse)
+}
+
+func TestSessionScope(t *testing.T) {
+	raw, err := os.ReadFile("/proc/self/sessionid")
+	if os.IsNotExist(err) {
+		t.Skip("Kernel doesn't support audit sessions")
+	}
+	qt.Assert(t, err, qt.IsNil)
+
+	var id uint64
+	_, err = fmt.Sscan(strings.TrimSpace(string(raw)), &id)
+	qt.Assert(t, err, qt.IsNil)
+
+	scope, err := SessionScope(uint32(id))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, scope.Contains(uint32(os.Getpid())), qt.IsTrue)
+}
+
+func TestScopeProcessesVanish(t *testing.T) {
+	root := t.TempDir()
+	for _, pid := range []string{"1", "2", "self"} {
+		qt.Assert(t, os.Mkdir(filepath.Join(root, pid), 0755), qt.IsNil)
+	}
+
+	scope, err := newScope(root, func(dir string) (bool, error) {
+		if filepath.Base(dir) == "2" {
+			return false, os.ErrNotExist
+		}
+		return true, nil
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, scope.Pids(), qt.DeepEquals, []int{1})
+}
+
+func TestScopeUpdate(t *testing.T) {
+	scope := &Scope{pids: map[uint32]struct{}{10: {}}}
+
+	pid, ok := scope.Update(taskRecord(linux.PERF_RECORD_FORK, 11, 10, 11))
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, pid, qt.Equals, 11)
+
+	// A new thread of a member.
+	_, ok = scope.Update(taskRecord(linux.PERF_RECORD_FORK, 11, 11, 12))
+	qt.Assert(t, ok, qt.IsFalse)
+
+	// A child of an unrelated process.
+	_, ok = scope.Update(taskRecord(linux.PERF_RECORD_FORK, 21, 20, 21))
+	qt.Assert(t, ok, qt.IsFalse)
+	qt.Assert(t, scope.Pids(), qt.DeepEquals, []int{10, 11})
+
+	// Threads exiting don't end the process.
+	_, ok = scope.Update(taskRecord(linux.PERF_RECORD_EXIT, 11, 11, 12))
+	qt.Assert(t, ok, qt.IsFalse)
+
+	pid, ok = scope.Update(taskRecord(linux.PERF_RECORD_EXIT, 10, 1, 10))
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, pid, qt.Equals, 10)
+	qt.Assert(t, scope.Pids(), qt.DeepEquals, []int{11})
+}
@@ -0,0 +1,111 @@
+package perf
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TopOptions control the behaviour of a TopN aggregator.
+type TopOptions struct {
+	// Key extracts the key to aggregate by, for example the pid or the raw
+	// bytes of a stack. ok is false for records which should be ignored.
+	Key func(rec *Record) (key string, ok bool)
+	// Weight returns the amount a record adds to its key. Defaults to 1.
+	Weight func(rec *Record) uint64
+	// The number of entries in a summary.
+	N int
+}
+
+// TopEntry is a key and its aggregated weight.
+type TopEntry struct {
+	Key    string
+	Weight uint64
+}
+
+// TopN aggregates records by key and produces summaries of the keys with the
+// largest weight.
+//
+// Memory use grows with the number of distinct keys seen since the last
+// summary. It is safe to call methods of a TopN concurrently.
+type TopN struct {
+	key    func(*Record) (string, bool)
+	weight func(*Record) uint64
+	n      int
+
+	mu     sync.Mutex
+	totals map[string]uint64
+}
+
+// NewTopN creates a TopN aggregator.
+func NewTopN(opts TopOptions) *TopN {
+	weight := opts.Weight
+	if weight == nil {
+		weight = func(*Record) uint64 { return 1 }
+	}
+
+	return &TopN{
+		key:    opts.Key,
+		weight: weight,
+		n:      opts.N,
+		totals: make(map[string]uint64),
+	}
+}
+
+// Add aggregates a record.
+func (top *TopN) Add(rec *Record) {
+	key, ok := top.key(rec)
+	if !ok {
+		return
+	}
+	weight := top.weight(rec)
+
+	top.mu.Lock()
+	defer top.mu.Unlock()
+
+	top.totals[key] += weight
+}
+
+// Summary returns the N entries with the largest weight in descending order
+// and resets all weights.
+func (top *TopN) Summary() []TopEntry {
+	top.mu.Lock()
+	totals := top.totals
+	top.totals = make(map[string]uint64, len(totals))
+	top.mu.Unlock()
+
+	entries := make([]TopEntry, 0, len(totals))
+	for key, weight := range totals {
+		entries = append(entries, TopEntry{key, weight})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Weight != entries[j].Weight {
+			return entries[i].Weight > entries[j].Weight
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if len(entries) > top.n {
+		entries = entries[:top.n]
+	}
+	return entries
+}
+
+// Emit passes a Summary to fn every interval until ctx is cancelled.
+//
+// Returns ctx.Err().
+func (top *TopN) Emit(ctx context.Context, interval time.Duration, fn func([]TopEntry)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			fn(top.Summary())
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
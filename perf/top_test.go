@@ -0,0 +1,61 @@
+package perf
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTopN(t *testing.T) {
+	top := NewTopN(TopOptions{
+		Key: func(rec *Record) (string, bool) {
+			return strconv.Itoa(int(rec.Pid)), rec.Pid != 0
+		},
+		N: 2,
+	})
+
+	for _, pid := range []uint32{1, 2, 2, 3, 3, 3, 0, 0, 0, 0} {
+		top.Add(&Record{Pid: pid})
+	}
+
+	qt.Assert(t, top.Summary(), qt.DeepEquals, []TopEntry{{"3", 3}, {"2", 2}})
+	qt.Assert(t, top.Summary(), qt.HasLen, 0)
+}
+
+func TestTopNWeight(t *testing.T) {
+	top := NewTopN(TopOptions{
+		Key:    func(rec *Record) (string, bool) { return string(rec.RawSample), true },
+		Weight: func(rec *Record) uint64 { return rec.Weight.Latency },
+		N:      10,
+	})
+
+	top.Add(&Record{RawSample: []byte("a"), Weight: SampleWeight{Latency: 5}})
+	top.Add(&Record{RawSample: []byte("b"), Weight: SampleWeight{Latency: 3}})
+	top.Add(&Record{RawSample: []byte("b"), Weight: SampleWeight{Latency: 3}})
+	top.Add(&Record{RawSample: []byte("c"), Weight: SampleWeight{Latency: 5}})
+
+	qt.Assert(t, top.Summary(), qt.DeepEquals, []TopEntry{{"b", 6}, {"a", 5}, {"c", 5}})
+}
+
+func TestTopNEmit(t *testing.T) {
+	top := NewTopN(TopOptions{
+		Key: func(*Record) (string, bool) { return "x", true },
+		N:   1,
+	})
+	top.Add(&Record{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var summaries [][]TopEntry
+	err := top.Emit(ctx, time.Millisecond, func(entries []TopEntry) {
+		summaries = append(summaries, entries)
+		if len(summaries) == 2 {
+			cancel()
+		}
+	})
+	qt.Assert(t, errors.Is(err, context.Canceled), qt.IsTrue)
+	qt.Assert(t, summaries, qt.DeepEquals, [][]TopEntry{{{"x", 1}}, {}})
+}
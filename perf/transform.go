@@ -0,0 +1,102 @@
+package perf
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// Transform modifies a record before it is returned by any of the Read
+// methods, for example to redact sensitive data. Returns false to drop the
+// record.
+//
+// Transforms may modify rec.RawSample in place but must not retain it.
+type Transform func(rec *Record) bool
+
+// SetTransform applies t to every record which passes the Filter. It may be
+// called concurrently with reads. Passing nil removes the transform.
+func (pr *Reader) SetTransform(t Transform) {
+	pr.transform.Store(t)
+}
+
+// transforms returns true if a Transform is set.
+func (pr *Reader) transforms() bool {
+	t, _ := pr.transform.Load().(Transform)
+	return t != nil
+}
+
+// accept applies the Filter and Transform of the Reader and returns true if
+// rec should be returned to the caller.
+func (pr *Reader) accept(rec *Record) bool {
+	if rf, _ := pr.filter.Load().(*recordFilter); rf != nil && !rf.matches(rec) {
+		return false
+	}
+
+	t, _ := pr.transform.Load().(Transform)
+	return t == nil || t(rec)
+}
+
+// ChainTransforms applies each of ts in order, stopping at the first one
+// which drops the record.
+func ChainTransforms(ts ...Transform) Transform {
+	return func(rec *Record) bool {
+		for _, t := range ts {
+			if !t(rec) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// StripSample returns a Transform which zeroes n bytes of samples at offset
+// off into Record.RawSample, for example a path or an argument vector.
+// Samples which are too short are zeroed up to their end.
+func StripSample(off, n int) Transform {
+	return func(rec *Record) bool {
+		field := sampleRange(rec, off, n)
+		for i := range field {
+			field[i] = 0
+		}
+		return true
+	}
+}
+
+// HashSample returns a Transform which replaces n bytes of samples at offset
+// off into Record.RawSample with a keyed hash of their contents, for example an address. Equal
+// values map to equal hashes, so they can still be correlated without being
+// disclosed. Hashes are truncated or padded with zeroes to n bytes.
+func HashSample(key []byte, off, n int) Transform {
+	return func(rec *Record) bool {
+		field := sampleRange(rec, off, n)
+		if len(field) == 0 {
+			return true
+		}
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write(field)
+		sum := mac.Sum(nil)
+
+		copied := copy(field, sum)
+		for i := copied; i < len(field); i++ {
+			field[i] = 0
+		}
+		return true
+	}
+}
+
+// sampleRange returns the part of the sample of a PERF_RECORD_SAMPLE at
+// [off, off+n), or nil for other records.
+func sampleRange(rec *Record, off, n int) []byte {
+	if rec.RecordType != unix.PERF_RECORD_SAMPLE {
+		return nil
+	}
+	if off < 0 || n < 0 || off >= len(rec.RawSample) {
+		return nil
+	}
+	if end := off + n; end < len(rec.RawSample) {
+		return rec.RawSample[off:end]
+	}
+	return rec.RawSample[off:]
+}
@@ -0,0 +1,93 @@
+package perf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/unix"
+	qt "github.com/frankban/quicktest"
+)
+
+func TestStripSample(t *testing.T) {
+	strip := StripSample(1, 2)
+
+	rec := Record{RecordType: unix.PERF_RECORD_SAMPLE, RawSample: []byte{1, 2, 3, 4}}
+	qt.Assert(t, strip(&rec), qt.IsTrue)
+	qt.Assert(t, rec.RawSample, qt.DeepEquals, []byte{1, 0, 0, 4})
+
+	rec.RawSample = []byte{1, 2}
+	strip(&rec)
+	qt.Assert(t, rec.RawSample, qt.DeepEquals, []byte{1, 0})
+
+	rec.RawSample = []byte{1}
+	strip(&rec)
+	qt.Assert(t, rec.RawSample, qt.DeepEquals, []byte{1})
+
+	rec = Record{RecordType: unix.PERF_RECORD_LOST, RawSample: []byte{1, 2, 3}}
+	strip(&rec)
+	qt.Assert(t, rec.RawSample, qt.DeepEquals, []byte{1, 2, 3})
+}
+
+func TestHashSample(t *testing.T) {
+	hash := HashSample([]byte("key"), 0, 8)
+
+	sample := func(b ...byte) *Record {
+		return &Record{RecordType: unix.PERF_RECORD_SAMPLE, RawSample: b}
+	}
+
+	a, b, c := sample(1, 2, 3, 4, 5, 6, 7, 8), sample(1, 2, 3, 4, 5, 6, 7, 8), sample(8, 7, 6, 5, 4, 3, 2, 1)
+	hash(a)
+	hash(b)
+	hash(c)
+	qt.Assert(t, a.RawSample, qt.DeepEquals, b.RawSample)
+	qt.Assert(t, a.RawSample, qt.Not(qt.DeepEquals), c.RawSample)
+	qt.Assert(t, a.RawSample, qt.Not(qt.DeepEquals), []byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	long := sample(make([]byte, 40)...)
+	HashSample(nil, 0, 40)(long)
+	qt.Assert(t, long.RawSample[32:], qt.DeepEquals, make([]byte, 8))
+}
+
+func TestChainTransforms(t *testing.T) {
+	var calls int
+	count := func(*Record) bool { calls++; return true }
+	drop := func(*Record) bool { return false }
+
+	qt.Assert(t, ChainTransforms(count, count)(&Record{}), qt.IsTrue)
+	qt.Assert(t, calls, qt.Equals, 2)
+
+	qt.Assert(t, ChainTransforms(drop, count)(&Record{}), qt.IsFalse)
+	qt.Assert(t, calls, qt.Equals, 2)
+}
+
+func TestReaderTransform(t *testing.T) {
+	events := perfEventArray(t)
+
+	rd, err := NewReader(events, 4096)
+	qt.Assert(t, err, qt.IsNil)
+	defer rd.Close()
+
+	rd.SetTransform(ChainTransforms(
+		func(rec *Record) bool { return rec.RawSample[perfEventSampleSize] != 3 },
+		StripSample(perfEventSampleSize+2, 1),
+	))
+
+	outputSamples(t, events, 3, 5, 5)
+	rd.SetDeadline(time.Now().Add(time.Second))
+
+	rec, err := rd.Read()
+	qt.Assert(t, err, qt.IsNil)
+	sample := rec.RawSample[perfEventSampleSize:]
+	qt.Assert(t, sample[:3], qt.DeepEquals, []byte{5, 1, 0})
+
+	var view Record
+	qt.Assert(t, rd.ReadView(&view), qt.IsNil)
+	sample = view.RawSample[perfEventSampleSize:]
+	qt.Assert(t, sample[:3], qt.DeepEquals, []byte{5, 2, 0})
+
+	rd.SetTransform(nil)
+	outputSamples(t, events, 5)
+	qt.Assert(t, rd.ReadView(&view), qt.IsNil)
+	sample = view.RawSample[perfEventSampleSize:]
+	qt.Assert(t, sample[:3], qt.DeepEquals, []byte{5, 0, 0xff})
+}
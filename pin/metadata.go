@@ -0,0 +1,136 @@
+package pin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+const (
+	// The extended attribute holding the metadata of a pin.
+	metadataXattr = "user.ebpf.metadata"
+	// Appended to the name of a pin to find its sidecar map.
+	metaSuffix = "__meta"
+)
+
+// Metadata is arbitrary information attached to a pin, for example the
+// owner, version or commit of an object.
+type Metadata map[string]string
+
+// PinWithMetadata is like Pin, except that it also attaches md to the pin.
+//
+// md is stored in an extended attribute of the pin if the BPF file system
+// supports it. Otherwise it's stored in an array map pinned next to name,
+// which isn't replaced atomically together with the pin.
+func (m *Manager) PinWithMetadata(name string, obj Pinner, md Metadata) error {
+	if md == nil {
+		md = Metadata{}
+	}
+	return m.pin(name, obj, md)
+}
+
+// Metadata returns the metadata attached to name by PinWithMetadata.
+//
+// Returns nil if name has no metadata.
+func (m *Manager) Metadata(name string) (Metadata, error) {
+	path, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	md, err := readMetadata(path)
+	if err != nil {
+		return nil, fmt.Errorf("metadata of %s: %w", name, err)
+	}
+	return md, nil
+}
+
+// LoadWithMetadata combines Load and Metadata.
+func (m *Manager) LoadWithMetadata(name string, opts *ebpf.LoadPinOptions) (Pinner, Metadata, error) {
+	md, err := m.Metadata(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	obj, err := m.Load(name, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, md, nil
+}
+
+func readMetadata(path string) (Metadata, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	buf, err := getxattr(path, metadataXattr)
+	if errors.Is(err, unix.ENODATA) || errors.Is(err, unix.EOPNOTSUPP) {
+		buf, err = readSidecar(path + metaSuffix)
+	}
+	if err != nil || buf == nil {
+		return nil, err
+	}
+
+	var md Metadata
+	if err := json.Unmarshal(buf, &md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+func getxattr(path, attr string) ([]byte, error) {
+	for {
+		n, err := unix.Getxattr(path, attr, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, n)
+		n, err = unix.Getxattr(path, attr, buf)
+		if errors.Is(err, unix.ERANGE) {
+			// The attribute grew in the meantime.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// pinSidecar stores metadata in an array map with a single element.
+func pinSidecar(path string, buf []byte) error {
+	sidecar, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "pin_metadata",
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  uint32(len(buf)),
+		MaxEntries: 1,
+		Contents:   []ebpf.MapKV{{Key: uint32(0), Value: buf}},
+	})
+	if err != nil {
+		return err
+	}
+	defer sidecar.Close()
+
+	return pinAtomic(path, sidecar.FD(), nil)
+}
+
+// readSidecar returns nil if there is no sidecar at path.
+func readSidecar(path string) ([]byte, error) {
+	sidecar, err := ebpf.LoadPinnedMap(path, &ebpf.LoadPinOptions{ReadOnly: true})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer sidecar.Close()
+
+	return sidecar.LookupBytes(uint32(0))
+}
@@ -0,0 +1,85 @@
+package pin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestManagerMetadata(t *testing.T) {
+	mgr := newManager(t)
+	m := newMap(t)
+
+	md := Metadata{"owner": "agent", "version": "1.2.3"}
+	qt.Assert(t, mgr.PinWithMetadata("m", m, md), qt.IsNil)
+
+	got, err := mgr.Metadata("m")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, md)
+
+	obj, got, err := mgr.LoadWithMetadata("m", nil)
+	qt.Assert(t, err, qt.IsNil)
+	defer obj.(*ebpf.Map).Close()
+	qt.Assert(t, got, qt.DeepEquals, md)
+
+	// Sidecars are not part of collections.
+	qt.Assert(t, mgr.PinWithMetadata("maps/m", m, md), qt.IsNil)
+	coll, err := mgr.LoadCollection(nil)
+	qt.Assert(t, err, qt.IsNil)
+	defer coll.Close()
+	qt.Assert(t, coll.Maps, qt.HasLen, 1)
+
+	qt.Assert(t, mgr.Pin("m", m), qt.IsNil)
+	got, err = mgr.Metadata("m")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.IsNil)
+
+	qt.Assert(t, mgr.PinWithMetadata("m", m, nil), qt.IsNil)
+	got, err = mgr.Metadata("m")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, Metadata{})
+
+	qt.Assert(t, mgr.Unpin("m"), qt.IsNil)
+	_, err = mgr.Metadata("m")
+	qt.Assert(t, err, qt.ErrorIs, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(mgr.Root(), "m"+metaSuffix))
+	qt.Assert(t, err, qt.ErrorIs, os.ErrNotExist)
+
+	qt.Assert(t, mgr.Pin("m"+metaSuffix, m), qt.IsNotNil)
+}
+
+func TestManagerPruneMetadata(t *testing.T) {
+	mgr := newManager(t)
+	keep, orphan := newMap(t), newMap(t)
+
+	md := Metadata{"owner": "agent"}
+	qt.Assert(t, mgr.PinWithMetadata("keep", keep, md), qt.IsNil)
+	qt.Assert(t, mgr.PinWithMetadata("orphan", orphan, md), qt.IsNil)
+
+	_, err := mgr.Prune(keep)
+	qt.Assert(t, err, qt.IsNil)
+
+	got, err := mgr.Metadata("keep")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, md)
+
+	_, err = os.Stat(filepath.Join(mgr.Root(), "orphan"+metaSuffix))
+	qt.Assert(t, err, qt.ErrorIs, os.ErrNotExist)
+}
+
+func TestSidecar(t *testing.T) {
+	path := filepath.Join(newManager(t).Root(), "sidecar")
+
+	buf, err := readSidecar(path)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, buf, qt.IsNil)
+
+	qt.Assert(t, pinSidecar(path, []byte("{}")), qt.IsNil)
+	buf, err = readSidecar(path)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, string(buf), qt.Equals, "{}")
+}
@@ -0,0 +1,405 @@
+// Package pin manages a tree of objects pinned to a BPF file system.
+//
+// A Manager owns a directory, usually below /sys/fs/bpf. Replacing a pin is
+// atomic, so other processes always find either the old or the new object.
+// This allows long-running agents to upgrade their programs while keeping
+// the state in their maps. Pins may carry Metadata, which makes it possible
+// to audit what is loaded on a host.
+package pin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+	"github.com/cilium/ebpf/link"
+)
+
+// Pins of an interrupted Manager.Pin start with this prefix. The BPF file
+// system doesn't allow dots in names.
+const tempPrefix = "__tmp_"
+
+// The directories used by PinCollection.
+const (
+	mapsDir     = "maps"
+	programsDir = "programs"
+)
+
+var tempCounter uint64
+
+// Pinner is an object which can be pinned: *ebpf.Map, *ebpf.Program or one
+// of the links returned by package link.
+type Pinner interface {
+	FD() int
+}
+
+// Manager pins objects into a directory on a BPF file system.
+//
+// Names passed to a Manager are relative to its directory and may contain
+// slashes.
+type Manager struct {
+	root string
+}
+
+// NewManager creates a Manager for root, which is created if necessary.
+//
+// Returns an error if root is not on a BPF file system.
+func NewManager(root string) (*Manager, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+
+	fsType, err := internal.FSType(root)
+	if err != nil {
+		return nil, err
+	}
+	if fsType != unix.BPF_FS_MAGIC {
+		return nil, fmt.Errorf("%s is not on a bpf filesystem", root)
+	}
+
+	return &Manager{root}, nil
+}
+
+// Root returns the directory of the Manager.
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// Namespace returns a Manager for the subdirectory name, which allows
+// several components to share a Manager without clashing names.
+func (m *Manager) Namespace(name string) (*Manager, error) {
+	path, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewManager(path)
+}
+
+// Pin obj as name, replacing any existing pin atomically.
+//
+// The pin isn't tracked by obj, for example ebpf.Map.IsPinned returns false.
+func (m *Manager) Pin(name string, obj Pinner) error {
+	return m.pin(name, obj, nil)
+}
+
+// pin obj as name with optional metadata.
+func (m *Manager) pin(name string, obj Pinner, md Metadata) error {
+	path, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	var buf []byte
+	if md != nil {
+		buf, err = json.Marshal(md)
+		if err != nil {
+			return fmt.Errorf("pin %s: metadata: %w", name, err)
+		}
+	}
+
+	// Attaching the metadata before the rename replaces it together with
+	// the object.
+	var sidecar bool
+	err = pinAtomic(path, obj.FD(), func(tmp string) error {
+		if buf == nil {
+			return nil
+		}
+
+		err := unix.Setxattr(tmp, metadataXattr, buf, 0)
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			sidecar = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("pin %s: %w", name, err)
+	}
+
+	if !sidecar {
+		// Don't leave stale metadata from a previous object behind.
+		return internal.Unpin(path + metaSuffix)
+	}
+
+	if err := pinSidecar(path+metaSuffix, buf); err != nil {
+		return fmt.Errorf("pin %s: metadata: %w", name, err)
+	}
+	return nil
+}
+
+// pinAtomic pins fd to path, replacing any existing pin. prepare is invoked
+// with a temporary pin of fd before it's moved to path, and may be nil.
+func pinAtomic(path string, fd int, prepare func(tmp string) error) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Pin to a temporary name first, since the kernel refuses to pin over
+	// an existing file.
+	n := atomic.AddUint64(&tempCounter, 1)
+	tmp := filepath.Join(dir, fmt.Sprintf("%s%d_%d_%s", tempPrefix, os.Getpid(), n, filepath.Base(path)))
+	err := sys.ObjPin(&sys.ObjPinAttr{
+		Pathname: sys.NewStringPointer(tmp),
+		BpfFd:    uint32(fd),
+	})
+	if err != nil {
+		return err
+	}
+
+	if prepare != nil {
+		if err := prepare(tmp); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// Unpin removes name and its metadata. It's not an error if name doesn't
+// exist.
+func (m *Manager) Unpin(name string) error {
+	path, err := m.path(name)
+	if err != nil {
+		return err
+	}
+	if err := internal.Unpin(path); err != nil {
+		return err
+	}
+	return internal.Unpin(path + metaSuffix)
+}
+
+// Load the object pinned as name. The result is an *ebpf.Map, an
+// *ebpf.Program or a link.Link.
+func (m *Manager) Load(name string, opts *ebpf.LoadPinOptions) (Pinner, error) {
+	path, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	kind, _, err := pinnedObject(path)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", name, err)
+	}
+
+	switch kind {
+	case "map":
+		return ebpf.LoadPinnedMap(path, opts)
+	case "prog":
+		return ebpf.LoadPinnedProgram(path, opts)
+	default:
+		l, err := link.LoadPinnedLink(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		return l.(Pinner), nil
+	}
+}
+
+// PinCollection pins the maps and programs of coll below the directories
+// "maps" and "programs".
+func (m *Manager) PinCollection(coll *ebpf.Collection) error {
+	for name, mp := range coll.Maps {
+		if err := m.Pin(filepath.Join(mapsDir, name), mp); err != nil {
+			return err
+		}
+	}
+	for name, prog := range coll.Programs {
+		if err := m.Pin(filepath.Join(programsDir, name), prog); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadCollection loads the maps and programs pinned by PinCollection.
+func (m *Manager) LoadCollection(opts *ebpf.LoadPinOptions) (_ *ebpf.Collection, err error) {
+	coll := &ebpf.Collection{
+		Maps:     make(map[string]*ebpf.Map),
+		Programs: make(map[string]*ebpf.Program),
+	}
+	defer func() {
+		if err != nil {
+			coll.Close()
+		}
+	}()
+
+	maps, err := m.list(mapsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range maps {
+		coll.Maps[name], err = ebpf.LoadPinnedMap(filepath.Join(m.root, mapsDir, name), opts)
+		if err != nil {
+			return nil, fmt.Errorf("map %s: %w", name, err)
+		}
+	}
+
+	progs, err := m.list(programsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range progs {
+		coll.Programs[name], err = ebpf.LoadPinnedProgram(filepath.Join(m.root, programsDir, name), opts)
+		if err != nil {
+			return nil, fmt.Errorf("program %s: %w", name, err)
+		}
+	}
+
+	return coll, nil
+}
+
+// Prune removes pins which refer to none of the objects in keep, and the
+// leftovers of interrupted calls to Pin. Pins of namespaces are included.
+//
+// Returns the paths of the removed pins.
+func (m *Manager) Prune(keep ...Pinner) ([]string, error) {
+	live := make(map[objectKey]bool)
+	for _, obj := range keep {
+		kind, id, err := objectID(obj.FD())
+		if err != nil {
+			return nil, err
+		}
+		live[objectKey{kind, id}] = true
+	}
+
+	var removed []string
+	err := filepath.WalkDir(m.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		if owner := strings.TrimSuffix(path, metaSuffix); owner != path {
+			// Metadata is kept as long as its pin exists. The walk is in
+			// lexical order, so the pin has already been pruned.
+			_, err := os.Stat(owner)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		} else if !strings.HasPrefix(d.Name(), tempPrefix) {
+			kind, id, err := pinnedObject(path)
+			if err != nil {
+				return err
+			}
+			if live[objectKey{kind, id}] {
+				return nil
+			}
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("prune: %w", err)
+	}
+
+	return removed, nil
+}
+
+// path returns the absolute path of name.
+func (m *Manager) path(name string) (string, error) {
+	clean := filepath.Clean(name)
+	if name == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	if strings.HasPrefix(filepath.Base(clean), tempPrefix) {
+		return "", fmt.Errorf("name %q uses reserved prefix %q", name, tempPrefix)
+	}
+	if strings.HasSuffix(clean, metaSuffix) {
+		return "", fmt.Errorf("name %q uses reserved suffix %q", name, metaSuffix)
+	}
+	return filepath.Join(m.root, clean), nil
+}
+
+// list returns the names of the pins in dir, which may not exist.
+func (m *Manager) list(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(m.root, dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && !strings.HasPrefix(name, tempPrefix) && !strings.HasSuffix(name, metaSuffix) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+type objectKey struct {
+	kind string
+	id   uint32
+}
+
+// pinnedObject returns the kind and ID of the object pinned at path.
+func pinnedObject(path string) (string, uint32, error) {
+	fd, err := sys.ObjGet(&sys.ObjGetAttr{Pathname: sys.NewStringPointer(path)})
+	if err != nil {
+		return "", 0, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer fd.Close()
+
+	return objectID(fd.Int())
+}
+
+// objectID returns the kind ("map", "prog" or "link") and the ID of a BPF
+// object from its fdinfo.
+func objectID(fd int) (string, uint32, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/self/fdinfo/%d", fd))
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+
+		kind := strings.TrimSuffix(name, "_id")
+		if kind != "map" && kind != "prog" && kind != "link" {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: %w", f.Name(), err)
+		}
+		return kind, uint32(id), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return "", 0, err
+	}
+
+	return "", 0, fmt.Errorf("%s: no object ID: %w", f.Name(), ebpf.ErrNotSupported)
+}
@@ -0,0 +1,160 @@
+package pin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func newManager(t *testing.T) *Manager {
+	t.Helper()
+
+	mgr, err := NewManager(filepath.Join(testutils.TempBPFFS(t), "root"))
+	qt.Assert(t, err, qt.IsNil)
+	return mgr
+}
+
+func newMap(t *testing.T) *ebpf.Map {
+	t.Helper()
+
+	m, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func newProgram(t *testing.T) *ebpf.Program {
+	t.Helper()
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type: ebpf.SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	qt.Assert(t, err, qt.IsNil)
+	t.Cleanup(func() { prog.Close() })
+	return prog
+}
+
+func mapID(t *testing.T, m *ebpf.Map) ebpf.MapID {
+	t.Helper()
+
+	info, err := m.Info()
+	qt.Assert(t, err, qt.IsNil)
+	id, ok := info.ID()
+	if !ok {
+		t.Skip("Map IDs are not supported")
+	}
+	return id
+}
+
+func TestNewManagerNotBPFFS(t *testing.T) {
+	_, err := NewManager(t.TempDir())
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestManagerPin(t *testing.T) {
+	mgr := newManager(t)
+	m1, m2 := newMap(t), newMap(t)
+
+	qt.Assert(t, mgr.Pin("a/b", m1), qt.IsNil)
+	qt.Assert(t, mgr.Pin("a/b", m2), qt.IsNil)
+
+	loaded, err := mgr.Load("a/b", nil)
+	qt.Assert(t, err, qt.IsNil)
+	defer loaded.(*ebpf.Map).Close()
+	qt.Assert(t, mapID(t, loaded.(*ebpf.Map)), qt.Equals, mapID(t, m2))
+
+	entries, err := os.ReadDir(filepath.Join(mgr.Root(), "a"))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, entries, qt.HasLen, 1)
+
+	qt.Assert(t, mgr.Unpin("a/b"), qt.IsNil)
+	qt.Assert(t, mgr.Unpin("a/b"), qt.IsNil)
+	_, err = mgr.Load("a/b", nil)
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestManagerInvalidName(t *testing.T) {
+	mgr := newManager(t)
+	m := newMap(t)
+
+	for _, name := range []string{"", ".", "..", "../a", "/a", tempPrefix + "a"} {
+		qt.Assert(t, mgr.Pin(name, m), qt.IsNotNil, qt.Commentf("name %q", name))
+	}
+}
+
+func TestManagerLoadProgram(t *testing.T) {
+	mgr := newManager(t)
+	prog := newProgram(t)
+
+	qt.Assert(t, mgr.Pin("prog", prog), qt.IsNil)
+
+	loaded, err := mgr.Load("prog", nil)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer loaded.(*ebpf.Program).Close()
+}
+
+func TestManagerCollection(t *testing.T) {
+	mgr := newManager(t)
+
+	coll := &ebpf.Collection{
+		Maps:     map[string]*ebpf.Map{"m": newMap(t)},
+		Programs: map[string]*ebpf.Program{"p": newProgram(t)},
+	}
+	qt.Assert(t, mgr.PinCollection(coll), qt.IsNil)
+
+	loaded, err := mgr.LoadCollection(nil)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer loaded.Close()
+
+	qt.Assert(t, loaded.Maps, qt.HasLen, 1)
+	qt.Assert(t, loaded.Programs, qt.HasLen, 1)
+	qt.Assert(t, mapID(t, loaded.Maps["m"]), qt.Equals, mapID(t, coll.Maps["m"]))
+
+	empty, err := mgr.Namespace("empty")
+	qt.Assert(t, err, qt.IsNil)
+	loaded, err = empty.LoadCollection(nil)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, loaded.Maps, qt.HasLen, 0)
+}
+
+func TestManagerPrune(t *testing.T) {
+	mgr := newManager(t)
+	keep, orphan := newMap(t), newMap(t)
+
+	ns, err := mgr.Namespace("ns")
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ns.Root(), qt.Equals, filepath.Join(mgr.Root(), "ns"))
+
+	qt.Assert(t, mgr.Pin("keep", keep), qt.IsNil)
+	qt.Assert(t, ns.Pin("orphan", orphan), qt.IsNil)
+	leftover := filepath.Join(mgr.Root(), tempPrefix+"1_1_keep")
+	qt.Assert(t, keep.Pin(leftover), qt.IsNil)
+
+	removed, err := mgr.Prune(keep)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, removed, qt.ContentEquals, []string{
+		leftover,
+		filepath.Join(ns.Root(), "orphan"),
+	})
+
+	_, err = os.Stat(filepath.Join(mgr.Root(), "keep"))
+	qt.Assert(t, err, qt.IsNil)
+}
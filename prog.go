@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -41,6 +42,10 @@ const DefaultVerifierLogSize = 64 * 1024
 // will accept before returning EINVAL.
 const maxVerifierLogSize = math.MaxUint32 >> 2
 
+// maxGrownVerifierLogSize is the largest verifier log buffer allocated when
+// growing the default buffer.
+const maxGrownVerifierLogSize = 64 * 1024 * 1024
+
 // ProgramOptions control loading a program into the kernel.
 type ProgramOptions struct {
 	// Bitmap controlling the detail emitted by the kernel's eBPF verifier log.
@@ -66,9 +71,11 @@ type ProgramOptions struct {
 	//
 	// If this value is set too low to fit the verifier log, the resulting
 	// [ebpf.VerifierError]'s Truncated flag will be true, and the error string
-	// will also contain a hint to that effect.
+	// will also contain a hint to that effect. From Linux 6.4 the log then
+	// contains the last instead of the first lines.
 	//
-	// Defaults to DefaultVerifierLogSize.
+	// Defaults to DefaultVerifierLogSize. The default buffer is grown and the
+	// program loaded again until the log fits, up to 64 MiB.
 	LogSize int
 
 	// Disables the verifier log completely, regardless of other options.
@@ -79,7 +86,27 @@ type ProgramOptions struct {
 	// This is useful in environments where the kernel BTF is not available
 	// (containers) or where it is in a non-standard location. Defaults to
 	// use the kernel BTF from a well-known location if nil.
+	//
+	// Use [btf.Hub] to select type information for kernels without BTF.
 	KernelTypes *btf.Spec
+
+	// Features which may be removed from the program if the kernel rejects
+	// it with EINVAL or E2BIG. Fallbacks are applied cumulatively in the
+	// order of their declaration until the program loads.
+	//
+	// Use [Program.Fallbacks] to find out which fallbacks were applied.
+	// Defaults to none.
+	Fallbacks ProgramFallback
+
+	// Namespace is entered while loading the program, for example a network
+	// namespace opened from /proc/<pid>/ns/net. Defaults to the namespaces of
+	// the calling thread.
+	//
+	// Requires CAP_SYS_ADMIN. User and mount namespaces are not supported.
+	Namespace *os.File
+
+	// Token grants the permission to load the program and its BTF.
+	Token *Token
 }
 
 // ProgramSpec defines a Program.
@@ -99,9 +126,18 @@ type ProgramSpec struct {
 
 	// Name of a kernel data structure or function to attach to. Its
 	// interpretation depends on Type and AttachType.
+	//
+	// For StructOps programs AttachTo is the implemented function pointer in
+	// the form "struct:member", for example "tcp_congestion_ops:ssthresh".
+	// It is set automatically for programs referenced from a .struct_ops
+	// section.
 	AttachTo string
 
 	// The program to attach to. Must be provided manually.
+	//
+	// For Extension programs AttachTo is the name of a global function in
+	// AttachTarget. If the program doesn't have BTF, the prototype of the
+	// replaced function is used.
 	AttachTarget *Program
 
 	// The name of the ELF section this program originated from.
@@ -166,6 +202,9 @@ type Program struct {
 	name       string
 	pinnedPath string
 	typ        ProgramType
+	// The expected attach type, if the program was loaded from a spec.
+	attachType AttachType
+	fallbacks  ProgramFallback
 }
 
 // NewProgram creates a new Program.
@@ -198,6 +237,24 @@ func NewProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 }
 
 func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, error) {
+	if opts.Namespace != nil {
+		ns := opts.Namespace
+		opts.Namespace = nil
+
+		var prog *Program
+		err := internal.InNamespace(ns, func() (err error) {
+			prog, err = newProgramWithOptions(spec, opts)
+			return err
+		})
+		if err != nil {
+			// The program may have been loaded before restoring the
+			// namespace failed.
+			prog.Close()
+			return nil, err
+		}
+		return prog, nil
+	}
+
 	if len(spec.Instructions) == 0 {
 		return nil, errors.New("instructions cannot be empty")
 	}
@@ -235,6 +292,11 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 		KernVersion:        kv,
 	}
 
+	if opts.Token != nil {
+		attr.ProgFlags |= uint32(sys.BPF_F_TOKEN_FD)
+		attr.ProgTokenFd = int32(opts.Token.FD())
+	}
+
 	if haveObjName() == nil {
 		attr.ProgName = sys.NewObjName(spec.Name)
 	}
@@ -242,7 +304,20 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 	insns := make(asm.Instructions, len(spec.Instructions))
 	copy(insns, spec.Instructions)
 
-	handle, fib, lib, err := btf.MarshalExtInfos(insns)
+	var targetID btf.TypeID
+	if spec.AttachTarget != nil {
+		id, target, err := findTargetInProgram(spec.AttachTarget, spec.AttachTo, spec.Type, spec.AttachType)
+		if err != nil {
+			return nil, fmt.Errorf("attach %s/%s: %w", spec.Type, spec.AttachType, err)
+		}
+		targetID = id
+
+		if spec.Type == Extension {
+			fixupExtension(insns, spec.Name, target)
+		}
+	}
+
+	handle, fib, lib, err := btf.MarshalExtInfosWithOptions(insns, btf.HandleOptions{TokenFD: opts.Token.tokenFD()})
 	if err != nil && !errors.Is(err, btf.ErrNotSupported) {
 		return nil, fmt.Errorf("load ext_infos: %w", err)
 	}
@@ -278,6 +353,11 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 	if err != nil {
 		return nil, fmt.Errorf("fixing up kfuncs: %w", err)
 	}
+
+	if err := fixupKsyms(insns, &handles); err != nil {
+		handles.close()
+		return nil, fmt.Errorf("fixing up ksyms: %w", err)
+	}
 	defer handles.close()
 
 	if len(handles) > 0 {
@@ -296,14 +376,19 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 	attr.InsnCnt = uint32(len(bytecode) / asm.InstructionSize)
 
 	if spec.AttachTarget != nil {
-		targetID, err := findTargetInProgram(spec.AttachTarget, spec.AttachTo, spec.Type, spec.AttachType)
+		attr.AttachBtfId = targetID
+		attr.AttachBtfObjFd = uint32(spec.AttachTarget.FD())
+		defer runtime.KeepAlive(spec.AttachTarget)
+	} else if spec.Type == StructOps && spec.AttachTo != "" {
+		targetID, member, err := findStructOpsMember(spec.AttachTo)
 		if err != nil {
-			return nil, fmt.Errorf("attach %s/%s: %w", spec.Type, spec.AttachType, err)
+			return nil, fmt.Errorf("attach %s: %w", spec.Type, err)
 		}
 
+		// The kernel expects the index of the implemented member instead of
+		// an attach type.
 		attr.AttachBtfId = targetID
-		attr.AttachBtfObjFd = uint32(spec.AttachTarget.FD())
-		defer runtime.KeepAlive(spec.AttachTarget)
+		attr.ExpectedAttachType = sys.AttachType(member)
 	} else if spec.AttachTo != "" {
 		module, targetID, err := findProgramTargetInKernel(spec.AttachTo, spec.Type, spec.AttachType)
 		if err != nil && !errors.Is(err, errUnrecognizedAttachType) {
@@ -319,22 +404,27 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 		}
 	}
 
+	growLog := opts.LogSize == 0
 	if opts.LogSize == 0 {
 		opts.LogSize = DefaultVerifierLogSize
 	}
 
 	// The caller requested a specific verifier log level. Set up the log buffer.
-	var logBuf []byte
+	var (
+		logBuf []byte
+		fd     *sys.FD
+	)
 	if !opts.LogDisabled && opts.LogLevel != 0 {
-		logBuf = make([]byte, opts.LogSize)
-		attr.LogLevel = opts.LogLevel
-		attr.LogSize = uint32(len(logBuf))
-		attr.LogBuf = sys.NewSlicePointer(logBuf)
+		fd, logBuf, err = loadWithLog(attr, opts.LogLevel, opts.LogSize, growLog)
+	} else {
+		fd, err = sys.ProgLoad(attr)
+	}
+	var applied ProgramFallback
+	if err != nil && opts.Fallbacks != 0 {
+		fd, applied, err = loadWithFallbacks(attr, opts.Fallbacks, err)
 	}
-
-	fd, err := sys.ProgLoad(attr)
 	if err == nil {
-		return &Program{unix.ByteSliceToString(logBuf), fd, spec.Name, "", spec.Type}, nil
+		return &Program{unix.ByteSliceToString(logBuf), fd, spec.Name, "", spec.Type, spec.AttachType, applied}, nil
 	}
 
 	// An error occurred loading the program, but the caller did not explicitly
@@ -344,12 +434,7 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 	// cause.
 	var err2 error
 	if !opts.LogDisabled && opts.LogLevel == 0 {
-		logBuf = make([]byte, opts.LogSize)
-		attr.LogLevel = LogLevelBranch
-		attr.LogSize = uint32(len(logBuf))
-		attr.LogBuf = sys.NewSlicePointer(logBuf)
-
-		_, err2 = sys.ProgLoad(attr)
+		_, logBuf, err2 = loadWithLog(attr, LogLevelBranch, opts.LogSize, growLog)
 	}
 
 	switch {
@@ -369,13 +454,78 @@ func newProgramWithOptions(spec *ProgramSpec, opts ProgramOptions) (*Program, er
 			}
 		}
 
+		if usesExceptions(spec.Instructions) {
+			if err := haveBPFExceptions(); err != nil {
+				return nil, fmt.Errorf("load program: %w", err)
+			}
+		}
+
 		if opts.LogSize > maxVerifierLogSize {
 			return nil, fmt.Errorf("load program: %w (ProgramOptions.LogSize exceeds maximum value of %d)", err, maxVerifierLogSize)
 		}
 	}
 
 	truncated := errors.Is(err, unix.ENOSPC) || errors.Is(err2, unix.ENOSPC)
-	return nil, internal.ErrorWithLog("load program", err, logBuf, truncated)
+	ve := internal.ErrorWithLog("load program", err, logBuf, truncated)
+	if source := sourceAt(insns, ve.Instruction); source != "" {
+		ve.Source = source
+	}
+	return nil, ve
+}
+
+// loadWithLog loads a program with a verifier log of the given level and size.
+//
+// If grow is true, the log buffer is enlarged and the program loaded again
+// until the log fits. Returns the log buffer.
+func loadWithLog(attr *sys.ProgLoadAttr, level LogLevel, size int, grow bool) (*sys.FD, []byte, error) {
+	for {
+		logBuf := make([]byte, size)
+		attr.LogLevel = level
+		attr.LogSize = uint32(len(logBuf))
+		attr.LogBuf = sys.NewSlicePointer(logBuf)
+		attr.LogTrueSize = 0
+
+		fd, err := sys.ProgLoad(attr)
+		if !grow || !errors.Is(err, unix.ENOSPC) || size >= maxGrownVerifierLogSize {
+			return fd, logBuf, err
+		}
+
+		// The kernel reports the size of the full log from 6.4.
+		if trueSize := int(attr.LogTrueSize); trueSize > size {
+			size = trueSize
+		} else {
+			size *= 2
+		}
+		if size > maxGrownVerifierLogSize {
+			size = maxGrownVerifierLogSize
+		}
+	}
+}
+
+// sourceAt returns the line of source code from BTF line info which applies
+// to the instruction at the raw offset.
+func sourceAt(insns asm.Instructions, offset int) string {
+	if offset < 0 {
+		return ""
+	}
+
+	var source fmt.Stringer
+	iter := insns.Iterate()
+	for iter.Next() && int(iter.Offset) <= offset {
+		// Line info only marks the first instruction of a line.
+		if src := iter.Ins.Source(); src != nil {
+			source = src
+		}
+	}
+
+	switch src := source.(type) {
+	case nil:
+		return ""
+	case *btf.Line:
+		return fmt.Sprintf("%s:%d: %s", src.FileName(), src.LineNumber(), strings.TrimSpace(src.Line()))
+	default:
+		return strings.TrimSpace(src.String())
+	}
 }
 
 // NewProgramFromFD creates a program from a raw fd.
@@ -413,7 +563,7 @@ func newProgramFromFD(fd *sys.FD) (*Program, error) {
 		return nil, fmt.Errorf("discover program type: %w", err)
 	}
 
-	return &Program{"", fd, info.Name, "", info.Type}, nil
+	return &Program{"", fd, info.Name, "", info.Type, AttachNone, 0}, nil
 }
 
 func (p *Program) String() string {
@@ -428,6 +578,12 @@ func (p *Program) Type() ProgramType {
 	return p.typ
 }
 
+// Fallbacks returns the features which were removed from the program to make
+// the kernel accept it. See [ProgramOptions.Fallbacks].
+func (p *Program) Fallbacks() ProgramFallback {
+	return p.fallbacks
+}
+
 // Info returns metadata about the program.
 //
 // Requires at least 4.10.
@@ -475,7 +631,7 @@ func (p *Program) Clone() (*Program, error) {
 		return nil, fmt.Errorf("can't clone program: %w", err)
 	}
 
-	return &Program{p.VerifierLog, dup, p.name, "", p.typ}, nil
+	return &Program{p.VerifierLog, dup, p.name, "", p.typ, p.attachType, p.fallbacks}, nil
 }
 
 // Pin persists the Program on the BPF virtual file system past the lifetime of
@@ -528,11 +684,18 @@ type RunOptions struct {
 	// Program's data input. Required field.
 	//
 	// The kernel expects at least 14 bytes input for an ethernet header for
-	// XDP and SKB programs.
+	// XDP and SKB programs. XDP programs loaded with BPF_F_XDP_HAS_FRAGS
+	// accept data which doesn't fit into a single page, the remainder is
+	// passed as fragments.
 	Data []byte
 	// Program's data after Program has run. Caller must allocate. Optional field.
+	//
+	// Fragments of XDP programs are appended to the linear data. Returns an
+	// error wrapping unix.ENOSPC if DataOut is too small.
 	DataOut []byte
 	// Program's context input. Optional field.
+	//
+	// XDP programs take an XDPMd.
 	Context interface{}
 	// Program's context after Program has run. Must be a pointer or slice. Optional field.
 	ContextOut interface{}
@@ -541,11 +704,14 @@ type RunOptions struct {
 	// The program may be executed more often than this due to interruptions, e.g.
 	// when runtime.AllThreadsSyscall is invoked.
 	Repeat uint32
-	// Optional flags.
+	// Optional flags, for example RunOnCPU or RunXDPLiveFrames.
 	Flags uint32
 	// CPU to run Program on. Optional field.
 	// Note not all program types support this field.
 	CPU uint32
+	// Number of frames processed at once with RunXDPLiveFrames. Optional
+	// field, the kernel uses a default of 64.
+	BatchSize uint32
 	// Called whenever the syscall is interrupted, and should be set to testing.B.ResetTimer
 	// or similar. Typically used during benchmarking. Optional field.
 	//
@@ -553,6 +719,35 @@ type RunOptions struct {
 	Reset func()
 }
 
+// Flags for RunOptions.
+const (
+	// RunOnCPU runs the program on RunOptions.CPU instead of the current CPU.
+	RunOnCPU = unix.BPF_F_TEST_RUN_ON_CPU
+	// RunXDPLiveFrames processes the frames returned by an XDP program like
+	// real traffic: XDP_TX and XDP_REDIRECT transmit the frame, XDP_PASS
+	// hands it to the network stack. Used for load testing.
+	//
+	// Data is used as the initial content of every frame. DataOut and
+	// ContextOut must not be set.
+	//
+	// Requires at least Linux 5.18.
+	RunXDPLiveFrames = unix.BPF_F_TEST_XDP_LIVE_FRAMES
+)
+
+// XDPMd is the context of an XDP program, see struct xdp_md.
+//
+// Data and DataEnd are offsets into RunOptions.Data. DataEnd must be the
+// length of the data and DataMeta must be zero. If Data isn't zero, the first
+// Data bytes are passed to the program as metadata.
+type XDPMd struct {
+	Data           uint32
+	DataEnd        uint32
+	DataMeta       uint32
+	IngressIfindex uint32
+	RxQueueIndex   uint32
+	EgressIfindex  uint32
+}
+
 // Test runs the Program in the kernel with the given input and returns the
 // value returned by the eBPF program. outLen may be zero.
 //
@@ -674,6 +869,14 @@ func (p *Program) run(opts *RunOptions) (uint32, time.Duration, error) {
 		return 0, 0, err
 	}
 
+	if opts.Flags&RunXDPLiveFrames != 0 {
+		if opts.DataOut != nil || opts.ContextOut != nil {
+			return 0, 0, fmt.Errorf("DataOut and ContextOut can't be used with live frames")
+		}
+	} else if opts.BatchSize != 0 {
+		return 0, 0, fmt.Errorf("BatchSize requires live frames")
+	}
+
 	var ctxBytes []byte
 	if opts.Context != nil {
 		ctx := new(bytes.Buffer)
@@ -701,6 +904,7 @@ func (p *Program) run(opts *RunOptions) (uint32, time.Duration, error) {
 		CtxOut:      sys.NewSlicePointer(ctxOut),
 		Flags:       opts.Flags,
 		Cpu:         opts.CPU,
+		BatchSize:   opts.BatchSize,
 	}
 
 	if attr.Repeat == 0 {
@@ -741,6 +945,10 @@ retry:
 			return 0, 0, fmt.Errorf("kernel doesn't support running %s: %w", p.Type(), ErrNotSupported)
 		}
 
+		if errors.Is(err, unix.ENOSPC) && opts.DataOut != nil {
+			return 0, 0, fmt.Errorf("DataOut is too small, need %d bytes: %w", attr.DataSizeOut, err)
+		}
+
 		return 0, 0, err
 	}
 
@@ -810,7 +1018,7 @@ func LoadPinnedProgram(fileName string, opts *LoadPinOptions) (*Program, error)
 		progName = filepath.Base(fileName)
 	}
 
-	return &Program{"", fd, progName, fileName, info.Type}, nil
+	return &Program{"", fd, progName, fileName, info.Type, AttachNone, 0}, nil
 }
 
 // SanitizeName replaces all invalid characters in name with replacement.
@@ -837,6 +1045,69 @@ func ProgramGetNextID(startID ProgramID) (ProgramID, error) {
 	return ProgramID(attr.NextId), sys.ProgGetNextId(attr)
 }
 
+// LoadedProgramIterator allows enumerating the programs loaded into the kernel.
+//
+// Requires CAP_SYS_ADMIN.
+type LoadedProgramIterator struct {
+	// The ID of the current program. Only valid after a call to Next.
+	ID ProgramID
+	// The current Program. Only valid until a call to Next.
+	// See Take if you want to retain the program.
+	Program *Program
+	err     error
+}
+
+// Next retrieves the next program.
+//
+// Returns true if another program was found. Call [LoadedProgramIterator.Err] after
+// the function returns false.
+func (it *LoadedProgramIterator) Next() bool {
+	id := it.ID
+	for {
+		var err error
+		id, err = ProgramGetNextID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			// There are no more programs.
+			break
+		} else if err != nil {
+			it.err = fmt.Errorf("get next program ID: %w", err)
+			break
+		}
+
+		prog, err := NewProgramFromID(id)
+		if errors.Is(err, os.ErrNotExist) {
+			// The program was unloaded in the meantime.
+			continue
+		} else if err != nil {
+			it.err = fmt.Errorf("open program %d: %w", id, err)
+			break
+		}
+
+		it.Program.Close()
+		it.ID, it.Program = id, prog
+		return true
+	}
+
+	// No more programs or we encountered an error.
+	it.Program.Close()
+	it.Program = nil
+	return false
+}
+
+// Take the ownership of the current program.
+//
+// It's the callers responsibility to close the program.
+func (it *LoadedProgramIterator) Take() *Program {
+	prog := it.Program
+	it.Program = nil
+	return prog
+}
+
+// Err returns an error if iteration failed for some reason.
+func (it *LoadedProgramIterator) Err() error {
+	return it.err
+}
+
 // BindMap binds map to the program and is only released once program is released.
 //
 // This may be used in cases where metadata should be associated with the program
@@ -992,7 +1263,7 @@ func findTargetInModule(base *btf.Spec, typeName string, target *btf.Type) (*btf
 // find an attach target type in a program.
 //
 // Returns errUnrecognizedAttachType.
-func findTargetInProgram(prog *Program, name string, progType ProgramType, attachType AttachType) (btf.TypeID, error) {
+func findTargetInProgram(prog *Program, name string, progType ProgramType, attachType AttachType) (btf.TypeID, *btf.Func, error) {
 	type match struct {
 		p ProgramType
 		a AttachType
@@ -1003,25 +1274,52 @@ func findTargetInProgram(prog *Program, name string, progType ProgramType, attac
 	case match{Extension, AttachNone}:
 		typeName = name
 	default:
-		return 0, errUnrecognizedAttachType
+		return 0, nil, errUnrecognizedAttachType
 	}
 
 	btfHandle, err := prog.Handle()
 	if err != nil {
-		return 0, fmt.Errorf("load target BTF: %w", err)
+		return 0, nil, fmt.Errorf("load target BTF: %w", err)
 	}
 	defer btfHandle.Close()
 
 	spec, err := btfHandle.Spec(nil)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	var targetFunc *btf.Func
 	err = spec.TypeByName(typeName, &targetFunc)
 	if err != nil {
-		return 0, fmt.Errorf("find target %s: %w", typeName, err)
+		return 0, nil, fmt.Errorf("find target %s: %w", typeName, err)
+	}
+
+	id, err := spec.TypeID(targetFunc)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return id, targetFunc, nil
+}
+
+// fixupExtension makes sure that the entry point of an Extension program has
+// BTF, which the kernel requires to check that the extension matches the
+// function it replaces.
+//
+// Programs built without BTF, for example using the asm package, borrow the
+// prototype of the target function.
+func fixupExtension(insns asm.Instructions, name string, target *btf.Func) {
+	if btf.FuncMetadata(&insns[0]) != nil {
+		return
 	}
 
-	return spec.TypeID(targetFunc)
+	if name == "" {
+		name = target.Name
+	}
+
+	insns[0] = btf.WithFuncMetadata(insns[0], &btf.Func{
+		Name:    name,
+		Type:    target.Type,
+		Linkage: btf.GlobalFunc,
+	})
 }
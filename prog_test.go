@@ -136,6 +136,106 @@ func TestProgramRunWithOptions(t *testing.T) {
 	}
 }
 
+func TestProgramRunXDPFrags(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.18", "XDP multi-buffer BPF_PROG_RUN")
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			// Return XDP_PASS
+			asm.LoadImm(asm.R0, 2, asm.DWord),
+			asm.Return(),
+		},
+		Flags:   unix.BPF_F_XDP_HAS_FRAGS,
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	// Larger than a page, so the kernel has to use fragments.
+	in := make([]byte, 8000)
+	for i := range in {
+		in[i] = byte(i)
+	}
+
+	opts := RunOptions{
+		Data:       in,
+		DataOut:    make([]byte, len(in)),
+		Context:    XDPMd{DataEnd: uint32(len(in))},
+		ContextOut: new(XDPMd),
+	}
+	ret, err := prog.Run(&opts)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 2 {
+		t.Error("Expected return value to be 2, got", ret)
+	}
+	if !bytes.Equal(opts.DataOut, in) {
+		t.Error("DataOut doesn't match input")
+	}
+	if ctx := opts.ContextOut.(*XDPMd); ctx.DataEnd >= uint32(len(in)) {
+		t.Error("Expected linear part to be shorter than the input, got", ctx.DataEnd)
+	}
+
+	opts = RunOptions{
+		Data:    in,
+		DataOut: make([]byte, 100),
+	}
+	if _, err := prog.Run(&opts); !errors.Is(err, unix.ENOSPC) {
+		t.Error("Expected ENOSPC for short DataOut, got", err)
+	}
+}
+
+func TestProgramRunXDPLiveFrames(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.18", "BPF_F_TEST_XDP_LIVE_FRAMES")
+
+	prog, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			// Return XDP_DROP
+			asm.LoadImm(asm.R0, 1, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	opts := RunOptions{
+		Data:      make([]byte, 64),
+		Repeat:    16,
+		BatchSize: 4,
+		Flags:     RunXDPLiveFrames,
+	}
+	ret, err := prog.Run(&opts)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 0 {
+		t.Error("Expected return value to be 0, got", ret)
+	}
+
+	opts.DataOut = make([]byte, 64)
+	if _, err := prog.Run(&opts); err == nil {
+		t.Error("Live frames with DataOut don't return an error")
+	}
+
+	opts = RunOptions{
+		Data:      make([]byte, 64),
+		BatchSize: 4,
+	}
+	if _, err := prog.Run(&opts); err == nil {
+		t.Error("BatchSize without live frames doesn't return an error")
+	}
+}
+
 func TestProgramRunEmptyData(t *testing.T) {
 	testutils.SkipOnOldKernel(t, "5.13", "sk_lookup BPF_PROG_RUN")
 
@@ -380,6 +480,62 @@ func TestProgramVerifierOutputOnError(t *testing.T) {
 		t.Logf("%+v", ve)
 		t.Error("Missing verifier log in error summary")
 	}
+
+	if ve.Instruction != 0 {
+		t.Error("Expected failing instruction 0, got", ve.Instruction)
+	}
+	if _, ok := ve.Registers["R1"]; !ok {
+		t.Errorf("Missing state of R1 in %v", ve.Registers)
+	}
+}
+
+func TestProgramVerifierErrorSource(t *testing.T) {
+	_, err := NewProgram(&ProgramSpec{
+		Type: SocketFilter,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 0, asm.DWord).WithSource(asm.Comment("return 0;")),
+			asm.Mov.Reg(asm.R10, asm.R0).WithSource(asm.Comment("clobber(fp);")),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+
+	var ve *VerifierError
+	if !errors.As(err, &ve) {
+		t.Fatal("Expected a VerifierError, got", err)
+	}
+
+	// The first instruction is double-wide.
+	if ve.Instruction != 2 {
+		t.Fatalf("Expected failing instruction 2, got %d: %+v", ve.Instruction, ve)
+	}
+	if ve.Source != "clobber(fp);" {
+		t.Errorf("Expected source of instruction 2, got %q", ve.Source)
+	}
+}
+
+func TestProgramVerifierLogGrow(t *testing.T) {
+	var insns asm.Instructions
+	for i := 0; i < 4096; i++ {
+		insns = append(insns, asm.Mov.Imm(asm.R0, int32(i)))
+	}
+	insns = append(insns, asm.Return())
+
+	prog, err := NewProgramWithOptions(&ProgramSpec{
+		Type:         SocketFilter,
+		Instructions: insns,
+		License:      "MIT",
+	}, ProgramOptions{
+		LogLevel: LogLevelInstruction,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prog.Close()
+
+	if len(prog.VerifierLog) <= DefaultVerifierLogSize {
+		t.Errorf("Expected log larger than the default buffer, got %d bytes", len(prog.VerifierLog))
+	}
 }
 
 func TestProgramKernelVersion(t *testing.T) {
@@ -582,6 +738,19 @@ func TestProgramCloneNil(t *testing.T) {
 	}
 }
 
+func TestProgramNamespace(t *testing.T) {
+	ns := testutils.NewNetNS(t)
+
+	prog, err := NewProgramWithOptions(socketFilterSpec, ProgramOptions{Namespace: ns})
+	qt.Assert(t, err, qt.IsNil)
+	defer prog.Close()
+
+	ret, _, err := prog.Test(internal.EmptyBPFContext)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, ret, qt.Equals, uint32(2))
+}
+
 func TestProgramMarshaling(t *testing.T) {
 	const idx = uint32(0)
 
@@ -670,6 +839,39 @@ func TestProgramGetNextID(t *testing.T) {
 	}
 }
 
+func TestLoadedProgramIterator(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.13", "bpf_prog_get_next_id")
+
+	prog := mustSocketFilter(t)
+	info, err := prog.Info()
+	if err != nil {
+		t.Fatal("Can't get program info:", err)
+	}
+	want, _ := info.ID()
+
+	var found *Program
+	it := new(LoadedProgramIterator)
+	for it.Next() {
+		if it.ID == want {
+			found = it.Take()
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal("Iteration failed:", err)
+	}
+	if it.Program != nil {
+		t.Error("Program isn't reset after iteration")
+	}
+	if found == nil {
+		t.Fatalf("Program %d not found", want)
+	}
+	defer found.Close()
+
+	if found.Type() != SocketFilter {
+		t.Error("Expected SocketFilter, got", found.Type())
+	}
+}
+
 func TestNewProgramFromID(t *testing.T) {
 	prog := mustSocketFilter(t)
 
@@ -832,6 +1034,29 @@ func TestProgramAttachToKernel(t *testing.T) {
 	}
 }
 
+func TestFixupExtension(t *testing.T) {
+	proto := &btf.FuncProto{Return: &btf.Int{Name: "int", Size: 4}}
+	target := &btf.Func{Name: "slot", Type: proto, Linkage: btf.GlobalFunc}
+
+	insns := asm.Instructions{
+		asm.Mov.Imm(asm.R0, 0),
+		asm.Return(),
+	}
+	fixupExtension(insns, "", target)
+
+	fn := btf.FuncMetadata(&insns[0])
+	qt.Assert(t, fn, qt.IsNotNil)
+	qt.Assert(t, fn.Name, qt.Equals, "slot")
+	qt.Assert(t, fn.Type, qt.Equals, btf.Type(proto))
+	qt.Assert(t, fn.Linkage, qt.Equals, btf.GlobalFunc)
+
+	// Existing BTF is left alone.
+	existing := &btf.Func{Name: "replacement", Type: proto}
+	insns[0] = btf.WithFuncMetadata(insns[0], existing)
+	fixupExtension(insns, "other", target)
+	qt.Assert(t, btf.FuncMetadata(&insns[0]), qt.Equals, existing)
+}
+
 func TestProgramKernelTypes(t *testing.T) {
 	if _, err := os.Stat("/sys/kernel/btf/vmlinux"); os.IsNotExist(err) {
 		t.Skip("/sys/kernel/btf/vmlinux not present")
@@ -0,0 +1,115 @@
+package ebpf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// The longest interval between two attempts of PopWait.
+const maxPopInterval = 100 * time.Millisecond
+
+// Push adds a value to a Queue, Stack or BloomFilter.
+//
+// Passing UpdateExist replaces the oldest value of a full Queue or Stack.
+// Returns ErrFull if the Queue or Stack is full otherwise.
+func (m *Map) Push(value interface{}, flags MapUpdateFlags) error {
+	switch m.typ {
+	case Queue, Stack, BloomFilter:
+	default:
+		return fmt.Errorf("can't push to %s", m.typ)
+	}
+
+	valuePtr, err := m.marshalValue(value)
+	if err != nil {
+		return fmt.Errorf("can't marshal value: %w", err)
+	}
+
+	attr := sys.MapUpdateElemAttr{
+		MapFd: m.fd.Uint(),
+		Value: valuePtr,
+		Flags: uint64(flags),
+	}
+
+	err = sys.MapUpdateElem(&attr)
+	if errors.Is(err, unix.E2BIG) {
+		return fmt.Errorf("push: %w", sysErrFull)
+	}
+	if err != nil {
+		return fmt.Errorf("push: %w", wrapMapError(err))
+	}
+	return nil
+}
+
+// Pop removes the next value from a Queue or Stack.
+//
+// Returns ErrEmpty if the map is empty.
+func (m *Map) Pop(valueOut interface{}) error {
+	if err := m.checkQueue("pop"); err != nil {
+		return err
+	}
+
+	err := m.lookupAndDelete(nil, valueOut, 0)
+	if errors.Is(err, ErrKeyNotExist) {
+		return fmt.Errorf("pop: %w", sysErrEmpty)
+	}
+	return err
+}
+
+// Peek retrieves the next value of a Queue or Stack without removing it.
+//
+// Returns ErrEmpty if the map is empty.
+func (m *Map) Peek(valueOut interface{}) error {
+	if err := m.checkQueue("peek"); err != nil {
+		return err
+	}
+
+	err := m.Lookup(nil, valueOut)
+	if errors.Is(err, ErrKeyNotExist) {
+		return fmt.Errorf("peek: %w", sysErrEmpty)
+	}
+	return err
+}
+
+// PopWait is like Pop, except that it waits for a value until ctx is done.
+//
+// The kernel doesn't notify user space when a BPF program pushes a value, so
+// the map is polled at increasing intervals of up to 100ms. A send on notify
+// retries immediately, for example when a program signals pushes through a
+// ring buffer. notify may be nil.
+//
+// Returns ctx.Err() if ctx is done before a value is available.
+func (m *Map) PopWait(ctx context.Context, valueOut interface{}, notify <-chan struct{}) error {
+	interval := time.Millisecond
+	for {
+		err := m.Pop(valueOut)
+		if !errors.Is(err, ErrEmpty) {
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-notify:
+			interval = time.Millisecond
+		case <-timer.C:
+			if interval < maxPopInterval {
+				interval *= 2
+			}
+		}
+		timer.Stop()
+	}
+}
+
+func (m *Map) checkQueue(op string) error {
+	if m.typ != Queue && m.typ != Stack {
+		return fmt.Errorf("can't %s %s", op, m.typ)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package ebpf
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestMapQueuePushPop(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.20", "map type queue")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Queue,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	var v uint32
+	if err := m.Peek(&v); !errors.Is(err, ErrEmpty) {
+		t.Fatal("Peek on empty Queue:", err)
+	}
+	if err := m.Pop(&v); !errors.Is(err, ErrEmpty) {
+		t.Fatal("Pop on empty Queue:", err)
+	}
+
+	for _, v := range []uint32{1, 2} {
+		if err := m.Push(v, UpdateAny); err != nil {
+			t.Fatalf("Can't push %d: %s", v, err)
+		}
+	}
+	if err := m.Push(uint32(3), UpdateAny); !errors.Is(err, ErrFull) {
+		t.Fatal("Push to full Queue:", err)
+	}
+	if err := m.Push(uint32(3), UpdateExist); err != nil {
+		t.Fatal("Can't push to full Queue with UpdateExist:", err)
+	}
+
+	if err := m.Peek(&v); err != nil {
+		t.Fatal("Can't peek:", err)
+	}
+	if v != 2 {
+		t.Error("Want value 2, got", v)
+	}
+
+	for _, want := range []uint32{2, 3} {
+		if err := m.Pop(&v); err != nil {
+			t.Fatal("Can't pop:", err)
+		}
+		if v != want {
+			t.Errorf("Want value %d, got %d", want, v)
+		}
+	}
+
+	if _, err := m.Contains(uint32(2)); err == nil {
+		t.Error("Contains on a Queue doesn't return an error")
+	}
+}
+
+func TestMapStackPushPop(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.20", "map type stack")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Stack,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	for _, v := range []uint32{1, 2} {
+		if err := m.Push(v, UpdateAny); err != nil {
+			t.Fatalf("Can't push %d: %s", v, err)
+		}
+	}
+
+	var v uint32
+	for _, want := range []uint32{2, 1} {
+		if err := m.Pop(&v); err != nil {
+			t.Fatal("Can't pop:", err)
+		}
+		if v != want {
+			t.Errorf("Want value %d, got %d", want, v)
+		}
+	}
+}
+
+func TestMapPopWait(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "4.20", "map type queue")
+
+	m, err := NewMap(&MapSpec{
+		Type:       Queue,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var v uint32
+	if err := m.PopWait(ctx, &v, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("PopWait on empty Queue:", err)
+	}
+
+	notify := make(chan struct{}, 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if err := m.Push(uint32(42), UpdateAny); err != nil {
+			panic(err)
+		}
+		notify <- struct{}{}
+	}()
+
+	if err := m.PopWait(context.Background(), &v, notify); err != nil {
+		t.Fatal("PopWait:", err)
+	}
+	if v != 42 {
+		t.Error("Want value 42, got", v)
+	}
+}
+
+func TestMapPushInvalid(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if err := m.Push(uint32(1), UpdateAny); err == nil {
+		t.Error("Push to an Array doesn't return an error")
+	}
+	if err := m.Pop(new(uint32)); err == nil {
+		t.Error("Pop from an Array doesn't return an error")
+	}
+}
@@ -0,0 +1,226 @@
+package ebpf
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Registry keeps track of objects like maps, programs and readers of an
+// application by attaching string tags to them, and allows finding objects
+// by their tags.
+//
+// A Registry only lives in the current process and doesn't own the objects
+// added to it, except when calling Close. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	objects map[registryKey]*registryEntry
+	// seq orders entries by the time they were added.
+	seq uint64
+}
+
+// registryKey identifies an object by the type and address of the pointer
+// it's made of. The address can't be reused while the object is in the
+// registry, since the entry references the object.
+type registryKey struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+func registryKeyOf(obj io.Closer) (registryKey, bool) {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return registryKey{}, false
+	}
+	return registryKey{v.Type(), v.Pointer()}, true
+}
+
+type registryEntry struct {
+	obj  io.Closer
+	seq  uint64
+	tags map[string]struct{}
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{objects: make(map[registryKey]*registryEntry)}
+}
+
+// entry returns the entry of obj, or nil if obj isn't in the registry.
+//
+// Must be called with r.mu held.
+func (r *Registry) entry(obj io.Closer) *registryEntry {
+	key, ok := registryKeyOf(obj)
+	if !ok {
+		return nil
+	}
+	return r.objects[key]
+}
+
+// Tag adds obj to the registry if necessary and attaches tags to it.
+//
+// obj is usually a *Map, *Program or a reader from the perf or ringbuf
+// packages. It must be a non-nil pointer and is compared by identity.
+func (r *Registry) Tag(obj io.Closer, tags ...string) error {
+	key, ok := registryKeyOf(obj)
+	if !ok {
+		return fmt.Errorf("can't tag %T: not a non-nil pointer", obj)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := r.objects[key]
+	if entry == nil {
+		r.seq++
+		entry = &registryEntry{obj, r.seq, make(map[string]struct{})}
+		r.objects[key] = entry
+	}
+
+	for _, tag := range tags {
+		entry.tags[tag] = struct{}{}
+	}
+
+	return nil
+}
+
+// TagCollection tags all maps and programs of coll. Each object is also
+// tagged with its name in the collection.
+func (r *Registry) TagCollection(coll *Collection, tags ...string) error {
+	for _, name := range sortedKeys(coll.Maps) {
+		if err := r.Tag(coll.Maps[name], append([]string{name}, tags...)...); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(coll.Programs) {
+		if err := r.Tag(coll.Programs[name], append([]string{name}, tags...)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Untag removes tags from obj. obj stays in the registry even if it has no
+// tags left, use Remove to forget about it.
+func (r *Registry) Untag(obj io.Closer, tags ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry := r.entry(obj); entry != nil {
+		for _, tag := range tags {
+			delete(entry.tags, tag)
+		}
+	}
+}
+
+// Remove obj from the registry without closing it.
+func (r *Registry) Remove(obj io.Closer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, ok := registryKeyOf(obj); ok {
+		delete(r.objects, key)
+	}
+}
+
+// Tags returns the tags of obj in ascending order, or nil if obj isn't in
+// the registry.
+func (r *Registry) Tags(obj io.Closer) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry := r.entry(obj)
+	if entry == nil {
+		return nil
+	}
+
+	tags := make([]string, 0, len(entry.tags))
+	for tag := range entry.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Find returns the objects which have all of the given tags, in the order
+// they were added. Returns all objects if no tags are given.
+func (r *Registry) Find(tags ...string) []io.Closer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type match struct {
+		obj io.Closer
+		seq uint64
+	}
+
+	var matches []match
+outer:
+	for _, entry := range r.objects {
+		for _, tag := range tags {
+			if _, ok := entry.tags[tag]; !ok {
+				continue outer
+			}
+		}
+		matches = append(matches, match{entry.obj, entry.seq})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].seq < matches[j].seq
+	})
+
+	objs := make([]io.Closer, 0, len(matches))
+	for _, m := range matches {
+		objs = append(objs, m.obj)
+	}
+	return objs
+}
+
+// Maps returns the maps which have all of the given tags, see Find.
+func (r *Registry) Maps(tags ...string) []*Map {
+	return findType[*Map](r, tags)
+}
+
+// Programs returns the programs which have all of the given tags, see Find.
+func (r *Registry) Programs(tags ...string) []*Program {
+	return findType[*Program](r, tags)
+}
+
+func findType[T io.Closer](r *Registry, tags []string) []T {
+	var objs []T
+	for _, obj := range r.Find(tags...) {
+		if t, ok := obj.(T); ok {
+			objs = append(objs, t)
+		}
+	}
+	return objs
+}
+
+// Close closes all objects in the registry and removes them from it.
+//
+// Returns the first error encountered.
+func (r *Registry) Close() error {
+	objs := r.Find()
+
+	r.mu.Lock()
+	r.objects = make(map[registryKey]*registryEntry)
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, obj := range objs {
+		if err := obj.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,71 @@
+package ebpf
+
+import (
+	"io"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// sameObjects compares maps and programs by identity.
+var sameObjects = qt.CmpEquals(
+	cmp.Comparer(func(a, b *Map) bool { return a == b }),
+	cmp.Comparer(func(a, b *Program) bool { return a == b }),
+)
+
+func TestRegistry(t *testing.T) {
+	hash := newHash(t)
+	array := createArray(t)
+	defer array.Close()
+	prog := mustSocketFilter(t)
+
+	r := NewRegistry()
+	qt.Assert(t, r.Tag(hash, "tenant-a", "flows"), qt.IsNil)
+	qt.Assert(t, r.Tag(array, "tenant-b"), qt.IsNil)
+	qt.Assert(t, r.Tag(prog, "tenant-a"), qt.IsNil)
+	qt.Assert(t, r.Tag(nil, "foo"), qt.IsNotNil)
+	qt.Assert(t, r.Tag((*Map)(nil), "foo"), qt.IsNotNil)
+	qt.Assert(t, r.Tag(closerFunc(nil), "foo"), qt.IsNotNil)
+	qt.Assert(t, r.Tags(closerFunc(nil)), qt.IsNil)
+	r.Remove(closerFunc(nil))
+
+	qt.Assert(t, r.Tags(hash), qt.DeepEquals, []string{"flows", "tenant-a"})
+	qt.Assert(t, r.Tags(newHash(t)), qt.IsNil)
+
+	qt.Assert(t, r.Find("tenant-a"), sameObjects, []io.Closer{hash, prog})
+	qt.Assert(t, r.Find("tenant-a", "flows"), sameObjects, []io.Closer{hash})
+	qt.Assert(t, r.Find("missing"), qt.HasLen, 0)
+	qt.Assert(t, r.Find(), qt.HasLen, 3)
+	qt.Assert(t, r.Maps("tenant-a"), sameObjects, []*Map{hash})
+	qt.Assert(t, r.Programs("tenant-a"), sameObjects, []*Program{prog})
+
+	r.Untag(hash, "tenant-a")
+	qt.Assert(t, r.Maps("tenant-a"), qt.HasLen, 0)
+	qt.Assert(t, r.Maps("flows"), sameObjects, []*Map{hash})
+
+	r.Remove(hash)
+	qt.Assert(t, r.Tags(hash), qt.IsNil)
+
+	qt.Assert(t, r.Close(), qt.IsNil)
+	qt.Assert(t, r.Find(), qt.HasLen, 0)
+	qt.Assert(t, array.FD() < 0, qt.IsTrue, qt.Commentf("array wasn't closed"))
+}
+
+// closerFunc isn't comparable and therefore can't be used as a map key.
+type closerFunc func() error
+
+func (fn closerFunc) Close() error { return fn() }
+
+func TestRegistryTagCollection(t *testing.T) {
+	coll := &Collection{
+		Maps:     map[string]*Map{"hash": newHash(t)},
+		Programs: map[string]*Program{"filter": mustSocketFilter(t)},
+	}
+
+	r := NewRegistry()
+	qt.Assert(t, r.TagCollection(coll, "agent"), qt.IsNil)
+	qt.Assert(t, r.Maps("hash", "agent"), sameObjects, []*Map{coll.Maps["hash"]})
+	qt.Assert(t, r.Programs("filter", "agent"), sameObjects, []*Program{coll.Programs["filter"]})
+}
@@ -0,0 +1,169 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal/epoll"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// MultiRecord is a Record read by a MultiReader.
+type MultiRecord struct {
+	Record
+	// The map the record was read from.
+	Map *ebpf.Map
+}
+
+// MultiReader reads from multiple BPF ringbufs using a single poller.
+//
+// Records of a single map are returned in the order they were submitted,
+// there is no ordering between records of different maps.
+type MultiReader struct {
+	poller *epoll.Poller
+
+	// mu protects read/write access to the MultiReader structure
+	mu          sync.Mutex
+	maps        []*ebpf.Map
+	rings       []*ringbufEventRing
+	epollEvents []unix.EpollEvent
+	header      []byte
+	// Indices of rings which may contain records, in the order they became
+	// ready.
+	ready    []int
+	deadline time.Time
+}
+
+// NewMultiReader creates a reader for the given RingBuf maps.
+func NewMultiReader(ringbufMaps ...*ebpf.Map) (_ *MultiReader, err error) {
+	if len(ringbufMaps) == 0 {
+		return nil, errors.New("no ringbuffer maps given")
+	}
+
+	poller, err := epoll.New()
+	if err != nil {
+		return nil, err
+	}
+
+	mr := &MultiReader{
+		poller:      poller,
+		maps:        append([]*ebpf.Map(nil), ringbufMaps...),
+		rings:       make([]*ringbufEventRing, 0, len(ringbufMaps)),
+		epollEvents: make([]unix.EpollEvent, len(ringbufMaps)),
+		header:      make([]byte, ringbufHeaderSize),
+	}
+	defer func() {
+		if err != nil {
+			mr.Close()
+		}
+	}()
+
+	for i, m := range ringbufMaps {
+		maxEntries, err := ringbufSize(m)
+		if err != nil {
+			return nil, fmt.Errorf("map %d: %w", i, err)
+		}
+
+		if err := poller.Add(m.FD(), i); err != nil {
+			return nil, fmt.Errorf("map %d: %w", i, err)
+		}
+
+		ring, err := newRingBufEventRing(m.FD(), maxEntries)
+		if err != nil {
+			return nil, fmt.Errorf("map %d: failed to create ringbuf ring: %w", i, err)
+		}
+		mr.rings = append(mr.rings, ring)
+	}
+
+	return mr, nil
+}
+
+// Close frees resources used by the reader.
+//
+// It interrupts calls to Read.
+func (mr *MultiReader) Close() error {
+	if err := mr.poller.Close(); err != nil {
+		if errors.Is(err, os.ErrClosed) {
+			return nil
+		}
+		return err
+	}
+
+	// Acquire the lock. This ensures that Read isn't running.
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, ring := range mr.rings {
+		ring.Close()
+	}
+	mr.rings = nil
+
+	return nil
+}
+
+// SetDeadline controls how long Read and ReadInto will block waiting for samples.
+//
+// Passing a zero time.Time will remove the deadline.
+func (mr *MultiReader) SetDeadline(t time.Time) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.deadline = t
+}
+
+// Read the next record from any of the BPF ringbufs.
+//
+// Returns os.ErrClosed if Close is called on the MultiReader, or
+// os.ErrDeadlineExceeded if a deadline was set.
+func (mr *MultiReader) Read() (MultiRecord, error) {
+	var rec MultiRecord
+	return rec, mr.ReadInto(&rec)
+}
+
+// ReadInto is like Read except that it allows reusing MultiRecord and
+// associated buffers.
+func (mr *MultiReader) ReadInto(rec *MultiRecord) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.rings == nil {
+		return fmt.Errorf("ringbuffer: %w", ErrClosed)
+	}
+
+	for {
+		if len(mr.ready) == 0 {
+			n, err := mr.poller.Wait(mr.epollEvents, mr.deadline)
+			if err != nil {
+				return err
+			}
+
+			for _, event := range mr.epollEvents[:n] {
+				mr.ready = append(mr.ready, int(event.Pad))
+			}
+		}
+
+		// Drain the first ready ring before moving on to the next one, like
+		// Reader does.
+		i := mr.ready[0]
+		for {
+			err := readRecord(mr.rings[i], &rec.Record, mr.header)
+			if err == errBusy || err == errDiscard {
+				continue
+			}
+			if err == errEOR {
+				mr.ready = mr.ready[1:]
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			rec.Map = mr.maps[i]
+			return nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ringbuf
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMultiReader(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	// Every second sample is discarded.
+	prog1, events1 := mustOutputSamplesProg(t, 0, 5, 10, 15, 20, 25)
+	prog2, events2 := mustOutputSamplesProg(t, 0, 7, 8, 9)
+
+	rd, err := NewMultiReader(events1, events2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	for _, prog := range []*ebpf.Program{prog1, prog2} {
+		if _, _, err := prog.Test(internal.EmptyBPFContext); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[*ebpf.Map][]int)
+	for i := 0; i < 5; i++ {
+		rec, err := rd.Read()
+		if err != nil {
+			t.Fatal("Can't read samples:", err)
+		}
+		got[rec.Map] = append(got[rec.Map], len(rec.RawSample))
+	}
+
+	want := map[*ebpf.Map][]int{
+		events1: {5, 15, 25},
+		events2: {7, 9},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Read samples mismatch (-want +got):\n%s", diff)
+	}
+
+	rd.SetDeadline(time.Now().Add(-time.Second))
+	if _, err := rd.Read(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("Expected os.ErrDeadlineExceeded, got:", err)
+	}
+
+	if err := rd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rd.Read(); !errors.Is(err, ErrClosed) {
+		t.Error("Expected ErrClosed, got:", err)
+	}
+}
+
+func TestMultiReaderInvalidMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	_, events := mustOutputSamplesProg(t, 0, 5)
+	array, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.Array,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer array.Close()
+
+	if _, err := NewMultiReader(events, array); err == nil {
+		t.Fatal("NewMultiReader accepts an array")
+	}
+
+	if _, err := NewMultiReader(); err == nil {
+		t.Fatal("NewMultiReader accepts no maps")
+	}
+}
@@ -1,6 +1,7 @@
 package ringbuf
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -44,6 +45,9 @@ func (rh *ringbufHeader) dataLen() int {
 
 type Record struct {
 	RawSample []byte
+
+	// view is set for records returned by Reader.ReadView.
+	view *recordView
 }
 
 // Read a record from an event ring.
@@ -52,6 +56,20 @@ type Record struct {
 func readRecord(rd *ringbufEventRing, rec *Record, buf []byte) error {
 	rd.loadConsumer()
 
+	err := nextRecord(rd, rec, buf)
+	if err == nil || err == errDiscard {
+		rd.storeConsumer()
+	}
+	return err
+}
+
+// nextRecord reads the record at the consumer position of rd and advances
+// it, without committing the position to the kernel.
+//
+// buf must be at least ringbufHeaderSize bytes long.
+func nextRecord(rd *ringbufEventRing, rec *Record, buf []byte) error {
+	start := rd.cons
+
 	buf = buf[:ringbufHeaderSize]
 	if _, err := io.ReadFull(rd, buf); err == io.EOF {
 		return errEOR
@@ -66,8 +84,8 @@ func readRecord(rd *ringbufEventRing, rec *Record, buf []byte) error {
 
 	if header.isBusy() {
 		// the next sample in the ring is not committed yet so we
-		// exit without storing the reader/consumer position
-		// and start again from the same position.
+		// rewind the consumer position to read it again later.
+		rd.cons = start
 		return errBusy
 	}
 
@@ -81,7 +99,6 @@ func readRecord(rd *ringbufEventRing, rec *Record, buf []byte) error {
 		// and reading/copying from the ring (which normally keeps track of the
 		// consumer position).
 		rd.skipRead(dataLenAligned)
-		rd.storeConsumer()
 
 		return errDiscard
 	}
@@ -96,7 +113,6 @@ func readRecord(rd *ringbufEventRing, rec *Record, buf []byte) error {
 		return fmt.Errorf("read sample: %w", err)
 	}
 
-	rd.storeConsumer()
 	rec.RawSample = rec.RawSample[:header.dataLen()]
 	return nil
 }
@@ -107,23 +123,57 @@ type Reader struct {
 	poller *epoll.Poller
 
 	// mu protects read/write access to the Reader structure
-	mu          sync.Mutex
+	mu sync.Mutex
+	// ringMu protects ring and dropCounter from being released while Stats
+	// or Record.Release access them, since they don't wait for Read. It also
+	// protects views.
+	ringMu      sync.Mutex
 	ring        *ringbufEventRing
+	dropCounter *ebpf.Map
+	// views are the records returned by ReadView which haven't been
+	// committed to the kernel yet, in the order they were read.
+	views       []*recordView
 	epollEvents []unix.EpollEvent
 	header      []byte
 	haveData    bool
 	deadline    time.Time
 }
 
+// ReaderOptions control the behaviour of a Reader.
+type ReaderOptions struct {
+	// DropCounter is an optional Array or PerCPUArray map with a uint64
+	// value at key zero, which the BPF program increments when reserving
+	// space in the ring fails. It's reported by Stats.
+	DropCounter *ebpf.Map
+}
+
 // NewReader creates a new BPF ringbuf reader.
 func NewReader(ringbufMap *ebpf.Map) (*Reader, error) {
-	if ringbufMap.Type() != ebpf.RingBuf {
-		return nil, fmt.Errorf("invalid Map type: %s", ringbufMap.Type())
+	return NewReaderWithOptions(ringbufMap, ReaderOptions{})
+}
+
+// NewReaderWithOptions creates a new BPF ringbuf reader with the given options.
+func NewReaderWithOptions(ringbufMap *ebpf.Map, opts ReaderOptions) (_ *Reader, err error) {
+	maxEntries, err := ringbufSize(ringbufMap)
+	if err != nil {
+		return nil, err
 	}
 
-	maxEntries := int(ringbufMap.MaxEntries())
-	if maxEntries == 0 || (maxEntries&(maxEntries-1)) != 0 {
-		return nil, fmt.Errorf("ringbuffer map size %d is zero or not a power of two", maxEntries)
+	var dropCounter *ebpf.Map
+	if opts.DropCounter != nil {
+		if err := checkDropCounter(opts.DropCounter); err != nil {
+			return nil, err
+		}
+
+		dropCounter, err = opts.DropCounter.Clone()
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				dropCounter.Close()
+			}
+		}()
 	}
 
 	poller, err := epoll.New()
@@ -145,11 +195,26 @@ func NewReader(ringbufMap *ebpf.Map) (*Reader, error) {
 	return &Reader{
 		poller:      poller,
 		ring:        ring,
+		dropCounter: dropCounter,
 		epollEvents: make([]unix.EpollEvent, 1),
 		header:      make([]byte, ringbufHeaderSize),
 	}, nil
 }
 
+// ringbufSize returns the size of a RingBuf map.
+func ringbufSize(ringbufMap *ebpf.Map) (int, error) {
+	if ringbufMap.Type() != ebpf.RingBuf {
+		return 0, fmt.Errorf("invalid Map type: %s", ringbufMap.Type())
+	}
+
+	maxEntries := int(ringbufMap.MaxEntries())
+	if maxEntries == 0 || (maxEntries&(maxEntries-1)) != 0 {
+		return 0, fmt.Errorf("ringbuffer map size %d is zero or not a power of two", maxEntries)
+	}
+
+	return maxEntries, nil
+}
+
 // Close frees resources used by the reader.
 //
 // It interrupts calls to Read.
@@ -165,9 +230,18 @@ func (r *Reader) Close() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	r.ringMu.Lock()
+	defer r.ringMu.Unlock()
+
 	if r.ring != nil {
 		r.ring.Close()
 		r.ring = nil
+		r.views = nil
+	}
+
+	if r.dropCounter != nil {
+		r.dropCounter.Close()
+		r.dropCounter = nil
 	}
 
 	return nil
@@ -194,6 +268,10 @@ func (r *Reader) Read() (Record, error) {
 
 // ReadInto is like Read except that it allows reusing Record and associated buffers.
 func (r *Reader) ReadInto(rec *Record) error {
+	return r.readInto(context.Background(), rec)
+}
+
+func (r *Reader) readInto(ctx context.Context, rec *Record) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -201,9 +279,15 @@ func (r *Reader) ReadInto(rec *Record) error {
 		return fmt.Errorf("ringbuffer: %w", ErrClosed)
 	}
 
+	// RawSample may point into the ring, which mustn't be written to.
+	rec.Release()
+	if r.pendingViews() {
+		return errPendingViews
+	}
+
 	for {
 		if !r.haveData {
-			_, err := r.poller.Wait(r.epollEvents[:cap(r.epollEvents)], r.deadline)
+			err := r.wait(ctx)
 			if err != nil {
 				return err
 			}
@@ -224,3 +308,121 @@ func (r *Reader) ReadInto(rec *Record) error {
 		}
 	}
 }
+
+// ReadBatch reads multiple records at once, reusing the buffers of recs.
+//
+// It blocks until at least one record is available and then fills recs with
+// as many records as are available without blocking again. The consumer
+// position is only committed to the kernel once at the end, which avoids
+// contending with BPF programs for the cache line holding it and makes
+// ReadBatch cheaper than repeated calls to ReadInto under high event rates.
+//
+// The buffers of recs are reused as described in ReadInto.
+//
+// Returns the number of records read. n may be non-zero even if an error is
+// returned.
+func (r *Reader) ReadBatch(recs []Record) (n int, err error) {
+	if len(recs) == 0 {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ring == nil {
+		return 0, fmt.Errorf("ringbuffer: %w", ErrClosed)
+	}
+
+	for i := range recs {
+		recs[i].Release()
+	}
+	if r.pendingViews() {
+		return 0, errPendingViews
+	}
+
+	for {
+		if !r.haveData {
+			if err := r.wait(context.Background()); err != nil {
+				return 0, err
+			}
+			r.haveData = true
+		}
+
+		n, err := r.readBatch(recs)
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// readBatch reads the records which are available into recs.
+func (r *Reader) readBatch(recs []Record) (n int, err error) {
+	ring := r.ring
+	ring.loadConsumer()
+	defer ring.storeConsumer()
+
+	for n < len(recs) {
+		err := nextRecord(ring, &recs[n], r.header)
+		switch {
+		case err == errDiscard:
+		case err == errBusy && n == 0:
+			// Wait for the first record to be committed, like ReadInto.
+		case err == errBusy:
+			return n, nil
+		case err == errEOR:
+			r.haveData = false
+			return n, nil
+		case err != nil:
+			return n, err
+		default:
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// wait blocks until the ring has data, the deadline expires or ctx is
+// cancelled.
+func (r *Reader) wait(ctx context.Context) error {
+	for {
+		err := r.waitOnce(ctx)
+		if errors.Is(err, epoll.ErrInterrupted) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// The interrupt was issued on behalf of a previous call whose
+			// context was cancelled after it returned.
+			continue
+		}
+
+		return err
+	}
+}
+
+func (r *Reader) waitOnce(ctx context.Context) error {
+	done := ctx.Done()
+	if done == nil {
+		_, err := r.poller.Wait(r.epollEvents[:cap(r.epollEvents)], r.deadline)
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Forward cancellation of ctx to the poller.
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-done:
+			_ = r.poller.Interrupt()
+		case <-stop:
+		}
+	}()
+
+	_, err := r.poller.Wait(r.epollEvents[:cap(r.epollEvents)], r.deadline)
+	return err
+}
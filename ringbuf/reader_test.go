@@ -1,8 +1,11 @@
 package ringbuf
 
 import (
+	"context"
 	"errors"
 	"os"
+	"sort"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -253,6 +256,331 @@ func TestReaderSetDeadline(t *testing.T) {
 	}
 }
 
+func TestReaderStats(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	for _, typ := range []ebpf.MapType{ebpf.Array, ebpf.PerCPUArray} {
+		prog, events := mustOutputSamplesProg(t, 0, 5, 10)
+
+		counter, err := ebpf.NewMap(&ebpf.MapSpec{
+			Type:       typ,
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer counter.Close()
+
+		var value interface{} = uint64(3)
+		if typ == ebpf.PerCPUArray {
+			n, err := internal.PossibleCPUs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			values := make([]uint64, n)
+			values[0]++
+			values[n-1] += 2
+			value = values
+		}
+		if err := counter.Put(uint32(0), value); err != nil {
+			t.Fatal(err)
+		}
+
+		rd, err := NewReaderWithOptions(events, ReaderOptions{DropCounter: counter})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rd.Close()
+
+		if _, _, err := prog.Test(internal.EmptyBPFContext); err != nil {
+			t.Fatal(err)
+		}
+
+		before, err := rd.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if before.Size != 4096 {
+			t.Errorf("Expected size 4096, got %d", before.Size)
+		}
+		// A sample of 5 bytes and a discarded one of 10 bytes, with headers.
+		if before.AvailableData != 16+24 {
+			t.Errorf("Expected 40 bytes of available data, got %d", before.AvailableData)
+		}
+		if before.ReserveFailures != 3 {
+			t.Errorf("Expected 3 reserve failures, got %d", before.ReserveFailures)
+		}
+
+		if _, err := rd.Read(); err != nil {
+			t.Fatal(err)
+		}
+
+		after, err := rd.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.ConsumerPos != before.ConsumerPos+16 {
+			t.Errorf("Expected consumer to advance by 16 bytes, got %d", after.ConsumerPos-before.ConsumerPos)
+		}
+		if after.AvailableData != int(after.ProducerPos-after.ConsumerPos) {
+			t.Errorf("AvailableData %d doesn't match positions %d and %d", after.AvailableData, after.ConsumerPos, after.ProducerPos)
+		}
+
+		if err := rd.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rd.Stats(); !errors.Is(err, ErrClosed) {
+			t.Error("Expected ErrClosed, got", err)
+		}
+	}
+
+	_, events := mustOutputSamplesProg(t, 0, 5)
+	if _, err := NewReaderWithOptions(events, ReaderOptions{DropCounter: events}); err == nil {
+		t.Error("NewReaderWithOptions accepts a ringbuf as drop counter")
+	}
+}
+
+func TestReaderReadView(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	// The second sample is discarded.
+	prog, events := mustOutputSamplesProg(t, 0, 5, 10, 15)
+
+	rd, err := NewReader(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	consumerPos := func() uint64 {
+		t.Helper()
+		stats, err := rd.Stats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats.ConsumerPos
+	}
+
+	for _, inOrder := range []bool{true, false} {
+		if _, _, err := prog.Test(internal.EmptyBPFContext); err != nil {
+			t.Fatal(err)
+		}
+		start := consumerPos()
+
+		var first, second Record
+		if err := rd.ReadView(&first); err != nil {
+			t.Fatal(err)
+		}
+		if err := rd.ReadView(&second); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff([]byte{1, 2, 3, 4, 4}, first.RawSample); diff != "" {
+			t.Errorf("First sample mismatch (-want +got):\n%s", diff)
+		}
+		if len(second.RawSample) != 15 {
+			t.Errorf("Expected second sample of 15 bytes, got %d", len(second.RawSample))
+		}
+
+		if pos := consumerPos(); pos != start {
+			t.Fatal("Consumer position advanced before records were released")
+		}
+		if _, err := rd.Read(); !errors.Is(err, errPendingViews) {
+			t.Fatal("Expected errPendingViews, got", err)
+		}
+
+		if inOrder {
+			first.Release()
+			// The discarded sample following the first one is released too.
+			if pos := consumerPos(); pos != start+16+24 {
+				t.Errorf("Expected consumer position %d, got %d", start+16+24, pos)
+			}
+			second.Release()
+		} else {
+			second.Release()
+			if pos := consumerPos(); pos != start {
+				t.Error("Consumer position advanced past an unreleased record")
+			}
+			first.Release()
+		}
+
+		if pos := consumerPos(); pos != start+16+24+24 {
+			t.Errorf("Expected consumer position %d, got %d", start+16+24+24, pos)
+		}
+		if first.RawSample != nil || second.RawSample != nil {
+			t.Error("Release doesn't clear RawSample")
+		}
+	}
+
+	rd.SetDeadline(time.Now().Add(-time.Second))
+	if _, err := rd.Read(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Error("Expected os.ErrDeadlineExceeded after releasing all records, got", err)
+	}
+}
+
+func TestReaderRun(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	// Every second sample is discarded, which leaves samples of odd length.
+	var sizes []int
+	for i := 1; i < 20; i++ {
+		sizes = append(sizes, i)
+	}
+	prog, events := mustOutputSamplesProg(t, 0, sizes...)
+
+	rd, err := NewReader(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	// Run ignores the deadline while active and restores it afterwards.
+	deadline := time.Now().Add(-time.Second)
+	rd.SetDeadline(deadline)
+
+	for _, key := range []func(*Record) uint64{
+		nil,
+		func(rec *Record) uint64 { return uint64(len(rec.RawSample) % 3) },
+	} {
+		if _, _, err := prog.Test(internal.EmptyBPFContext); err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var (
+			mu    sync.Mutex
+			byKey = make(map[uint64][]int)
+			total int
+		)
+		err = rd.Run(ctx, func(rec Record) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			var k uint64
+			if key != nil {
+				k = key(&rec)
+			}
+			byKey[k] = append(byKey[k], len(rec.RawSample))
+
+			if total++; total == 10 {
+				cancel()
+			}
+			return nil
+		}, &RunOptions{Workers: 4, Key: key})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal("Expected context.Canceled, got", err)
+		}
+
+		if key == nil {
+			sort.Ints(byKey[0])
+			if diff := cmp.Diff([]int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}, byKey[0]); diff != "" {
+				t.Errorf("Handled samples mismatch (-want +got):\n%s", diff)
+			}
+			continue
+		}
+
+		want := map[uint64][]int{
+			0: {3, 9, 15},
+			1: {1, 7, 13, 19},
+			2: {5, 11, 17},
+		}
+		if diff := cmp.Diff(want, byKey); diff != "" {
+			t.Errorf("Samples with the same key are out of order (-want +got):\n%s", diff)
+		}
+	}
+
+	if _, _, err := prog.Test(internal.EmptyBPFContext); err != nil {
+		t.Fatal(err)
+	}
+
+	errHandler := errors.New("handler failed")
+	err = rd.Run(context.Background(), func(Record) error {
+		return errHandler
+	}, nil)
+	if !errors.Is(err, errHandler) {
+		t.Fatal("Expected the error of the handler, got", err)
+	}
+	if !rd.deadline.Equal(deadline) {
+		t.Fatal("Run didn't restore the deadline")
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { rd.Close() })
+	if err := rd.Run(context.Background(), func(Record) error { return nil }, nil); err != nil {
+		t.Fatal("Expected nil after closing the Reader, got", err)
+	}
+}
+
+func TestReaderReadBatch(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	prog, events := mustOutputSamplesProg(t, 0, 5, 10, 15, 20, 25)
+
+	rd, err := NewReader(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+
+	if n, err := rd.ReadBatch(nil); n != 0 || err != nil {
+		t.Fatal("Expected no records from empty batch, got", n, err)
+	}
+
+	ret, _, err := prog.Test(internal.EmptyBPFContext)
+	testutils.SkipIfNotSupported(t, err)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errno := syscall.Errno(-int32(ret)); errno != 0 {
+		t.Fatal("Expected 0 as return value, got", errno)
+	}
+
+	recs := make([]Record, 2)
+	n, err := rd.ReadBatch(recs)
+	if err != nil {
+		t.Fatal("Can't read batch:", err)
+	}
+	if n != 2 || len(recs[0].RawSample) != 5 || len(recs[1].RawSample) != 15 {
+		t.Fatalf("Expected samples of 5 and 15 bytes, got %d records", n)
+	}
+
+	// The position is committed up to the last record of the batch.
+	stats, err := rd.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.AvailableData == 0 || stats.AvailableData >= stats.Size {
+		t.Fatal("Expected the remaining records to be available, got", stats.AvailableData)
+	}
+
+	n, err = rd.ReadBatch(recs)
+	if err != nil {
+		t.Fatal("Can't read batch:", err)
+	}
+	if n != 1 || len(recs[0].RawSample) != 25 {
+		t.Fatalf("Expected a sample of 25 bytes, got %d records", n)
+	}
+
+	stats, err = rd.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.AvailableData != 0 {
+		t.Fatal("Expected all data to be consumed, got", stats.AvailableData)
+	}
+
+	rd.SetDeadline(time.Now().Add(10 * time.Millisecond))
+	if _, err := rd.ReadBatch(recs); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("Expected os.ErrDeadlineExceeded, got", err)
+	}
+
+	rd.Close()
+	if _, err := rd.ReadBatch(recs); !errors.Is(err, ErrClosed) {
+		t.Fatal("Expected os.ErrClosed, got", err)
+	}
+}
+
 func BenchmarkReader(b *testing.B) {
 	testutils.SkipOnOldKernel(b, "5.8", "BPF ring buffer")
 
@@ -326,3 +654,67 @@ func BenchmarkReadInto(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkReadBatch(b *testing.B) {
+	testutils.SkipOnOldKernel(b, "5.8", "BPF ring buffer")
+
+	// Every other sample is discarded, which leaves 16 records per run.
+	sizes := make([]int, 32)
+	for i := range sizes {
+		sizes[i] = 80
+	}
+	const records = 16
+
+	read := map[string]func(rd *Reader, recs []Record) (int, error){
+		"ReadInto": func(rd *Reader, recs []Record) (int, error) {
+			for i := range recs {
+				if err := rd.ReadInto(&recs[i]); err != nil {
+					return i, err
+				}
+			}
+			return len(recs), nil
+		},
+		"ReadBatch": func(rd *Reader, recs []Record) (int, error) {
+			var n int
+			for n < len(recs) {
+				m, err := rd.ReadBatch(recs[n:])
+				if err != nil {
+					return n, err
+				}
+				n += m
+			}
+			return n, nil
+		},
+	}
+
+	for _, name := range []string{"ReadInto", "ReadBatch"} {
+		b.Run(name, func(b *testing.B) {
+			prog, events := mustOutputSamplesProg(b, 0, sizes...)
+
+			rd, err := NewReader(events)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer rd.Close()
+
+			buf := internal.EmptyBPFContext
+			recs := make([]Record, records)
+
+			b.ResetTimer()
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				ret, _, err := prog.Test(buf)
+				if err != nil {
+					b.Fatal(err)
+				} else if errno := syscall.Errno(-int32(ret)); errno != 0 {
+					b.Fatal("Expected 0 as return value, got", errno)
+				}
+
+				if _, err := read[name](rd, recs); err != nil {
+					b.Fatal("Can't read samples:", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package ringbuf
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// runQueueSize is the number of records buffered per queue in Reader.Run.
+const runQueueSize = 64
+
+// RunOptions control the behaviour of Reader.Run.
+type RunOptions struct {
+	// The number of goroutines invoking the handler. Defaults to 1.
+	Workers int
+	// Key orders records by key: records with the same key are always
+	// passed to the same goroutine, in the order they were read. Records
+	// with different keys may be handled concurrently.
+	//
+	// The ring doesn't record which CPU submitted a record. To keep the
+	// records of each CPU in order, include bpf_get_smp_processor_id() in the
+	// sample and return it from Key.
+	//
+	// If nil, records are handled in any order by whichever goroutine is
+	// idle.
+	Key func(*Record) uint64
+}
+
+// Run reads records until ctx is cancelled, the Reader is closed or the
+// handler returns an error.
+//
+// Each record is passed to handler from one of RunOptions.Workers goroutines.
+// Records are owned by the handler and may be retained. The deadline set via
+// SetDeadline doesn't apply while Run is active and is restored once it
+// returns.
+//
+// Returns nil if the Reader was closed, the first error returned by handler,
+// or ctx.Err().
+func (r *Reader) Run(ctx context.Context, handler func(Record) error, opts *RunOptions) error {
+	restore := r.suspendDeadline()
+	defer restore()
+
+	var o RunOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg         sync.WaitGroup
+		failOnce   sync.Once
+		handlerErr error
+	)
+
+	// Idle workers steal from a shared queue, unless records are ordered by
+	// key. In that case each worker owns a queue.
+	queues := make([]chan Record, 1)
+	if o.Key != nil {
+		queues = make([]chan Record, o.Workers)
+	}
+	for i := range queues {
+		queues[i] = make(chan Record, runQueueSize)
+	}
+
+	for i := 0; i < o.Workers; i++ {
+		wg.Add(1)
+		go func(queue <-chan Record) {
+			defer wg.Done()
+
+			for rec := range queue {
+				if ctx.Err() != nil {
+					// Drain the queue after a failure.
+					continue
+				}
+
+				if err := handler(rec); err != nil {
+					failOnce.Do(func() {
+						handlerErr = err
+						cancel()
+					})
+				}
+			}
+		}(queues[i%len(queues)])
+	}
+
+	err := r.dispatch(ctx, queues, o.Key)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	if handlerErr != nil {
+		return handlerErr
+	}
+	return err
+}
+
+// suspendDeadline clears the deadline of the Reader and returns a function
+// which restores it.
+func (r *Reader) suspendDeadline() (restore func()) {
+	r.mu.Lock()
+	deadline := r.deadline
+	r.deadline = time.Time{}
+	r.mu.Unlock()
+
+	return func() { r.SetDeadline(deadline) }
+}
+
+// dispatch reads records and distributes them to queues based on their key.
+func (r *Reader) dispatch(ctx context.Context, queues []chan Record, key func(*Record) uint64) error {
+	for {
+		var rec Record
+		err := r.readInto(ctx, &rec)
+		if errors.Is(err, ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		queue := queues[0]
+		if key != nil {
+			queue = queues[key(&rec)%uint64(len(queues))]
+		}
+
+		select {
+		case queue <- rec:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
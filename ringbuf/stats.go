@@ -0,0 +1,82 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+)
+
+// Stats describes the fill level of a ring.
+type Stats struct {
+	// The size of the ring in bytes.
+	Size int
+	// The positions of the consumer and producer in bytes, which only ever
+	// increase. The difference is the amount of data waiting to be read.
+	ConsumerPos, ProducerPos uint64
+	// The number of bytes waiting to be read, including record headers.
+	AvailableData int
+	// The number of times BPF programs failed to reserve space, as read from
+	// ReaderOptions.DropCounter. Zero if no counter was provided.
+	ReserveFailures uint64
+}
+
+// Stats returns the current fill level of the ring.
+//
+// Unlike other methods, Stats doesn't wait for a concurrent Read.
+func (r *Reader) Stats() (Stats, error) {
+	r.ringMu.Lock()
+	defer r.ringMu.Unlock()
+
+	if r.ring == nil {
+		return Stats{}, fmt.Errorf("ringbuffer: %w", ErrClosed)
+	}
+
+	cons := atomic.LoadUint64(r.ring.cons_pos)
+	prod := atomic.LoadUint64(r.ring.prod_pos)
+	stats := Stats{
+		Size:          int(r.ring.mask + 1),
+		ConsumerPos:   cons,
+		ProducerPos:   prod,
+		AvailableData: int(prod - cons),
+	}
+
+	if r.dropCounter != nil {
+		failures, err := readDropCounter(r.dropCounter)
+		if err != nil {
+			return Stats{}, fmt.Errorf("read drop counter: %w", err)
+		}
+		stats.ReserveFailures = failures
+	}
+
+	return stats, nil
+}
+
+func checkDropCounter(m *ebpf.Map) error {
+	if m.Type() != ebpf.Array && m.Type() != ebpf.PerCPUArray {
+		return fmt.Errorf("drop counter: invalid Map type: %s", m.Type())
+	}
+	if m.KeySize() != 4 || m.ValueSize() != 8 {
+		return errors.New("drop counter: key must be 4 bytes and value 8 bytes")
+	}
+	return nil
+}
+
+func readDropCounter(m *ebpf.Map) (uint64, error) {
+	if m.Type() == ebpf.Array {
+		var value uint64
+		return value, m.Lookup(uint32(0), &value)
+	}
+
+	var values []uint64
+	if err := m.Lookup(uint32(0), &values); err != nil {
+		return 0, err
+	}
+
+	var sum uint64
+	for _, value := range values {
+		sum += value
+	}
+	return sum, nil
+}
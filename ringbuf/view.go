@@ -0,0 +1,159 @@
+package ringbuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cilium/ebpf/internal"
+)
+
+var errPendingViews = errors.New("ringbuffer: records returned by ReadView must be released before calling ReadInto")
+
+// recordView is the space of a record returned by ReadView.
+type recordView struct {
+	reader *Reader
+	// The position following the record.
+	end      uint64
+	released bool
+}
+
+// ReadView is like ReadInto, except that rec.RawSample points directly into
+// the memory shared with the kernel instead of being copied.
+//
+// The space occupied by the record is only handed back to the kernel once
+// rec.Release is called, and records are handed back in the order they were
+// read. Until then BPF programs can't reuse it, so holding on to records
+// for a long time leads to reservations failing. rec.RawSample must not be
+// modified, and must not be accessed after calling Release or Close.
+//
+// Passing a record which wasn't released to ReadView or ReadInto releases
+// it. ReadInto and Read return an error while other records returned by
+// ReadView haven't been released.
+func (r *Reader) ReadView(rec *Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ring == nil {
+		return fmt.Errorf("ringbuffer: %w", ErrClosed)
+	}
+
+	rec.Release()
+
+	for {
+		if !r.haveData {
+			if err := r.wait(context.Background()); err != nil {
+				return err
+			}
+			r.haveData = true
+		}
+
+		for {
+			err := r.readView(rec)
+			if err == errBusy || err == errDiscard {
+				continue
+			}
+			if err == errEOR {
+				r.haveData = false
+				break
+			}
+
+			return err
+		}
+	}
+}
+
+// readView returns a view of the record following the previous view.
+func (r *Reader) readView(rec *Record) error {
+	r.ringMu.Lock()
+	defer r.ringMu.Unlock()
+
+	ring := r.ring.ringReader
+	pos := atomic.LoadUint64(ring.cons_pos)
+	if n := len(r.views); n > 0 {
+		pos = r.views[n-1].end
+	}
+
+	prod := atomic.LoadUint64(ring.prod_pos)
+	if prod-pos < uint64(ringbufHeaderSize) {
+		return errEOR
+	}
+
+	// The data pages are mapped twice in a row, so a record is contiguous
+	// even if it wraps around the end of the ring.
+	start := pos & ring.mask
+	header := ringbufHeader{
+		Len: atomic.LoadUint32((*uint32)(unsafe.Pointer(&ring.ring[start]))),
+	}
+	if header.isBusy() {
+		return errBusy
+	}
+
+	end := pos + uint64(internal.Align(ringbufHeaderSize+header.dataLen(), 8))
+	if header.isDiscard() {
+		r.addView(&recordView{r, end, true})
+		return errDiscard
+	}
+
+	view := &recordView{reader: r, end: end}
+	r.addView(view)
+
+	sample := start + uint64(ringbufHeaderSize)
+	rec.RawSample = ring.ring[sample : sample+uint64(header.dataLen()) : sample+uint64(header.dataLen())]
+	rec.view = view
+	return nil
+}
+
+// addView tracks a view and commits it if it's released.
+//
+// Requires ringMu.
+func (r *Reader) addView(view *recordView) {
+	r.views = append(r.views, view)
+	r.commitViews()
+}
+
+// commitViews hands the space of all released views preceding the first
+// unreleased view back to the kernel.
+//
+// Requires ringMu.
+func (r *Reader) commitViews() {
+	var n int
+	for n < len(r.views) && r.views[n].released {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+
+	atomic.StoreUint64(r.ring.cons_pos, r.views[n-1].end)
+	r.views = r.views[n:]
+}
+
+// pendingViews returns true if views haven't been committed yet.
+func (r *Reader) pendingViews() bool {
+	r.ringMu.Lock()
+	defer r.ringMu.Unlock()
+
+	return len(r.views) > 0
+}
+
+// Release hands the space of a record returned by Reader.ReadView back to
+// the kernel, and sets RawSample to nil. Does nothing for other records.
+func (rec *Record) Release() {
+	view := rec.view
+	if view == nil {
+		return
+	}
+	rec.view, rec.RawSample = nil, nil
+
+	r := view.reader
+	r.ringMu.Lock()
+	defer r.ringMu.Unlock()
+
+	view.released = true
+	if r.ring != nil {
+		r.commitViews()
+	}
+}
@@ -0,0 +1,187 @@
+package ringbuf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// ErrFull is returned by Writer if the ring doesn't have enough space for a
+// record, since BPF programs haven't drained it yet.
+var ErrFull = errors.New("ring buffer is full")
+
+// Writer submits records to a BPF_MAP_TYPE_USER_RINGBUF map, which BPF
+// programs consume via bpf_user_ringbuf_drain.
+type Writer struct {
+	// mu serializes reservations, since the ring only supports a single
+	// producer.
+	mu   sync.Mutex
+	cons []byte
+	prod []byte
+	// These point into mmap'ed memory and must be accessed atomically.
+	consPos, prodPos *uint64
+	mask             uint64
+	// data is mapped twice in a row, so that records which wrap around the
+	// end of the ring are contiguous.
+	data []byte
+}
+
+// NewWriter creates a Writer for a UserRingbuf map.
+func NewWriter(userRingbufMap *ebpf.Map) (*Writer, error) {
+	if userRingbufMap.Type() != ebpf.UserRingbuf {
+		return nil, fmt.Errorf("invalid Map type: %s", userRingbufMap.Type())
+	}
+
+	maxEntries := int(userRingbufMap.MaxEntries())
+	if maxEntries == 0 || (maxEntries&(maxEntries-1)) != 0 {
+		return nil, fmt.Errorf("user ringbuffer map size %d is zero or not a power of two", maxEntries)
+	}
+
+	// The consumer page is only writable by the kernel.
+	cons, err := unix.Mmap(userRingbufMap.FD(), 0, os.Getpagesize(), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("can't mmap consumer page: %w", err)
+	}
+
+	prod, err := unix.Mmap(userRingbufMap.FD(), int64(os.Getpagesize()), os.Getpagesize()+2*maxEntries, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		_ = unix.Munmap(cons)
+		return nil, fmt.Errorf("can't mmap data pages: %w", err)
+	}
+
+	w := &Writer{
+		cons:    cons,
+		prod:    prod,
+		consPos: (*uint64)(unsafe.Pointer(&cons[0])),
+		prodPos: (*uint64)(unsafe.Pointer(&prod[0])),
+		mask:    uint64(maxEntries - 1),
+		data:    prod[os.Getpagesize():],
+	}
+	runtime.SetFinalizer(w, (*Writer).Close)
+	return w, nil
+}
+
+// Close frees resources used by the writer.
+//
+// Reservations must not be used after calling Close.
+func (w *Writer) Close() error {
+	runtime.SetFinalizer(w, nil)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.data == nil {
+		return nil
+	}
+
+	_ = unix.Munmap(w.prod)
+	_ = unix.Munmap(w.cons)
+	w.prod, w.cons, w.data = nil, nil, nil
+	return nil
+}
+
+// Reservation is space for a record reserved in the ring. It must be
+// passed to Submit or Discard, otherwise BPF programs can't consume any
+// following records.
+type Reservation struct {
+	// Sample is the space reserved for the record. It must not be used
+	// after calling Submit or Discard.
+	Sample []byte
+	hdr    *uint32
+}
+
+// Submit makes the record available to BPF programs.
+func (r *Reservation) Submit() {
+	r.commit(0)
+}
+
+// Discard releases the space of the record without passing it to BPF
+// programs.
+func (r *Reservation) Discard() {
+	r.commit(unix.BPF_RINGBUF_DISCARD_BIT)
+}
+
+func (r *Reservation) commit(flags uint32) {
+	if r.hdr == nil {
+		return
+	}
+
+	length := atomic.LoadUint32(r.hdr) &^ unix.BPF_RINGBUF_BUSY_BIT
+	atomic.StoreUint32(r.hdr, length|flags)
+	r.hdr, r.Sample = nil, nil
+}
+
+// Reserve reserves space for a record of size bytes.
+//
+// Returns ErrFull if BPF programs haven't consumed enough records to make
+// space.
+func (w *Writer) Reserve(size int) (*Reservation, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.data == nil {
+		return nil, fmt.Errorf("ringbuffer: %w", ErrClosed)
+	}
+
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid record size %d", size)
+	}
+
+	total := uint64(internal.Align(size+ringbufHeaderSize, 8))
+	if total > w.mask+1 {
+		return nil, fmt.Errorf("record of %d bytes exceeds the size of the ring", size)
+	}
+
+	cons := atomic.LoadUint64(w.consPos)
+	prod := atomic.LoadUint64(w.prodPos)
+	if avail := w.mask + 1 - (prod - cons); avail < total {
+		return nil, fmt.Errorf("reserve %d bytes: %w", size, ErrFull)
+	}
+
+	off := prod & w.mask
+	hdr := (*uint32)(unsafe.Pointer(&w.data[off]))
+	atomic.StoreUint32(hdr, uint32(size)|unix.BPF_RINGBUF_BUSY_BIT)
+	internal.NativeEndian.PutUint32(w.data[off+4:], 0)
+	atomic.StoreUint64(w.prodPos, prod+total)
+
+	start := off + uint64(ringbufHeaderSize)
+	return &Reservation{
+		Sample: w.data[start : start+uint64(size) : start+uint64(size)],
+		hdr:    hdr,
+	}, nil
+}
+
+// Write submits a copy of sample as a record.
+func (w *Writer) Write(sample []byte) error {
+	r, err := w.Reserve(len(sample))
+	if err != nil {
+		return err
+	}
+
+	copy(r.Sample, sample)
+	r.Submit()
+	return nil
+}
+
+// AvailableBytes returns the number of bytes which can currently be
+// reserved, including the headers of records.
+func (w *Writer) AvailableBytes() (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.data == nil {
+		return 0, fmt.Errorf("ringbuffer: %w", ErrClosed)
+	}
+
+	cons := atomic.LoadUint64(w.consPos)
+	prod := atomic.LoadUint64(w.prodPos)
+	return int(w.mask + 1 - (prod - cons)), nil
+}
@@ -0,0 +1,153 @@
+package ringbuf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+)
+
+func TestWriter(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "6.1", "BPF user ring buffer")
+
+	userRingbuf, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.UserRingbuf,
+		MaxEntries: 4096,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer userRingbuf.Close()
+
+	// Counts the drained records and sums their first bytes.
+	prog := mustDrainProg(t, userRingbuf)
+
+	w, err := NewWriter(userRingbuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := w.Reserve(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Sample[0] = 4
+	r.Submit()
+
+	r, err = w.Reserve(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Sample[0] = 100
+	r.Discard()
+
+	ret, _, err := prog.Test(internal.EmptyBPFContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret != 2 {
+		t.Fatalf("Expected 2 records to be drained, got %d", ret)
+	}
+
+	avail, err := w.AvailableBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avail != 4096 {
+		t.Fatalf("Expected the ring to be empty after draining, %d bytes available", avail)
+	}
+
+	// Fill the ring.
+	for {
+		err := w.Write(make([]byte, 1000))
+		if errors.Is(err, ErrFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := w.Reserve(4096); err == nil || errors.Is(err, ErrFull) {
+		t.Fatal("Expected an error for a record larger than the ring, got", err)
+	}
+
+	w.Close()
+	if _, err := w.Reserve(8); !errors.Is(err, ErrClosed) {
+		t.Fatal("Expected ErrClosed, got", err)
+	}
+}
+
+func TestNewWriterInvalidMap(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF ring buffer")
+
+	ringbuf, err := ebpf.NewMap(&ebpf.MapSpec{
+		Type:       ebpf.RingBuf,
+		MaxEntries: 4096,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ringbuf.Close()
+
+	if _, err := NewWriter(ringbuf); err == nil {
+		t.Fatal("NewWriter accepts a RingBuf map")
+	}
+}
+
+func mustDrainProg(tb testing.TB, userRingbuf *ebpf.Map) *ebpf.Program {
+	tb.Helper()
+
+	voidPtr := &btf.Pointer{Target: &btf.Void{}}
+	long := &btf.Int{Name: "long", Size: 8, Encoding: btf.Signed}
+	main := &btf.Func{
+		Name:    "drain",
+		Type:    &btf.FuncProto{Return: long, Params: []btf.FuncParam{{Name: "ctx", Type: voidPtr}}},
+		Linkage: btf.GlobalFunc,
+	}
+	callback := &btf.Func{
+		Name: "callback",
+		Type: &btf.FuncProto{Return: long, Params: []btf.FuncParam{
+			{Name: "dynptr", Type: voidPtr},
+			{Name: "ctx", Type: voidPtr},
+		}},
+		Linkage: btf.StaticFunc,
+	}
+
+	insns := asm.Instructions{
+		btf.WithFuncMetadata(asm.LoadMapPtr(asm.R1, userRingbuf.FD()), main).WithSymbol("drain"),
+		asm.Instruction{
+			OpCode:   asm.LoadImmOp(asm.DWord),
+			Dst:      asm.R2,
+			Src:      asm.PseudoFunc,
+			Constant: -1,
+		}.WithReference("callback"),
+		asm.Mov.Imm(asm.R3, 0),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnUserRingbufDrain.Call(),
+		asm.Return(),
+
+		btf.WithFuncMetadata(asm.Mov.Imm(asm.R0, 0), callback).WithSymbol("callback"),
+		asm.Return(),
+	}
+
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:         ebpf.SocketFilter,
+		License:      "MIT",
+		Instructions: insns,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { prog.Close() })
+	return prog
+}
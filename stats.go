@@ -0,0 +1,119 @@
+package ebpf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// StatsRunTime is passed to EnableStats to collect the runtime and run count
+// of programs.
+const StatsRunTime = unix.BPF_STATS_RUN_TIME
+
+// ProgramStats are the runtime statistics of a program.
+//
+// Runtime and RunCount only increase while the collection of statistics is
+// enabled, see EnableStats.
+type ProgramStats struct {
+	// Total accumulated runtime of the program, summed over all CPUs.
+	// Available from 5.1.
+	Runtime time.Duration
+	// Total number of times the program was called. Available from 5.1.
+	RunCount uint64
+	// Total number of times the program was not called because it was
+	// already running on the same CPU. Available from 5.12.
+	RecursionMisses uint64
+}
+
+// Stats returns the runtime statistics of the program.
+//
+// This is cheaper than Info, since it doesn't retrieve instructions or maps.
+func (p *Program) Stats() (*ProgramStats, error) {
+	var info sys.ProgInfo
+	if err := sys.ObjInfo(p.fd, &info); err != nil {
+		return nil, fmt.Errorf("get program stats: %w", err)
+	}
+
+	return &ProgramStats{
+		Runtime:         time.Duration(info.RunTimeNs),
+		RunCount:        info.RunCnt,
+		RecursionMisses: info.RecursionMisses,
+	}, nil
+}
+
+// StatsRate is the change of a program's statistics between two samples.
+type StatsRate struct {
+	// The time between the two samples.
+	Interval time.Duration
+	// The change of each statistic during Interval.
+	ProgramStats
+}
+
+// RunsPerSecond returns how often the program was called per second.
+func (sr *StatsRate) RunsPerSecond() float64 {
+	if sr.Interval <= 0 {
+		return 0
+	}
+	return float64(sr.RunCount) / sr.Interval.Seconds()
+}
+
+// AverageRuntime returns the average runtime of a single call.
+func (sr *StatsRate) AverageRuntime() time.Duration {
+	if sr.RunCount == 0 {
+		return 0
+	}
+	return sr.Runtime / time.Duration(sr.RunCount)
+}
+
+// Load returns the fraction of Interval spent running the program.
+//
+// The result exceeds 1 if the program runs on multiple CPUs concurrently.
+func (sr *StatsRate) Load() float64 {
+	if sr.Interval <= 0 {
+		return 0
+	}
+	return float64(sr.Runtime) / float64(sr.Interval)
+}
+
+// StatsSampler computes how fast the statistics of a program change.
+//
+// The collection of statistics must be enabled, see EnableStats.
+type StatsSampler struct {
+	prog *Program
+	last ProgramStats
+	at   time.Time
+}
+
+// NewStatsSampler takes an initial sample of the statistics of prog.
+//
+// The sampler doesn't take ownership of prog.
+func NewStatsSampler(prog *Program) (*StatsSampler, error) {
+	ss := &StatsSampler{prog: prog}
+	if _, err := ss.Sample(); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+// Sample returns the change of the statistics since the previous sample.
+func (ss *StatsSampler) Sample() (*StatsRate, error) {
+	stats, err := ss.prog.Stats()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	rate := &StatsRate{
+		now.Sub(ss.at),
+		ProgramStats{
+			Runtime:         stats.Runtime - ss.last.Runtime,
+			RunCount:        stats.RunCount - ss.last.RunCount,
+			RecursionMisses: stats.RecursionMisses - ss.last.RecursionMisses,
+		},
+	}
+
+	ss.last, ss.at = *stats, now
+	return rate, nil
+}
@@ -0,0 +1,67 @@
+package ebpf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestProgramStats(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.8", "BPF_ENABLE_STATS")
+
+	prog := mustSocketFilter(t)
+
+	stats, err := prog.Stats()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, *stats, qt.Equals, ProgramStats{})
+
+	sampler, err := NewStatsSampler(prog)
+	qt.Assert(t, err, qt.IsNil)
+
+	enabled, err := EnableStats(StatsRunTime)
+	qt.Assert(t, err, qt.IsNil)
+	defer enabled.Close()
+
+	_, _, err = prog.Test(internal.EmptyBPFContext)
+	qt.Assert(t, err, qt.IsNil)
+
+	stats, err = prog.Stats()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, stats.RunCount >= 1, qt.IsTrue)
+	qt.Assert(t, stats.Runtime > 0, qt.IsTrue)
+
+	rate, err := sampler.Sample()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rate.Interval > 0, qt.IsTrue)
+	qt.Assert(t, rate.RunCount, qt.Equals, stats.RunCount)
+	qt.Assert(t, rate.Runtime, qt.Equals, stats.Runtime)
+	qt.Assert(t, rate.RunsPerSecond() > 0, qt.IsTrue)
+	qt.Assert(t, rate.AverageRuntime() > 0, qt.IsTrue)
+
+	// Nothing happened since the last sample.
+	rate, err = sampler.Sample()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, rate.RunCount, qt.Equals, uint64(0))
+	qt.Assert(t, rate.AverageRuntime(), qt.Equals, time.Duration(0))
+}
+
+func TestStatsRate(t *testing.T) {
+	rate := StatsRate{
+		time.Second,
+		ProgramStats{
+			Runtime:  500 * time.Millisecond,
+			RunCount: 100,
+		},
+	}
+
+	qt.Assert(t, rate.RunsPerSecond(), qt.Equals, 100.0)
+	qt.Assert(t, rate.AverageRuntime(), qt.Equals, 5*time.Millisecond)
+	qt.Assert(t, rate.Load(), qt.Equals, 0.5)
+
+	qt.Assert(t, (&StatsRate{}).RunsPerSecond(), qt.Equals, 0.0)
+	qt.Assert(t, (&StatsRate{}).Load(), qt.Equals, 0.0)
+}
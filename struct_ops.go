@@ -0,0 +1,233 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/linux"
+)
+
+// StructOpsValue is the initial value of a StructOpsMap, for example a
+// struct tcp_congestion_ops.
+//
+// It is emitted for each variable in the .struct_ops and .struct_ops.link
+// ELF sections. Loading a CollectionSpec loads the referenced programs and
+// writes the value to the map, which registers it with the kernel.
+type StructOpsValue struct {
+	// Data contains the struct as laid out by MapSpec.Value. Function
+	// pointers are zero.
+	Data []byte
+	// Programs maps the names of function pointer members to the name of the
+	// program implementing them.
+	Programs map[string]string
+}
+
+// structOpsKernelValue describes how the kernel stores the value of a
+// struct_ops map.
+type structOpsKernelValue struct {
+	// The bpf_struct_ops_<name> wrapper and its ID in vmlinux BTF.
+	value   *btf.Struct
+	valueID btf.TypeID
+	// The struct implemented by the map, e.g. tcp_congestion_ops.
+	data *btf.Struct
+	// Offset of data in value, in bytes.
+	dataOffset uint32
+}
+
+// findStructOpsKernelValue finds the kernel representation of typ, which
+// must be a struct.
+//
+// Only struct_ops defined in vmlinux are supported, since map creation
+// doesn't allow specifying a module.
+func findStructOpsKernelValue(typ btf.Type) (*structOpsKernelValue, error) {
+	user, ok := typ.(*btf.Struct)
+	if !ok {
+		return nil, fmt.Errorf("struct_ops value must be a struct, got %T", typ)
+	}
+
+	spec, err := linux.TypesNoCopy()
+	if err != nil {
+		return nil, fmt.Errorf("load kernel spec: %w", err)
+	}
+
+	var value *btf.Struct
+	err = spec.TypeByName("bpf_struct_ops_"+user.Name, &value)
+	if errors.Is(err, btf.ErrNotFound) {
+		return nil, &internal.UnsupportedFeatureError{Name: "struct_ops " + user.Name}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("struct_ops %s: %w", user.Name, err)
+	}
+
+	id, err := spec.TypeID(value)
+	if err != nil {
+		return nil, fmt.Errorf("struct_ops %s: %w", user.Name, err)
+	}
+
+	for _, m := range value.Members {
+		data, ok := m.Type.(*btf.Struct)
+		if m.Name != "data" || !ok {
+			continue
+		}
+
+		return &structOpsKernelValue{value, id, data, m.Offset.Bytes()}, nil
+	}
+
+	return nil, fmt.Errorf("struct_ops %s: %s has no data member", user.Name, value.Name)
+}
+
+// marshal converts value into the layout expected by the kernel.
+//
+// Members are matched by name. Members which the kernel doesn't know about
+// are ignored if they are zero. Function pointers are replaced with the
+// file descriptors in fds, indexed by program name.
+func (kv *structOpsKernelValue) marshal(user *btf.Struct, value *StructOpsValue, fds map[string]int) ([]byte, error) {
+	if uint32(len(value.Data)) != user.Size {
+		return nil, fmt.Errorf("data is %d bytes, expected %d", len(value.Data), user.Size)
+	}
+
+	kernelMembers := make(map[string]btf.Member, len(kv.data.Members))
+	for _, m := range kv.data.Members {
+		kernelMembers[m.Name] = m
+	}
+
+	buf := make([]byte, kv.value.Size)
+	for i, m := range user.Members {
+		if m.BitfieldSize > 0 {
+			return nil, fmt.Errorf("member %s: bitfields are not supported", m.Name)
+		}
+
+		size, err := btf.Sizeof(m.Type)
+		if err != nil {
+			return nil, fmt.Errorf("member %s: %w", m.Name, err)
+		}
+
+		// Use the next member to include trailing padding in the check for
+		// non-zero data.
+		off, end := m.Offset.Bytes(), m.Offset.Bytes()+uint32(size)
+		if i+1 < len(user.Members) {
+			end = user.Members[i+1].Offset.Bytes()
+		}
+		data := value.Data[off : off+uint32(size)]
+
+		km, ok := kernelMembers[m.Name]
+		if !ok {
+			if _, ok := value.Programs[m.Name]; ok || !isZero(value.Data[off:end]) {
+				return nil, fmt.Errorf("member %s: not present in kernel type %s", m.Name, kv.data.Name)
+			}
+			continue
+		}
+		koff := kv.dataOffset + km.Offset.Bytes()
+
+		if _, ok := btf.UnderlyingType(km.Type).(*btf.Pointer); ok {
+			if !isZero(data) {
+				return nil, fmt.Errorf("member %s: pointers must be zero", m.Name)
+			}
+
+			prog, ok := value.Programs[m.Name]
+			if !ok {
+				continue
+			}
+
+			fd, ok := fds[prog]
+			if !ok {
+				return nil, fmt.Errorf("member %s: missing program %s", m.Name, prog)
+			}
+
+			// The kernel reads the fd from the pointer-sized member.
+			internal.NativeEndian.PutUint64(buf[koff:], uint64(fd))
+			continue
+		}
+
+		if _, ok := value.Programs[m.Name]; ok {
+			return nil, fmt.Errorf("member %s: program assigned to non-pointer member", m.Name)
+		}
+
+		ksize, err := btf.Sizeof(km.Type)
+		if err != nil {
+			return nil, fmt.Errorf("member %s: %w", m.Name, err)
+		}
+
+		if ksize != size {
+			return nil, fmt.Errorf("member %s: size %d doesn't match kernel size %d", m.Name, size, ksize)
+		}
+
+		copy(buf[koff:], data)
+	}
+
+	return buf, nil
+}
+
+// marshalStructOpsValue converts value into the layout expected by the
+// kernel, using the file descriptors of progs for function pointers.
+func marshalStructOpsValue(typ btf.Type, value *StructOpsValue, progs map[string]*Program) ([]byte, error) {
+	kv, err := findStructOpsKernelValue(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := make(map[string]int, len(progs))
+	for name, prog := range progs {
+		fds[name] = prog.FD()
+	}
+
+	return kv.marshal(typ.(*btf.Struct), value, fds)
+}
+
+// findStructOpsMember finds the struct and member index a StructOps program
+// attaches to. target is of the form "struct:member".
+func findStructOpsMember(target string) (btf.TypeID, uint32, error) {
+	structName, memberName, ok := strings.Cut(target, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("target %q: expected struct:member", target)
+	}
+
+	spec, err := linux.TypesNoCopy()
+	if err != nil {
+		return 0, 0, fmt.Errorf("load kernel spec: %w", err)
+	}
+
+	var s *btf.Struct
+	err = spec.TypeByName(structName, &s)
+	if errors.Is(err, btf.ErrNotFound) {
+		return 0, 0, &internal.UnsupportedFeatureError{Name: "struct_ops " + structName}
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("find struct %s: %w", structName, err)
+	}
+
+	id, err := spec.TypeID(s)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i, m := range s.Members {
+		if m.Name != memberName {
+			continue
+		}
+
+		ptr, ok := btf.UnderlyingType(m.Type).(*btf.Pointer)
+		if !ok {
+			return 0, 0, fmt.Errorf("%s is not a function pointer", target)
+		}
+		if _, ok := btf.UnderlyingType(ptr.Target).(*btf.FuncProto); !ok {
+			return 0, 0, fmt.Errorf("%s is not a function pointer", target)
+		}
+
+		return id, uint32(i), nil
+	}
+
+	return 0, 0, fmt.Errorf("struct %s has no member %s: %w", structName, memberName, btf.ErrNotFound)
+}
+
+func isZero(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
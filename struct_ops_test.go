@@ -0,0 +1,228 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// newTCPCongestionOps returns a subset of struct tcp_congestion_ops and an
+// initial value for it.
+func newTCPCongestionOps(name string) (*btf.Struct, *StructOpsValue) {
+	fn := &btf.Pointer{Target: &btf.FuncProto{Return: &btf.Int{Size: 4}}}
+	ops := &btf.Struct{
+		Name: "tcp_congestion_ops",
+		Size: 40,
+		Members: []btf.Member{
+			{Name: "ssthresh", Type: fn, Offset: 0},
+			{Name: "cong_avoid", Type: fn, Offset: 64},
+			{Name: "undo_cwnd", Type: fn, Offset: 128},
+			{Name: "name", Type: &btf.Array{
+				Index:  &btf.Int{Size: 4},
+				Type:   &btf.Int{Name: "char", Size: 1, Encoding: btf.Char},
+				Nelems: 16,
+			}, Offset: 192},
+		},
+	}
+
+	value := &StructOpsValue{
+		Data: make([]byte, ops.Size),
+		Programs: map[string]string{
+			"ssthresh":   "ssthresh",
+			"cong_avoid": "cong_avoid",
+			"undo_cwnd":  "undo_cwnd",
+		},
+	}
+	copy(value.Data[24:], name)
+
+	return ops, value
+}
+
+func TestStructOpsMarshal(t *testing.T) {
+	fn := &btf.Pointer{Target: &btf.FuncProto{}}
+	u32 := &btf.Int{Size: 4}
+	kernelOps := &btf.Struct{
+		Name: "ops",
+		Size: 24,
+		Members: []btf.Member{
+			{Name: "flags", Type: u32, Offset: 0},
+			{Name: "init", Type: fn, Offset: 64},
+			{Name: "release", Type: fn, Offset: 128},
+		},
+	}
+	kv := &structOpsKernelValue{
+		value: &btf.Struct{Name: "bpf_struct_ops_ops", Size: 32 + kernelOps.Size},
+		data:  kernelOps,
+		// Leave space for struct bpf_struct_ops_common_value.
+		dataOffset: 32,
+	}
+
+	// The user space definition has a different layout and a member unknown
+	// to the kernel.
+	userOps := &btf.Struct{
+		Name: "ops",
+		Size: 16,
+		Members: []btf.Member{
+			{Name: "init", Type: fn, Offset: 0},
+			{Name: "flags", Type: u32, Offset: 64},
+			{Name: "unknown", Type: u32, Offset: 96},
+		},
+	}
+	value := &StructOpsValue{
+		Data:     make([]byte, userOps.Size),
+		Programs: map[string]string{"init": "init_prog"},
+	}
+	internal.NativeEndian.PutUint32(value.Data[8:], 0xcafe)
+
+	buf, err := kv.marshal(userOps, value, map[string]int{"init_prog": 42})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, buf, qt.HasLen, 56)
+	qt.Assert(t, internal.NativeEndian.Uint32(buf[32:]), qt.Equals, uint32(0xcafe))
+	qt.Assert(t, internal.NativeEndian.Uint64(buf[40:]), qt.Equals, uint64(42))
+	qt.Assert(t, internal.NativeEndian.Uint64(buf[48:]), qt.Equals, uint64(0))
+
+	// Members unknown to the kernel must be zero.
+	internal.NativeEndian.PutUint32(value.Data[12:], 1)
+	_, err = kv.marshal(userOps, value, map[string]int{"init_prog": 42})
+	qt.Assert(t, err, qt.IsNotNil)
+	internal.NativeEndian.PutUint32(value.Data[12:], 0)
+
+	// Programs must be present.
+	_, err = kv.marshal(userOps, value, nil)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	// Programs can't be assigned to non-pointers.
+	value.Programs["flags"] = "init_prog"
+	_, err = kv.marshal(userOps, value, map[string]int{"init_prog": 42})
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestStructOpsMemberAt(t *testing.T) {
+	ops, _ := newTCPCongestionOps("")
+
+	m, err := structOpsMemberAt(ops, 8)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, m.Name, qt.Equals, "cong_avoid")
+
+	_, err = structOpsMemberAt(ops, 4)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	_, err = structOpsMemberAt(ops, 24)
+	qt.Assert(t, err, qt.IsNotNil)
+}
+
+func TestAssignStructOpsPrograms(t *testing.T) {
+	ops, value := newTCPCongestionOps("")
+	maps := map[string]*MapSpec{
+		"cc": {
+			Type:     StructOpsMap,
+			Value:    ops,
+			Contents: []MapKV{{uint32(0), value}},
+		},
+	}
+	progs := map[string]*ProgramSpec{
+		"ssthresh":   {Type: StructOps},
+		"cong_avoid": {Type: StructOps},
+		"undo_cwnd":  {Type: StructOps},
+	}
+
+	qt.Assert(t, assignStructOpsPrograms(maps, progs), qt.IsNil)
+	qt.Assert(t, progs["cong_avoid"].AttachTo, qt.Equals, "tcp_congestion_ops:cong_avoid")
+
+	// A program can only implement a single member.
+	value.Programs["undo_cwnd"] = "ssthresh"
+	qt.Assert(t, assignStructOpsPrograms(maps, progs), qt.IsNotNil)
+
+	delete(progs, "ssthresh")
+	qt.Assert(t, assignStructOpsPrograms(maps, progs), qt.IsNotNil)
+}
+
+// skipIfNoTCPCongestionOps skips the test if the kernel doesn't allow
+// implementing tcp_congestion_ops. Other errors fail the test.
+func skipIfNoTCPCongestionOps(t *testing.T) {
+	t.Helper()
+
+	kv, err := findStructOpsKernelValue(&btf.Struct{Name: "tcp_congestion_ops"})
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+
+	fd, err := sys.MapCreate(&sys.MapCreateAttr{
+		MapType:               sys.MapType(StructOpsMap),
+		KeySize:               4,
+		ValueSize:             kv.value.Size,
+		MaxEntries:            1,
+		BtfVmlinuxValueTypeId: kv.valueID,
+	})
+	if errors.Is(err, sys.ENOTSUPP) || errors.Is(err, unix.EOPNOTSUPP) {
+		t.Skip("struct_ops tcp_congestion_ops not supported:", err)
+	}
+	qt.Assert(t, err, qt.IsNil)
+	fd.Close()
+}
+
+func TestStructOpsTCPCongestionControl(t *testing.T) {
+	testutils.SkipOnOldKernel(t, "5.6", "struct_ops")
+	skipIfNoTCPCongestionOps(t)
+
+	for _, link := range []bool{false, true} {
+		name := "ebpf_go_cc"
+		var flags uint32
+		if link {
+			name = "ebpf_go_cc_link"
+			flags = uint32(sys.BPF_F_LINK)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			ops, value := newTCPCongestionOps(name)
+			prog := func(ret int32) *ProgramSpec {
+				return &ProgramSpec{
+					Type: StructOps,
+					Instructions: asm.Instructions{
+						asm.Mov.Imm(asm.R0, ret),
+						asm.Return(),
+					},
+					License: "GPL",
+				}
+			}
+
+			spec := &CollectionSpec{
+				Maps: map[string]*MapSpec{
+					"cc": {
+						Type:     StructOpsMap,
+						Flags:    flags,
+						Value:    ops,
+						Contents: []MapKV{{uint32(0), value}},
+					},
+				},
+				Programs: map[string]*ProgramSpec{
+					"ssthresh":   prog(2),
+					"cong_avoid": prog(0),
+					"undo_cwnd":  prog(2),
+				},
+			}
+			qt.Assert(t, assignStructOpsPrograms(spec.Maps, spec.Programs), qt.IsNil)
+
+			coll, err := NewCollection(spec)
+			testutils.SkipIfNotSupported(t, err)
+			qt.Assert(t, err, qt.IsNil)
+			defer coll.Close()
+
+			cc := coll.Maps["cc"]
+			qt.Assert(t, cc.Type(), qt.Equals, StructOpsMap)
+			qt.Assert(t, cc.Flags(), qt.Equals, flags)
+
+			if !link {
+				// Deleting the value unregisters the struct.
+				qt.Assert(t, cc.Delete(uint32(0)), qt.IsNil)
+			}
+		})
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"runtime"
 
 	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/linux"
 	"github.com/cilium/ebpf/internal/sys"
 	"github.com/cilium/ebpf/internal/tracefs"
 	"github.com/cilium/ebpf/internal/unix"
@@ -21,6 +24,8 @@ var (
 	sysErrKeyNotExist  = sys.Error(ErrKeyNotExist, unix.ENOENT)
 	sysErrKeyExist     = sys.Error(ErrKeyExist, unix.EEXIST)
 	sysErrNotSupported = sys.Error(ErrNotSupported, sys.ENOTSUPP)
+	sysErrEmpty        = sys.Error(ErrEmpty, unix.ENOENT)
+	sysErrFull         = sys.Error(ErrFull, unix.E2BIG)
 )
 
 // invalidBPFObjNameChar returns true if char may not appear in
@@ -142,6 +147,32 @@ var haveNoPreallocMaps = internal.NewFeatureTest("prealloc maps", "4.6", func()
 	return nil
 })
 
+var haveArenaMaps = internal.NewFeatureTest("arena maps", "6.9", func() error {
+	m, err := sys.MapCreate(&sys.MapCreateAttr{
+		MapType:    sys.MapType(Arena),
+		MaxEntries: 1,
+		MapFlags:   unix.BPF_F_MMAPABLE,
+	})
+	if err != nil {
+		return internal.ErrNotSupported
+	}
+	_ = m.Close()
+	return nil
+})
+
+var haveBloomFilterMaps = internal.NewFeatureTest("bloom filter maps", "5.16", func() error {
+	m, err := sys.MapCreate(&sys.MapCreateAttr{
+		MapType:    sys.MapType(BloomFilter),
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		return internal.ErrNotSupported
+	}
+	_ = m.Close()
+	return nil
+})
+
 func wrapMapError(err error) error {
 	if err == nil {
 		return nil
@@ -275,6 +306,22 @@ var haveBPFToBPFCalls = internal.NewFeatureTest("bpf2bpf calls", "4.16", func()
 	return nil
 })
 
+// haveBPFExceptions checks whether the kernel exports the bpf_throw kfunc,
+// which was added together with support for exception callbacks.
+var haveBPFExceptions = internal.NewFeatureTest("BPF exceptions", "6.7", func() error {
+	spec, err := linux.TypesNoCopy()
+	if err != nil {
+		return err
+	}
+
+	var fn *btf.Func
+	err = spec.TypeByName("bpf_throw", &fn)
+	if errors.Is(err, btf.ErrNotFound) {
+		return internal.ErrNotSupported
+	}
+	return err
+})
+
 var haveSyscallWrapper = internal.NewFeatureTest("syscall wrapper", "4.17", func() error {
 	prefix := internal.PlatformPrefix()
 	if prefix == "" {
@@ -303,3 +350,15 @@ var haveSyscallWrapper = internal.NewFeatureTest("syscall wrapper", "4.17", func
 
 	return evt.Close()
 })
+
+var haveBPFToken = internal.NewFeatureTest("BPF token", "6.9", func() error {
+	// Passing an invalid bpffs fd fails with EBADF if the command is known.
+	_, err := sys.TokenCreate(&sys.TokenCreateAttr{BpffsFd: math.MaxUint32})
+	if errors.Is(err, unix.EBADF) {
+		return nil
+	}
+	if errors.Is(err, unix.EINVAL) {
+		return internal.ErrNotSupported
+	}
+	return err
+})
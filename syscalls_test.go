@@ -47,6 +47,10 @@ func TestHaveMmapableMaps(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveMmapableMaps)
 }
 
+func TestHaveBloomFilterMaps(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBloomFilterMaps)
+}
+
 func TestHaveInnerMaps(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveInnerMaps)
 }
@@ -62,3 +66,7 @@ func TestHaveBPFToBPFCalls(t *testing.T) {
 func TestHaveSyscallWrapper(t *testing.T) {
 	testutils.CheckFeatureTest(t, haveSyscallWrapper)
 }
+
+func TestHaveBPFExceptions(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBPFExceptions)
+}
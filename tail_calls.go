@@ -0,0 +1,220 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+// TailCallArray populates a ProgramArray map by slot name instead of by
+// numeric index.
+//
+// All programs in the array must have the same type, and the same expected
+// attach type if they were loaded from a ProgramSpec. The first program added
+// determines the type of the array.
+//
+// A TailCallArray doesn't own the map or the programs added to it. It is safe
+// for concurrent use, but modifying the map by other means isn't reflected.
+type TailCallArray struct {
+	m     *Map
+	slots map[string]uint32
+
+	mu    sync.Mutex
+	progs map[uint32]*Program
+	// The program type and expected attach type of the array, valid once
+	// typed is true.
+	typed      bool
+	typ        ProgramType
+	attachType AttachType
+}
+
+// NewTailCallArray creates a TailCallArray for m, which must be a
+// ProgramArray. slots maps names to indices into the array.
+func NewTailCallArray(m *Map, slots map[string]uint32) (*TailCallArray, error) {
+	if m.Type() != ProgramArray {
+		return nil, fmt.Errorf("map %s: expected %s, got %s", m, ProgramArray, m.Type())
+	}
+
+	cpy := make(map[string]uint32, len(slots))
+	for name, slot := range slots {
+		cpy[name] = slot
+	}
+
+	return &TailCallArray{
+		m:     m,
+		slots: cpy,
+		progs: make(map[uint32]*Program),
+	}, nil
+}
+
+// NewTailCallArrayFromEnum creates a TailCallArray for m, using the values of
+// an enum to name the slots of the array.
+//
+// Values which are out of bounds for the array, like a trailing
+// enumerator counting the slots, can't be used with Set.
+func NewTailCallArrayFromEnum(m *Map, slots *btf.Enum) (*TailCallArray, error) {
+	names := make(map[string]uint32, len(slots.Values))
+	for _, v := range slots.Values {
+		if v.Value > math.MaxUint32 {
+			continue
+		}
+		names[v.Name] = uint32(v.Value)
+	}
+
+	return NewTailCallArray(m, names)
+}
+
+// Slot returns the index of a named slot.
+func (tc *TailCallArray) Slot(name string) (uint32, bool) {
+	slot, ok := tc.slots[name]
+	return slot, ok
+}
+
+// Slots returns the names of all slots, sorted by name.
+func (tc *TailCallArray) Slots() []string {
+	return sortedKeys(tc.slots)
+}
+
+// Program returns the program in a named slot, or nil if the slot is empty.
+//
+// Only programs added via the TailCallArray are returned.
+func (tc *TailCallArray) Program(name string) *Program {
+	slot, ok := tc.slots[name]
+	if !ok {
+		return nil
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return tc.progs[slot]
+}
+
+// Set inserts prog into a named slot, atomically replacing any program in
+// the slot.
+func (tc *TailCallArray) Set(name string, prog *Program) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return tc.set(name, prog)
+}
+
+func (tc *TailCallArray) set(name string, prog *Program) error {
+	slot, err := tc.slot(name)
+	if err != nil {
+		return err
+	}
+
+	if err := tc.checkCompatible(prog); err != nil {
+		return fmt.Errorf("slot %s: %w", name, err)
+	}
+
+	if err := tc.m.Update(slot, prog, UpdateAny); err != nil {
+		return fmt.Errorf("slot %s: %w", name, err)
+	}
+
+	tc.progs[slot] = prog
+	if !tc.typed {
+		tc.typed, tc.typ, tc.attachType = true, prog.typ, prog.attachType
+	}
+	return nil
+}
+
+// SetAll inserts multiple programs, indexed by slot name.
+//
+// Stops at the first error, leaving the programs inserted so far in place.
+func (tc *TailCallArray) SetAll(progs map[string]*Program) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for _, name := range sortedKeys(progs) {
+		if err := tc.set(name, progs[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replace inserts new into all slots which currently hold old.
+//
+// Use this to keep the array in sync when a program is reloaded.
+func (tc *TailCallArray) Replace(old, new *Program) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if err := tc.checkCompatible(new); err != nil {
+		return err
+	}
+
+	for slot, prog := range tc.progs {
+		if prog != old {
+			continue
+		}
+
+		if err := tc.m.Update(slot, new, UpdateAny); err != nil {
+			return fmt.Errorf("slot %d: %w", slot, err)
+		}
+		tc.progs[slot] = new
+	}
+
+	return nil
+}
+
+// Delete removes the program from a named slot.
+//
+// Returns an error wrapping ErrKeyNotExist if the slot is empty.
+func (tc *TailCallArray) Delete(name string) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	slot, err := tc.slot(name)
+	if err != nil {
+		return err
+	}
+
+	if err := tc.m.Delete(slot); err != nil {
+		return fmt.Errorf("slot %s: %w", name, err)
+	}
+
+	delete(tc.progs, slot)
+	return nil
+}
+
+func (tc *TailCallArray) slot(name string) (uint32, error) {
+	slot, ok := tc.slots[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown slot %s", name)
+	}
+
+	if slot >= tc.m.MaxEntries() {
+		return 0, fmt.Errorf("slot %s: index %d exceeds max entries %d", name, slot, tc.m.MaxEntries())
+	}
+
+	return slot, nil
+}
+
+// checkCompatible returns an error if prog can't be added to the array.
+func (tc *TailCallArray) checkCompatible(prog *Program) error {
+	if prog == nil {
+		return errors.New("can't insert nil program")
+	}
+
+	if !tc.typed {
+		return nil
+	}
+
+	if prog.typ != tc.typ {
+		return fmt.Errorf("program %s: expected type %s, got %s", prog, tc.typ, prog.typ)
+	}
+
+	// The expected attach type is unknown for programs which weren't loaded
+	// from a spec.
+	if prog.attachType != AttachNone && tc.attachType != AttachNone && prog.attachType != tc.attachType {
+		return fmt.Errorf("program %s: expected attach type %s, got %s", prog, tc.attachType, prog.attachType)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTailCallArray(t *testing.T) {
+	arr, err := NewMap(&MapSpec{
+		Type:       ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 2,
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer arr.Close()
+
+	tc, err := NewTailCallArrayFromEnum(arr, &btf.Enum{
+		Name: "tail_calls",
+		Values: []btf.EnumValue{
+			{Name: "PARSE", Value: 0},
+			{Name: "FILTER", Value: 1},
+			{Name: "TAIL_CALLS_MAX", Value: 2},
+		},
+	})
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tc.Slots(), qt.DeepEquals, []string{"FILTER", "PARSE", "TAIL_CALLS_MAX"})
+
+	slot, ok := tc.Slot("FILTER")
+	qt.Assert(t, ok, qt.IsTrue)
+	qt.Assert(t, slot, qt.Equals, uint32(1))
+
+	parse, filter := mustSocketFilter(t), mustSocketFilter(t)
+	qt.Assert(t, tc.SetAll(map[string]*Program{"PARSE": parse, "FILTER": filter}), qt.IsNil)
+	qt.Assert(t, tc.Program("PARSE"), qt.Equals, parse)
+	qt.Assert(t, tc.Program("FILTER"), qt.Equals, filter)
+
+	assertSlot := func(slot uint32, prog *Program) {
+		t.Helper()
+
+		var got *Program
+		qt.Assert(t, arr.Lookup(slot, &got), qt.IsNil)
+		defer got.Close()
+
+		gotInfo, err := got.Info()
+		qt.Assert(t, err, qt.IsNil)
+		wantInfo, err := prog.Info()
+		qt.Assert(t, err, qt.IsNil)
+
+		gotID, _ := gotInfo.ID()
+		wantID, _ := wantInfo.ID()
+		qt.Assert(t, gotID, qt.Equals, wantID)
+	}
+	assertSlot(0, parse)
+	assertSlot(1, filter)
+
+	// Unknown or out of bounds slots are rejected.
+	qt.Assert(t, tc.Set("MISSING", parse), qt.IsNotNil)
+	qt.Assert(t, tc.Set("TAIL_CALLS_MAX", parse), qt.IsNotNil)
+
+	// Programs of a different type are rejected.
+	xdp, err := NewProgram(&ProgramSpec{
+		Type: XDP,
+		Instructions: asm.Instructions{
+			asm.LoadImm(asm.R0, 2, asm.DWord),
+			asm.Return(),
+		},
+		License: "MIT",
+	})
+	qt.Assert(t, err, qt.IsNil)
+	defer xdp.Close()
+	qt.Assert(t, tc.Set("PARSE", xdp), qt.IsNotNil)
+	qt.Assert(t, tc.Replace(parse, xdp), qt.IsNotNil)
+	qt.Assert(t, tc.Program("PARSE"), qt.Equals, parse)
+
+	// Replacing a program updates all slots which refer to it.
+	qt.Assert(t, tc.Set("FILTER", parse), qt.IsNil)
+	reloaded := mustSocketFilter(t)
+	qt.Assert(t, tc.Replace(parse, reloaded), qt.IsNil)
+	qt.Assert(t, tc.Program("PARSE"), qt.Equals, reloaded)
+	qt.Assert(t, tc.Program("FILTER"), qt.Equals, reloaded)
+	assertSlot(0, reloaded)
+	assertSlot(1, reloaded)
+
+	qt.Assert(t, tc.Delete("FILTER"), qt.IsNil)
+	qt.Assert(t, tc.Program("FILTER"), qt.IsNil)
+	err = tc.Delete("FILTER")
+	qt.Assert(t, errors.Is(err, ErrKeyNotExist), qt.IsTrue, qt.Commentf("got %v", err))
+}
+
+func TestTailCallArrayWrongMap(t *testing.T) {
+	m := createArray(t)
+	defer m.Close()
+
+	_, err := NewTailCallArray(m, nil)
+	qt.Assert(t, err, qt.IsNotNil)
+}
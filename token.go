@@ -0,0 +1,82 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf/internal/sys"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// Token grants a subset of BPF permissions delegated by a bpffs instance.
+//
+// A privileged process mounts bpffs with the delegate_cmds, delegate_maps,
+// delegate_progs and delegate_attachs options inside a user namespace and
+// hands the mount to an unprivileged process, for example a container. The
+// latter then creates a Token from the mount and passes it to
+// MapOptions.Token and ProgramOptions.Token.
+//
+// Requires kernel 6.9.
+type Token struct {
+	fd *sys.FD
+}
+
+// NewToken creates a token from the bpffs mounted at path.
+func NewToken(path string) (*Token, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open bpffs: %w", err)
+	}
+	defer f.Close()
+
+	return NewTokenFromFD(int(f.Fd()))
+}
+
+// NewTokenFromFD creates a token from a file descriptor referring to a bpffs
+// mount, for example one obtained via fsmount(2).
+//
+// The file descriptor remains owned by the caller.
+func NewTokenFromFD(bpffs int) (*Token, error) {
+	if bpffs < 0 {
+		return nil, fmt.Errorf("invalid bpffs fd %d", bpffs)
+	}
+
+	fd, err := sys.TokenCreate(&sys.TokenCreateAttr{
+		BpffsFd: uint32(bpffs),
+	})
+	if err != nil {
+		if haveErr := haveBPFToken(); haveErr != nil {
+			return nil, haveErr
+		}
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			return nil, fmt.Errorf("create token: bpffs doesn't delegate permissions: %w", err)
+		}
+		return nil, fmt.Errorf("create token: %w", err)
+	}
+
+	return &Token{fd}, nil
+}
+
+// FD returns the file descriptor of the token.
+func (t *Token) FD() int {
+	return t.fd.Int()
+}
+
+// Close releases the token.
+//
+// Objects created with the token remain valid.
+func (t *Token) Close() error {
+	if t == nil {
+		return nil
+	}
+	return t.fd.Close()
+}
+
+// tokenFD returns the file descriptor of t, or zero if t is nil.
+func (t *Token) tokenFD() int {
+	if t == nil {
+		return 0
+	}
+	return t.fd.Int()
+}
@@ -0,0 +1,35 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestHaveBPFToken(t *testing.T) {
+	testutils.CheckFeatureTest(t, haveBPFToken)
+}
+
+func TestNewToken(t *testing.T) {
+	if err := haveBPFToken(); err != nil {
+		t.Skip(err)
+	}
+
+	// Tokens can't be created from a bpffs in the initial user namespace.
+	_, err := NewToken("/sys/fs/bpf")
+	qt.Assert(t, errors.Is(err, unix.EOPNOTSUPP), qt.IsTrue, qt.Commentf("%v", err))
+
+	// Only the root of a bpffs mount may be used.
+	_, err = NewToken(testutils.TempBPFFS(t))
+	qt.Assert(t, errors.Is(err, unix.EINVAL), qt.IsTrue, qt.Commentf("%v", err))
+
+	_, err = NewToken("/proc")
+	qt.Assert(t, err, qt.IsNotNil)
+
+	_, err = NewTokenFromFD(-1)
+	qt.Assert(t, err, qt.IsNotNil)
+}
@@ -0,0 +1,135 @@
+package ebpf
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// TypedMap wraps a Map with a fixed key type K and value type V.
+//
+// Values of per-CPU maps must be slices, which hold the value of each
+// possible CPU.
+type TypedMap[K, V any] struct {
+	m *Map
+}
+
+// NewTypedMap wraps m.
+//
+// Returns an error if K or V don't encode to the key or value size of m.
+// Types which implement encoding.BinaryMarshaler or encoding.BinaryUnmarshaler
+// and slices other than the values of per-CPU maps aren't checked.
+//
+// The TypedMap doesn't take ownership of m.
+func NewTypedMap[K, V any](m *Map) (*TypedMap[K, V], error) {
+	if err := checkTypeSize[K](int(m.keySize), false); err != nil {
+		return nil, fmt.Errorf("key: %w", err)
+	}
+	if err := checkTypeSize[V](int(m.valueSize), m.typ.hasPerCPUValue()); err != nil {
+		return nil, fmt.Errorf("value: %w", err)
+	}
+	return &TypedMap[K, V]{m}, nil
+}
+
+// Map returns the underlying Map.
+func (tm *TypedMap[K, V]) Map() *Map {
+	return tm.m
+}
+
+// Lookup retrieves the value of key.
+//
+// Returns an error if the key doesn't exist, see ErrKeyNotExist.
+func (tm *TypedMap[K, V]) Lookup(key K) (V, error) {
+	var value V
+	err := tm.m.Lookup(key, &value)
+	return value, err
+}
+
+// LookupAndDelete retrieves and deletes the value of key.
+//
+// Returns ErrKeyNotExist if the key doesn't exist.
+func (tm *TypedMap[K, V]) LookupAndDelete(key K) (V, error) {
+	var value V
+	err := tm.m.LookupAndDelete(key, &value)
+	return value, err
+}
+
+// Update changes the value of key, see Map.Update.
+func (tm *TypedMap[K, V]) Update(key K, value V, flags MapUpdateFlags) error {
+	return tm.m.Update(key, value, flags)
+}
+
+// Delete removes key.
+//
+// Returns ErrKeyNotExist if the key does not exist.
+func (tm *TypedMap[K, V]) Delete(key K) error {
+	return tm.m.Delete(key)
+}
+
+// Iterate traverses the map, see Map.Iterate.
+func (tm *TypedMap[K, V]) Iterate() *TypedMapIterator[K, V] {
+	return &TypedMapIterator[K, V]{tm.m.Iterate()}
+}
+
+// TypedMapIterator iterates a TypedMap.
+type TypedMapIterator[K, V any] struct {
+	mi *MapIterator
+}
+
+// Next decodes the next key and value, see MapIterator.Next.
+//
+// Returns false if there are no more entries. You must check the result of
+// Err afterwards.
+func (ti *TypedMapIterator[K, V]) Next(keyOut *K, valueOut *V) bool {
+	return ti.mi.Next(keyOut, valueOut)
+}
+
+// Err returns any encountered error, see MapIterator.Err.
+func (ti *TypedMapIterator[K, V]) Err() error {
+	return ti.mi.Err()
+}
+
+// checkTypeSize returns an error if T doesn't encode to size bytes, or to a
+// slice of size byte elements if perCPU is true.
+func checkTypeSize[T any](size int, perCPU bool) error {
+	var zero T
+	typ := reflect.TypeOf(&zero).Elem()
+
+	if perCPU {
+		if typ.Kind() != reflect.Slice {
+			return fmt.Errorf("per-CPU value %s must be a slice", typ)
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Implements(binaryMarshalerType) || reflect.PtrTo(typ).Implements(binaryUnmarshalerType) {
+		return nil
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.String, reflect.Interface:
+		// The length is only known at runtime.
+		return nil
+	}
+	if typ == reflect.TypeOf(Map{}) || typ == reflect.TypeOf(Program{}) {
+		// Encoded as a file descriptor.
+		return nil
+	}
+
+	typSize := binary.Size(reflect.Zero(typ).Interface())
+	if typSize < 0 {
+		return fmt.Errorf("%s has no fixed size", typ)
+	}
+	if typSize != size {
+		return fmt.Errorf("%s is %d bytes instead of %d", typ, typSize, size)
+	}
+	return nil
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
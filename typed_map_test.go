@@ -0,0 +1,117 @@
+package ebpf
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf/internal"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestTypedMap(t *testing.T) {
+	type value struct {
+		A uint32
+		B uint16
+		_ uint16
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	tm, err := NewTypedMap[uint32, value](m)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, tm.Map(), qt.Equals, m)
+
+	qt.Assert(t, tm.Update(1, value{A: 42, B: 7}, UpdateAny), qt.IsNil)
+
+	v, err := tm.Lookup(1)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, v, qt.Equals, value{A: 42, B: 7})
+
+	_, err = tm.Lookup(2)
+	qt.Assert(t, errors.Is(err, ErrKeyNotExist), qt.IsTrue)
+
+	var (
+		key   uint32
+		count int
+	)
+	it := tm.Iterate()
+	for it.Next(&key, &v) {
+		qt.Assert(t, key, qt.Equals, uint32(1))
+		qt.Assert(t, v.A, qt.Equals, uint32(42))
+		count++
+	}
+	qt.Assert(t, it.Err(), qt.IsNil)
+	qt.Assert(t, count, qt.Equals, 1)
+
+	qt.Assert(t, tm.Delete(1), qt.IsNil)
+	qt.Assert(t, errors.Is(tm.Delete(1), ErrKeyNotExist), qt.IsTrue)
+}
+
+func TestTypedMapPerCPU(t *testing.T) {
+	possibleCPUs, err := internal.PossibleCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := NewMap(&MapSpec{
+		Type:       PerCPUArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	_, err = NewTypedMap[uint32, uint32](m)
+	qt.Assert(t, err, qt.IsNotNil)
+
+	tm, err := NewTypedMap[uint32, []uint32](m)
+	qt.Assert(t, err, qt.IsNil)
+
+	values := make([]uint32, possibleCPUs)
+	for i := range values {
+		values[i] = uint32(i) + 1
+	}
+	qt.Assert(t, tm.Update(0, values, UpdateAny), qt.IsNil)
+
+	got, err := tm.Lookup(0)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, values)
+}
+
+func TestTypedMapSizeMismatch(t *testing.T) {
+	m, err := NewMap(&MapSpec{
+		Type:       Hash,
+		KeySize:    4,
+		ValueSize:  8,
+		MaxEntries: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	_, err = NewTypedMap[uint64, uint64](m)
+	qt.Assert(t, err, qt.ErrorMatches, "key: .*")
+
+	_, err = NewTypedMap[uint32, uint32](m)
+	qt.Assert(t, err, qt.ErrorMatches, "value: .*")
+
+	_, err = NewTypedMap[uint32, struct{ P *int }](m)
+	qt.Assert(t, err, qt.ErrorMatches, "value: .* no fixed size")
+
+	_, err = NewTypedMap[[]byte, []byte](m)
+	qt.Assert(t, err, qt.IsNil)
+}
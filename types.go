@@ -95,6 +95,14 @@ const (
 	InodeStorage
 	// TaskStorage - Specialized local storage map for task_struct.
 	TaskStorage
+	// BloomFilter - Space-efficient probabilistic set membership test.
+	BloomFilter
+	// UserRingbuf - Similar to RingBuf, but written to by user space.
+	UserRingbuf
+	// CgroupStorage - Specialized local storage map for cgroups.
+	CgroupStorage
+	// Arena - Sparse shared memory region between a BPF program and user space.
+	Arena
 )
 
 // hasPerCPUValue returns true if the Map stores a value per CPU.
@@ -114,6 +122,17 @@ func (mt MapType) canStoreProgram() bool {
 	return mt == ProgramArray
 }
 
+// canDump returns true if the map type only holds plain data which can be
+// restored on another host, see Map.Dump.
+func (mt MapType) canDump() bool {
+	switch mt {
+	case Hash, Array, PerCPUHash, PerCPUArray, LRUHash, LRUCPUHash, LPMTrie:
+		return true
+	default:
+		return false
+	}
+}
+
 // ProgramType of the eBPF program
 type ProgramType uint32
 
@@ -207,11 +226,25 @@ const (
 	AttachSkReuseportSelectOrMigrate
 	AttachPerfEvent
 	AttachTraceKprobeMulti
+	AttachLSMCgroup
+	AttachStructOps
 )
 
 // AttachFlags of the eBPF program used in BPF_PROG_ATTACH command
 type AttachFlags uint32
 
+// Access flags for MapSpec.Flags.
+const (
+	// User space can only read the map.
+	MapReadOnly = unix.BPF_F_RDONLY
+	// User space can only write the map.
+	MapWriteOnly = unix.BPF_F_WRONLY
+	// BPF programs can only read the map. Requires Linux 5.2.
+	MapReadOnlyProg = unix.BPF_F_RDONLY_PROG
+	// BPF programs can only write the map. Requires Linux 5.2.
+	MapWriteOnlyProg = unix.BPF_F_WRONLY_PROG
+)
+
 // PinType determines whether a map is pinned into a BPFFS.
 type PinType int
 
@@ -259,6 +292,11 @@ func (lpo *LoadPinOptions) Marshal() uint32 {
 type BatchOptions struct {
 	ElemFlags uint64
 	Flags     uint64
+
+	// The number of elements processed per system call by BatchLookupAll,
+	// BatchLookupAndDeleteAll, BatchUpdateAll and BatchDeleteAll.
+	// Defaults to 256.
+	ChunkSize int
 }
 
 // LogLevel controls the verbosity of the kernel's eBPF program verifier.
@@ -0,0 +1,231 @@
+package ebpf
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal/unix"
+)
+
+// VariableSpec is a global variable declared in a data section like .data,
+// .bss or .rodata.
+//
+// Unlike RewriteConstants, a VariableSpec knows the offset, size and type of
+// the variable from BTF, so values can't end up in the wrong place.
+type VariableSpec struct {
+	name    string
+	mapName string
+	offset  uint32
+	size    uint32
+
+	// The data section the variable lives in.
+	m *MapSpec
+	t *btf.Var
+}
+
+// Name returns the name of the variable.
+func (s *VariableSpec) Name() string {
+	return s.name
+}
+
+// MapName returns the name of the data section the variable lives in, as
+// found in CollectionSpec.Maps.
+func (s *VariableSpec) MapName() string {
+	return s.mapName
+}
+
+// Offset returns the offset of the variable in its data section.
+func (s *VariableSpec) Offset() uint32 {
+	return s.offset
+}
+
+// Size returns the size of the variable in bytes.
+func (s *VariableSpec) Size() uint32 {
+	return s.size
+}
+
+// Type returns the BTF type of the variable.
+func (s *VariableSpec) Type() *btf.Var {
+	return s.t
+}
+
+// Constant returns true if the variable is read-only after loading, which is
+// the case for variables in .rodata.
+func (s *VariableSpec) Constant() bool {
+	return s.m.Flags&unix.BPF_F_RDONLY_PROG != 0
+}
+
+// Set the initial value of the variable.
+//
+// in is marshalled according to the same rules as map values and must be
+// exactly Size bytes long.
+func (s *VariableSpec) Set(in interface{}) error {
+	buf, err := marshalBytes(in, int(s.size))
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", s.name, err)
+	}
+
+	// MapSpec.Copy() performs a shallow copy. Fully copy the contents to
+	// avoid any changes affecting other copies of the MapSpec.
+	data := make([]byte, s.m.ValueSize)
+	if len(s.m.Contents) > 0 {
+		old, ok := s.m.Contents[0].Value.([]byte)
+		if !ok {
+			return fmt.Errorf("variable %s: contents of %s are %T, not []byte", s.name, s.mapName, s.m.Contents[0].Value)
+		}
+		copy(data, old)
+	}
+
+	copy(data[s.offset:], buf)
+	s.m.Contents = []MapKV{{uint32(0), data}}
+	return nil
+}
+
+// Get the initial value of the variable.
+//
+// out is unmarshalled according to the same rules as map values.
+func (s *VariableSpec) Get(out interface{}) error {
+	// Sections without contents like .bss are zero.
+	data := make([]byte, s.size)
+	if len(s.m.Contents) > 0 {
+		value, ok := s.m.Contents[0].Value.([]byte)
+		if !ok {
+			return fmt.Errorf("variable %s: contents of %s are %T, not []byte", s.name, s.mapName, s.m.Contents[0].Value)
+		}
+		copy(data, value[s.offset:])
+	}
+
+	if err := unmarshalBytes(out, data); err != nil {
+		return fmt.Errorf("variable %s: %w", s.name, err)
+	}
+	return nil
+}
+
+func (s *VariableSpec) String() string {
+	return fmt.Sprintf("%s (%s+%d, %d bytes)", s.name, s.mapName, s.offset, s.size)
+}
+
+// copy returns a copy of s which refers to the data sections in maps.
+func (s *VariableSpec) copy(maps map[string]*MapSpec) *VariableSpec {
+	cpy := *s
+	cpy.m = maps[s.mapName]
+	return &cpy
+}
+
+// dataSectionVariables returns the variables declared in a data section.
+//
+// Variables which are declared multiple times, like static variables in
+// different functions, are ambiguous and therefore omitted.
+func dataSectionVariables(vars map[string]*VariableSpec, ambiguous map[string]bool, mapName string, m *MapSpec) error {
+	ds, ok := m.Value.(*btf.Datasec)
+	if !ok {
+		return nil
+	}
+
+	for _, vsi := range ds.Vars {
+		v, ok := vsi.Type.(*btf.Var)
+		if !ok {
+			return fmt.Errorf("data section %s: unexpected type %T", mapName, vsi.Type)
+		}
+
+		if vsi.Offset+vsi.Size > m.ValueSize {
+			return fmt.Errorf("data section %s: offset %d(+%d) for variable %s is out of bounds", mapName, vsi.Offset, vsi.Size, v.Name)
+		}
+
+		if ambiguous[v.Name] {
+			continue
+		}
+		if _, ok := vars[v.Name]; ok {
+			delete(vars, v.Name)
+			ambiguous[v.Name] = true
+			continue
+		}
+
+		vars[v.Name] = &VariableSpec{v.Name, mapName, vsi.Offset, vsi.Size, m, v}
+	}
+
+	return nil
+}
+
+// Variable is a global variable of a loaded Collection.
+//
+// Reads and writes go through the data section's array map. They are not
+// atomic with respect to concurrent modification by BPF programs.
+type Variable struct {
+	name   string
+	offset uint32
+	size   uint32
+	t      *btf.Var
+
+	m *Map
+}
+
+func newVariable(spec *VariableSpec, m *Map) *Variable {
+	return &Variable{spec.name, spec.offset, spec.size, spec.t, m}
+}
+
+// Name returns the name of the variable.
+func (v *Variable) Name() string {
+	return v.name
+}
+
+// Size returns the size of the variable in bytes.
+func (v *Variable) Size() uint32 {
+	return v.size
+}
+
+// Type returns the BTF type of the variable.
+func (v *Variable) Type() *btf.Var {
+	return v.t
+}
+
+// Constant returns true if the variable can't be modified, which is the case
+// for variables in .rodata.
+func (v *Variable) Constant() bool {
+	return v.m.Flags()&unix.BPF_F_RDONLY_PROG != 0
+}
+
+// Set the value of the variable.
+//
+// in is marshalled according to the same rules as map values and must be
+// exactly Size bytes long. Returns ErrReadOnly if the variable is Constant.
+func (v *Variable) Set(in interface{}) error {
+	if v.Constant() {
+		return fmt.Errorf("variable %s: %w", v.name, ErrReadOnly)
+	}
+
+	buf, err := marshalBytes(in, int(v.size))
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", v.name, err)
+	}
+
+	data, err := v.m.LookupBytes(uint32(0))
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", v.name, err)
+	}
+
+	copy(data[v.offset:], buf)
+	if err := v.m.Update(uint32(0), data, UpdateExist); err != nil {
+		return fmt.Errorf("variable %s: %w", v.name, err)
+	}
+	return nil
+}
+
+// Get the value of the variable.
+//
+// out is unmarshalled according to the same rules as map values.
+func (v *Variable) Get(out interface{}) error {
+	data, err := v.m.LookupBytes(uint32(0))
+	if err != nil {
+		return fmt.Errorf("variable %s: %w", v.name, err)
+	}
+
+	if err := unmarshalBytes(out, data[v.offset:v.offset+v.size]); err != nil {
+		return fmt.Errorf("variable %s: %w", v.name, err)
+	}
+	return nil
+}
+
+func (v *Variable) String() string {
+	return fmt.Sprintf("%s (%s+%d, %d bytes)", v.name, v.m.name, v.offset, v.size)
+}
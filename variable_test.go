@@ -0,0 +1,135 @@
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/internal"
+	"github.com/cilium/ebpf/internal/testutils"
+	"github.com/cilium/ebpf/internal/unix"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestVariableSpecFromELF(t *testing.T) {
+	spec, err := LoadCollectionSpec(fmt.Sprintf("testdata/loader-%s.elf", internal.ClangEndian))
+	qt.Assert(t, err, qt.IsNil)
+
+	if spec.Types == nil {
+		t.Skip("No BTF in ELF")
+	}
+
+	for name, section := range map[string]string{
+		"key1": ".bss",
+		"key2": ".data",
+		"key3": ".rodata",
+		"arg2": ".rodata.test",
+	} {
+		vs := spec.Variables[name]
+		qt.Assert(t, vs, qt.IsNotNil, qt.Commentf("variable %s", name))
+		qt.Assert(t, vs.MapName(), qt.Equals, section)
+		qt.Assert(t, vs.Size(), qt.Equals, uint32(4))
+		qt.Assert(t, vs.Type().Name, qt.Equals, name)
+		qt.Assert(t, vs.Constant(), qt.Equals, section != ".bss" && section != ".data")
+	}
+
+	var value uint32
+	qt.Assert(t, spec.Variables["key1"].Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(0))
+	qt.Assert(t, spec.Variables["key2"].Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(1))
+	qt.Assert(t, spec.Variables["key3"].Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(2))
+
+	// Setting a variable in a copy doesn't affect the original.
+	cpy := spec.Copy()
+	qt.Assert(t, cpy.Variables["key3"].Set(uint32(42)), qt.IsNil)
+	qt.Assert(t, cpy.Variables["key3"].Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(42))
+	qt.Assert(t, spec.Variables["key3"].Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(2))
+
+	// Sections without contents are populated on demand.
+	qt.Assert(t, cpy.Variables["key1"].Set(uint32(23)), qt.IsNil)
+	qt.Assert(t, cpy.Maps[".bss"].Contents, qt.HasLen, 1)
+	qt.Assert(t, spec.Maps[".bss"].Contents, qt.HasLen, 0)
+
+	qt.Assert(t, spec.Variables["key3"].Set(uint64(1)), qt.IsNotNil)
+}
+
+func TestVariable(t *testing.T) {
+	integer := &btf.Int{Size: 4}
+	newDataSection := func(name string, vars ...string) *MapSpec {
+		ds := &btf.Datasec{Name: name}
+		for i, v := range vars {
+			ds.Vars = append(ds.Vars, btf.VarSecinfo{
+				Type:   &btf.Var{Name: v, Type: integer},
+				Offset: uint32(i) * 4,
+				Size:   4,
+			})
+		}
+		ds.Size = uint32(len(vars)) * 4
+
+		return &MapSpec{
+			Name:       SanitizeName(name, -1),
+			Type:       Array,
+			KeySize:    4,
+			ValueSize:  uint32(len(vars)) * 4,
+			MaxEntries: 1,
+			Key:        &btf.Void{},
+			Value:      ds,
+		}
+	}
+
+	data := newDataSection(".data", "counter", "dup")
+	rodata := newDataSection(".rodata", "limit", "dup")
+	rodata.Flags = unix.BPF_F_RDONLY_PROG
+	rodata.Freeze = true
+
+	spec := &CollectionSpec{
+		Maps:      map[string]*MapSpec{".data": data, ".rodata": rodata},
+		Variables: make(map[string]*VariableSpec),
+	}
+	ambiguous := make(map[string]bool)
+	for name, m := range spec.Maps {
+		qt.Assert(t, dataSectionVariables(spec.Variables, ambiguous, name, m), qt.IsNil)
+	}
+
+	// Variables which occur in multiple sections are omitted.
+	qt.Assert(t, spec.Variables, qt.HasLen, 2)
+	qt.Assert(t, spec.Variables["dup"], qt.IsNil)
+
+	qt.Assert(t, spec.Variables["counter"].Set(uint32(1)), qt.IsNil)
+	qt.Assert(t, spec.Variables["limit"].Set(uint32(100)), qt.IsNil)
+
+	coll, err := NewCollection(spec)
+	testutils.SkipIfNotSupported(t, err)
+	qt.Assert(t, err, qt.IsNil)
+	defer coll.Close()
+
+	counter, limit := coll.Variables["counter"], coll.Variables["limit"]
+	qt.Assert(t, counter, qt.IsNotNil)
+	qt.Assert(t, limit, qt.IsNotNil)
+	qt.Assert(t, counter.Constant(), qt.IsFalse)
+	qt.Assert(t, limit.Constant(), qt.IsTrue)
+
+	var value uint32
+	qt.Assert(t, counter.Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(1))
+	qt.Assert(t, limit.Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(100))
+
+	qt.Assert(t, counter.Set(uint32(2)), qt.IsNil)
+	qt.Assert(t, counter.Get(&value), qt.IsNil)
+	qt.Assert(t, value, qt.Equals, uint32(2))
+
+	// The rest of the section is unaffected.
+	raw, err := coll.Maps[".data"].LookupBytes(uint32(0))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, raw[4:], qt.DeepEquals, []byte{0, 0, 0, 0})
+
+	err = limit.Set(uint32(1))
+	qt.Assert(t, errors.Is(err, ErrReadOnly), qt.IsTrue, qt.Commentf("got %v", err))
+}